@@ -5,74 +5,170 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rjxby/rss-sum/backend/metrics"
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/tracing"
 )
 
+var tracer = otel.Tracer("github.com/rjxby/rss-sum/backend/assistant")
+
 type Settings struct {
-	OllamaHost              string
-	OllamaPort              string
-	OllamaScheme            string
-	OllamaModel             string
-	RequestTimeoutInSeconds int
+	OllamaHost   string `yaml:"host"`
+	OllamaPort   string `yaml:"port"`
+	OllamaScheme string `yaml:"scheme"`
+	OllamaModel  string `yaml:"model"`
+	// OllamaEmbeddingModel falls back to OllamaModel when empty
+	OllamaEmbeddingModel    string  `yaml:"embedding_model"`
+	RequestTimeoutInSeconds int     `yaml:"timeout_in_seconds"`
+	Prompts                 Prompts `yaml:"prompts"`
+	// Profiles every summarized post is scored against, scoring is off without them
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// Profile describes the interests of readers in free text
+type Profile struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+// Prompts steer the generation, empty prompts fall back to the built-in ones
+type Prompts struct {
+	// System is the system prompt of summarization
+	System string `yaml:"system"`
+	// Summary instructs how to summarize, the text to summarize is appended to it
+	Summary string `yaml:"summary"`
+	// Answer is the system prompt of question answering
+	Answer string `yaml:"answer"`
 }
 
 // AssistantProc processes the text
 type AssistantProc struct {
 	settings Settings
 	client   *ollamaClient
+	// prompts and profiles are shared by the copies of the proc, so reloading updates all of them
+	prompts  *atomic.Pointer[Prompts]
+	profiles *atomic.Pointer[[]Profile]
 }
 
-func ParseSettings() (*Settings, error) {
-	settings := Settings{}
+// maxProfileNameLength fits the profile names into the column of the scores
+const maxProfileNameLength = 100
 
-	ollamaHost := os.Getenv("OLLAMA_HOST")
-	if ollamaHost == "" {
-		return nil, fmt.Errorf("OLLAMA_HOST environment variable is empty")
+// DefaultSettings returns the settings used for the values which are not configured
+func DefaultSettings() Settings {
+	return Settings{
+		OllamaScheme:            "http",
+		RequestTimeoutInSeconds: 30,
 	}
-	settings.OllamaHost = ollamaHost
+}
 
-	ollamaPort := os.Getenv("OLLAMA_PORT")
-	if ollamaPort == "" {
-		return nil, fmt.Errorf("OLLAMA_PORT environment variable is empty")
-	}
-	settings.OllamaPort = ollamaPort
+// Validate reports every invalid setting
+func (s Settings) Validate() error {
+	var errs []error
 
-	ollamaScheme := os.Getenv("OLLAMA_SCHEME")
-	if ollamaScheme == "" {
-		return nil, fmt.Errorf("OLLAMA_SCHEME environment variable is empty")
+	if s.OllamaHost == "" {
+		errs = append(errs, fmt.Errorf("host is empty"))
 	}
-	settings.OllamaScheme = ollamaScheme
-
-	ollamaModel := os.Getenv("OLLAMA_MODEL")
-	if ollamaModel == "" {
-		return nil, fmt.Errorf("OLLAMA_MODEL environment variable is empty")
+	if s.OllamaPort == "" {
+		errs = append(errs, fmt.Errorf("port is empty"))
+	} else if port, err := strconv.Atoi(s.OllamaPort); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port %q is not a valid port number", s.OllamaPort))
+	}
+	if s.OllamaScheme != "http" && s.OllamaScheme != "https" {
+		errs = append(errs, fmt.Errorf("scheme should be %q or %q", "http", "https"))
+	}
+	if s.OllamaModel == "" {
+		errs = append(errs, fmt.Errorf("model is empty"))
 	}
-	settings.OllamaModel = ollamaModel
+	if s.RequestTimeoutInSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("timeout_in_seconds should be positive"))
+	}
+
+	seen := make(map[string]bool)
+	for i, profile := range s.Profiles {
+		switch {
+		case strings.TrimSpace(profile.Name) == "":
+			errs = append(errs, fmt.Errorf("profile %d: name is empty", i+1))
+		case utf8.RuneCountInString(profile.Name) > maxProfileNameLength:
+			errs = append(errs, fmt.Errorf("profile %q: name is longer than %d characters", profile.Name, maxProfileNameLength))
+		case seen[profile.Name]:
+			errs = append(errs, fmt.Errorf("profile %q is defined more than once", profile.Name))
+		}
+		seen[profile.Name] = true
 
-	settings.RequestTimeoutInSeconds = 30
-	if timeoutStr := os.Getenv("OLLAMA_TIMEOUT_IN_SECONDS"); timeoutStr != "" {
-		if timeout, err := strconv.Atoi(timeoutStr); err == nil {
-			settings.RequestTimeoutInSeconds = timeout
+		if strings.TrimSpace(profile.Description) == "" {
+			errs = append(errs, fmt.Errorf("profile %q: description is empty", profile.Name))
 		}
 	}
 
-	return &settings, nil
+	return errors.Join(errs...)
 }
 
 func New(settings *Settings) *AssistantProc {
 	client := newOlamaClient(settings)
 
-	return &AssistantProc{
+	proc := &AssistantProc{
 		settings: *settings,
 		client:   client,
+		prompts:  &atomic.Pointer[Prompts]{},
+		profiles: &atomic.Pointer[[]Profile]{},
+	}
+	if proc.settings.OllamaEmbeddingModel == "" {
+		proc.settings.OllamaEmbeddingModel = proc.settings.OllamaModel
+	}
+	proc.UpdatePrompts(settings.Prompts)
+	proc.UpdateProfiles(settings.Profiles)
+
+	return proc
+}
+
+// UpdatePrompts replaces the prompts of the following generations
+func (p AssistantProc) UpdatePrompts(prompts Prompts) {
+	if prompts.System == "" {
+		prompts.System = textSystemPrompt
+	}
+	if prompts.Summary == "" {
+		prompts.Summary = summaryInstructions
+	}
+	if prompts.Answer == "" {
+		prompts.Answer = askSystemPrompt
+	}
+
+	p.prompts.Store(&prompts)
+}
+
+// UpdateProfiles replaces the interest profiles of the following scorings
+func (p AssistantProc) UpdateProfiles(profiles []Profile) {
+	profiles = slices.Clone(profiles)
+	p.profiles.Store(&profiles)
+}
+
+// ProfileNames returns the names of the interest profiles in their configured order
+func (p AssistantProc) ProfileNames() []string {
+	profiles := *p.profiles.Load()
+
+	names := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		names = append(names, profile.Name)
 	}
+	return names
 }
 
 type ollamaClient struct {
@@ -80,15 +176,18 @@ type ollamaClient struct {
 	http    *http.Client
 }
 
+// newOlamaClient only bounds the wait for Ollama to start responding, a streamed generation lasts as long
+// as the context of its caller
 func newOlamaClient(settings *Settings) *ollamaClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = time.Duration(settings.RequestTimeoutInSeconds) * time.Second
+
 	return &ollamaClient{
 		baseURL: &url.URL{
 			Scheme: settings.OllamaScheme,
 			Host:   net.JoinHostPort(settings.OllamaHost, settings.OllamaPort),
 		},
-		http: &http.Client{
-			Timeout: time.Duration(settings.RequestTimeoutInSeconds) * time.Second,
-		},
+		http: &http.Client{Transport: transport},
 	}
 }
 
@@ -96,36 +195,155 @@ type ollamaRequest struct {
 	Model  string `json:"model"`
 	Prompt string `json:"prompt"`
 	System string `json:"system"`
+	// Format constrains the response, "json" for a JSON value
+	Format string `json:"format,omitempty"`
 }
 
 type ollamaResponse struct {
 	Response string `json:"response"`
+	Done     bool   `json:"done"`
+
+	// token counts are reported with the final part of the response
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+type ollamaVersionResponse struct {
+	Version string `json:"version"`
 }
 
 type ollamaResponseFunc func(ollamaResponse) error
 
-func (p AssistantProc) doText(prompt string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(),
+type ollamaEmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+type ollamaEmbeddingResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// generation tasks, used to label metrics
+const (
+	taskSummarize = "summarize"
+	taskAnswer    = "answer"
+	taskScore     = "score"
+)
+
+const (
+	textSystemPrompt      = "Act like assistant that returns only result text. Result text should not contain any text formatting, sections or web links."
+	askSystemPrompt       = "Act like research assistant that answers questions using only the provided sources. Cite the sources you rely on with their numbers in square brackets, like [1]. If the sources do not contain the answer, say so."
+	relevanceSystemPrompt = "Act like editor who rates how relevant a text is to the interests of readers. Respond only with a JSON object which maps the name of every interest profile to the relevance of the text to it, an integer from 0 (not relevant) to 100 (highly relevant)."
+)
+
+// summaryInstructions is the default summary prompt, the text to summarize follows it
+const summaryInstructions = `Summarize the following text with the following guidelines:
+ - Limit the summary to around 500 characters
+ - Capture the core message and most important points
+ - Write it as a brief, engaging narrative
+ - Preserve the tone of the original
+ - Ensure the summary is coherent and self-contained
+ - Do not include any explanation, formatting, or introduction—just return the summary text
+
+-------------------------------------------------------------
+Example:
+
+Walgreens is collapsing, closing thousands of stores—not due to mismanagement or Amazon—but because of monopoly power from Pharmacy Benefit Managers (PBMs). PBMs (like CVS Caremark, Express Scripts, and OptumRx) control 80 per cent of drug pricing and insurance reimbursements. With unfair pricing, CVS profits while competitors like Walgreens and independents are squeezed out, worsening access and creating pharmacy deserts across the U.S.
+--------------------------------------------------------------`
+
+func (p AssistantProc) doText(ctx context.Context, prompt string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx,
 		time.Duration(p.settings.RequestTimeoutInSeconds)*time.Second)
 	defer cancel()
 
-	req := &ollamaRequest{
+	var result string
+	tokenFunc := func(token string) error {
+		result += token
+		return nil
+	}
+
+	if err := p.streamText(ctx, taskSummarize, p.prompts.Load().System, prompt, tokenFunc); err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+// streamText passes every generated token to fn as soon as Ollama produces it
+func (p AssistantProc) streamText(ctx context.Context, task, system, prompt string, fn func(token string) error) error {
+	return p.generate(ctx, task, &ollamaRequest{
 		Model:  p.settings.OllamaModel,
-		System: "Act like assistant that returns only result text. Result text should not contain any text formatting, sections or web links.",
+		System: system,
 		Prompt: prompt,
-	}
+	}, fn)
+}
+
+// generate streams the response to the request token by token, recording the task in metrics and traces
+func (p AssistantProc) generate(ctx context.Context, task string, req *ollamaRequest, fn func(token string) error) error {
+	ctx, span := tracer.Start(ctx, "assistant."+task, trace.WithAttributes(
+		attribute.String("llm.model", req.Model),
+		attribute.Int("llm.prompt_length", len(req.Prompt)),
+	))
+	defer span.End()
 
-	var result string
 	respFunc := func(model ollamaResponse) error {
-		result += model.Response
-		return nil
+		if model.Done {
+			metrics.GenerationTokensTotal.WithLabelValues(req.Model, task, "prompt").Add(float64(model.PromptEvalCount))
+			metrics.GenerationTokensTotal.WithLabelValues(req.Model, task, "completion").Add(float64(model.EvalCount))
+			span.SetAttributes(
+				attribute.Int("llm.tokens.prompt", model.PromptEvalCount),
+				attribute.Int("llm.tokens.completion", model.EvalCount),
+			)
+		}
+		return fn(model.Response)
 	}
 
+	started := time.Now()
 	if err := p.client.streamData(ctx, http.MethodPost, "/api/generate", req, respFunc); err != nil {
-		return "", fmt.Errorf("failed to stream Ollama response: %v", err)
+		metrics.GenerationDuration.WithLabelValues(req.Model, task, metrics.OutcomeError).Observe(time.Since(started).Seconds())
+		return tracing.Error(span, fmt.Errorf("failed to stream Ollama response: %v", err))
 	}
+	metrics.GenerationDuration.WithLabelValues(req.Model, task, metrics.OutcomeSuccess).Observe(time.Since(started).Seconds())
 
-	return result, nil
+	return nil
+}
+
+func (c *ollamaClient) do(ctx context.Context, method, path string, data any, result any) error {
+	var requestBody []byte
+	if data != nil {
+		var err error
+		requestBody, err = json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request data: %v", err)
+		}
+	}
+
+	requestURL := c.baseURL.JoinPath(path)
+	req, err := http.NewRequestWithContext(ctx, method, requestURL.String(), bytes.NewReader(requestBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to perform request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama API returned non-200 status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	return nil
 }
 
 func (c *ollamaClient) streamData(ctx context.Context, method, path string, data *ollamaRequest, fn ollamaResponseFunc) error {
@@ -151,7 +369,7 @@ func (c *ollamaClient) streamData(ctx context.Context, method, path string, data
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("[WARN] failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
 	}()
 
@@ -170,33 +388,185 @@ func (c *ollamaClient) streamData(ctx context.Context, method, path string, data
 			return fmt.Errorf("failed to aggregate result: %v", err)
 		}
 	}
-	if s.Err() != nil {
+	if err := s.Err(); err != nil {
 		return fmt.Errorf("failed to scan response: %v", err)
 	}
 
 	return nil
 }
 
-func (p AssistantProc) SummarizeText(text string) (string, error) {
-	prompt := fmt.Sprintf(`Summarize the following text with the following guidelines:
- - Limit the summary to around 500 characters
- - Capture the core message and most important points
- - Write it as a brief, engaging narrative
- - Preserve the tone of the original
- - Ensure the summary is coherent and self-contained
- - Do not include any explanation, formatting, or introduction—just return the summary text
+func summaryPrompt(instructions, text string) string {
+	return fmt.Sprintf("%s\n\nThe text to summarize is: '%s'", instructions, text)
+}
 
--------------------------------------------------------------
-Example:
+func (p AssistantProc) SummarizeText(ctx context.Context, text string) (string, error) {
+	result, err := p.doText(ctx, summaryPrompt(p.prompts.Load().Summary, text))
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize text: %v", err)
+	}
+	return result, nil
+}
 
-Walgreens is collapsing, closing thousands of stores—not due to mismanagement or Amazon—but because of monopoly power from Pharmacy Benefit Managers (PBMs). PBMs (like CVS Caremark, Express Scripts, and OptumRx) control 80 per cent of drug pricing and insurance reimbursements. With unfair pricing, CVS profits while competitors like Walgreens and independents are squeezed out, worsening access and creating pharmacy deserts across the U.S.
---------------------------------------------------------------
+// SummarizeTextStream passes the summary to fn token by token as it is generated,
+// it lasts until the summary is complete or ctx is done
+func (p AssistantProc) SummarizeTextStream(ctx context.Context, text string, fn func(token string) error) error {
+	prompts := p.prompts.Load()
+	if err := p.streamText(ctx, taskSummarize, prompts.System, summaryPrompt(prompts.Summary, text), fn); err != nil {
+		return fmt.Errorf("failed to summarize text: %v", err)
+	}
+
+	return nil
+}
+
+// AnswerQuestion streams an answer grounded on the given posts, citing them by their position,
+// it lasts until the answer is complete or ctx is done
+func (p AssistantProc) AnswerQuestion(ctx context.Context, question string, sources []*store.PostV1, fn func(token string) error) error {
+	var sb strings.Builder
+	sb.WriteString("Sources:\n\n")
+	for i, source := range sources {
+		fmt.Fprintf(&sb, "[%d] %s (%s, %s)\n%s\n\n",
+			i+1, source.Title, source.SourceURL, source.CreatedAt.Format(time.DateOnly), source.Text)
+	}
+	fmt.Fprintf(&sb, "Question: %s", question)
+
+	if err := p.streamText(ctx, taskAnswer, p.prompts.Load().Answer, sb.String(), fn); err != nil {
+		return fmt.Errorf("failed to answer question: %v", err)
+	}
 
-The text to summarize is: '%s'`, text)
+	return nil
+}
+
+// ScoreRelevance rates the relevance of the text to every interest profile from 0 to 100, by profile name.
+// Nothing is generated without profiles
+func (p AssistantProc) ScoreRelevance(ctx context.Context, text string) (map[string]int, error) {
+	profiles := *p.profiles.Load()
+	if len(profiles) == 0 {
+		return map[string]int{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx,
+		time.Duration(p.settings.RequestTimeoutInSeconds)*time.Second)
+	defer cancel()
+
+	var response strings.Builder
+	tokenFunc := func(token string) error {
+		response.WriteString(token)
+		return nil
+	}
 
-	result, err := p.doText(prompt)
+	req := &ollamaRequest{
+		Model:  p.settings.OllamaModel,
+		System: relevanceSystemPrompt,
+		Prompt: relevancePrompt(profiles, text),
+		Format: "json",
+	}
+	if err := p.generate(ctx, taskScore, req, tokenFunc); err != nil {
+		return nil, fmt.Errorf("failed to score relevance: %v", err)
+	}
+
+	scores, err := parseRelevance(response.String(), profiles)
 	if err != nil {
-		return "", fmt.Errorf("failed to summarize text: %v", err)
+		return nil, fmt.Errorf("failed to score relevance: %v", err)
 	}
-	return result, nil
+
+	return scores, nil
+}
+
+// relevancePrompt lists the profiles followed by the text to score
+func relevancePrompt(profiles []Profile, text string) string {
+	var sb strings.Builder
+	sb.WriteString("Interest profiles:\n\n")
+	for _, profile := range profiles {
+		fmt.Fprintf(&sb, "%q: %s\n", profile.Name, profile.Description)
+	}
+	fmt.Fprintf(&sb, "\nThe text to score is: '%s'", text)
+
+	return sb.String()
+}
+
+// parseRelevance reads the score of every profile from the generated JSON object, models may change the case
+// of the names or quote the numbers. Scores are rounded and clamped to 0 to 100
+func parseRelevance(response string, profiles []Profile) (map[string]int, error) {
+	var generated map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(response), &generated); err != nil {
+		return nil, fmt.Errorf("response is not a JSON object: %v", err)
+	}
+
+	scores := make(map[string]int, len(profiles))
+	for _, profile := range profiles {
+		raw, ok := generated[profile.Name]
+		for name, value := range generated {
+			if !ok && strings.EqualFold(name, profile.Name) {
+				raw, ok = value, true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("response has no score of profile %q", profile.Name)
+		}
+
+		var number json.Number
+		if err := json.Unmarshal(raw, &number); err != nil {
+			return nil, fmt.Errorf("score of profile %q is not a number: %s", profile.Name, raw)
+		}
+		value, err := number.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("score of profile %q is not a number: %s", profile.Name, raw)
+		}
+
+		scores[profile.Name] = min(max(int(math.Round(value)), 0), store.MaxScore)
+	}
+
+	return scores, nil
+}
+
+// Model returns the name of the model used to generate text
+func (p AssistantProc) Model() string {
+	return p.settings.OllamaModel
+}
+
+// Ping checks that the Ollama API is reachable
+func (p AssistantProc) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx,
+		time.Duration(p.settings.RequestTimeoutInSeconds)*time.Second)
+	defer cancel()
+
+	var resp ollamaVersionResponse
+	if err := p.client.do(ctx, http.MethodGet, "/api/version", nil, &resp); err != nil {
+		return fmt.Errorf("failed to reach Ollama: %v", err)
+	}
+
+	return nil
+}
+
+// EmbeddingModel returns the name of the model used to build embeddings
+func (p AssistantProc) EmbeddingModel() string {
+	return p.settings.OllamaEmbeddingModel
+}
+
+// EmbedText builds a vector representation of the text for semantic search
+func (p AssistantProc) EmbedText(ctx context.Context, text string) ([]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx,
+		time.Duration(p.settings.RequestTimeoutInSeconds)*time.Second)
+	defer cancel()
+
+	req := &ollamaEmbeddingRequest{
+		Model:  p.settings.OllamaEmbeddingModel,
+		Prompt: text,
+	}
+
+	ctx, span := tracer.Start(ctx, "assistant.embed", trace.WithAttributes(
+		attribute.String("llm.model", req.Model),
+	))
+	defer span.End()
+
+	var resp ollamaEmbeddingResponse
+	if err := p.client.do(ctx, http.MethodPost, "/api/embeddings", req, &resp); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to embed text: %v", err))
+	}
+
+	if len(resp.Embedding) == 0 {
+		return nil, tracing.Error(span, fmt.Errorf("failed to embed text: empty embedding returned"))
+	}
+
+	return resp.Embedding, nil
 }
@@ -1,81 +1,131 @@
 package assistant
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rjxby/rss-sum/backend/metrics"
+	"github.com/rjxby/rss-sum/backend/store"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
-func TestParseSettings(t *testing.T) {
-	t.Run("ValidSettings", func(t *testing.T) {
-		t.Setenv("OLLAMA_HOST", "localhost")
-		t.Setenv("OLLAMA_PORT", "11434")
-		t.Setenv("OLLAMA_SCHEME", "http")
-		t.Setenv("OLLAMA_MODEL", "llama3:8b")
-
-		settings, err := ParseSettings()
-
-		assert.NoError(t, err)
-		assert.Equal(t, "localhost", settings.OllamaHost)
-		assert.Equal(t, "11434", settings.OllamaPort)
-		assert.Equal(t, "http", settings.OllamaScheme)
-		assert.Equal(t, "llama3:8b", settings.OllamaModel)
-		assert.Equal(t, 30, settings.RequestTimeoutInSeconds) // Default value
-	})
-
-	t.Run("CustomTimeout", func(t *testing.T) {
-		t.Setenv("OLLAMA_HOST", "localhost")
-		t.Setenv("OLLAMA_PORT", "11434")
-		t.Setenv("OLLAMA_SCHEME", "http")
-		t.Setenv("OLLAMA_MODEL", "llama3:8b")
-		t.Setenv("OLLAMA_TIMEOUT_IN_SECONDS", "60")
-
-		settings, err := ParseSettings()
-
-		assert.NoError(t, err)
-		assert.Equal(t, 60, settings.RequestTimeoutInSeconds)
-	})
+func TestValidate(t *testing.T) {
+	valid := Settings{
+		OllamaHost:              "localhost",
+		OllamaPort:              "11434",
+		OllamaScheme:            "http",
+		OllamaModel:             "llama3:8b",
+		RequestTimeoutInSeconds: 30,
+	}
 
 	tbl := []struct {
 		name        string
-		envVar      string
-		envValue    string
-		expectError bool
+		modify      func(s *Settings)
+		expectError string
 	}{
-		{"MissingHost", "OLLAMA_HOST", "", true},
-		{"MissingPort", "OLLAMA_PORT", "", true},
-		{"MissingScheme", "OLLAMA_SCHEME", "", true},
-		{"MissingModel", "OLLAMA_MODEL", "", true},
+		{"Valid", func(s *Settings) {}, ""},
+		{"MissingHost", func(s *Settings) { s.OllamaHost = "" }, "host is empty"},
+		{"MissingPort", func(s *Settings) { s.OllamaPort = "" }, "port is empty"},
+		{"InvalidPort", func(s *Settings) { s.OllamaPort = "ollama" }, `port "ollama" is not a valid port number`},
+		{"InvalidScheme", func(s *Settings) { s.OllamaScheme = "ftp" }, "scheme should be"},
+		{"MissingModel", func(s *Settings) { s.OllamaModel = "" }, "model is empty"},
+		{"InvalidTimeout", func(s *Settings) { s.RequestTimeoutInSeconds = 0 }, "timeout_in_seconds should be positive"},
+		{"Profiles", func(s *Settings) { s.Profiles = []Profile{{Name: "go", Description: "Go releases"}} }, ""},
+		{"ProfileWithoutName", func(s *Settings) { s.Profiles = []Profile{{Description: "Go releases"}} }, "profile 1: name is empty"},
+		{"ProfileWithoutDescription", func(s *Settings) { s.Profiles = []Profile{{Name: "go"}} }, `profile "go": description is empty`},
+		{"DuplicateProfile", func(s *Settings) {
+			s.Profiles = []Profile{{Name: "go", Description: "Go releases"}, {Name: "go", Description: "Go tooling"}}
+		}, `profile "go" is defined more than once`},
+		{"LongProfileName", func(s *Settings) {
+			s.Profiles = []Profile{{Name: strings.Repeat("a", 101), Description: "Anything"}}
+		}, "name is longer than 100 characters"},
 	}
 
 	for _, tt := range tbl {
 		t.Run(tt.name, func(t *testing.T) {
-			// Set all required variables first
-			t.Setenv("OLLAMA_HOST", "localhost")
-			t.Setenv("OLLAMA_PORT", "11434")
-			t.Setenv("OLLAMA_SCHEME", "http")
-			t.Setenv("OLLAMA_MODEL", "llama3:8b")
-
-			// Then override with the test case
-			t.Setenv(tt.envVar, tt.envValue)
+			settings := valid
+			tt.modify(&settings)
 
-			settings, err := ParseSettings()
+			err := settings.Validate()
 
-			if tt.expectError {
-				assert.Error(t, err)
-				assert.Nil(t, settings)
-			} else {
+			if tt.expectError == "" {
 				assert.NoError(t, err)
-				assert.NotNil(t, settings)
+			} else {
+				assert.ErrorContains(t, err, tt.expectError)
 			}
 		})
 	}
 }
 
+func TestNew(t *testing.T) {
+	t.Run("EmbeddingModelDefaultsToModel", func(t *testing.T) {
+		assistant := New(&Settings{OllamaModel: "llama3:8b"})
+
+		assert.Equal(t, "llama3:8b", assistant.EmbeddingModel())
+	})
+
+	t.Run("DefaultPrompts", func(t *testing.T) {
+		assistant := New(&Settings{Prompts: Prompts{Answer: "Answer briefly."}})
+
+		assert.Equal(t, &Prompts{System: textSystemPrompt, Summary: summaryInstructions, Answer: "Answer briefly."}, assistant.prompts.Load())
+	})
+}
+
+func TestUpdatePrompts(t *testing.T) {
+	// Setup
+	var requests []ollamaRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+
+		respJSON, _ := json.Marshal(ollamaResponse{Response: "Summary", Done: true})
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(respJSON); err != nil {
+			t.Fatalf("Failed to write response: %v", err)
+		}
+	}))
+	defer ts.Close()
+
+	assistant := New(&Settings{OllamaModel: "llama3:8b", RequestTimeoutInSeconds: 5})
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server URL: %v", err)
+	}
+	assistant.client.baseURL = serverURL
+	assistant.client.http = ts.Client()
+
+	// a copy of the proc shares the prompts, like the server and the worker do
+	shared := *assistant
+
+	// Execute
+	_, err = shared.SummarizeText(context.Background(), "Test input text")
+	assert.NoError(t, err)
+
+	assistant.UpdatePrompts(Prompts{System: "Be terse.", Summary: "Summarize in one sentence."})
+
+	_, err = shared.SummarizeText(context.Background(), "Test input text")
+	assert.NoError(t, err)
+
+	// Verify
+	assert.Len(t, requests, 2)
+	assert.Equal(t, textSystemPrompt, requests[0].System)
+	assert.Equal(t, summaryPrompt(summaryInstructions, "Test input text"), requests[0].Prompt)
+	assert.Equal(t, "Be terse.", requests[1].System)
+	assert.Equal(t, "Summarize in one sentence.\n\nThe text to summarize is: 'Test input text'", requests[1].Prompt)
+}
+
 func TestSummarizeText(t *testing.T) {
 	// Create a mock server that returns a successful response
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -114,7 +164,7 @@ func TestSummarizeText(t *testing.T) {
 	assistant.client.http = ts.Client()
 
 	// Test summarization
-	summary, err := assistant.SummarizeText("Test input text")
+	summary, err := assistant.SummarizeText(context.Background(), "Test input text")
 
 	assert.NoError(t, err)
 	assert.Equal(t, "This is a test summary.", summary)
@@ -148,9 +198,406 @@ func TestSummarizeTextError(t *testing.T) {
 	assistant.client.http = ts.Client()
 
 	// Test summarization with error
-	summary, err := assistant.SummarizeText("Test input text")
+	summary, err := assistant.SummarizeText(context.Background(), "Test input text")
 
 	assert.Error(t, err)
 	assert.Empty(t, summary)
 	assert.Contains(t, err.Error(), "non-200 status code")
 }
+
+func TestEmbedText(t *testing.T) {
+	// Create a mock server that returns an embedding
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Verify the request
+		assert.Equal(t, "/api/embeddings", r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+
+		var req ollamaEmbeddingRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "nomic-embed-text", req.Model)
+		assert.Equal(t, "Test input text", req.Prompt)
+
+		// Return a response
+		resp := ollamaEmbeddingResponse{Embedding: []float64{0.1, 0.2, 0.3}}
+		respJSON, _ := json.Marshal(resp)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(respJSON); err != nil {
+			t.Fatalf("Failed to write response: %v", err)
+		}
+	}))
+	defer ts.Close()
+
+	// Create assistant with test server URL
+	settings := &Settings{
+		OllamaHost:              "localhost",
+		OllamaPort:              "8080",
+		OllamaScheme:            "http",
+		OllamaModel:             "llama3:8b",
+		OllamaEmbeddingModel:    "nomic-embed-text",
+		RequestTimeoutInSeconds: 5,
+	}
+
+	// Override client to use test server
+	assistant := New(settings)
+
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server URL: %v", err)
+	}
+
+	assistant.client.baseURL = serverURL
+	assistant.client.http = ts.Client()
+
+	// Test embedding
+	vector, err := assistant.EmbedText(context.Background(), "Test input text")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.1, 0.2, 0.3}, vector)
+	assert.Equal(t, "nomic-embed-text", assistant.EmbeddingModel())
+}
+
+func TestAnswerQuestion(t *testing.T) {
+	// Create a mock server that streams the answer in several chunks
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Verify the request
+		assert.Equal(t, "/api/generate", r.URL.Path)
+
+		var req ollamaRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, askSystemPrompt, req.System)
+		assert.Contains(t, req.Prompt, "[1] Go 1.25 (http://example.com/1, 2025-08-12)")
+		assert.Contains(t, req.Prompt, "Question: what changed in Go?")
+
+		// Return a response
+		w.WriteHeader(http.StatusOK)
+		for _, chunk := range []string{"Range ", "over ", "func [1]"} {
+			respJSON, _ := json.Marshal(ollamaResponse{Response: chunk})
+			if _, err := w.Write(append(respJSON, '\n')); err != nil {
+				t.Fatalf("Failed to write response: %v", err)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	// Create assistant with test server URL
+	settings := &Settings{
+		OllamaHost:              "localhost",
+		OllamaPort:              "8080",
+		OllamaScheme:            "http",
+		OllamaModel:             "llama3:8b",
+		RequestTimeoutInSeconds: 5,
+	}
+
+	// Override client to use test server
+	assistant := New(settings)
+
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server URL: %v", err)
+	}
+
+	assistant.client.baseURL = serverURL
+	assistant.client.http = ts.Client()
+
+	sources := []*store.PostV1{
+		{ID: "1", Title: "Go 1.25", SourceURL: "http://example.com/1", Text: "Release notes", CreatedAt: time.Date(2025, 8, 12, 0, 0, 0, 0, time.UTC)},
+	}
+
+	// Test answering
+	var tokens []string
+	err = assistant.AnswerQuestion(context.Background(), "what changed in Go?", sources, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Range ", "over ", "func [1]"}, tokens)
+}
+
+func TestAnswerQuestionOutlastsTimeout(t *testing.T) {
+	// Create a mock server that keeps streaming past the request timeout
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		for i, chunk := range []string{"slow ", "answer"} {
+			if i > 0 {
+				time.Sleep(1500 * time.Millisecond)
+			}
+			respJSON, _ := json.Marshal(ollamaResponse{Response: chunk})
+			if _, err := w.Write(append(respJSON, '\n')); err != nil {
+				t.Errorf("Failed to write response: %v", err)
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server URL: %v", err)
+	}
+
+	// Keep the client of the assistant, only its response headers are bounded by the timeout
+	assistant := New(&Settings{OllamaModel: "llama3:8b", RequestTimeoutInSeconds: 1})
+	assistant.client.baseURL = serverURL
+
+	var tokens []string
+	err = assistant.AnswerQuestion(context.Background(), "slow?", nil, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"slow ", "answer"}, tokens)
+}
+
+func TestScoreRelevance(t *testing.T) {
+	// Setup
+	var requests []ollamaRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+
+		w.WriteHeader(http.StatusOK)
+		for _, chunk := range []string{`{"go": 87.6, `, `"Rust": "20"}`} {
+			respJSON, _ := json.Marshal(ollamaResponse{Response: chunk})
+			if _, err := w.Write(append(respJSON, '\n')); err != nil {
+				t.Fatalf("Failed to write response: %v", err)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	assistant := New(&Settings{OllamaModel: "llama3:8b", RequestTimeoutInSeconds: 5})
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server URL: %v", err)
+	}
+	assistant.client.baseURL = serverURL
+	assistant.client.http = ts.Client()
+
+	// Execute
+	withoutProfiles, err := assistant.ScoreRelevance(context.Background(), "Go 1.24 is released")
+	assert.NoError(t, err)
+
+	assistant.UpdateProfiles([]Profile{{Name: "go", Description: "Go releases"}, {Name: "rust", Description: "Rust tooling"}})
+	scores, err := assistant.ScoreRelevance(context.Background(), "Go 1.24 is released")
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Empty(t, withoutProfiles)
+	assert.Equal(t, map[string]int{"go": 88, "rust": 20}, scores)
+	assert.Equal(t, []string{"go", "rust"}, assistant.ProfileNames())
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "json", requests[0].Format)
+	assert.Equal(t, relevanceSystemPrompt, requests[0].System)
+	assert.Equal(t, "Interest profiles:\n\n\"go\": Go releases\n\"rust\": Rust tooling\n\nThe text to score is: 'Go 1.24 is released'", requests[0].Prompt)
+}
+
+func TestParseRelevance(t *testing.T) {
+	profiles := []Profile{{Name: "go", Description: "Go releases"}, {Name: "rust", Description: "Rust tooling"}}
+
+	tbl := []struct {
+		name        string
+		response    string
+		expected    map[string]int
+		expectError string
+	}{
+		{"Scores", `{"go": 90, "rust": 10}`, map[string]int{"go": 90, "rust": 10}, ""},
+		{"Clamped", `{"go": 120, "rust": -5}`, map[string]int{"go": 100, "rust": 0}, ""},
+		{"ExtraProfile", `{"go": 90, "rust": 10, "java": {"score": 1}}`, map[string]int{"go": 90, "rust": 10}, ""},
+		{"MissingProfile", `{"go": 90}`, nil, `response has no score of profile "rust"`},
+		{"NotANumber", `{"go": 90, "rust": "high"}`, nil, `score of profile "rust" is not a number: "high"`},
+		{"NotAnObject", `90`, nil, "response is not a JSON object"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			scores, err := parseRelevance(tt.response, profiles)
+
+			if tt.expectError == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, scores)
+			} else {
+				assert.ErrorContains(t, err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestSummarizeTextStream(t *testing.T) {
+	// Create a mock server that streams the summary in several chunks
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		for _, chunk := range []string{"This is ", "a test summary."} {
+			respJSON, _ := json.Marshal(ollamaResponse{Response: chunk})
+			if _, err := w.Write(append(respJSON, '\n')); err != nil {
+				t.Fatalf("Failed to write response: %v", err)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	// Create assistant with test server URL
+	settings := &Settings{
+		OllamaHost:              "localhost",
+		OllamaPort:              "8080",
+		OllamaScheme:            "http",
+		OllamaModel:             "llama3:8b",
+		RequestTimeoutInSeconds: 5,
+	}
+
+	// Override client to use test server
+	assistant := New(settings)
+
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server URL: %v", err)
+	}
+
+	assistant.client.baseURL = serverURL
+	assistant.client.http = ts.Client()
+
+	// Test streaming summarization
+	var tokens []string
+	err = assistant.SummarizeTextStream(context.Background(), "Test input text", func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"This is ", "a test summary."}, tokens)
+}
+
+func TestStreamTextMetrics(t *testing.T) {
+	// Create a mock server that reports token counts with the final chunk
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		for _, part := range []ollamaResponse{
+			{Response: "Summary"},
+			{Done: true, PromptEvalCount: 42, EvalCount: 7},
+		} {
+			respJSON, _ := json.Marshal(part)
+			if _, err := w.Write(append(respJSON, '\n')); err != nil {
+				t.Fatalf("Failed to write response: %v", err)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	settings := &Settings{
+		OllamaModel:             "metrics-model",
+		RequestTimeoutInSeconds: 5,
+	}
+	assistant := New(settings)
+
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server URL: %v", err)
+	}
+
+	assistant.client.baseURL = serverURL
+	assistant.client.http = ts.Client()
+
+	// Execute
+	result, err := assistant.SummarizeText(context.Background(), "Test input text")
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, "Summary", result)
+	assert.Equal(t, 42.0, testutil.ToFloat64(metrics.GenerationTokensTotal.WithLabelValues("metrics-model", taskSummarize, "prompt")))
+	assert.Equal(t, 7.0, testutil.ToFloat64(metrics.GenerationTokensTotal.WithLabelValues("metrics-model", taskSummarize, "completion")))
+}
+
+func TestStreamTextTracing(t *testing.T) {
+	// Setup
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		respJSON, _ := json.Marshal(ollamaResponse{Response: "Summary", Done: true, PromptEvalCount: 42, EvalCount: 7})
+		if _, err := w.Write(append(respJSON, '\n')); err != nil {
+			t.Fatalf("Failed to write response: %v", err)
+		}
+	}))
+	defer ts.Close()
+
+	assistant := New(&Settings{
+		OllamaModel:             "tracing-model",
+		RequestTimeoutInSeconds: 5,
+	})
+
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server URL: %v", err)
+	}
+
+	assistant.client.baseURL = serverURL
+	assistant.client.http = ts.Client()
+
+	// Execute
+	_, err = assistant.SummarizeText(context.Background(), "Test input text")
+
+	// Verify
+	assert.NoError(t, err)
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "assistant.summarize", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("llm.model", "tracing-model"))
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("llm.tokens.prompt", 42))
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("llm.tokens.completion", 7))
+}
+
+func TestPing(t *testing.T) {
+	t.Run("Reachable", func(t *testing.T) {
+		// Setup
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/version", r.URL.Path)
+			assert.Equal(t, http.MethodGet, r.Method)
+
+			w.WriteHeader(http.StatusOK)
+			if _, err := w.Write([]byte(`{"version":"0.6.5"}`)); err != nil {
+				t.Fatalf("Failed to write response: %v", err)
+			}
+		}))
+		defer ts.Close()
+
+		assistant := New(&Settings{RequestTimeoutInSeconds: 5})
+		serverURL, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatalf("Failed to parse test server URL: %v", err)
+		}
+		assistant.client.baseURL = serverURL
+		assistant.client.http = ts.Client()
+
+		// Execute
+		err = assistant.Ping(context.Background())
+
+		// Verify
+		assert.NoError(t, err)
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		// Setup
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		assistant := New(&Settings{RequestTimeoutInSeconds: 5})
+		serverURL, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatalf("Failed to parse test server URL: %v", err)
+		}
+		assistant.client.baseURL = serverURL
+		assistant.client.http = ts.Client()
+
+		// Execute
+		err = assistant.Ping(context.Background())
+
+		// Verify
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,414 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything after 72 bytes
+	maxPasswordLength = 72
+	maxNameLength     = 100
+
+	// TokenPrefix starts every API token, so leaked tokens are easy to recognize
+	TokenPrefix = "rss_"
+
+	// lastUsedResolution limits the writes of busy API tokens
+	lastUsedResolution = time.Minute
+)
+
+// Roles in ascending order of privileges
+var roles = []string{store.RoleReader, store.RoleAdmin}
+
+var (
+	// ErrInvalidCredentials is returned when signing in with an unknown name or a wrong password
+	ErrInvalidCredentials = errors.New("invalid name or password")
+	// ErrUnauthenticated is returned for unknown or expired sessions and API tokens
+	ErrUnauthenticated = errors.New("not authenticated")
+	// ErrInvalidUser is returned for invalid names, passwords and roles
+	ErrInvalidUser = errors.New("invalid user")
+	// ErrLastAdmin is returned when removing or demoting the only admin
+	ErrLastAdmin = errors.New("the last admin can't be removed or demoted")
+)
+
+// Settings configure signing in
+type Settings struct {
+	// Enabled requires signing in for everything but the probes and metrics, the server is open to anyone otherwise
+	Enabled             bool `yaml:"enabled"`
+	SessionTTLInSeconds int  `yaml:"session_ttl_in_seconds"`
+}
+
+// DefaultSettings returns the settings used for the values which are not configured
+func DefaultSettings() Settings {
+	return Settings{
+		SessionTTLInSeconds: 30 * 24 * 60 * 60,
+	}
+}
+
+// Validate reports every invalid setting
+func (s Settings) Validate() error {
+	var errs []error
+
+	if s.SessionTTLInSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("session_ttl_in_seconds should be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Blogger defines an interface to load and save users, their sessions and API tokens
+type Blogger interface {
+	CreateUser(ctx context.Context, user *store.UserV1) error
+	GetUser(ctx context.Context, id uint64) (*store.UserV1, error)
+	GetUserByName(ctx context.Context, name string) (*store.UserV1, error)
+	GetUsers(ctx context.Context) ([]*store.UserV1, error)
+	SaveUser(ctx context.Context, user *store.UserV1) error
+	DeleteUser(ctx context.Context, id uint64) error
+	SaveSession(ctx context.Context, session *store.SessionV1) error
+	GetSession(ctx context.Context, id string) (*store.SessionV1, error)
+	DeleteSession(ctx context.Context, id string) error
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error)
+	SaveAPIToken(ctx context.Context, token *store.APITokenV1) error
+	GetAPITokenByHash(ctx context.Context, hash string) (*store.APITokenV1, error)
+	GetAPITokens(ctx context.Context, userID uint64) ([]*store.APITokenV1, error)
+	DeleteAPIToken(ctx context.Context, id uint64) error
+}
+
+// Authenticator signs users in with their passwords and authenticates their sessions and API tokens.
+// Only hashes of sessions and tokens are stored
+type Authenticator struct {
+	Blogger  Blogger
+	Settings Settings
+	// Cost is the bcrypt cost of new password hashes, bcrypt.DefaultCost when zero
+	Cost int
+
+	// dummyHash is compared for unknown users, so signing in takes as long as with a wrong password
+	dummyHash []byte
+	dummyOnce sync.Once
+}
+
+// Session is a signed in browser, Token is the value of its cookie
+type Session struct {
+	Token     string
+	User      *store.UserV1
+	ExpiresAt time.Time
+}
+
+// HasRole tells whether the role grants the privileges of the required one
+func HasRole(role string, required string) bool {
+	granted := slices.Index(roles, role)
+	return granted >= 0 && granted >= slices.Index(roles, required)
+}
+
+// AddUser creates a user with the bcrypt hash of the password
+func (a *Authenticator) AddUser(ctx context.Context, name string, password string, role string) (*store.UserV1, error) {
+	if err := errors.Join(validateName(name), validatePassword(password), validateRole(role)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUser, err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), a.cost())
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	user := &store.UserV1{Name: name, PasswordHash: string(hash), Role: role}
+	if err := a.Blogger.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "user added", "user", name, "role", role)
+	return user, nil
+}
+
+// SetPassword replaces the password of the user
+func (a *Authenticator) SetPassword(ctx context.Context, name string, password string) error {
+	if err := validatePassword(password); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUser, err)
+	}
+
+	user, err := a.Blogger.GetUserByName(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), a.cost())
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+	user.PasswordHash = string(hash)
+
+	return a.Blogger.SaveUser(ctx, user)
+}
+
+// SetRole changes the role of the user, the last admin keeps its role
+func (a *Authenticator) SetRole(ctx context.Context, name string, role string) error {
+	if err := validateRole(role); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUser, err)
+	}
+
+	user, err := a.Blogger.GetUserByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if user.Role == role {
+		return nil
+	}
+	if err := a.checkLastAdmin(ctx, user); err != nil {
+		return err
+	}
+
+	user.Role = role
+	return a.Blogger.SaveUser(ctx, user)
+}
+
+// RemoveUser deletes the user with its sessions and API tokens, the last admin is kept
+func (a *Authenticator) RemoveUser(ctx context.Context, id uint64) error {
+	user, err := a.Blogger.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := a.checkLastAdmin(ctx, user); err != nil {
+		return err
+	}
+
+	if err := a.Blogger.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "user removed", "user", user.Name)
+	return nil
+}
+
+// Users returns all users ordered by name
+func (a *Authenticator) Users(ctx context.Context) ([]*store.UserV1, error) {
+	return a.Blogger.GetUsers(ctx)
+}
+
+// Login checks the password of the user and starts a session
+func (a *Authenticator) Login(ctx context.Context, name string, password string) (*Session, error) {
+	user, err := a.Blogger.GetUserByName(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(a.dummy(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	// sessions are only pruned here, expired ones are rejected anyway
+	now := time.Now().UTC()
+	if _, err := a.Blogger.DeleteExpiredSessions(ctx, now); err != nil {
+		slog.WarnContext(ctx, "failed to delete expired sessions", "error", err)
+	}
+
+	token, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	session := &store.SessionV1{
+		ID:        hash(token),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(time.Duration(a.Settings.SessionTTLInSeconds) * time.Second),
+	}
+	if err := a.Blogger.SaveSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "user signed in", "user", user.Name)
+	return &Session{Token: token, User: user, ExpiresAt: session.ExpiresAt}, nil
+}
+
+// Logout ends the session
+func (a *Authenticator) Logout(ctx context.Context, token string) error {
+	return a.Blogger.DeleteSession(ctx, hash(token))
+}
+
+// SessionUser returns the user of an unexpired session
+func (a *Authenticator) SessionUser(ctx context.Context, token string) (*store.UserV1, error) {
+	session, err := a.Blogger.GetSession(ctx, hash(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown session", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !session.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: session expired", ErrUnauthenticated)
+	}
+
+	return a.user(ctx, session.UserID)
+}
+
+// CreateToken creates an API token for the user, the token is returned only once
+func (a *Authenticator) CreateToken(ctx context.Context, userID uint64, name string) (string, *store.APITokenV1, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", nil, fmt.Errorf("%w: token name should have 1 to %d characters", ErrInvalidUser, maxNameLength)
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	token := TokenPrefix + secret
+
+	apiToken := &store.APITokenV1{UserID: userID, Name: name, Hash: hash(token)}
+	if err := a.Blogger.SaveAPIToken(ctx, apiToken); err != nil {
+		return "", nil, err
+	}
+
+	return token, apiToken, nil
+}
+
+// TokenUser returns the user of an API token and records when the token was used
+func (a *Authenticator) TokenUser(ctx context.Context, token string) (*store.UserV1, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return nil, fmt.Errorf("%w: malformed token", ErrUnauthenticated)
+	}
+
+	apiToken, err := a.Blogger.GetAPITokenByHash(ctx, hash(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown token", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.user(ctx, apiToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) >= lastUsedResolution {
+		apiToken.LastUsedAt = &now
+		if err := a.Blogger.SaveAPIToken(ctx, apiToken); err != nil {
+			slog.WarnContext(ctx, "failed to record token use", "token", apiToken.ID, "error", err)
+		}
+	}
+
+	return user, nil
+}
+
+// Tokens returns the API tokens of the user
+func (a *Authenticator) Tokens(ctx context.Context, userID uint64) ([]*store.APITokenV1, error) {
+	return a.Blogger.GetAPITokens(ctx, userID)
+}
+
+// RevokeToken deletes an API token of the user, store.ErrNotFound is returned for tokens of other users
+func (a *Authenticator) RevokeToken(ctx context.Context, userID uint64, id uint64) error {
+	tokens, err := a.Blogger.GetAPITokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(tokens, func(token *store.APITokenV1) bool { return token.ID == id }) {
+		return fmt.Errorf("token %d: %w", id, store.ErrNotFound)
+	}
+
+	return a.Blogger.DeleteAPIToken(ctx, id)
+}
+
+// user loads the user of a session or token, removed users are not authenticated anymore
+func (a *Authenticator) user(ctx context.Context, id uint64) (*store.UserV1, error) {
+	user, err := a.Blogger.GetUser(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown user", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// checkLastAdmin returns ErrLastAdmin when the user is the only admin
+func (a *Authenticator) checkLastAdmin(ctx context.Context, user *store.UserV1) error {
+	if user.Role != store.RoleAdmin {
+		return nil
+	}
+
+	users, err := a.Blogger.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range users {
+		if other.ID != user.ID && other.Role == store.RoleAdmin {
+			return nil
+		}
+	}
+
+	return ErrLastAdmin
+}
+
+func (a *Authenticator) cost() int {
+	if a.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return a.Cost
+}
+
+// dummy hashes with the cost of real passwords, so unknown users can't be told apart by timing
+func (a *Authenticator) dummy() []byte {
+	a.dummyOnce.Do(func() {
+		a.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("rss-sum"), a.cost())
+	})
+	return a.dummyHash
+}
+
+func validateName(name string) error {
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Errorf("name should have 1 to %d characters", maxNameLength)
+	}
+	if strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("name should not contain spaces")
+	}
+	return nil
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return fmt.Errorf("password should have %d to %d bytes", minPasswordLength, maxPasswordLength)
+	}
+	return nil
+}
+
+func validateRole(role string) error {
+	if !slices.Contains(roles, role) {
+		return fmt.Errorf("role should be one of %s", strings.Join(roles, ", "))
+	}
+	return nil
+}
+
+// newSecret returns 32 random bytes, URL-safe encoded
+func newSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
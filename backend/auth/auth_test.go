@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/rjxby/rss-sum/backend/blogger"
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/store/storetest"
+)
+
+const password = "correct horse"
+
+// newTestAuthenticator has an admin named alice and a reader named bob
+func newTestAuthenticator(t *testing.T) (*Authenticator, *blogger.BloggerProc) {
+	proc := storetest.NewBlogger(t)
+	authenticator := &Authenticator{Blogger: proc, Settings: DefaultSettings(), Cost: bcrypt.MinCost}
+
+	for name, role := range map[string]string{"alice": store.RoleAdmin, "bob": store.RoleReader} {
+		if _, err := authenticator.AddUser(context.Background(), name, password, role); err != nil {
+			t.Fatalf("Failed to add user: %v", err)
+		}
+	}
+	return authenticator, proc
+}
+
+func TestSettingsValidate(t *testing.T) {
+	assert.NoError(t, DefaultSettings().Validate())
+	assert.EqualError(t, Settings{Enabled: true}.Validate(), "session_ttl_in_seconds should be positive")
+}
+
+func TestHasRole(t *testing.T) {
+	assert.True(t, HasRole(store.RoleAdmin, store.RoleReader))
+	assert.True(t, HasRole(store.RoleAdmin, store.RoleAdmin))
+	assert.True(t, HasRole(store.RoleReader, store.RoleReader))
+	assert.False(t, HasRole(store.RoleReader, store.RoleAdmin))
+	assert.False(t, HasRole("", store.RoleReader))
+}
+
+func TestAddUser(t *testing.T) {
+	tbl := []struct {
+		name     string
+		user     string
+		password string
+		role     string
+		err      error
+	}{
+		{"Valid", "carol", password, store.RoleReader, nil},
+		{"EmptyName", "", password, store.RoleReader, ErrInvalidUser},
+		{"NameWithSpace", "carol smith", password, store.RoleReader, ErrInvalidUser},
+		{"ShortPassword", "carol", "short", store.RoleReader, ErrInvalidUser},
+		{"LongPassword", "carol", strings.Repeat("x", 73), store.RoleReader, ErrInvalidUser},
+		{"UnknownRole", "carol", password, "owner", ErrInvalidUser},
+		{"Taken", "bob", password, store.RoleReader, store.ErrAlreadyExists},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			authenticator, proc := newTestAuthenticator(t)
+
+			// Execute
+			user, err := authenticator.AddUser(context.Background(), tt.user, tt.password, tt.role)
+
+			// Verify
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Nil(t, user)
+				return
+			}
+			assert.NoError(t, err)
+			stored, err := proc.GetUserByName(context.Background(), tt.user)
+			assert.NoError(t, err)
+			assert.Equal(t, user.ID, stored.ID)
+			assert.NotEqual(t, tt.password, stored.PasswordHash)
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		authenticator, proc := newTestAuthenticator(t)
+
+		// Execute
+		session, err := authenticator.Login(context.Background(), "bob", password)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, "bob", session.User.Name)
+		assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), session.ExpiresAt, time.Minute)
+		user, err := authenticator.SessionUser(context.Background(), session.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, "bob", user.Name)
+		// only the hash of the token is stored
+		_, err = proc.GetSession(context.Background(), session.Token)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("InvalidCredentials", func(t *testing.T) {
+		// Setup
+		authenticator, _ := newTestAuthenticator(t)
+
+		// Execute
+		_, wrongPassword := authenticator.Login(context.Background(), "bob", "wrong password")
+		_, unknownUser := authenticator.Login(context.Background(), "carol", password)
+
+		// Verify
+		assert.ErrorIs(t, wrongPassword, ErrInvalidCredentials)
+		assert.ErrorIs(t, unknownUser, ErrInvalidCredentials)
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		// Setup
+		authenticator, _ := newTestAuthenticator(t)
+		session, err := authenticator.Login(context.Background(), "bob", password)
+		assert.NoError(t, err)
+
+		// Execute
+		err = authenticator.Logout(context.Background(), session.Token)
+
+		// Verify
+		assert.NoError(t, err)
+		_, err = authenticator.SessionUser(context.Background(), session.Token)
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		// Setup
+		authenticator, proc := newTestAuthenticator(t)
+		session, err := authenticator.Login(context.Background(), "bob", password)
+		assert.NoError(t, err)
+		stored, err := proc.GetSession(context.Background(), hash(session.Token))
+		assert.NoError(t, err)
+		stored.ExpiresAt = time.Now().Add(-time.Second)
+		assert.NoError(t, proc.SaveSession(context.Background(), stored))
+
+		// Execute
+		_, err = authenticator.SessionUser(context.Background(), session.Token)
+
+		// Verify
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+		// the next sign in prunes it
+		_, err = authenticator.Login(context.Background(), "alice", password)
+		assert.NoError(t, err)
+		_, err = proc.GetSession(context.Background(), hash(session.Token))
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("RemovedUser", func(t *testing.T) {
+		// Setup
+		authenticator, _ := newTestAuthenticator(t)
+		session, err := authenticator.Login(context.Background(), "bob", password)
+		assert.NoError(t, err)
+
+		// Execute
+		assert.NoError(t, authenticator.RemoveUser(context.Background(), session.User.ID))
+
+		// Verify
+		_, err = authenticator.SessionUser(context.Background(), session.Token)
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+	})
+}
+
+func TestTokens(t *testing.T) {
+	t.Run("Authenticate", func(t *testing.T) {
+		// Setup
+		authenticator, proc := newTestAuthenticator(t)
+		bob, err := proc.GetUserByName(context.Background(), "bob")
+		assert.NoError(t, err)
+
+		// Execute
+		token, apiToken, err := authenticator.CreateToken(context.Background(), bob.ID, " cli ")
+		assert.NoError(t, err)
+		user, err := authenticator.TokenUser(context.Background(), token)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, "bob", user.Name)
+		assert.True(t, strings.HasPrefix(token, TokenPrefix))
+		assert.Equal(t, "cli", apiToken.Name)
+
+		tokens, err := authenticator.Tokens(context.Background(), bob.ID)
+		assert.NoError(t, err)
+		assert.Len(t, tokens, 1)
+		assert.NotNil(t, tokens[0].LastUsedAt)
+		assert.NotEqual(t, token, tokens[0].Hash)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		// Setup
+		authenticator, _ := newTestAuthenticator(t)
+
+		// Execute
+		_, malformed := authenticator.TokenUser(context.Background(), "secret")
+		_, unknown := authenticator.TokenUser(context.Background(), TokenPrefix+"secret")
+
+		// Verify
+		assert.ErrorIs(t, malformed, ErrUnauthenticated)
+		assert.ErrorIs(t, unknown, ErrUnauthenticated)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		// Setup
+		authenticator, proc := newTestAuthenticator(t)
+		alice, err := proc.GetUserByName(context.Background(), "alice")
+		assert.NoError(t, err)
+		bob, err := proc.GetUserByName(context.Background(), "bob")
+		assert.NoError(t, err)
+		token, apiToken, err := authenticator.CreateToken(context.Background(), bob.ID, "cli")
+		assert.NoError(t, err)
+
+		// Execute
+		otherUser := authenticator.RevokeToken(context.Background(), alice.ID, apiToken.ID)
+		err = authenticator.RevokeToken(context.Background(), bob.ID, apiToken.ID)
+
+		// Verify
+		assert.ErrorIs(t, otherUser, store.ErrNotFound)
+		assert.NoError(t, err)
+		_, err = authenticator.TokenUser(context.Background(), token)
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+	})
+
+	t.Run("EmptyName", func(t *testing.T) {
+		// Setup
+		authenticator, _ := newTestAuthenticator(t)
+
+		// Execute
+		_, _, err := authenticator.CreateToken(context.Background(), 1, " ")
+
+		// Verify
+		assert.ErrorIs(t, err, ErrInvalidUser)
+	})
+}
+
+func TestLastAdmin(t *testing.T) {
+	// Setup
+	authenticator, proc := newTestAuthenticator(t)
+	alice, err := proc.GetUserByName(context.Background(), "alice")
+	assert.NoError(t, err)
+
+	// Execute
+	removeErr := authenticator.RemoveUser(context.Background(), alice.ID)
+	demoteErr := authenticator.SetRole(context.Background(), "alice", store.RoleReader)
+
+	// Verify
+	assert.ErrorIs(t, removeErr, ErrLastAdmin)
+	assert.ErrorIs(t, demoteErr, ErrLastAdmin)
+
+	// with a second admin alice can step down
+	assert.NoError(t, authenticator.SetRole(context.Background(), "bob", store.RoleAdmin))
+	assert.NoError(t, authenticator.SetRole(context.Background(), "alice", store.RoleReader))
+	assert.NoError(t, authenticator.RemoveUser(context.Background(), alice.ID))
+	_, err = authenticator.SessionUser(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestSetPassword(t *testing.T) {
+	// Setup
+	authenticator, _ := newTestAuthenticator(t)
+
+	// Execute
+	err := authenticator.SetPassword(context.Background(), "bob", "new password")
+
+	// Verify
+	assert.NoError(t, err)
+	_, err = authenticator.Login(context.Background(), "bob", password)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = authenticator.Login(context.Background(), "bob", "new password")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, authenticator.SetPassword(context.Background(), "carol", "new password"), store.ErrNotFound)
+	assert.ErrorIs(t, authenticator.SetPassword(context.Background(), "bob", "short"), ErrInvalidUser)
+}
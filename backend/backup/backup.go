@@ -0,0 +1,523 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+// Names of the files in an archive
+const (
+	manifestFile = "manifest.json"
+	databaseFile = "rss-sum.sqlite"
+	feedsFile    = "feeds.json"
+	configFile   = "config.yaml"
+)
+
+const (
+	archivePrefix = "rss-sum-"
+	// archiveTimeFormat sorts archives by their creation time, with nanoseconds two backups never share a name
+	archiveTimeFormat = "20060102T150405.000000000Z"
+	// archiveParseFormat also reads the names of archives written with one second resolution
+	archiveParseFormat = "20060102T150405Z"
+)
+
+// ErrTargetExists is returned when a restore would overwrite a file without being forced to
+var ErrTargetExists = errors.New("file already exists")
+
+type Settings struct {
+	Dir string `yaml:"dir"`
+	// IntervalInSeconds between scheduled backups, 0 disables them
+	IntervalInSeconds int `yaml:"interval_in_seconds"`
+	// Keep is the number of archives kept in Dir, older ones are deleted after every backup
+	Keep     int  `yaml:"keep"`
+	Compress bool `yaml:"compress"`
+}
+
+// Snapshotter defines an interface to copy the database while it is in use
+type Snapshotter interface {
+	Snapshot(path string) error
+}
+
+// Blogger defines an interface to load the subscribed feeds
+type Blogger interface {
+	GetFeeds(ctx context.Context) ([]*store.FeedV1, error)
+}
+
+// Archiver writes backup archives of the database, the subscribed feeds and the configuration file
+type Archiver struct {
+	Store   Snapshotter
+	Blogger Blogger
+	// ConfigFile is copied into the archives, nothing is copied when empty
+	ConfigFile string
+	Settings   Settings
+	Version    string
+
+	// mu serializes backups
+	mu       sync.Mutex
+	reloaded atomic.Pointer[Settings]
+}
+
+// Manifest describes the content of an archive
+type Manifest struct {
+	Version   string    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []string  `json:"files"`
+}
+
+// Archive is a backup file
+type Archive struct {
+	Name      string
+	Path      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// DefaultSettings returns the settings used for the values which are not configured
+func DefaultSettings() Settings {
+	return Settings{
+		Dir:      "data/backups",
+		Keep:     7,
+		Compress: true,
+	}
+}
+
+// Validate reports every invalid setting
+func (s Settings) Validate() error {
+	var errs []error
+
+	if s.Dir == "" {
+		errs = append(errs, fmt.Errorf("dir is empty"))
+	}
+	if s.IntervalInSeconds < 0 {
+		errs = append(errs, fmt.Errorf("interval_in_seconds should not be negative"))
+	}
+	if s.Keep <= 0 {
+		errs = append(errs, fmt.Errorf("keep should be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Reload swaps the settings of the following backups, the schedule is fixed once the archiver runs
+func (a *Archiver) Reload(settings Settings) {
+	a.reloaded.Store(&settings)
+}
+
+// settings returns the reloaded settings, or the initial ones until the first reload
+func (a *Archiver) settings() *Settings {
+	if settings := a.reloaded.Load(); settings != nil {
+		return settings
+	}
+	return &a.Settings
+}
+
+// Run backs up every interval until the context is cancelled, nothing is scheduled for a zero interval
+func (a *Archiver) Run(ctx context.Context) error {
+	interval := a.settings().IntervalInSeconds
+	if interval == 0 {
+		slog.Info("scheduled backups are disabled")
+		return nil
+	}
+
+	slog.Info("activate scheduled backups", "interval", interval, "dir", a.settings().Dir)
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := a.Create(ctx); err != nil {
+				slog.Error("failed to back up", "error", err)
+			}
+		}
+	}
+}
+
+// Create writes an archive to the configured directory and deletes the archives exceeding the configured number
+func (a *Archiver) Create(ctx context.Context) (*Archive, error) {
+	settings := a.settings()
+
+	archive, err := a.Backup(ctx, settings.Dir, settings.Compress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Rotate(settings.Dir, settings.Keep); err != nil {
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+// Backup writes an archive to dir, named after its creation time
+func (a *Archiver) Backup(ctx context.Context, dir string, compress bool) (*Archive, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	createdAt := time.Now().UTC()
+	name := archivePrefix + createdAt.Format(archiveTimeFormat) + ".tar"
+	if compress {
+		name += ".gz"
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	// the snapshot is written next to the archive, VACUUM INTO refuses existing files
+	tmpDir, err := os.MkdirTemp(dir, ".snapshot-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshotPath := filepath.Join(tmpDir, databaseFile)
+	if err := a.Store.Snapshot(snapshotPath); err != nil {
+		return nil, err
+	}
+
+	feeds, err := a.Blogger.GetFeeds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	feedsJSON, err := json.MarshalIndent(feeds, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode feeds: %v", err)
+	}
+
+	files := []string{databaseFile, feedsFile}
+	var configData []byte
+	if a.ConfigFile != "" {
+		if configData, err = os.ReadFile(a.ConfigFile); err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		files = append(files, configFile)
+	}
+
+	manifestJSON, err := json.MarshalIndent(Manifest{Version: a.Version, CreatedAt: createdAt, Files: files}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %v", err)
+	}
+
+	// the archive only gets its name once it is complete, rotation and restores never see partial ones
+	path := filepath.Join(dir, name)
+	tmpPath := filepath.Join(tmpDir, name)
+	err = writeArchive(tmpPath, compress, func(tw *tar.Writer) error {
+		if err := addBytes(tw, manifestFile, manifestJSON, createdAt); err != nil {
+			return err
+		}
+		if err := addFile(tw, databaseFile, snapshotPath, createdAt); err != nil {
+			return err
+		}
+		if err := addBytes(tw, feedsFile, feedsJSON, createdAt); err != nil {
+			return err
+		}
+		if configData != nil {
+			return addBytes(tw, configFile, configData, createdAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, fmt.Errorf("failed to move archive: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %v", err)
+	}
+
+	slog.Info("backed up", "archive", path, "size", info.Size(), "feeds", len(feeds))
+	return &Archive{Name: name, Path: path, Size: info.Size(), CreatedAt: createdAt}, nil
+}
+
+// Archives returns the archives in the configured directory, newest first
+func (a *Archiver) Archives() ([]Archive, error) {
+	return List(a.settings().Dir)
+}
+
+// List returns the archives in dir, newest first
+func List(dir string) ([]Archive, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %v", err)
+	}
+
+	var archives []Archive
+	for _, entry := range entries {
+		createdAt, ok := parseArchiveName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup %s: %v", entry.Name(), err)
+		}
+		archives = append(archives, Archive{
+			Name:      entry.Name(),
+			Path:      filepath.Join(dir, entry.Name()),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].CreatedAt.After(archives[j].CreatedAt) })
+
+	return archives, nil
+}
+
+// Rotate deletes the archives in dir except the newest keep ones
+func Rotate(dir string, keep int) error {
+	archives, err := List(dir)
+	if err != nil {
+		return err
+	}
+
+	for i := keep; i < len(archives); i++ {
+		if err := os.Remove(archives[i].Path); err != nil {
+			return fmt.Errorf("failed to delete backup %s: %v", archives[i].Name, err)
+		}
+		slog.Info("deleted old backup", "archive", archives[i].Path)
+	}
+
+	return nil
+}
+
+// Restore replaces the SQLite database at databasePath with the one of the archive, and writes the configuration
+// file of the archive to configPath unless it is empty. Existing files are only replaced when forced to.
+// The database is checked before it replaces the existing one, the service has to be stopped meanwhile
+func Restore(archivePath string, databasePath string, configPath string, force bool) (*Manifest, error) {
+	for _, target := range []string{databasePath, configPath} {
+		if target == "" || force {
+			continue
+		}
+		if _, err := os.Stat(target); err == nil {
+			return nil, fmt.Errorf("%w: %s, restore with --force to replace it", ErrTargetExists, target)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(databasePath), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %v", err)
+	}
+	tmpDatabase := databasePath + ".restore"
+	defer os.Remove(tmpDatabase)
+
+	var manifest *Manifest
+	var configData []byte
+	var hasDatabase bool
+	err := readArchive(archivePath, func(header *tar.Header, r io.Reader) error {
+		switch header.Name {
+		case manifestFile:
+			manifest = &Manifest{}
+			if err := json.NewDecoder(r).Decode(manifest); err != nil {
+				return fmt.Errorf("failed to decode manifest: %v", err)
+			}
+		case databaseFile:
+			hasDatabase = true
+			return writeFile(tmpDatabase, r)
+		case configFile:
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %v", configFile, err)
+			}
+			configData = data
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil || !hasDatabase {
+		return nil, fmt.Errorf("%s is not a backup archive", archivePath)
+	}
+
+	// a database migrated by a newer version of the service is refused like at startup
+	database, err := store.NewDatabase(&store.Settings{DSN: tmpDatabase})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open restored database: %v", err)
+	}
+	pingErr := database.Ping()
+	if err := errors.Join(pingErr, database.Close()); err != nil {
+		return nil, fmt.Errorf("restored database is not usable: %v", err)
+	}
+
+	// journals of the replaced database would be applied to the restored one
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(databasePath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to delete %s: %v", databasePath+suffix, err)
+		}
+	}
+	if err := os.Rename(tmpDatabase, databasePath); err != nil {
+		return nil, fmt.Errorf("failed to replace database: %v", err)
+	}
+	slog.Info("restored database", "path", databasePath, "backup_version", manifest.Version, "backup_created_at", manifest.CreatedAt)
+
+	if configPath != "" {
+		if configData == nil {
+			slog.Warn("backup has no config file, keeping the current one", "path", configPath)
+		} else {
+			if err := writeFile(configPath, bytes.NewReader(configData)); err != nil {
+				return nil, err
+			}
+			slog.Info("restored config file", "path", configPath)
+		}
+	}
+
+	return manifest, nil
+}
+
+func parseArchiveName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, archivePrefix) {
+		return time.Time{}, false
+	}
+
+	stamp := strings.TrimPrefix(name, archivePrefix)
+	switch {
+	case strings.HasSuffix(stamp, ".tar.gz"):
+		stamp = strings.TrimSuffix(stamp, ".tar.gz")
+	case strings.HasSuffix(stamp, ".tar"):
+		stamp = strings.TrimSuffix(stamp, ".tar")
+	default:
+		return time.Time{}, false
+	}
+
+	createdAt, err := time.Parse(archiveParseFormat, stamp)
+	return createdAt, err == nil
+}
+
+// writeArchive writes a tar archive, gzip compressed when compress is set
+func writeArchive(path string, compress bool, fn func(tw *tar.Writer) error) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %v", err)
+	}
+	defer file.Close()
+
+	var w io.Writer = file
+	var gw *gzip.Writer
+	if compress {
+		gw = gzip.NewWriter(file)
+		w = gw
+	}
+
+	tw := tar.NewWriter(w)
+	if err := fn(tw); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %v", err)
+	}
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			return fmt.Errorf("failed to compress archive: %v", err)
+		}
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write archive: %v", err)
+	}
+
+	return file.Close()
+}
+
+// readArchive calls fn for every file of a tar archive, compressed archives are detected by their content
+func readArchive(path string, fn func(header *tar.Header, r io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	br := bufio.NewReader(file)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to decompress archive: %v", err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func addBytes(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: modTime}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %v", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %v", name, err)
+	}
+	return nil
+}
+
+func addFile(tw *tar.Writer, name string, path string, modTime time.Time) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", name, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+
+	header := &tar.Header{Name: name, Mode: 0o600, Size: info.Size(), ModTime: modTime}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %v", name, err)
+	}
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %v", name, err)
+	}
+	return nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,206 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rjxby/rss-sum/backend/blogger"
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/store/storetest"
+)
+
+// newTestArchiver backs up a SQLite database with a feed and a post, and a config file
+func newTestArchiver(t *testing.T) *Archiver {
+	dir := t.TempDir()
+
+	database, err := store.NewDatabase(&store.Settings{DSN: filepath.Join(dir, "rss-sum.sqlite")})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	proc := blogger.New(database)
+	storetest.Seed(t, proc, storetest.NewPost("1", storetest.FeedID, time.Now()))
+
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("log:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	return &Archiver{
+		Store:      database,
+		Blogger:    proc,
+		ConfigFile: configPath,
+		Settings:   Settings{Dir: filepath.Join(dir, "backups"), Keep: 2, Compress: true},
+		Version:    "test",
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	assert.NoError(t, DefaultSettings().Validate())
+	assert.EqualError(t, Settings{IntervalInSeconds: -1}.Validate(),
+		"dir is empty\ninterval_in_seconds should not be negative\nkeep should be positive")
+}
+
+func TestBackupRestore(t *testing.T) {
+	for _, compress := range []bool{true, false} {
+		name := "Plain"
+		if compress {
+			name = "Compressed"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			// Setup
+			archiver := newTestArchiver(t)
+			target := t.TempDir()
+			databasePath := filepath.Join(target, "data", "rss-sum.sqlite")
+			configPath := filepath.Join(target, "config.yaml")
+
+			// Execute
+			archive, err := archiver.Backup(context.Background(), archiver.Settings.Dir, compress)
+			assert.NoError(t, err)
+			manifest, err := Restore(archive.Path, databasePath, configPath, false)
+
+			// Verify
+			assert.NoError(t, err)
+			assert.Equal(t, "test", manifest.Version)
+			assert.Equal(t, []string{databaseFile, feedsFile, configFile}, manifest.Files)
+			assert.Equal(t, compress, filepath.Ext(archive.Name) == ".gz")
+
+			restored, err := store.NewDatabase(&store.Settings{DSN: databasePath})
+			assert.NoError(t, err)
+			defer restored.Close()
+			posts, err := restored.GetPostsByIDs([]string{"1"})
+			assert.NoError(t, err)
+			assert.Len(t, posts, 1)
+			feeds, err := restored.GetFeeds()
+			assert.NoError(t, err)
+			assert.Len(t, feeds, 1)
+
+			config, err := os.ReadFile(configPath)
+			assert.NoError(t, err)
+			assert.Equal(t, "log:\n  level: debug\n", string(config))
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Run("TargetExists", func(t *testing.T) {
+		// Setup
+		archiver := newTestArchiver(t)
+		archive, err := archiver.Backup(context.Background(), archiver.Settings.Dir, true)
+		assert.NoError(t, err)
+		databasePath := filepath.Join(t.TempDir(), "rss-sum.sqlite")
+		assert.NoError(t, os.WriteFile(databasePath, []byte("current"), 0o600))
+
+		// Execute
+		_, err = Restore(archive.Path, databasePath, "", false)
+
+		// Verify
+		assert.ErrorIs(t, err, ErrTargetExists)
+		current, err := os.ReadFile(databasePath)
+		assert.NoError(t, err)
+		assert.Equal(t, "current", string(current))
+	})
+
+	t.Run("Force", func(t *testing.T) {
+		// Setup
+		archiver := newTestArchiver(t)
+		archive, err := archiver.Backup(context.Background(), archiver.Settings.Dir, true)
+		assert.NoError(t, err)
+		databasePath := filepath.Join(t.TempDir(), "rss-sum.sqlite")
+		assert.NoError(t, os.WriteFile(databasePath, []byte("current"), 0o600))
+		assert.NoError(t, os.WriteFile(databasePath+"-wal", []byte("journal"), 0o600))
+
+		// Execute
+		_, err = Restore(archive.Path, databasePath, "", true)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.NoFileExists(t, databasePath+"-wal")
+		assert.NoFileExists(t, databasePath+".restore")
+		restored, err := store.NewDatabase(&store.Settings{DSN: databasePath})
+		assert.NoError(t, err)
+		defer restored.Close()
+		assert.NoError(t, restored.Ping())
+	})
+
+	t.Run("NotAnArchive", func(t *testing.T) {
+		// Setup
+		path := filepath.Join(t.TempDir(), "rss-sum.tar")
+		assert.NoError(t, os.WriteFile(path, []byte("not an archive"), 0o600))
+
+		// Execute
+		_, err := Restore(path, filepath.Join(t.TempDir(), "rss-sum.sqlite"), "", false)
+
+		// Verify
+		assert.Error(t, err)
+	})
+}
+
+func TestCreate(t *testing.T) {
+	// Setup
+	archiver := newTestArchiver(t)
+	dir := archiver.Settings.Dir
+	assert.NoError(t, os.MkdirAll(dir, 0o750))
+	for _, name := range []string{"rss-sum-20240101T000000Z.tar.gz", "rss-sum-20240102T000000Z.tar", "notes.txt"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	// Execute
+	archive, err := archiver.Create(context.Background())
+
+	// Verify
+	assert.NoError(t, err)
+	archives, err := List(dir)
+	assert.NoError(t, err)
+	assert.Len(t, archives, 2)
+	assert.Equal(t, archive.Name, archives[0].Name)
+	assert.Equal(t, "rss-sum-20240102T000000Z.tar", archives[1].Name)
+	assert.FileExists(t, filepath.Join(dir, "notes.txt"))
+}
+
+func TestBackupNames(t *testing.T) {
+	// Setup
+	archiver := newTestArchiver(t)
+	dir := archiver.Settings.Dir
+
+	// Execute
+	first, err := archiver.Backup(context.Background(), dir, false)
+	assert.NoError(t, err)
+	second, err := archiver.Backup(context.Background(), dir, false)
+	assert.NoError(t, err)
+
+	// Verify
+	assert.NotEqual(t, first.Name, second.Name)
+	archives, err := List(dir)
+	assert.NoError(t, err)
+	assert.Len(t, archives, 2)
+	assert.Equal(t, second.Name, archives[0].Name)
+	assert.True(t, archives[0].CreatedAt.Equal(second.CreatedAt))
+}
+
+func TestBackupUnsupported(t *testing.T) {
+	// Setup
+	memory := store.NewMemory()
+	archiver := &Archiver{Store: memory, Blogger: blogger.New(memory), Settings: DefaultSettings()}
+	dir := t.TempDir()
+
+	// Execute
+	archive, err := archiver.Backup(context.Background(), dir, true)
+
+	// Verify
+	assert.ErrorIs(t, err, store.ErrSnapshotUnsupported)
+	assert.Nil(t, archive)
+	archives, err := List(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, archives)
+}
@@ -1,11 +1,25 @@
 package blogger
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel"
 
 	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/tracing"
 )
 
+var tracer = otel.Tracer("github.com/rjxby/rss-sum/backend/blogger")
+
+// ErrJobNotDead is returned when requeueing a job which has not exhausted its attempts
+var ErrJobNotDead = errors.New("job is not dead")
+
 // BloggerProc creates and save blogs
 type BloggerProc struct {
 	engine Engine
@@ -21,23 +35,952 @@ func New(engine Engine) *BloggerProc {
 // Engine defines interface to save and load data
 type Engine interface {
 	GetPosts(page int, pageSize int, partitionKey string) (*store.PaginationPostsResult, error)
+	QueryPosts(query store.PostsQuery) (*store.PaginationPostsResult, error)
+	MarkPostsRead(query store.PostsQuery, until time.Time, readAt time.Time) (int64, error)
 	SavePostsBulk(postsToSave []*store.PostV1) ([]*store.PostV1, error)
+	GetPostsByIDs(ids []string) ([]*store.PostV1, error)
+	GetPostsWithoutEmbedding(model string, limit int) ([]*store.PostV1, error)
+	GetPostsAfter(partitionKey string, afterID string, limit int) ([]*store.PostV1, error)
+	UpsertPosts(posts []*store.PostV1) (int, error)
+	GetPartitionKeys() ([]string, error)
+	GetExpiredPosts(partitionKey string, skip int, createdBefore time.Time) ([]*store.PostV1, error)
+	DeletePosts(ids []string) (int64, error)
+	Optimize() error
+	GetPostEmbedding(postID string) (*store.PostEmbeddingV1, error)
+	GetPostEmbeddings(model string) ([]*store.PostEmbeddingV1, error)
+	SavePostEmbedding(embedding *store.PostEmbeddingV1) error
+	GetFeeds() ([]*store.FeedV1, error)
+	GetFeed(id string) (*store.FeedV1, error)
+	SaveFeed(feed *store.FeedV1) error
+	GetFeedHealth(feedID string) (*store.FeedHealthV1, error)
+	GetFeedsHealth() ([]*store.FeedHealthV1, error)
+	SaveFeedHealth(health *store.FeedHealthV1) error
+	IncrementFeedItemsSummarized(feedID string) error
+	ResetFeedItemsSummarized(feedID string) error
+	SaveFeedRun(health *store.FeedHealthV1) error
+	EnqueueJobs(jobs []*store.JobV1) (int, error)
+	LeaseJobs(now time.Time, lease time.Duration, limit int) ([]*store.JobV1, error)
+	GetJob(id uint64) (*store.JobV1, error)
+	GetJobsByStatus(status string) ([]*store.JobV1, error)
+	SaveJob(job *store.JobV1) error
+	DeleteJob(id uint64) error
+	CreateUser(user *store.UserV1) error
+	GetUser(id uint64) (*store.UserV1, error)
+	GetUserByName(name string) (*store.UserV1, error)
+	GetUsers() ([]*store.UserV1, error)
+	SaveUser(user *store.UserV1) error
+	DeleteUser(id uint64) error
+	SaveSession(session *store.SessionV1) error
+	GetSession(id string) (*store.SessionV1, error)
+	DeleteSession(id string) error
+	DeleteExpiredSessions(now time.Time) (int64, error)
+	SaveAPIToken(token *store.APITokenV1) error
+	GetAPITokenByHash(hash string) (*store.APITokenV1, error)
+	GetAPITokens(userID uint64) ([]*store.APITokenV1, error)
+	DeleteAPIToken(id uint64) error
+	SaveSubscription(subscription *store.SubscriptionV1) error
+	DeleteSubscription(userID uint64, feedID string) error
+	GetSubscriptions(userID uint64) ([]*store.SubscriptionV1, error)
+	SavePostState(state *store.PostStateV1) error
+	GetPostStates(userID uint64, postIDs []string) ([]*store.PostStateV1, error)
+	GetPostsWithoutScores(profiles []string, limit int) ([]*store.PostV1, error)
+	GetPostScores(postIDs []string, profiles []string) ([]*store.PostScoreV1, error)
+	SavePostScores(scores []*store.PostScoreV1) error
+	Ping() error
 }
 
-func (p BloggerProc) GetPosts(page int, pageSize int, searchTerm string) (*store.PaginationPostsResult, error) {
+func (p BloggerProc) GetPosts(ctx context.Context, page int, pageSize int, searchTerm string) (*store.PaginationPostsResult, error) {
+	_, span := tracer.Start(ctx, "blogger.GetPosts")
+	defer span.End()
+
 	results, err := p.engine.GetPosts(page, pageSize, searchTerm)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get posts: %v", err)
+		return nil, tracing.Error(span, fmt.Errorf("failed to get posts: %v", err))
 	}
 
 	return results, nil
 }
 
-func (p BloggerProc) SavePostsBulk(postsToSave []*store.PostV1) ([]*store.PostV1, error) {
+func (p BloggerProc) SavePostsBulk(ctx context.Context, postsToSave []*store.PostV1) ([]*store.PostV1, error) {
+	_, span := tracer.Start(ctx, "blogger.SavePostsBulk")
+	defer span.End()
+
 	results, err := p.engine.SavePostsBulk(postsToSave)
 	if err != nil {
-		return nil, fmt.Errorf("failed to save posts bulk: %v", err)
+		return nil, tracing.Error(span, fmt.Errorf("failed to save posts bulk: %v", err))
+	}
+
+	return results, nil
+}
+
+func (p BloggerProc) GetPostsByIDs(ctx context.Context, ids []string) ([]*store.PostV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetPostsByIDs")
+	defer span.End()
+
+	results, err := p.engine.GetPostsByIDs(ids)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get posts by ids: %v", err))
+	}
+
+	return results, nil
+}
+
+func (p BloggerProc) GetPostsWithoutEmbedding(ctx context.Context, model string, limit int) ([]*store.PostV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetPostsWithoutEmbedding")
+	defer span.End()
+
+	results, err := p.engine.GetPostsWithoutEmbedding(model, limit)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get posts without embedding: %v", err))
+	}
+
+	return results, nil
+}
+
+// GetPostsAfter returns up to limit posts ordered by ID, the ones following afterID, of the partition unless it is empty
+func (p BloggerProc) GetPostsAfter(ctx context.Context, partitionKey string, afterID string, limit int) ([]*store.PostV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetPostsAfter")
+	defer span.End()
+
+	results, err := p.engine.GetPostsAfter(partitionKey, afterID, limit)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get posts: %v", err))
+	}
+
+	return results, nil
+}
+
+// UpsertPosts creates the posts and replaces the stored ones with the same IDs, returns how many posts were created
+func (p BloggerProc) UpsertPosts(ctx context.Context, posts []*store.PostV1) (int, error) {
+	_, span := tracer.Start(ctx, "blogger.UpsertPosts")
+	defer span.End()
+
+	if len(posts) == 0 {
+		return 0, nil
+	}
+
+	created, err := p.engine.UpsertPosts(posts)
+	if err != nil {
+		return 0, tracing.Error(span, fmt.Errorf("failed to upsert posts: %v", err))
+	}
+
+	return created, nil
+}
+
+func (p BloggerProc) GetPartitionKeys(ctx context.Context) ([]string, error) {
+	_, span := tracer.Start(ctx, "blogger.GetPartitionKeys")
+	defer span.End()
+
+	results, err := p.engine.GetPartitionKeys()
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get partition keys: %v", err))
+	}
+
+	return results, nil
+}
+
+// GetExpiredPosts returns the posts of the partition except its newest skip ones, only the ones created before
+// createdBefore unless it is zero
+func (p BloggerProc) GetExpiredPosts(ctx context.Context, partitionKey string, skip int, createdBefore time.Time) ([]*store.PostV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetExpiredPosts")
+	defer span.End()
+
+	results, err := p.engine.GetExpiredPosts(partitionKey, skip, createdBefore)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get expired posts: %v", err))
+	}
+
+	return results, nil
+}
+
+func (p BloggerProc) DeletePosts(ctx context.Context, ids []string) (int64, error) {
+	_, span := tracer.Start(ctx, "blogger.DeletePosts")
+	defer span.End()
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	deleted, err := p.engine.DeletePosts(ids)
+	if err != nil {
+		return 0, tracing.Error(span, fmt.Errorf("failed to delete posts: %v", err))
+	}
+
+	return deleted, nil
+}
+
+// Optimize runs the maintenance of the store, e.g. after deleting posts
+func (p BloggerProc) Optimize(ctx context.Context) error {
+	_, span := tracer.Start(ctx, "blogger.Optimize")
+	defer span.End()
+
+	if err := p.engine.Optimize(); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to optimize store: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) SavePostEmbedding(ctx context.Context, postID string, model string, vector []float64) error {
+	_, span := tracer.Start(ctx, "blogger.SavePostEmbedding")
+	defer span.End()
+
+	embedding := &store.PostEmbeddingV1{
+		PostID: postID,
+		Model:  model,
+		Vector: vector,
+	}
+
+	if err := p.engine.SavePostEmbedding(embedding); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to save post embedding: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) GetFeeds(ctx context.Context) ([]*store.FeedV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetFeeds")
+	defer span.End()
+
+	results, err := p.engine.GetFeeds()
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get feeds: %v", err))
+	}
+
+	return results, nil
+}
+
+func (p BloggerProc) SaveFeed(ctx context.Context, feed *store.FeedV1) error {
+	_, span := tracer.Start(ctx, "blogger.SaveFeed")
+	defer span.End()
+
+	if err := p.engine.SaveFeed(feed); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to save feed: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) GetFeedHealth(ctx context.Context, feedID string) (*store.FeedHealthV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetFeedHealth")
+	defer span.End()
+
+	result, err := p.engine.GetFeedHealth(feedID)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get feed health: %w", err))
+	}
+
+	return result, nil
+}
+
+func (p BloggerProc) SaveFeedHealth(ctx context.Context, health *store.FeedHealthV1) error {
+	_, span := tracer.Start(ctx, "blogger.SaveFeedHealth")
+	defer span.End()
+
+	if err := p.engine.SaveFeedHealth(health); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to save feed health: %v", err))
+	}
+
+	return nil
+}
+
+// ResetFeedItemsSummarized starts counting the summarized items of a new run of the feed
+func (p BloggerProc) ResetFeedItemsSummarized(ctx context.Context, feedID string) error {
+	_, span := tracer.Start(ctx, "blogger.ResetFeedItemsSummarized")
+	defer span.End()
+
+	if err := p.engine.ResetFeedItemsSummarized(feedID); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to reset feed items summarized: %v", err))
+	}
+
+	return nil
+}
+
+// SaveFeedRun records the outcome of a fetch without touching the items counted by the jobs
+func (p BloggerProc) SaveFeedRun(ctx context.Context, health *store.FeedHealthV1) error {
+	_, span := tracer.Start(ctx, "blogger.SaveFeedRun")
+	defer span.End()
+
+	if err := p.engine.SaveFeedRun(health); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to save feed run: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) IncrementFeedItemsSummarized(ctx context.Context, feedID string) error {
+	_, span := tracer.Start(ctx, "blogger.IncrementFeedItemsSummarized")
+	defer span.End()
+
+	if err := p.engine.IncrementFeedItemsSummarized(feedID); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to increment feed items summarized: %v", err))
+	}
+
+	return nil
+}
+
+// EnqueueJobs queues pending jobs which are due right away
+func (p BloggerProc) EnqueueJobs(ctx context.Context, jobs []*store.JobV1) (int, error) {
+	_, span := tracer.Start(ctx, "blogger.EnqueueJobs")
+	defer span.End()
+
+	now := time.Now().UTC()
+	for _, job := range jobs {
+		job.Status = store.JobStatusPending
+		job.RunAt = now
+	}
+
+	created, err := p.engine.EnqueueJobs(jobs)
+	if err != nil {
+		return 0, tracing.Error(span, fmt.Errorf("failed to enqueue jobs: %v", err))
+	}
+
+	return created, nil
+}
+
+func (p BloggerProc) LeaseJobs(ctx context.Context, lease time.Duration, limit int) ([]*store.JobV1, error) {
+	_, span := tracer.Start(ctx, "blogger.LeaseJobs")
+	defer span.End()
+
+	results, err := p.engine.LeaseJobs(time.Now().UTC(), lease, limit)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to lease jobs: %v", err))
+	}
+
+	return results, nil
+}
+
+func (p BloggerProc) SaveJob(ctx context.Context, job *store.JobV1) error {
+	_, span := tracer.Start(ctx, "blogger.SaveJob")
+	defer span.End()
+
+	if err := p.engine.SaveJob(job); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to save job: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) CompleteJob(ctx context.Context, id uint64) error {
+	_, span := tracer.Start(ctx, "blogger.CompleteJob")
+	defer span.End()
+
+	if err := p.engine.DeleteJob(id); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to complete job: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) GetDeadJobs(ctx context.Context) ([]*store.JobV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetDeadJobs")
+	defer span.End()
+
+	results, err := p.engine.GetJobsByStatus(store.JobStatusDead)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get dead jobs: %v", err))
+	}
+
+	return results, nil
+}
+
+// RequeueJob gives a dead job a fresh set of attempts, its last error is kept for reference
+func (p BloggerProc) RequeueJob(ctx context.Context, id uint64) (*store.JobV1, error) {
+	_, span := tracer.Start(ctx, "blogger.RequeueJob")
+	defer span.End()
+
+	job, err := p.engine.GetJob(id)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get job: %w", err))
+	}
+
+	if job.Status != store.JobStatusDead {
+		return nil, ErrJobNotDead
+	}
+
+	job.Status = store.JobStatusPending
+	job.Attempts = 0
+	job.RunAt = time.Now().UTC()
+	job.LeasedUntil = nil
+	if err := p.engine.SaveJob(job); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to save job: %v", err))
+	}
+
+	return job, nil
+}
+
+// CreateUser creates the user, store.ErrAlreadyExists is returned when the name is taken
+func (p BloggerProc) CreateUser(ctx context.Context, user *store.UserV1) error {
+	_, span := tracer.Start(ctx, "blogger.CreateUser")
+	defer span.End()
+
+	if err := p.engine.CreateUser(user); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to create user: %w", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) GetUser(ctx context.Context, id uint64) (*store.UserV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetUser")
+	defer span.End()
+
+	user, err := p.engine.GetUser(id)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get user: %w", err))
+	}
+
+	return user, nil
+}
+
+func (p BloggerProc) GetUserByName(ctx context.Context, name string) (*store.UserV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetUserByName")
+	defer span.End()
+
+	user, err := p.engine.GetUserByName(name)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get user: %w", err))
+	}
+
+	return user, nil
+}
+
+func (p BloggerProc) GetUsers(ctx context.Context) ([]*store.UserV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetUsers")
+	defer span.End()
+
+	users, err := p.engine.GetUsers()
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get users: %v", err))
+	}
+
+	return users, nil
+}
+
+func (p BloggerProc) SaveUser(ctx context.Context, user *store.UserV1) error {
+	_, span := tracer.Start(ctx, "blogger.SaveUser")
+	defer span.End()
+
+	if err := p.engine.SaveUser(user); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to save user: %v", err))
+	}
+
+	return nil
+}
+
+// DeleteUser deletes the user with its sessions, API tokens, subscriptions and post states
+func (p BloggerProc) DeleteUser(ctx context.Context, id uint64) error {
+	_, span := tracer.Start(ctx, "blogger.DeleteUser")
+	defer span.End()
+
+	if err := p.engine.DeleteUser(id); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to delete user: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) SaveSession(ctx context.Context, session *store.SessionV1) error {
+	_, span := tracer.Start(ctx, "blogger.SaveSession")
+	defer span.End()
+
+	if err := p.engine.SaveSession(session); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to save session: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) GetSession(ctx context.Context, id string) (*store.SessionV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetSession")
+	defer span.End()
+
+	session, err := p.engine.GetSession(id)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get session: %w", err))
+	}
+
+	return session, nil
+}
+
+func (p BloggerProc) DeleteSession(ctx context.Context, id string) error {
+	_, span := tracer.Start(ctx, "blogger.DeleteSession")
+	defer span.End()
+
+	if err := p.engine.DeleteSession(id); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to delete session: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	_, span := tracer.Start(ctx, "blogger.DeleteExpiredSessions")
+	defer span.End()
+
+	deleted, err := p.engine.DeleteExpiredSessions(now)
+	if err != nil {
+		return 0, tracing.Error(span, fmt.Errorf("failed to delete expired sessions: %v", err))
+	}
+
+	return deleted, nil
+}
+
+func (p BloggerProc) SaveAPIToken(ctx context.Context, token *store.APITokenV1) error {
+	_, span := tracer.Start(ctx, "blogger.SaveAPIToken")
+	defer span.End()
+
+	if err := p.engine.SaveAPIToken(token); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to save api token: %v", err))
+	}
+
+	return nil
+}
+
+func (p BloggerProc) GetAPITokenByHash(ctx context.Context, hash string) (*store.APITokenV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetAPITokenByHash")
+	defer span.End()
+
+	token, err := p.engine.GetAPITokenByHash(hash)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get api token: %w", err))
+	}
+
+	return token, nil
+}
+
+func (p BloggerProc) GetAPITokens(ctx context.Context, userID uint64) ([]*store.APITokenV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetAPITokens")
+	defer span.End()
+
+	tokens, err := p.engine.GetAPITokens(userID)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get api tokens: %v", err))
+	}
+
+	return tokens, nil
+}
+
+func (p BloggerProc) DeleteAPIToken(ctx context.Context, id uint64) error {
+	_, span := tracer.Start(ctx, "blogger.DeleteAPIToken")
+	defer span.End()
+
+	if err := p.engine.DeleteAPIToken(id); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to delete api token: %v", err))
+	}
+
+	return nil
+}
+
+// Subscribe adds the feed to the timeline of the user, subscribing twice is not an error
+func (p BloggerProc) Subscribe(ctx context.Context, userID uint64, feedID string) (*store.FeedV1, error) {
+	_, span := tracer.Start(ctx, "blogger.Subscribe")
+	defer span.End()
+
+	feed, err := p.engine.GetFeed(feedID)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get feed: %w", err))
+	}
+
+	if err := p.engine.SaveSubscription(&store.SubscriptionV1{UserID: userID, FeedID: feed.ID}); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to subscribe: %v", err))
+	}
+
+	return feed, nil
+}
+
+func (p BloggerProc) Unsubscribe(ctx context.Context, userID uint64, feedID string) error {
+	_, span := tracer.Start(ctx, "blogger.Unsubscribe")
+	defer span.End()
+
+	if err := p.engine.DeleteSubscription(userID, feedID); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to unsubscribe: %w", err))
+	}
+
+	return nil
+}
+
+// GetSubscribedFeeds returns the feeds the user is subscribed to ordered by ID
+func (p BloggerProc) GetSubscribedFeeds(ctx context.Context, userID uint64) ([]*store.FeedV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetSubscribedFeeds")
+	defer span.End()
+
+	subscriptions, err := p.engine.GetSubscriptions(userID)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get subscriptions: %v", err))
+	}
+
+	feeds := make([]*store.FeedV1, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		feed, err := p.engine.GetFeed(subscription.FeedID)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, tracing.Error(span, fmt.Errorf("failed to get feed: %v", err))
+		}
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, nil
+}
+
+// GetTimeline pages through the posts of the feeds the user is subscribed to and the saved links, newest first.
+// The anonymous user sees all posts, a partitionKey narrows the timeline down to a single one of them
+func (p BloggerProc) GetTimeline(ctx context.Context, query store.PostsQuery, partitionKey string) (*store.PaginationPostsResult, error) {
+	_, span := tracer.Start(ctx, "blogger.GetTimeline")
+	defer span.End()
+
+	partitionKeys, ok, err := p.timelinePartitions(query.UserID, partitionKey)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+	if !ok {
+		return &store.PaginationPostsResult{
+			Posts:        []*store.PostV1{},
+			PartitionKey: partitionKey,
+			Page:         query.Page,
+			PageSize:     query.PageSize}, nil
+	}
+	query.PartitionKeys = partitionKeys
+
+	results, err := p.engine.QueryPosts(query)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get posts: %v", err))
+	}
+	results.PartitionKey = partitionKey
+
+	return results, nil
+}
+
+// MarkPostsRead marks the posts of the timeline created until then as read, and returns how many were marked
+func (p BloggerProc) MarkPostsRead(ctx context.Context, query store.PostsQuery, partitionKey string, until time.Time) (int64, error) {
+	_, span := tracer.Start(ctx, "blogger.MarkPostsRead")
+	defer span.End()
+
+	partitionKeys, ok, err := p.timelinePartitions(query.UserID, partitionKey)
+	if err != nil {
+		return 0, tracing.Error(span, err)
+	}
+	if !ok {
+		return 0, nil
+	}
+	query.PartitionKeys = partitionKeys
+
+	marked, err := p.engine.MarkPostsRead(query, until, time.Now().UTC())
+	if err != nil {
+		return 0, tracing.Error(span, fmt.Errorf("failed to mark posts read: %v", err))
+	}
+
+	return marked, nil
+}
+
+// timelinePartitions returns the partition keys of the timeline of the user, empty ones select all partitions.
+// It is false when the partitionKey is not part of the timeline
+func (p BloggerProc) timelinePartitions(userID uint64, partitionKey string) ([]string, bool, error) {
+	if userID == store.AnonymousUserID {
+		if partitionKey == "" {
+			return nil, true, nil
+		}
+		return []string{partitionKey}, true, nil
+	}
+
+	subscriptions, err := p.engine.GetSubscriptions(userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get subscriptions: %v", err)
+	}
+
+	// saved links are shared by all users
+	partitionKeys := []string{store.SavedLinksPartitionKey}
+	for _, subscription := range subscriptions {
+		partitionKeys = append(partitionKeys, subscription.FeedID)
+	}
+	if partitionKey == "" {
+		return partitionKeys, true, nil
+	}
+	if !slices.Contains(partitionKeys, partitionKey) {
+		return nil, false, nil
+	}
+	return []string{partitionKey}, true, nil
+}
+
+// SetPostState sets or clears a flag of the post for the user and returns its state
+func (p BloggerProc) SetPostState(ctx context.Context, userID uint64, postID string, flag string, on bool) (*store.PostStateV1, error) {
+	_, span := tracer.Start(ctx, "blogger.SetPostState")
+	defer span.End()
+
+	posts, err := p.engine.GetPostsByIDs([]string{postID})
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get post: %v", err))
+	}
+	if len(posts) == 0 {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get post: %w", store.ErrNotFound))
+	}
+
+	states, err := p.engine.GetPostStates(userID, []string{postID})
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get post state: %v", err))
+	}
+	state := &store.PostStateV1{UserID: userID, PostID: postID}
+	if len(states) > 0 {
+		state = states[0]
+	}
+
+	if !state.Set(flag, on, time.Now().UTC()) {
+		return nil, tracing.Error(span, fmt.Errorf("unknown post flag %q", flag))
+	}
+	if err := p.engine.SavePostState(state); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to save post state: %v", err))
+	}
+
+	return state, nil
+}
+
+// GetPostStates returns the states of the posts for the user by post ID, posts without one are left out
+func (p BloggerProc) GetPostStates(ctx context.Context, userID uint64, postIDs []string) (map[string]*store.PostStateV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetPostStates")
+	defer span.End()
+
+	states, err := p.engine.GetPostStates(userID, postIDs)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get post states: %v", err))
+	}
+
+	results := make(map[string]*store.PostStateV1, len(states))
+	for _, state := range states {
+		results[state.PostID] = state
+	}
+
+	return results, nil
+}
+
+// GetPostsWithoutScores returns up to limit posts, newest first, which miss the score of any of the profiles
+func (p BloggerProc) GetPostsWithoutScores(ctx context.Context, profiles []string, limit int) ([]*store.PostV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetPostsWithoutScores")
+	defer span.End()
+
+	results, err := p.engine.GetPostsWithoutScores(profiles, limit)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get posts without scores: %v", err))
+	}
+
+	return results, nil
+}
+
+// SavePostScores stores the relevance of the post to the profiles by profile name, replacing earlier scores
+func (p BloggerProc) SavePostScores(ctx context.Context, postID string, model string, scores map[string]int) error {
+	_, span := tracer.Start(ctx, "blogger.SavePostScores")
+	defer span.End()
+
+	records := make([]*store.PostScoreV1, 0, len(scores))
+	for profile, score := range scores {
+		records = append(records, &store.PostScoreV1{
+			PostID:  postID,
+			Profile: profile,
+			Score:   score,
+			Model:   model,
+		})
+	}
+
+	if err := p.engine.SavePostScores(records); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to save post scores: %v", err))
+	}
+
+	return nil
+}
+
+// GetPostScores returns the scores of the posts for the profiles by post ID, posts without one are left out
+func (p BloggerProc) GetPostScores(ctx context.Context, postIDs []string, profiles []string) (map[string][]*store.PostScoreV1, error) {
+	_, span := tracer.Start(ctx, "blogger.GetPostScores")
+	defer span.End()
+
+	scores, err := p.engine.GetPostScores(postIDs, profiles)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get post scores: %v", err))
+	}
+
+	results := make(map[string][]*store.PostScoreV1)
+	for _, score := range scores {
+		results[score.PostID] = append(results[score.PostID], score)
 	}
 
 	return results, nil
 }
+
+// Ping checks that the store is reachable
+func (p BloggerProc) Ping(ctx context.Context) error {
+	_, span := tracer.Start(ctx, "blogger.Ping")
+	defer span.End()
+
+	if err := p.engine.Ping(); err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to ping store: %v", err))
+	}
+
+	return nil
+}
+
+// GetFeedStatus returns the feed along with its health, which is empty until the first fetch
+func (p BloggerProc) GetFeedStatus(ctx context.Context, id string) (*store.FeedStatus, error) {
+	_, span := tracer.Start(ctx, "blogger.GetFeedStatus")
+	defer span.End()
+
+	feed, err := p.engine.GetFeed(id)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get feed: %w", err))
+	}
+
+	health, err := p.engine.GetFeedHealth(id)
+	if errors.Is(err, store.ErrNotFound) {
+		health = &store.FeedHealthV1{FeedID: id}
+	} else if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get feed health: %v", err))
+	}
+
+	return &store.FeedStatus{Feed: feed, Health: health}, nil
+}
+
+// GetFeedStatuses returns all feeds along with their health
+func (p BloggerProc) GetFeedStatuses(ctx context.Context) ([]*store.FeedStatus, error) {
+	_, span := tracer.Start(ctx, "blogger.GetFeedStatuses")
+	defer span.End()
+
+	feeds, err := p.engine.GetFeeds()
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get feeds: %v", err))
+	}
+
+	healths, err := p.engine.GetFeedsHealth()
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get feeds health: %v", err))
+	}
+
+	healthMap := make(map[string]*store.FeedHealthV1, len(healths))
+	for _, health := range healths {
+		healthMap[health.FeedID] = health
+	}
+
+	statuses := make([]*store.FeedStatus, 0, len(feeds))
+	for _, feed := range feeds {
+		health, ok := healthMap[feed.ID]
+		if !ok {
+			health = &store.FeedHealthV1{FeedID: feed.ID}
+		}
+		statuses = append(statuses, &store.FeedStatus{Feed: feed, Health: health})
+	}
+
+	return statuses, nil
+}
+
+// EnableFeed puts a disabled feed back on schedule and resets its failures
+func (p BloggerProc) EnableFeed(ctx context.Context, id string) (*store.FeedStatus, error) {
+	ctx, span := tracer.Start(ctx, "blogger.EnableFeed")
+	defer span.End()
+
+	status, err := p.GetFeedStatus(ctx, id)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+
+	status.Feed.Disabled = false
+	status.Feed.DisabledAt = nil
+	status.Feed.NextFetchAt = time.Now().UTC()
+	if err := p.engine.SaveFeed(status.Feed); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to save feed: %v", err))
+	}
+
+	status.Health.ConsecutiveFailures = 0
+	if err := p.engine.SaveFeedHealth(status.Health); err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to save feed health: %v", err))
+	}
+
+	return status, nil
+}
+
+// GetSimilarPosts ranks stored posts by cosine similarity to the given post
+func (p BloggerProc) GetSimilarPosts(ctx context.Context, id string, limit int) ([]*store.ScoredPost, error) {
+	_, span := tracer.Start(ctx, "blogger.GetSimilarPosts")
+	defer span.End()
+
+	embedding, err := p.engine.GetPostEmbedding(id)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to get post embedding: %w", err))
+	}
+
+	results, err := p.searchPosts(embedding.Model, embedding.Vector, limit, id)
+	return results, tracing.Error(span, err)
+}
+
+// SearchPosts ranks stored posts by cosine similarity to the given query vector
+func (p BloggerProc) SearchPosts(ctx context.Context, model string, vector []float64, limit int) ([]*store.ScoredPost, error) {
+	_, span := tracer.Start(ctx, "blogger.SearchPosts")
+	defer span.End()
+
+	results, err := p.searchPosts(model, vector, limit, "")
+	return results, tracing.Error(span, err)
+}
+
+func (p BloggerProc) searchPosts(model string, vector []float64, limit int, excludeID string) ([]*store.ScoredPost, error) {
+	embeddings, err := p.engine.GetPostEmbeddings(model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post embeddings: %v", err)
+	}
+
+	type scoredID struct {
+		id    string
+		score float64
+	}
+
+	scored := make([]scoredID, 0, len(embeddings))
+	for _, embedding := range embeddings {
+		if embedding.PostID == excludeID || len(embedding.Vector) != len(vector) {
+			continue
+		}
+		scored = append(scored, scoredID{id: embedding.PostID, score: cosineSimilarity(vector, embedding.Vector)})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	scored = scored[:min(len(scored), limit)]
+
+	ids := make([]string, 0, len(scored))
+	for _, s := range scored {
+		ids = append(ids, s.id)
+	}
+
+	posts, err := p.engine.GetPostsByIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %v", err)
+	}
+
+	postsByID := make(map[string]*store.PostV1, len(posts))
+	for _, post := range posts {
+		postsByID[post.ID] = post
+	}
+
+	results := make([]*store.ScoredPost, 0, len(scored))
+	for _, s := range scored {
+		post, ok := postsByID[s.id]
+		if !ok {
+			continue
+		}
+		results = append(results, &store.ScoredPost{Post: post, Score: s.score})
+	}
+
+	return results, nil
+}
+
+func cosineSimilarity(a []float64, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -1,14 +1,22 @@
 package blogger
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/rjxby/rss-sum/backend/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+// every storage backend implements Engine
+var (
+	_ Engine = (*store.Database)(nil)
+	_ Engine = (*store.Memory)(nil)
+)
+
 // Mock engine for testing
 type MockEngine struct {
 	mock.Mock
@@ -19,11 +27,266 @@ func (m *MockEngine) GetPosts(page int, pageSize int, partitionKey string) (*sto
 	return args.Get(0).(*store.PaginationPostsResult), args.Error(1)
 }
 
+func (m *MockEngine) QueryPosts(query store.PostsQuery) (*store.PaginationPostsResult, error) {
+	args := m.Called(query)
+	return args.Get(0).(*store.PaginationPostsResult), args.Error(1)
+}
+
+func (m *MockEngine) MarkPostsRead(query store.PostsQuery, until time.Time, readAt time.Time) (int64, error) {
+	args := m.Called(query, until, readAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockEngine) SavePostsBulk(postsToSave []*store.PostV1) ([]*store.PostV1, error) {
 	args := m.Called(postsToSave)
 	return args.Get(0).([]*store.PostV1), args.Error(1)
 }
 
+func (m *MockEngine) GetPostsByIDs(ids []string) ([]*store.PostV1, error) {
+	args := m.Called(ids)
+	return args.Get(0).([]*store.PostV1), args.Error(1)
+}
+
+func (m *MockEngine) GetPostsWithoutEmbedding(model string, limit int) ([]*store.PostV1, error) {
+	args := m.Called(model, limit)
+	return args.Get(0).([]*store.PostV1), args.Error(1)
+}
+
+func (m *MockEngine) GetPostsAfter(partitionKey string, afterID string, limit int) ([]*store.PostV1, error) {
+	args := m.Called(partitionKey, afterID, limit)
+	return args.Get(0).([]*store.PostV1), args.Error(1)
+}
+
+func (m *MockEngine) UpsertPosts(posts []*store.PostV1) (int, error) {
+	args := m.Called(posts)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockEngine) GetPartitionKeys() ([]string, error) {
+	args := m.Called()
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockEngine) GetExpiredPosts(partitionKey string, skip int, createdBefore time.Time) ([]*store.PostV1, error) {
+	args := m.Called(partitionKey, skip, createdBefore)
+	return args.Get(0).([]*store.PostV1), args.Error(1)
+}
+
+func (m *MockEngine) DeletePosts(ids []string) (int64, error) {
+	args := m.Called(ids)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockEngine) Optimize() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockEngine) GetPostEmbedding(postID string) (*store.PostEmbeddingV1, error) {
+	args := m.Called(postID)
+	return args.Get(0).(*store.PostEmbeddingV1), args.Error(1)
+}
+
+func (m *MockEngine) GetPostEmbeddings(model string) ([]*store.PostEmbeddingV1, error) {
+	args := m.Called(model)
+	return args.Get(0).([]*store.PostEmbeddingV1), args.Error(1)
+}
+
+func (m *MockEngine) SavePostEmbedding(embedding *store.PostEmbeddingV1) error {
+	args := m.Called(embedding)
+	return args.Error(0)
+}
+
+func (m *MockEngine) GetFeeds() ([]*store.FeedV1, error) {
+	args := m.Called()
+	return args.Get(0).([]*store.FeedV1), args.Error(1)
+}
+
+func (m *MockEngine) SaveFeed(feed *store.FeedV1) error {
+	args := m.Called(feed)
+	return args.Error(0)
+}
+
+func (m *MockEngine) GetFeed(id string) (*store.FeedV1, error) {
+	args := m.Called(id)
+	return args.Get(0).(*store.FeedV1), args.Error(1)
+}
+
+func (m *MockEngine) GetFeedHealth(feedID string) (*store.FeedHealthV1, error) {
+	args := m.Called(feedID)
+	return args.Get(0).(*store.FeedHealthV1), args.Error(1)
+}
+
+func (m *MockEngine) GetFeedsHealth() ([]*store.FeedHealthV1, error) {
+	args := m.Called()
+	return args.Get(0).([]*store.FeedHealthV1), args.Error(1)
+}
+
+func (m *MockEngine) SaveFeedHealth(health *store.FeedHealthV1) error {
+	args := m.Called(health)
+	return args.Error(0)
+}
+
+func (m *MockEngine) ResetFeedItemsSummarized(feedID string) error {
+	args := m.Called(feedID)
+	return args.Error(0)
+}
+
+func (m *MockEngine) SaveFeedRun(health *store.FeedHealthV1) error {
+	args := m.Called(health)
+	return args.Error(0)
+}
+
+func (m *MockEngine) IncrementFeedItemsSummarized(feedID string) error {
+	args := m.Called(feedID)
+	return args.Error(0)
+}
+
+func (m *MockEngine) EnqueueJobs(jobs []*store.JobV1) (int, error) {
+	args := m.Called(jobs)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockEngine) LeaseJobs(now time.Time, lease time.Duration, limit int) ([]*store.JobV1, error) {
+	args := m.Called(now, lease, limit)
+	return args.Get(0).([]*store.JobV1), args.Error(1)
+}
+
+func (m *MockEngine) GetJob(id uint64) (*store.JobV1, error) {
+	args := m.Called(id)
+	return args.Get(0).(*store.JobV1), args.Error(1)
+}
+
+func (m *MockEngine) GetJobsByStatus(status string) ([]*store.JobV1, error) {
+	args := m.Called(status)
+	return args.Get(0).([]*store.JobV1), args.Error(1)
+}
+
+func (m *MockEngine) SaveJob(job *store.JobV1) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockEngine) DeleteJob(id uint64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockEngine) CreateUser(user *store.UserV1) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockEngine) GetUser(id uint64) (*store.UserV1, error) {
+	args := m.Called(id)
+	return args.Get(0).(*store.UserV1), args.Error(1)
+}
+
+func (m *MockEngine) GetUserByName(name string) (*store.UserV1, error) {
+	args := m.Called(name)
+	return args.Get(0).(*store.UserV1), args.Error(1)
+}
+
+func (m *MockEngine) GetUsers() ([]*store.UserV1, error) {
+	args := m.Called()
+	return args.Get(0).([]*store.UserV1), args.Error(1)
+}
+
+func (m *MockEngine) SaveUser(user *store.UserV1) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockEngine) DeleteUser(id uint64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockEngine) SaveSession(session *store.SessionV1) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockEngine) GetSession(id string) (*store.SessionV1, error) {
+	args := m.Called(id)
+	return args.Get(0).(*store.SessionV1), args.Error(1)
+}
+
+func (m *MockEngine) DeleteSession(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockEngine) DeleteExpiredSessions(now time.Time) (int64, error) {
+	args := m.Called(now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockEngine) SaveAPIToken(token *store.APITokenV1) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockEngine) GetAPITokenByHash(hash string) (*store.APITokenV1, error) {
+	args := m.Called(hash)
+	return args.Get(0).(*store.APITokenV1), args.Error(1)
+}
+
+func (m *MockEngine) GetAPITokens(userID uint64) ([]*store.APITokenV1, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]*store.APITokenV1), args.Error(1)
+}
+
+func (m *MockEngine) DeleteAPIToken(id uint64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockEngine) SaveSubscription(subscription *store.SubscriptionV1) error {
+	args := m.Called(subscription)
+	return args.Error(0)
+}
+
+func (m *MockEngine) DeleteSubscription(userID uint64, feedID string) error {
+	args := m.Called(userID, feedID)
+	return args.Error(0)
+}
+
+func (m *MockEngine) GetSubscriptions(userID uint64) ([]*store.SubscriptionV1, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]*store.SubscriptionV1), args.Error(1)
+}
+
+func (m *MockEngine) SavePostState(state *store.PostStateV1) error {
+	args := m.Called(state)
+	return args.Error(0)
+}
+
+func (m *MockEngine) GetPostStates(userID uint64, postIDs []string) ([]*store.PostStateV1, error) {
+	args := m.Called(userID, postIDs)
+	return args.Get(0).([]*store.PostStateV1), args.Error(1)
+}
+
+func (m *MockEngine) GetPostsWithoutScores(profiles []string, limit int) ([]*store.PostV1, error) {
+	args := m.Called(profiles, limit)
+	return args.Get(0).([]*store.PostV1), args.Error(1)
+}
+
+func (m *MockEngine) GetPostScores(postIDs []string, profiles []string) ([]*store.PostScoreV1, error) {
+	args := m.Called(postIDs, profiles)
+	return args.Get(0).([]*store.PostScoreV1), args.Error(1)
+}
+
+func (m *MockEngine) SavePostScores(scores []*store.PostScoreV1) error {
+	args := m.Called(scores)
+	return args.Error(0)
+}
+
+func (m *MockEngine) Ping() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func TestGetPosts(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		// Setup
@@ -40,7 +303,7 @@ func TestGetPosts(t *testing.T) {
 		blogger := New(mockEngine)
 
 		// Execute
-		result, err := blogger.GetPosts(1, 10, "test-key")
+		result, err := blogger.GetPosts(context.Background(), 1, 10, "test-key")
 
 		// Verify
 		assert.NoError(t, err)
@@ -56,7 +319,7 @@ func TestGetPosts(t *testing.T) {
 		blogger := New(mockEngine)
 
 		// Execute
-		result, err := blogger.GetPosts(1, 10, "test-key")
+		result, err := blogger.GetPosts(context.Background(), 1, 10, "test-key")
 
 		// Verify
 		assert.Error(t, err)
@@ -78,7 +341,7 @@ func TestSavePostsBulk(t *testing.T) {
 		blogger := New(mockEngine)
 
 		// Execute
-		result, err := blogger.SavePostsBulk(posts)
+		result, err := blogger.SavePostsBulk(context.Background(), posts)
 
 		// Verify
 		assert.NoError(t, err)
@@ -98,7 +361,7 @@ func TestSavePostsBulk(t *testing.T) {
 		blogger := New(mockEngine)
 
 		// Execute
-		result, err := blogger.SavePostsBulk(posts)
+		result, err := blogger.SavePostsBulk(context.Background(), posts)
 
 		// Verify
 		assert.Error(t, err)
@@ -107,3 +370,474 @@ func TestSavePostsBulk(t *testing.T) {
 		mockEngine.AssertExpectations(t)
 	})
 }
+
+func TestCosineSimilarity(t *testing.T) {
+	tbl := []struct {
+		name     string
+		a        []float64
+		b        []float64
+		expected float64
+	}{
+		{"Identical", []float64{1, 2, 3}, []float64{1, 2, 3}, 1},
+		{"Opposite", []float64{1, 0}, []float64{-1, 0}, -1},
+		{"Orthogonal", []float64{1, 0}, []float64{0, 1}, 0},
+		{"ZeroVector", []float64{0, 0}, []float64{1, 1}, 0},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, cosineSimilarity(tt.a, tt.b), 1e-9)
+		})
+	}
+}
+
+func TestGetSimilarPosts(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetPostEmbedding", "1").Return(&store.PostEmbeddingV1{PostID: "1", Model: "m", Vector: []float64{1, 0}}, nil)
+		mockEngine.On("GetPostEmbeddings", "m").Return([]*store.PostEmbeddingV1{
+			{PostID: "1", Model: "m", Vector: []float64{1, 0}},
+			{PostID: "2", Model: "m", Vector: []float64{0, 1}},
+			{PostID: "3", Model: "m", Vector: []float64{1, 1}},
+			{PostID: "4", Model: "m", Vector: []float64{1, 1, 1}},
+		}, nil)
+		mockEngine.On("GetPostsByIDs", []string{"3", "2"}).Return([]*store.PostV1{
+			{ID: "2", Title: "Post 2"},
+			{ID: "3", Title: "Post 3"},
+		}, nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.GetSimilarPosts(context.Background(), "1", 10)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(result))
+		assert.Equal(t, "3", result[0].Post.ID)
+		assert.Equal(t, "2", result[1].Post.ID)
+		assert.Greater(t, result[0].Score, result[1].Score)
+		mockEngine.AssertExpectations(t)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetPostEmbeddings", "m").Return([]*store.PostEmbeddingV1{
+			{PostID: "1", Model: "m", Vector: []float64{1, 0}},
+			{PostID: "2", Model: "m", Vector: []float64{0, 1}},
+		}, nil)
+		mockEngine.On("GetPostsByIDs", []string{"2"}).Return([]*store.PostV1{{ID: "2"}}, nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.SearchPosts(context.Background(), "m", []float64{0, 1}, 1)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(result))
+		assert.Equal(t, "2", result[0].Post.ID)
+		mockEngine.AssertExpectations(t)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetPostEmbedding", "1").Return((*store.PostEmbeddingV1)(nil), store.ErrNotFound)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.GetSimilarPosts(context.Background(), "1", 10)
+
+		// Verify
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, result)
+		mockEngine.AssertExpectations(t)
+	})
+}
+
+func TestGetFeedStatuses(t *testing.T) {
+	// Setup
+	mockEngine := new(MockEngine)
+	mockEngine.On("GetFeeds").Return([]*store.FeedV1{
+		{ID: "hash-1", URL: "http://example.com/feed1"},
+		{ID: "hash-2", URL: "http://example.com/feed2"},
+	}, nil)
+	mockEngine.On("GetFeedsHealth").Return([]*store.FeedHealthV1{
+		{FeedID: "hash-2", ConsecutiveFailures: 3},
+	}, nil)
+	blogger := New(mockEngine)
+
+	// Execute
+	result, err := blogger.GetFeedStatuses(context.Background())
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "hash-1", result[0].Health.FeedID)
+	assert.Equal(t, 0, result[0].Health.ConsecutiveFailures)
+	assert.Equal(t, 3, result[1].Health.ConsecutiveFailures)
+	mockEngine.AssertExpectations(t)
+}
+
+func TestGetTimeline(t *testing.T) {
+	subscriptions := []*store.SubscriptionV1{{UserID: 1, FeedID: "hash-1"}, {UserID: 1, FeedID: "hash-2"}}
+
+	tbl := []struct {
+		name          string
+		userID        uint64
+		partitionKey  string
+		partitionKeys []string
+	}{
+		{"AllSubscribed", 1, "", []string{store.SavedLinksPartitionKey, "hash-1", "hash-2"}},
+		{"SingleFeed", 1, "hash-2", []string{"hash-2"}},
+		{"SavedLinks", 1, store.SavedLinksPartitionKey, []string{store.SavedLinksPartitionKey}},
+		{"Anonymous", store.AnonymousUserID, "", nil},
+		{"AnonymousSingleFeed", store.AnonymousUserID, "hash-3", []string{"hash-3"}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockEngine := new(MockEngine)
+			mockEngine.On("GetSubscriptions", uint64(1)).Return(subscriptions, nil)
+			mockEngine.On("QueryPosts", store.PostsQuery{
+				UserID:        tt.userID,
+				PartitionKeys: tt.partitionKeys,
+				Unread:        true,
+				Page:          2,
+				PageSize:      10,
+			}).Return(&store.PaginationPostsResult{
+				Posts:    []*store.PostV1{{ID: "1"}},
+				Page:     2,
+				PageSize: 10,
+				Size:     11,
+			}, nil)
+			blogger := New(mockEngine)
+
+			// Execute
+			result, err := blogger.GetTimeline(context.Background(), store.PostsQuery{UserID: tt.userID, Unread: true, Page: 2, PageSize: 10}, tt.partitionKey)
+
+			// Verify
+			assert.NoError(t, err)
+			assert.Equal(t, tt.partitionKey, result.PartitionKey)
+			assert.Equal(t, int64(11), result.Size)
+		})
+	}
+
+	t.Run("NotSubscribed", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetSubscriptions", uint64(1)).Return(subscriptions, nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.GetTimeline(context.Background(), store.PostsQuery{UserID: 1, Page: 1, PageSize: 10}, "hash-3")
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Empty(t, result.Posts)
+		assert.Zero(t, result.Size)
+		mockEngine.AssertNotCalled(t, "QueryPosts", mock.Anything)
+	})
+}
+
+func TestMarkPostsRead(t *testing.T) {
+	// Setup
+	until := time.Now().Add(-time.Minute)
+	mockEngine := new(MockEngine)
+	mockEngine.On("GetSubscriptions", uint64(1)).Return([]*store.SubscriptionV1{{UserID: 1, FeedID: "hash-1"}}, nil)
+	mockEngine.On("MarkPostsRead", store.PostsQuery{UserID: 1, PartitionKeys: []string{"hash-1"}}, until, mock.AnythingOfType("time.Time")).Return(int64(3), nil)
+	blogger := New(mockEngine)
+
+	// Execute
+	marked, err := blogger.MarkPostsRead(context.Background(), store.PostsQuery{UserID: 1}, "hash-1", until)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), marked)
+	mockEngine.AssertExpectations(t)
+}
+
+func TestSetPostState(t *testing.T) {
+	t.Run("KeepsOtherFlags", func(t *testing.T) {
+		// Setup
+		readAt := time.Now().Add(-time.Hour)
+		state := &store.PostStateV1{UserID: 1, PostID: "1", ReadAt: &readAt}
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetPostsByIDs", []string{"1"}).Return([]*store.PostV1{{ID: "1"}}, nil)
+		mockEngine.On("GetPostStates", uint64(1), []string{"1"}).Return([]*store.PostStateV1{state}, nil)
+		mockEngine.On("SavePostState", state).Return(nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.SetPostState(context.Background(), 1, "1", store.PostFlagStarred, true)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, &readAt, result.ReadAt)
+		assert.NotNil(t, result.StarredAt)
+		assert.Nil(t, result.ReadLaterAt)
+		mockEngine.AssertExpectations(t)
+	})
+
+	t.Run("UnknownPost", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetPostsByIDs", []string{"404"}).Return([]*store.PostV1{}, nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		_, err := blogger.SetPostState(context.Background(), 1, "404", store.PostFlagRead, true)
+
+		// Verify
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		mockEngine.AssertNotCalled(t, "SavePostState", mock.Anything)
+	})
+}
+
+func TestSavePostScores(t *testing.T) {
+	// Setup
+	mockEngine := new(MockEngine)
+	mockEngine.On("SavePostScores", []*store.PostScoreV1{{PostID: "1", Profile: "go", Score: 90, Model: "llama3:8b"}}).Return(nil)
+	blogger := New(mockEngine)
+
+	// Execute
+	err := blogger.SavePostScores(context.Background(), "1", "llama3:8b", map[string]int{"go": 90})
+
+	// Verify
+	assert.NoError(t, err)
+	mockEngine.AssertExpectations(t)
+}
+
+func TestGetPostScores(t *testing.T) {
+	// Setup
+	mockEngine := new(MockEngine)
+	mockEngine.On("GetPostScores", []string{"1", "2", "3"}, []string{"go", "rust"}).Return([]*store.PostScoreV1{
+		{PostID: "1", Profile: "go", Score: 90},
+		{PostID: "1", Profile: "rust", Score: 10},
+		{PostID: "3", Profile: "go", Score: 50},
+	}, nil)
+	blogger := New(mockEngine)
+
+	// Execute
+	scores, err := blogger.GetPostScores(context.Background(), []string{"1", "2", "3"}, []string{"go", "rust"})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Len(t, scores, 2)
+	assert.Len(t, scores["1"], 2)
+	assert.Equal(t, 50, scores["3"][0].Score)
+	assert.NotContains(t, scores, "2")
+	mockEngine.AssertExpectations(t)
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		feed := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1"}
+		mockEngine.On("GetFeed", "hash-1").Return(feed, nil)
+		mockEngine.On("SaveSubscription", &store.SubscriptionV1{UserID: 1, FeedID: "hash-1"}).Return(nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.Subscribe(context.Background(), 1, "hash-1")
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, feed, result)
+		mockEngine.AssertExpectations(t)
+	})
+
+	t.Run("UnknownFeed", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetFeed", "hash-3").Return((*store.FeedV1)(nil), store.ErrNotFound)
+		blogger := New(mockEngine)
+
+		// Execute
+		_, err := blogger.Subscribe(context.Background(), 1, "hash-3")
+
+		// Verify
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		mockEngine.AssertNotCalled(t, "SaveSubscription", mock.Anything)
+	})
+}
+
+func TestGetSubscribedFeeds(t *testing.T) {
+	// Setup
+	mockEngine := new(MockEngine)
+	feed := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1"}
+	mockEngine.On("GetSubscriptions", uint64(1)).Return([]*store.SubscriptionV1{
+		{UserID: 1, FeedID: "hash-1"},
+		{UserID: 1, FeedID: "removed"},
+	}, nil)
+	mockEngine.On("GetFeed", "hash-1").Return(feed, nil)
+	mockEngine.On("GetFeed", "removed").Return((*store.FeedV1)(nil), store.ErrNotFound)
+	blogger := New(mockEngine)
+
+	// Execute
+	result, err := blogger.GetSubscribedFeeds(context.Background(), 1)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, []*store.FeedV1{feed}, result)
+	mockEngine.AssertExpectations(t)
+}
+
+func TestEnableFeed(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		disabledAt := time.Now().Add(-time.Hour)
+		feed := &store.FeedV1{ID: "hash-1", Disabled: true, DisabledAt: &disabledAt, NextFetchAt: time.Now().Add(time.Hour)}
+		health := &store.FeedHealthV1{FeedID: "hash-1", ConsecutiveFailures: 10, LastError: "404 Not Found"}
+		mockEngine.On("GetFeed", "hash-1").Return(feed, nil)
+		mockEngine.On("GetFeedHealth", "hash-1").Return(health, nil)
+		mockEngine.On("SaveFeed", feed).Return(nil)
+		mockEngine.On("SaveFeedHealth", health).Return(nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.EnableFeed(context.Background(), "hash-1")
+
+		// Verify
+		assert.NoError(t, err)
+		assert.False(t, result.Feed.Disabled)
+		assert.Nil(t, result.Feed.DisabledAt)
+		assert.WithinDuration(t, time.Now(), result.Feed.NextFetchAt, time.Second)
+		assert.Equal(t, 0, result.Health.ConsecutiveFailures)
+		assert.Equal(t, "404 Not Found", result.Health.LastError)
+		mockEngine.AssertExpectations(t)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetFeed", "unknown").Return((*store.FeedV1)(nil), store.ErrNotFound)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.EnableFeed(context.Background(), "unknown")
+
+		// Verify
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, result)
+		mockEngine.AssertNotCalled(t, "SaveFeed", mock.Anything)
+	})
+}
+
+func TestEnqueueJobs(t *testing.T) {
+	// Setup
+	mockEngine := new(MockEngine)
+	jobs := []*store.JobV1{{Kind: store.JobKindSaveLink, DedupKey: "save_link:http://example.com"}}
+	mockEngine.On("EnqueueJobs", jobs).Return(1, nil)
+	blogger := New(mockEngine)
+
+	// Execute
+	created, err := blogger.EnqueueJobs(context.Background(), jobs)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	assert.Equal(t, store.JobStatusPending, jobs[0].Status)
+	assert.WithinDuration(t, time.Now(), jobs[0].RunAt, time.Second)
+	mockEngine.AssertExpectations(t)
+}
+
+func TestRequeueJob(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		job := &store.JobV1{ID: 7, Status: store.JobStatusDead, Attempts: 5, LastError: "assistant is down"}
+		mockEngine.On("GetJob", uint64(7)).Return(job, nil)
+		mockEngine.On("SaveJob", job).Return(nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.RequeueJob(context.Background(), 7)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, store.JobStatusPending, result.Status)
+		assert.Equal(t, 0, result.Attempts)
+		assert.Equal(t, "assistant is down", result.LastError)
+		assert.WithinDuration(t, time.Now(), result.RunAt, time.Second)
+		mockEngine.AssertExpectations(t)
+	})
+
+	t.Run("NotDead", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetJob", uint64(7)).Return(&store.JobV1{ID: 7, Status: store.JobStatusPending}, nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.RequeueJob(context.Background(), 7)
+
+		// Verify
+		assert.ErrorIs(t, err, ErrJobNotDead)
+		assert.Nil(t, result)
+		mockEngine.AssertNotCalled(t, "SaveJob", mock.Anything)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("GetJob", uint64(7)).Return((*store.JobV1)(nil), store.ErrNotFound)
+		blogger := New(mockEngine)
+
+		// Execute
+		result, err := blogger.RequeueJob(context.Background(), 7)
+
+		// Verify
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, result)
+	})
+}
+
+func TestDeletePosts(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("DeletePosts", []string{"1", "2"}).Return(int64(2), nil)
+		blogger := New(mockEngine)
+
+		// Execute
+		deleted, err := blogger.DeletePosts(context.Background(), []string{"1", "2"})
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+		mockEngine.AssertExpectations(t)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		blogger := New(mockEngine)
+
+		// Execute
+		deleted, err := blogger.DeletePosts(context.Background(), nil)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Zero(t, deleted)
+		mockEngine.AssertNotCalled(t, "DeletePosts", mock.Anything)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		// Setup
+		mockEngine := new(MockEngine)
+		mockEngine.On("DeletePosts", []string{"1"}).Return(int64(0), errors.New("database is locked"))
+		blogger := New(mockEngine)
+
+		// Execute
+		deleted, err := blogger.DeletePosts(context.Background(), []string{"1"})
+
+		// Verify
+		assert.EqualError(t, err, "failed to delete posts: database is locked")
+		assert.Zero(t, deleted)
+	})
+}
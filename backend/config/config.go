@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rjxby/rss-sum/backend/assistant"
+	"github.com/rjxby/rss-sum/backend/auth"
+	"github.com/rjxby/rss-sum/backend/backup"
+	"github.com/rjxby/rss-sum/backend/logging"
+	"github.com/rjxby/rss-sum/backend/retention"
+	"github.com/rjxby/rss-sum/backend/rss/worker"
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/tracing"
+)
+
+// Config gathers the settings of all components
+type Config struct {
+	RunMigration bool               `yaml:"run_migration"`
+	Database     store.Settings     `yaml:"database"`
+	Log          logging.Settings   `yaml:"log"`
+	Tracing      tracing.Settings   `yaml:"tracing"`
+	Worker       worker.Settings    `yaml:"worker"`
+	Assistant    assistant.Settings `yaml:"assistant"`
+	Retention    retention.Settings `yaml:"retention"`
+	Backup       backup.Settings    `yaml:"backup"`
+	Auth         auth.Settings      `yaml:"auth"`
+}
+
+// Path returns the configuration file given by CONFIG_FILE, empty when only environment variables are used
+func Path() string {
+	return os.Getenv("CONFIG_FILE")
+}
+
+// Default returns the configuration used for the values which are not configured
+func Default() Config {
+	return Config{
+		Database:  store.DefaultSettings(),
+		Log:       logging.DefaultSettings(),
+		Tracing:   tracing.DefaultSettings(),
+		Worker:    worker.DefaultSettings(),
+		Assistant: assistant.DefaultSettings(),
+		Retention: retention.DefaultSettings(),
+		Backup:    backup.DefaultSettings(),
+		Auth:      auth.DefaultSettings(),
+	}
+}
+
+// Load reads the configuration file, when path is not empty, and overrides it with the environment variables
+// which are set. All invalid values are reported at once
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	envErr := cfg.parseEnv()
+	cfg.Log.Format = strings.ToLower(cfg.Log.Format)
+	cfg.Tracing.Exporter = strings.ToLower(cfg.Tracing.Exporter)
+
+	if err := errors.Join(envErr, cfg.Validate()); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// Validate reports every invalid setting, prefixed with its section
+func (c Config) Validate() error {
+	sections := []struct {
+		name string
+		err  error
+	}{
+		{"database", c.Database.Validate()},
+		{"log", c.Log.Validate()},
+		{"tracing", c.Tracing.Validate()},
+		{"worker", c.Worker.Validate()},
+		{"assistant", c.Assistant.Validate()},
+		{"retention", c.Retention.Validate()},
+		{"backup", c.Backup.Validate()},
+		{"auth", c.Auth.Validate()},
+	}
+
+	var errs []error
+	for _, section := range sections {
+		for _, err := range unwrapJoined(section.err) {
+			errs = append(errs, fmt.Errorf("%s: %v", section.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	// unknown keys are rejected, a typo should not silently fall back to the default
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// parseEnv overrides the configuration with the environment variables which are set
+func (c *Config) parseEnv() error {
+	env := envParser{}
+
+	env.bool("RUN_MIGRATION", &c.RunMigration)
+	env.string("DATABASE_DSN", &c.Database.DSN)
+
+	env.level("LOG_LEVEL", &c.Log.Level)
+	env.string("LOG_FORMAT", &c.Log.Format)
+
+	env.string("TRACING_EXPORTER", &c.Tracing.Exporter)
+	env.float("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+
+	env.list("FEEDS", &c.Worker.RSSFeedsURLs)
+	env.int("FEED_ITEMS_LIMIT", &c.Worker.RSSFeedLimit)
+	env.int("WORKER_INTERVAL_IN_SECONDS", &c.Worker.WorkerIntervalInSeconds)
+	env.int("WORKER_MIN_INTERVAL_IN_SECONDS", &c.Worker.WorkerMinIntervalInSeconds)
+	env.int("WORKER_MAX_INTERVAL_IN_SECONDS", &c.Worker.WorkerMaxIntervalInSeconds)
+	env.int("WORKER_TIMEOUT_IN_SECONDS", &c.Worker.WorkerTimeoutInSeconds)
+	env.int("FEED_MAX_CONSECUTIVE_FAILURES", &c.Worker.FeedMaxConsecutiveFailures)
+	env.int("JOB_WORKERS", &c.Worker.JobWorkers)
+	env.int("JOB_MAX_ATTEMPTS", &c.Worker.JobMaxAttempts)
+	env.int("JOB_LEASE_IN_SECONDS", &c.Worker.JobLeaseInSeconds)
+
+	env.string("OLLAMA_HOST", &c.Assistant.OllamaHost)
+	env.string("OLLAMA_PORT", &c.Assistant.OllamaPort)
+	env.string("OLLAMA_SCHEME", &c.Assistant.OllamaScheme)
+	env.string("OLLAMA_MODEL", &c.Assistant.OllamaModel)
+	env.string("OLLAMA_EMBEDDING_MODEL", &c.Assistant.OllamaEmbeddingModel)
+	env.int("OLLAMA_TIMEOUT_IN_SECONDS", &c.Assistant.RequestTimeoutInSeconds)
+	env.string("PROMPT_SYSTEM", &c.Assistant.Prompts.System)
+	env.string("PROMPT_SUMMARY", &c.Assistant.Prompts.Summary)
+	env.string("PROMPT_ANSWER", &c.Assistant.Prompts.Answer)
+
+	env.int("RETENTION_INTERVAL_IN_SECONDS", &c.Retention.IntervalInSeconds)
+	env.int("RETENTION_KEEP_DAYS", &c.Retention.KeepDays)
+	env.int("RETENTION_KEEP_POSTS", &c.Retention.KeepPosts)
+	env.bool("RETENTION_OPTIMIZE", &c.Retention.Optimize)
+
+	env.string("BACKUP_DIR", &c.Backup.Dir)
+	env.int("BACKUP_INTERVAL_IN_SECONDS", &c.Backup.IntervalInSeconds)
+	env.int("BACKUP_KEEP", &c.Backup.Keep)
+	env.bool("BACKUP_COMPRESS", &c.Backup.Compress)
+
+	env.bool("AUTH_ENABLED", &c.Auth.Enabled)
+	env.int("AUTH_SESSION_TTL_IN_SECONDS", &c.Auth.SessionTTLInSeconds)
+
+	return errors.Join(env.errs...)
+}
+
+func unwrapJoined(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
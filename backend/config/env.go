@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envParser collects the errors of all malformed environment variables, unset or empty variables are skipped
+type envParser struct {
+	errs []error
+}
+
+func (p *envParser) lookup(name string) (string, bool) {
+	value := strings.TrimSpace(os.Getenv(name))
+	return value, value != ""
+}
+
+func (p *envParser) string(name string, target *string) {
+	if value, ok := p.lookup(name); ok {
+		*target = value
+	}
+}
+
+func (p *envParser) list(name string, target *[]string) {
+	value, ok := p.lookup(name)
+	if !ok {
+		return
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*target = items
+}
+
+func (p *envParser) int(name string, target *int) {
+	value, ok := p.lookup(name)
+	if !ok {
+		return
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("failed to parse %s environment variable: %q is not an integer", name, value))
+		return
+	}
+	*target = parsed
+}
+
+func (p *envParser) float(name string, target *float64) {
+	value, ok := p.lookup(name)
+	if !ok {
+		return
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("failed to parse %s environment variable: %q is not a number", name, value))
+		return
+	}
+	*target = parsed
+}
+
+func (p *envParser) bool(name string, target *bool) {
+	value, ok := p.lookup(name)
+	if !ok {
+		return
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("failed to parse %s environment variable: %q is not a boolean", name, value))
+		return
+	}
+	*target = parsed
+}
+
+func (p *envParser) level(name string, target *slog.Level) {
+	value, ok := p.lookup(name)
+	if !ok {
+		return
+	}
+
+	if err := target.UnmarshalText([]byte(value)); err != nil {
+		p.errs = append(p.errs, fmt.Errorf("failed to parse %s environment variable: %v", name, err))
+	}
+}
@@ -0,0 +1,445 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+const (
+	FormatNDJSON = "ndjson"
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+)
+
+// batchSize is the number of posts loaded or upserted at once
+const batchSize = 500
+
+// maxLineLength fits the longest record of an NDJSON file
+const maxLineLength = 1 << 20
+
+// Column limits of store.PostV1
+const (
+	maxTitleLength = 500
+	maxTextLength  = 4000
+)
+
+// ErrUnknownFormat is returned for a format other than ndjson, json and csv
+var ErrUnknownFormat = errors.New("unknown format, expected ndjson, json or csv")
+
+// csvHeader lists the columns of CSV files, imports match columns by name
+var csvHeader = []string{"id", "partitionKey", "feed", "title", "text", "url", "model", "tags", "createdAt"}
+
+// Record is a post in a portable form, Text is its summary
+type Record struct {
+	ID           string    `json:"id"`
+	PartitionKey string    `json:"partitionKey"`
+	Feed         string    `json:"feed,omitempty"` // URL of the feed, empty for saved links and removed feeds
+	Title        string    `json:"title"`
+	Text         string    `json:"text"`
+	URL          string    `json:"url"`
+	Model        string    `json:"model,omitempty"` // model which summarized the post
+	Tags         string    `json:"tags,omitempty"`  // comma separated tags of the feed filters
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Filter selects the exported posts, zero values select all of them
+type Filter struct {
+	PartitionKey string
+	Since        time.Time
+}
+
+// ImportResult counts the imported posts
+type ImportResult struct {
+	Read    int
+	Created int
+	Updated int
+}
+
+// Blogger defines an interface to load and save posts
+type Blogger interface {
+	GetFeeds(ctx context.Context) ([]*store.FeedV1, error)
+	GetPostsAfter(ctx context.Context, partitionKey string, afterID string, limit int) ([]*store.PostV1, error)
+	UpsertPosts(ctx context.Context, posts []*store.PostV1) (int, error)
+}
+
+// Exporter writes posts to and reads posts from NDJSON, JSON and CSV files
+type Exporter struct {
+	Blogger Blogger
+}
+
+// ParseFormat validates a format, empty selects NDJSON
+func ParseFormat(format string) (string, error) {
+	switch format = strings.ToLower(format); format {
+	case "":
+		return FormatNDJSON, nil
+	case FormatNDJSON, FormatJSON, FormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// FormatOf returns the format of a file by its extension, NDJSON for unknown ones
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".csv":
+		return FormatCSV
+	default:
+		return FormatNDJSON
+	}
+}
+
+// ContentType returns the media type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatJSON:
+		return "application/json"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// Export writes the posts selected by the filter ordered by ID and returns how many were written
+func (e *Exporter) Export(ctx context.Context, w io.Writer, format string, filter Filter) (int, error) {
+	format, err := ParseFormat(format)
+	if err != nil {
+		return 0, err
+	}
+
+	feeds, err := e.Blogger.GetFeeds(ctx)
+	if err != nil {
+		return 0, err
+	}
+	feedURLs := make(map[string]string, len(feeds))
+	for _, feed := range feeds {
+		feedURLs[feed.ID] = feed.URL
+	}
+
+	encoder := newEncoder(w, format)
+	written := 0
+	afterID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		posts, err := e.Blogger.GetPostsAfter(ctx, filter.PartitionKey, afterID, batchSize)
+		if err != nil {
+			return written, err
+		}
+		if len(posts) == 0 {
+			break
+		}
+		afterID = posts[len(posts)-1].ID
+
+		for _, post := range posts {
+			if post.CreatedAt.Before(filter.Since) {
+				continue
+			}
+			if err := encoder.Encode(newRecord(post, feedURLs[post.PartitionKey])); err != nil {
+				return written, fmt.Errorf("failed to write post %s: %v", post.ID, err)
+			}
+			written++
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return written, fmt.Errorf("failed to write posts: %v", err)
+	}
+
+	return written, nil
+}
+
+// Import upserts the posts of a file, keeping their IDs and partition keys. Every record is validated
+// before anything is saved
+func (e *Exporter) Import(ctx context.Context, r io.Reader, format string) (*ImportResult, error) {
+	format, err := ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := decode(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]*store.PostV1, 0, len(records))
+	for i, record := range records {
+		if err := record.validate(); err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+		posts = append(posts, record.post())
+	}
+
+	result := &ImportResult{Read: len(posts)}
+	for start := 0; start < len(posts); start += batchSize {
+		created, err := e.Blogger.UpsertPosts(ctx, posts[start:min(start+batchSize, len(posts))])
+		if err != nil {
+			return nil, err
+		}
+		result.Created += created
+	}
+	result.Updated = result.Read - result.Created
+
+	return result, nil
+}
+
+func newRecord(post *store.PostV1, feedURL string) *Record {
+	return &Record{
+		ID:           post.ID,
+		PartitionKey: post.PartitionKey,
+		Feed:         feedURL,
+		Title:        post.Title,
+		Text:         post.Text,
+		URL:          post.SourceURL,
+		Model:        post.Model,
+		Tags:         post.Tags,
+		CreatedAt:    post.CreatedAt.UTC(),
+	}
+}
+
+func (r *Record) post() *store.PostV1 {
+	return &store.PostV1{
+		ID:           r.ID,
+		PartitionKey: r.PartitionKey,
+		Title:        r.Title,
+		Text:         r.Text,
+		SourceURL:    r.URL,
+		Model:        r.Model,
+		Tags:         r.Tags,
+		CreatedAt:    r.CreatedAt,
+	}
+}
+
+func (r *Record) validate() error {
+	var errs []error
+
+	if r.ID == "" {
+		errs = append(errs, fmt.Errorf("id is empty"))
+	}
+	if r.PartitionKey == "" {
+		errs = append(errs, fmt.Errorf("partitionKey is empty"))
+	}
+	if r.URL == "" {
+		errs = append(errs, fmt.Errorf("url is empty"))
+	}
+	if utf8.RuneCountInString(r.Title) > maxTitleLength {
+		errs = append(errs, fmt.Errorf("title is longer than %d characters", maxTitleLength))
+	}
+	if utf8.RuneCountInString(r.Text) > maxTextLength {
+		errs = append(errs, fmt.Errorf("text is longer than %d characters", maxTextLength))
+	}
+
+	return errors.Join(errs...)
+}
+
+// encoder writes records in one of the formats
+type encoder interface {
+	Encode(record *Record) error
+	Close() error
+}
+
+func newEncoder(w io.Writer, format string) encoder {
+	switch format {
+	case FormatJSON:
+		return &jsonEncoder{w: w}
+	case FormatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		return &ndjsonEncoder{encoder: encoder}
+	}
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(record *Record) error {
+	return e.encoder.Encode(record)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+// jsonEncoder writes an array with a record per line, without holding all of them in memory
+type jsonEncoder struct {
+	w       io.Writer
+	started bool
+}
+
+func (e *jsonEncoder) Encode(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	prefix := ",\n"
+	if !e.started {
+		prefix = "[\n"
+		e.started = true
+	}
+
+	_, err = fmt.Fprintf(e.w, "%s%s", prefix, data)
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	if !e.started {
+		_, err := io.WriteString(e.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
+
+type csvEncoder struct {
+	w       *csv.Writer
+	started bool
+}
+
+func (e *csvEncoder) Encode(record *Record) error {
+	if !e.started {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.started = true
+	}
+
+	return e.w.Write([]string{
+		record.ID,
+		record.PartitionKey,
+		record.Feed,
+		record.Title,
+		record.Text,
+		record.URL,
+		record.Model,
+		record.Tags,
+		record.CreatedAt.Format(time.RFC3339Nano),
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	if !e.started {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func decode(r io.Reader, format string) ([]*Record, error) {
+	switch format {
+	case FormatJSON:
+		return decodeJSON(r)
+	case FormatCSV:
+		return decodeCSV(r)
+	default:
+		return decodeNDJSON(r)
+	}
+}
+
+func decodeNDJSON(r io.Reader) ([]*Record, error) {
+	var records []*Record
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read posts: %v", err)
+	}
+
+	return records, nil
+}
+
+func decodeJSON(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to decode posts: %v", err)
+	}
+
+	return records, nil
+}
+
+func decodeCSV(r io.Reader) ([]*Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"id", "partitionKey", "url"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("header has no %s column", name)
+		}
+	}
+
+	var records []*Record
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read posts: %v", err)
+		}
+
+		value := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+
+		record := &Record{
+			ID:           value("id"),
+			PartitionKey: value("partitionKey"),
+			Feed:         value("feed"),
+			Title:        value("title"),
+			Text:         value("text"),
+			URL:          value("url"),
+			Model:        value("model"),
+			Tags:         value("tags"),
+		}
+		if createdAt := value("createdAt"); createdAt != "" {
+			line, _ := reader.FieldPos(0)
+			if record.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+				return nil, fmt.Errorf("line %d: createdAt is not an RFC 3339 time: %q", line, createdAt)
+			}
+		}
+		records = append(records, record)
+	}
+}
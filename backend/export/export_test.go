@@ -0,0 +1,231 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rjxby/rss-sum/backend/blogger"
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/store/storetest"
+)
+
+var createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// newTestBlogger stores a feed with two posts and a saved link
+func newTestBlogger(t *testing.T) *blogger.BloggerProc {
+	return storetest.NewBlogger(t,
+		&store.PostV1{ID: "1", PartitionKey: storetest.FeedID, Title: "First, \"quoted\"", Text: "Summary\nwith <b>lines</b>", SourceURL: "https://example.com/1", Model: "llama3:8b", Tags: "go,release", CreatedAt: createdAt},
+		&store.PostV1{ID: "2", PartitionKey: storetest.FeedID, Title: "Second", Text: "Summary", SourceURL: "https://example.com/2", CreatedAt: createdAt.Add(time.Hour)},
+		&store.PostV1{ID: "3", PartitionKey: store.SavedLinksPartitionKey, Title: "Saved", Text: "Summary", SourceURL: "https://example.com/3", CreatedAt: createdAt.Add(2 * time.Hour)},
+	)
+}
+
+func TestExportImport(t *testing.T) {
+	for _, format := range []string{FormatNDJSON, FormatJSON, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			// Setup
+			source := newTestBlogger(t)
+			target := blogger.New(store.NewMemory())
+			buf := &bytes.Buffer{}
+
+			// Execute
+			written, err := (&Exporter{Blogger: source}).Export(context.Background(), buf, format, Filter{})
+			assert.NoError(t, err)
+			result, err := (&Exporter{Blogger: target}).Import(context.Background(), buf, format)
+
+			// Verify
+			assert.NoError(t, err)
+			assert.Equal(t, 3, written)
+			assert.Equal(t, &ImportResult{Read: 3, Created: 3}, result)
+
+			expected, err := source.GetPostsByIDs(context.Background(), []string{"1", "2", "3"})
+			assert.NoError(t, err)
+			imported, err := target.GetPostsByIDs(context.Background(), []string{"1", "2", "3"})
+			assert.NoError(t, err)
+			assert.Len(t, imported, 3)
+			for i := range expected {
+				assert.Equal(t, expected[i].PartitionKey, imported[i].PartitionKey)
+				assert.Equal(t, expected[i].Title, imported[i].Title)
+				assert.Equal(t, expected[i].Text, imported[i].Text)
+				assert.Equal(t, expected[i].SourceURL, imported[i].SourceURL)
+				assert.Equal(t, expected[i].Model, imported[i].Model)
+				assert.Equal(t, expected[i].Tags, imported[i].Tags)
+				assert.True(t, expected[i].CreatedAt.Equal(imported[i].CreatedAt))
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Run("FeedMetadata", func(t *testing.T) {
+		// Setup
+		buf := &bytes.Buffer{}
+
+		// Execute
+		_, err := (&Exporter{Blogger: newTestBlogger(t)}).Export(context.Background(), buf, FormatNDJSON, Filter{})
+
+		// Verify
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 3)
+
+		var record Record
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+		assert.Equal(t, Record{
+			ID:           "1",
+			PartitionKey: "feed",
+			Feed:         storetest.FeedURL,
+			Title:        "First, \"quoted\"",
+			Text:         "Summary\nwith <b>lines</b>",
+			URL:          "https://example.com/1",
+			Model:        "llama3:8b",
+			Tags:         "go,release",
+			CreatedAt:    createdAt,
+		}, record)
+		var link Record
+		assert.NoError(t, json.Unmarshal([]byte(lines[2]), &link))
+		assert.Equal(t, store.SavedLinksPartitionKey, link.PartitionKey)
+		assert.Empty(t, link.Feed)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		// Setup
+		buf := &bytes.Buffer{}
+
+		// Execute
+		written, err := (&Exporter{Blogger: newTestBlogger(t)}).Export(context.Background(), buf, FormatJSON,
+			Filter{PartitionKey: "feed", Since: createdAt.Add(time.Minute)})
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, 1, written)
+		var records []Record
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &records))
+		assert.Len(t, records, 1)
+		assert.Equal(t, "2", records[0].ID)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		// Setup
+		buf := &bytes.Buffer{}
+
+		// Execute
+		written, err := (&Exporter{Blogger: blogger.New(store.NewMemory())}).Export(context.Background(), buf, FormatJSON, Filter{})
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Zero(t, written)
+		assert.Equal(t, "[]\n", buf.String())
+	})
+
+	t.Run("FieldNames", func(t *testing.T) {
+		// Setup
+		ndjson := &bytes.Buffer{}
+		csv := &bytes.Buffer{}
+
+		// Execute
+		_, err := (&Exporter{Blogger: newTestBlogger(t)}).Export(context.Background(), ndjson, FormatNDJSON, Filter{})
+		assert.NoError(t, err)
+		_, err = (&Exporter{Blogger: newTestBlogger(t)}).Export(context.Background(), csv, FormatCSV, Filter{})
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Contains(t, ndjson.String(), `"partitionKey":"feed"`)
+		assert.Contains(t, ndjson.String(), `"createdAt":"2024-01-02T03:04:05Z"`)
+		assert.True(t, strings.HasPrefix(csv.String(), "id,partitionKey,feed,title,text,url,model,tags,createdAt\n"))
+	})
+
+	t.Run("UnknownFormat", func(t *testing.T) {
+		// Setup
+		buf := &bytes.Buffer{}
+
+		// Execute
+		_, err := (&Exporter{Blogger: newTestBlogger(t)}).Export(context.Background(), buf, "xml", Filter{})
+
+		// Verify
+		assert.ErrorIs(t, err, ErrUnknownFormat)
+		assert.Empty(t, buf.String())
+	})
+}
+
+func TestImport(t *testing.T) {
+	t.Run("Upsert", func(t *testing.T) {
+		// Setup
+		proc := newTestBlogger(t)
+		input := `{"id":"1","partitionKey":"feed","title":"Renamed","text":"Summary","url":"https://example.com/1","createdAt":"2024-01-02T03:04:05Z"}
+
+{"id":"4","partitionKey":"other","title":"New","text":"Summary","url":"https://example.com/4","createdAt":"2024-01-02T03:04:05Z"}
+`
+
+		// Execute
+		result, err := (&Exporter{Blogger: proc}).Import(context.Background(), strings.NewReader(input), FormatNDJSON)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, &ImportResult{Read: 2, Created: 1, Updated: 1}, result)
+		posts, err := proc.GetPostsByIDs(context.Background(), []string{"1", "4"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Renamed", posts[0].Title)
+		assert.Equal(t, "other", posts[1].PartitionKey)
+	})
+
+	t.Run("CSVColumnsByName", func(t *testing.T) {
+		// Setup
+		proc := blogger.New(store.NewMemory())
+		input := "url,id,partitionKey,extra\nhttps://example.com/1,1,feed,ignored\n"
+
+		// Execute
+		result, err := (&Exporter{Blogger: proc}).Import(context.Background(), strings.NewReader(input), FormatCSV)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Created)
+		posts, err := proc.GetPostsByIDs(context.Background(), []string{"1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/1", posts[0].SourceURL)
+	})
+
+	tbl := []struct {
+		name   string
+		format string
+		input  string
+		err    string
+	}{
+		{"InvalidRecord", FormatNDJSON, `{"id":"1","title":"Title"}` + "\n", "record 1: partitionKey is empty\nurl is empty"},
+		{"TooLong", FormatJSON, `[{"id":"1","partitionKey":"feed","url":"u","title":"` + strings.Repeat("x", 501) + `"}]`,
+			"record 1: title is longer than 500 characters"},
+		{"MalformedLine", FormatNDJSON, "{\"id\":\"1\"}\n{\n", "line 2: unexpected end of JSON input"},
+		{"MissingColumn", FormatCSV, "id,url\n1,u\n", "header has no partitionKey column"},
+		{"BadTime", FormatCSV, "id,partitionKey,url,createdAt\n1,feed,u,yesterday\n", "line 2: createdAt is not an RFC 3339 time: \"yesterday\""},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			proc := blogger.New(store.NewMemory())
+
+			// Execute
+			result, err := (&Exporter{Blogger: proc}).Import(context.Background(), strings.NewReader(tt.input), tt.format)
+
+			// Verify
+			assert.EqualError(t, err, tt.err)
+			assert.Nil(t, result)
+			posts, err := proc.GetPostsAfter(context.Background(), "", "", 10)
+			assert.NoError(t, err)
+			assert.Empty(t, posts)
+		})
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, FormatJSON, FormatOf("posts.JSON"))
+	assert.Equal(t, FormatCSV, FormatOf("posts.csv"))
+	assert.Equal(t, FormatNDJSON, FormatOf("posts.jsonl"))
+	assert.Equal(t, FormatNDJSON, FormatOf("-"))
+}
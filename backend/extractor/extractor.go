@@ -0,0 +1,213 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rjxby/rss-sum/backend/tracing"
+)
+
+var tracer = otel.Tracer("github.com/rjxby/rss-sum/backend/extractor")
+
+const (
+	requestTimeout = 30 * time.Second
+	// maxTextLength keeps extracted articles within a reasonable prompt size
+	maxTextLength = 20000
+	// maxPageSize caps how much of a page is read, the rest is ignored
+	maxPageSize = 5 << 20
+	userAgent   = "rss-sum (+https://github.com/rjxby/rss-sum)"
+)
+
+// ErrForbiddenAddress is returned for pages on loopback, private, link-local and other non-public addresses
+var ErrForbiddenAddress = errors.New("address is not public")
+
+// sharedAddressSpace is used by carrier-grade NAT, it is not reachable from the internet either
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+var reWhitespace = regexp.MustCompile(`[\s\p{Zs}]+`)
+
+// ExtractorProc fetches web pages and extracts their readable content
+type ExtractorProc struct {
+	http *http.Client
+}
+
+// Article is the readable part of a web page
+type Article struct {
+	URL   string
+	Title string
+	Text  string
+}
+
+// New makes ExtractorProc, which only connects to public addresses since the links are submitted by users
+func New() *ExtractorProc {
+	return newExtractor(publicAddressesOnly)
+}
+
+// newExtractor checks every connection with control, including the ones of redirects
+func newExtractor(control func(network, address string, conn syscall.RawConn) error) *ExtractorProc {
+	dialer := &net.Dialer{Timeout: requestTimeout, Control: control}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would connect on our behalf, past the check of the address
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &ExtractorProc{
+		http: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: transport,
+		},
+	}
+}
+
+// publicAddressesOnly refuses connections to non-public addresses. It runs on the resolved address right before
+// connecting, so host names resolving to such an address are refused as well, however often they are resolved
+func publicAddressesOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("failed to parse address %q: %v", address, err)
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("failed to parse address %q: %v", address, err)
+	}
+
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+
+	return nil
+}
+
+// Extract downloads the page and returns its title and main text
+func (p ExtractorProc) Extract(ctx context.Context, rawURL string) (*Article, error) {
+	ctx, span := tracer.Start(ctx, "extractor.Extract", trace.WithAttributes(attribute.String("url", rawURL)))
+	defer span.End()
+
+	pageURL, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL.String(), nil)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to create request: %v", err))
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to fetch page: %v", err))
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, tracing.Error(span, fmt.Errorf("page returned non-200 status code: %d", resp.StatusCode))
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, tracing.Error(span, fmt.Errorf("failed to parse page: %v", err))
+	}
+
+	article := &Article{
+		URL:   pageURL.String(),
+		Title: extractTitle(doc),
+		Text:  extractText(doc),
+	}
+
+	span.SetAttributes(attribute.Int("text_length", len(article.Text)))
+	if article.Text == "" {
+		return nil, tracing.Error(span, fmt.Errorf("page has no readable content"))
+	}
+
+	return article, nil
+}
+
+// ParseURL validates that the link is an absolute http(s) URL
+func ParseURL(rawURL string) (*url.URL, error) {
+	pageURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %v", err)
+	}
+
+	if pageURL.Scheme != "http" && pageURL.Scheme != "https" {
+		return nil, fmt.Errorf("url scheme should be http or https")
+	}
+
+	if pageURL.Host == "" {
+		return nil, fmt.Errorf("url host is empty")
+	}
+
+	return pageURL, nil
+}
+
+func extractTitle(doc *goquery.Document) string {
+	if title, ok := doc.Find(`meta[property="og:title"]`).Attr("content"); ok && strings.TrimSpace(title) != "" {
+		return normalizeText(title)
+	}
+
+	return normalizeText(doc.Find("title").First().Text())
+}
+
+func extractText(doc *goquery.Document) string {
+	doc.Find("script, style, noscript, nav, header, footer, aside, form, iframe, svg").Remove()
+
+	// prefer semantic containers, fall back to the whole body
+	for _, selector := range []string{"article", "main", "body"} {
+		selection := doc.Find(selector)
+		if selection.Length() == 0 {
+			continue
+		}
+
+		var paragraphs []string
+		selection.Each(func(_ int, s *goquery.Selection) {
+			if text := normalizeText(s.Text()); text != "" {
+				paragraphs = append(paragraphs, text)
+			}
+		})
+
+		if text := strings.Join(paragraphs, "\n\n"); text != "" {
+			return Truncate(text, maxTextLength)
+		}
+	}
+
+	return ""
+}
+
+func normalizeText(text string) string {
+	return strings.TrimSpace(reWhitespace.ReplaceAllString(text, " "))
+}
+
+// Truncate cuts the text to at most limit characters, multi-byte characters are never split
+func Truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+
+	return string(runes[:limit])
+}
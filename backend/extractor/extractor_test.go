@@ -0,0 +1,180 @@
+package extractor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	t.Run("Article", func(t *testing.T) {
+		// Create a mock server that returns a web page
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html>
+<head>
+	<title>Fallback title</title>
+	<meta property="og:title" content="Chip export controls">
+	<script>var tracking = true;</script>
+</head>
+<body>
+	<nav>Home | About</nav>
+	<article>
+		<h1>Chip export controls</h1>
+		<p>New   rules were
+		announced.</p>
+	</article>
+	<footer>Copyright</footer>
+</body>
+</html>`))
+		}))
+		defer ts.Close()
+
+		// Execute
+		article, err := newExtractor(nil).Extract(context.Background(), ts.URL)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, "Chip export controls", article.Title)
+		assert.Equal(t, "Chip export controls New rules were announced.", article.Text)
+		assert.Equal(t, ts.URL, article.URL)
+	})
+
+	t.Run("BodyFallback", func(t *testing.T) {
+		// Create a mock server that returns a page without semantic containers
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`<html><head><title>Plain page</title></head><body><div>Just text</div></body></html>`))
+		}))
+		defer ts.Close()
+
+		// Execute
+		article, err := newExtractor(nil).Extract(context.Background(), ts.URL)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, "Plain page", article.Title)
+		assert.Equal(t, "Just text", article.Text)
+	})
+
+	t.Run("NonOKStatus", func(t *testing.T) {
+		// Create a mock server that returns an error
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+
+		// Execute
+		article, err := newExtractor(nil).Extract(context.Background(), ts.URL)
+
+		// Verify
+		assert.Error(t, err)
+		assert.Nil(t, article)
+		assert.Contains(t, err.Error(), "non-200 status code")
+	})
+}
+
+func TestExtractPrivateAddress(t *testing.T) {
+	t.Run("Loopback", func(t *testing.T) {
+		// Create a mock server on the loopback address
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`<html><body><p>Internal</p></body></html>`))
+		}))
+		defer ts.Close()
+
+		// Execute
+		article, err := New().Extract(context.Background(), ts.URL)
+
+		// Verify
+		assert.ErrorContains(t, err, ErrForbiddenAddress.Error())
+		assert.Nil(t, article)
+	})
+
+	t.Run("Redirect", func(t *testing.T) {
+		// Create a mock server redirecting to the metadata address of cloud providers
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		}))
+		defer ts.Close()
+		proc := newExtractor(nil)
+		proc.http.Transport.(*http.Transport).DialContext = (&net.Dialer{
+			Control: func(network, address string, conn syscall.RawConn) error {
+				// the mock server itself is on the loopback address
+				if address == ts.Listener.Addr().String() {
+					return nil
+				}
+				return publicAddressesOnly(network, address, conn)
+			},
+		}).DialContext
+
+		// Execute
+		article, err := proc.Extract(context.Background(), ts.URL)
+
+		// Verify
+		assert.ErrorContains(t, err, ErrForbiddenAddress.Error())
+		assert.Nil(t, article)
+	})
+}
+
+func TestPublicAddressesOnly(t *testing.T) {
+	tbl := []struct {
+		address     string
+		expectError bool
+	}{
+		{"93.184.216.34:443", false},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", false},
+		{"127.0.0.1:11434", true},
+		{"[::1]:80", true},
+		{"10.0.0.1:80", true},
+		{"192.168.1.1:80", true},
+		{"169.254.169.254:80", true},
+		{"[fe80::1]:80", true},
+		{"[::ffff:127.0.0.1]:80", true},
+		{"100.64.0.1:80", true},
+		{"0.0.0.0:80", true},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.address, func(t *testing.T) {
+			err := publicAddressesOnly("tcp", tt.address, nil)
+
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrForbiddenAddress)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	tbl := []struct {
+		name        string
+		input       string
+		expectError bool
+	}{
+		{"HTTP", "http://example.com/post", false},
+		{"HTTPS", " https://example.com/post ", false},
+		{"FileScheme", "file:///etc/passwd", true},
+		{"Relative", "/post", true},
+		{"Empty", "", true},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseURL(tt.input)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, strings.TrimSpace(tt.input), result.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Settings struct {
+	Level  slog.Level `yaml:"level"`
+	Format string     `yaml:"format"`
+}
+
+// DefaultSettings returns the settings used for the values which are not configured
+func DefaultSettings() Settings {
+	return Settings{
+		Level:  slog.LevelInfo,
+		Format: FormatText,
+	}
+}
+
+// Validate reports every invalid setting
+func (s Settings) Validate() error {
+	if s.Format != FormatText && s.Format != FormatJSON {
+		return fmt.Errorf("format should be %q or %q", FormatText, FormatJSON)
+	}
+
+	return nil
+}
+
+// New makes a logger writing to w, records carry the request ID of their context
+func New(w io.Writer, settings *Settings) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: settings.Level}
+
+	var handler slog.Handler
+	if settings.Format == FormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// Setup makes the logger the default one, output of the standard log package included
+func Setup(settings *Settings) *slog.Logger {
+	logger := New(os.Stderr, settings)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// contextHandler adds the request ID set by the chi RequestID middleware and the trace ID of the current span
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("DefaultValues", func(t *testing.T) {
+		settings := DefaultSettings()
+
+		assert.NoError(t, settings.Validate())
+		assert.Equal(t, slog.LevelInfo, settings.Level)
+		assert.Equal(t, FormatText, settings.Format)
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		settings := DefaultSettings()
+		settings.Format = "xml"
+
+		assert.Error(t, settings.Validate())
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("RequestID", func(t *testing.T) {
+		// Setup
+		var buf bytes.Buffer
+		logger := New(&buf, &Settings{Level: slog.LevelInfo, Format: FormatJSON}).With("feed", "http://example.com/feed")
+		ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-1")
+
+		// Execute
+		logger.InfoContext(ctx, "fetched feed")
+
+		// Verify
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "fetched feed", record["msg"])
+		assert.Equal(t, "req-1", record["request_id"])
+		assert.Equal(t, "http://example.com/feed", record["feed"])
+	})
+
+	t.Run("TraceID", func(t *testing.T) {
+		// Setup
+		var buf bytes.Buffer
+		logger := New(&buf, &Settings{Level: slog.LevelInfo, Format: FormatJSON})
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(),
+			trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+		// Execute
+		logger.InfoContext(ctx, "summarized post")
+
+		// Verify
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+	})
+
+	t.Run("Level", func(t *testing.T) {
+		// Setup
+		var buf bytes.Buffer
+		logger := New(&buf, &Settings{Level: slog.LevelWarn, Format: FormatText})
+
+		// Execute
+		logger.Info("hidden")
+		logger.Warn("shown")
+
+		// Verify
+		assert.NotContains(t, buf.String(), "hidden")
+		assert.Contains(t, buf.String(), "shown")
+	})
+}
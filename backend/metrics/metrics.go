@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "rss_sum"
+
+// Outcome label values
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	FeedFetchesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "feed_fetches_total",
+		Help:      "Number of feed fetches by feed and outcome.",
+	}, []string{"feed", "outcome"})
+
+	FeedFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "feed_fetch_duration_seconds",
+		Help:      "Duration of feed fetches, retries included, by feed.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"feed"})
+
+	GenerationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "generation_duration_seconds",
+		Help:      "Latency of text generation by model, task (summarize, answer or score) and outcome.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"model", "task", "outcome"})
+
+	GenerationTokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "generation_tokens_total",
+		Help:      "Number of tokens processed by model, task (summarize, answer or score) and type (prompt or completion).",
+	}, []string{"model", "task", "type"})
+
+	FeedItemsFilteredTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "feed_items_filtered_total",
+		Help:      "Number of new feed items the feed filters skipped, tagged or prioritized by feed and action.",
+	}, []string{"feed", "action"})
+
+	PostsPrunedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_pruned_total",
+		Help:      "Number of posts deleted by the retention janitor by reason (age or count).",
+	}, []string{"reason"})
+)
+
+// Handler serves the metrics of the default registry
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterStore exposes the queue depth and database size of the store
+func RegisterStore(stats StoreStats) {
+	prometheus.MustRegister(NewStoreCollector(stats))
+}
+
+// StoreStats defines an interface to read the state of the store
+type StoreStats interface {
+	CountJobsByStatus() (map[string]int64, error)
+	DatabaseSize() (int64, error)
+}
+
+// StoreCollector reads queue depth and database size on every scrape
+type StoreCollector struct {
+	stats StoreStats
+
+	jobs   *prometheus.Desc
+	dbSize *prometheus.Desc
+}
+
+// NewStoreCollector makes StoreCollector
+func NewStoreCollector(stats StoreStats) *StoreCollector {
+	return &StoreCollector{
+		stats: stats,
+		jobs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "jobs"),
+			"Number of summarization jobs in the queue by status.",
+			[]string{"status"}, nil),
+		dbSize: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "database_size_bytes"),
+			"Size of the database in bytes.",
+			nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *StoreCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.jobs
+	ch <- c.dbSize
+}
+
+// Collect implements prometheus.Collector, failed reads are logged and skipped
+func (c *StoreCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.stats.CountJobsByStatus()
+	if err != nil {
+		slog.Warn("failed to count jobs for metrics", "error", err)
+	} else {
+		for status, count := range counts {
+			ch <- prometheus.MustNewConstMetric(c.jobs, prometheus.GaugeValue, float64(count), status)
+		}
+	}
+
+	size, err := c.stats.DatabaseSize()
+	if err != nil {
+		slog.Warn("failed to read database size for metrics", "error", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.dbSize, prometheus.GaugeValue, float64(size))
+	}
+}
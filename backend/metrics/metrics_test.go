@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStoreStats struct {
+	mock.Mock
+}
+
+func (m *MockStoreStats) CountJobsByStatus() (map[string]int64, error) {
+	args := m.Called()
+	return args.Get(0).(map[string]int64), args.Error(1)
+}
+
+func (m *MockStoreStats) DatabaseSize() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestStoreCollector(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockStats := new(MockStoreStats)
+		mockStats.On("CountJobsByStatus").Return(map[string]int64{"pending": 3, "dead": 1}, nil)
+		mockStats.On("DatabaseSize").Return(int64(4096), nil)
+
+		registry := prometheus.NewPedanticRegistry()
+		registry.MustRegister(NewStoreCollector(mockStats))
+
+		// Execute
+		err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP rss_sum_database_size_bytes Size of the database in bytes.
+# TYPE rss_sum_database_size_bytes gauge
+rss_sum_database_size_bytes 4096
+# HELP rss_sum_jobs Number of summarization jobs in the queue by status.
+# TYPE rss_sum_jobs gauge
+rss_sum_jobs{status="dead"} 1
+rss_sum_jobs{status="pending"} 3
+`))
+
+		// Verify
+		assert.NoError(t, err)
+		mockStats.AssertExpectations(t)
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		// Setup
+		mockStats := new(MockStoreStats)
+		mockStats.On("CountJobsByStatus").Return(map[string]int64(nil), errors.New("database is locked"))
+		mockStats.On("DatabaseSize").Return(int64(4096), nil)
+
+		// Execute
+		count := testutil.CollectAndCount(NewStoreCollector(mockStats))
+
+		// Verify
+		assert.Equal(t, 1, count)
+	})
+}
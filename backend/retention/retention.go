@@ -0,0 +1,296 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjxby/rss-sum/backend/extractor"
+	"github.com/rjxby/rss-sum/backend/metrics"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+// Reasons a post is pruned for
+const (
+	ReasonAge   = "age"
+	ReasonCount = "count"
+)
+
+type Settings struct {
+	IntervalInSeconds int `yaml:"interval_in_seconds"`
+	// KeepDays prunes posts older than that many days, 0 keeps them regardless of their age
+	KeepDays int `yaml:"keep_days"`
+	// KeepPosts prunes all but the newest posts of a feed, 0 keeps them regardless of their number
+	KeepPosts int `yaml:"keep_posts"`
+	// Feeds override the global rules for single feeds
+	Feeds []FeedRule `yaml:"feeds"`
+	// Optimize runs VACUUM and ANALYZE after posts were pruned
+	Optimize bool `yaml:"optimize"`
+}
+
+// FeedRule overrides the global rules for the feed with the given URL, unset values inherit them
+type FeedRule struct {
+	URL       string `yaml:"url"`
+	KeepDays  *int   `yaml:"keep_days"`
+	KeepPosts *int   `yaml:"keep_posts"`
+}
+
+// rule is the retention of a single partition
+type rule struct {
+	keepDays  int
+	keepPosts int
+}
+
+// policy is the state swapped by Reload
+type policy struct {
+	settings        Settings
+	minPostsPerFeed int
+}
+
+// Blogger defines an interface to load and delete posts
+type Blogger interface {
+	GetFeeds(ctx context.Context) ([]*store.FeedV1, error)
+	GetPartitionKeys(ctx context.Context) ([]string, error)
+	GetExpiredPosts(ctx context.Context, partitionKey string, skip int, createdBefore time.Time) ([]*store.PostV1, error)
+	DeletePosts(ctx context.Context, ids []string) (int64, error)
+	Optimize(ctx context.Context) error
+}
+
+// Janitor prunes the posts expired by the retention rules in the background
+type Janitor struct {
+	Blogger  Blogger
+	Settings Settings
+	// MinPostsPerFeed newest posts of every feed are never pruned, the worker tells new items
+	// from known ones by comparing them with the newest posts, pruning those would summarize them again
+	MinPostsPerFeed int
+
+	reloaded atomic.Pointer[policy]
+}
+
+// Candidate is a post expired by the retention rules
+type Candidate struct {
+	PostID       string
+	Title        string
+	SourceURL    string
+	PartitionKey string
+	Feed         string // URL of the feed, empty for saved links and removed feeds
+	Reason       string
+	CreatedAt    time.Time
+}
+
+// Report lists the posts a run prunes, or would prune for a dry run
+type Report struct {
+	Posts     []*Candidate
+	Deleted   int64
+	DryRun    bool
+	CreatedAt time.Time
+}
+
+// DefaultSettings returns the settings used for the values which are not configured, nothing is pruned by default
+func DefaultSettings() Settings {
+	return Settings{
+		IntervalInSeconds: 86400, // 1 day
+		Optimize:          true,
+	}
+}
+
+// Validate reports every invalid setting
+func (s Settings) Validate() error {
+	var errs []error
+
+	if s.IntervalInSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("interval_in_seconds should be positive"))
+	}
+	if s.KeepDays < 0 {
+		errs = append(errs, fmt.Errorf("keep_days should not be negative"))
+	}
+	if s.KeepPosts < 0 {
+		errs = append(errs, fmt.Errorf("keep_posts should not be negative"))
+	}
+
+	seen := make(map[string]bool)
+	for _, feed := range s.Feeds {
+		if _, err := extractor.ParseURL(feed.URL); err != nil {
+			errs = append(errs, fmt.Errorf("feed %q is invalid: %v", feed.URL, err))
+		}
+		if seen[feed.URL] {
+			errs = append(errs, fmt.Errorf("feed %q has more than one rule", feed.URL))
+		}
+		seen[feed.URL] = true
+
+		if feed.KeepDays != nil && *feed.KeepDays < 0 {
+			errs = append(errs, fmt.Errorf("feed %q: keep_days should not be negative", feed.URL))
+		}
+		if feed.KeepPosts != nil && *feed.KeepPosts < 0 {
+			errs = append(errs, fmt.Errorf("feed %q: keep_posts should not be negative", feed.URL))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ruleFor returns the rule of the feed with the given URL, the global one when it has none
+func (s Settings) ruleFor(feedURL string) rule {
+	result := rule{keepDays: s.KeepDays, keepPosts: s.KeepPosts}
+	for _, feed := range s.Feeds {
+		if feed.URL != feedURL || feedURL == "" {
+			continue
+		}
+		if feed.KeepDays != nil {
+			result.keepDays = *feed.KeepDays
+		}
+		if feed.KeepPosts != nil {
+			result.keepPosts = *feed.KeepPosts
+		}
+	}
+	return result
+}
+
+// Reload swaps the rules of the following runs, minPostsPerFeed follows the feed items limit of the worker
+func (j *Janitor) Reload(settings Settings, minPostsPerFeed int) {
+	j.reloaded.Store(&policy{settings: settings, minPostsPerFeed: minPostsPerFeed})
+}
+
+// policy returns the reloaded rules, or the initial ones until the first reload
+func (j *Janitor) policy() *policy {
+	if p := j.reloaded.Load(); p != nil {
+		return p
+	}
+	return &policy{settings: j.Settings, minPostsPerFeed: j.MinPostsPerFeed}
+}
+
+// Run prunes expired posts every interval until the context is cancelled
+func (j *Janitor) Run(ctx context.Context) error {
+	slog.Info("activate retention janitor", "interval", j.policy().settings.IntervalInSeconds)
+
+	for {
+		if _, err := j.Prune(ctx); err != nil {
+			slog.Error("failed to prune posts", "error", err)
+		}
+
+		interval := time.Duration(j.policy().settings.IntervalInSeconds) * time.Second
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// DryRun reports the posts the next run would prune without deleting them
+func (j *Janitor) DryRun(ctx context.Context) (*Report, error) {
+	posts, err := j.expiredPosts(ctx, j.policy(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{Posts: posts, DryRun: true, CreatedAt: time.Now()}, nil
+}
+
+// Prune deletes the expired posts and optimizes the store afterwards when posts were deleted
+func (j *Janitor) Prune(ctx context.Context) (*Report, error) {
+	p := j.policy()
+
+	posts, err := j.expiredPosts(ctx, p, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Posts: posts, CreatedAt: time.Now()}
+	if len(posts) == 0 {
+		slog.Debug("no posts to prune")
+		return report, nil
+	}
+
+	ids := make([]string, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.PostID)
+	}
+
+	if report.Deleted, err = j.Blogger.DeletePosts(ctx, ids); err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		metrics.PostsPrunedTotal.WithLabelValues(post.Reason).Inc()
+	}
+	slog.Info("pruned posts", "deleted", report.Deleted)
+
+	if p.settings.Optimize {
+		if err := j.Blogger.Optimize(ctx); err != nil {
+			return nil, err
+		}
+		slog.Info("optimized store")
+	}
+
+	return report, nil
+}
+
+// expiredPosts collects the posts of every partition expired by its rule, posts expired by count first
+func (j *Janitor) expiredPosts(ctx context.Context, p *policy, now time.Time) ([]*Candidate, error) {
+	feeds, err := j.Blogger.GetFeeds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	feedURLs := make(map[string]string, len(feeds))
+	for _, feed := range feeds {
+		feedURLs[feed.ID] = feed.URL
+	}
+
+	partitionKeys, err := j.Blogger.GetPartitionKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Candidate
+	for _, partitionKey := range partitionKeys {
+		feedURL := feedURLs[partitionKey]
+		r := p.settings.ruleFor(feedURL)
+
+		// saved links are not deduplicated against the newest posts
+		minPosts := p.minPostsPerFeed
+		if partitionKey == store.SavedLinksPartitionKey {
+			minPosts = 0
+		}
+
+		seen := make(map[string]bool)
+		add := func(posts []*store.PostV1, reason string) {
+			for _, post := range posts {
+				if seen[post.ID] {
+					continue
+				}
+				seen[post.ID] = true
+				results = append(results, &Candidate{
+					PostID:       post.ID,
+					Title:        post.Title,
+					SourceURL:    post.SourceURL,
+					PartitionKey: partitionKey,
+					Feed:         feedURL,
+					Reason:       reason,
+					CreatedAt:    post.CreatedAt,
+				})
+			}
+		}
+
+		if r.keepPosts > 0 {
+			posts, err := j.Blogger.GetExpiredPosts(ctx, partitionKey, max(r.keepPosts, minPosts), time.Time{})
+			if err != nil {
+				return nil, err
+			}
+			add(posts, ReasonCount)
+		}
+
+		if r.keepDays > 0 {
+			createdBefore := now.AddDate(0, 0, -r.keepDays)
+			posts, err := j.Blogger.GetExpiredPosts(ctx, partitionKey, minPosts, createdBefore)
+			if err != nil {
+				return nil, err
+			}
+			add(posts, ReasonAge)
+		}
+	}
+
+	return results, nil
+}
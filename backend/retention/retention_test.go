@@ -0,0 +1,165 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rjxby/rss-sum/backend/blogger"
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/store/storetest"
+)
+
+// newTestBlogger stores a feed with posts 0, 1, 3, 5 and 7 days old, three saved links and
+// two posts of a removed feed, all 10 days old
+func newTestBlogger(t *testing.T) *blogger.BloggerProc {
+	now := time.Now()
+	var posts []*store.PostV1
+	for i, days := range []int{0, 1, 3, 5, 7} {
+		posts = append(posts, storetest.NewPost(fmt.Sprintf("feed-%d", i), storetest.FeedID, now.AddDate(0, 0, -days)))
+	}
+	for i := range 3 {
+		posts = append(posts, storetest.NewPost(fmt.Sprintf("link-%d", i), store.SavedLinksPartitionKey, now.AddDate(0, 0, -10).Add(time.Duration(i)*time.Minute)))
+	}
+	for i := range 2 {
+		posts = append(posts, storetest.NewPost(fmt.Sprintf("gone-%d", i), "gone", now.AddDate(0, 0, -10).Add(time.Duration(i)*time.Minute)))
+	}
+	return storetest.NewBlogger(t, posts...)
+}
+
+func candidateIDs(report *Report) map[string]string {
+	ids := make(map[string]string, len(report.Posts))
+	for _, post := range report.Posts {
+		ids[post.PostID] = post.Reason
+	}
+	return ids
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func TestSettingsValidate(t *testing.T) {
+	tbl := []struct {
+		name     string
+		settings Settings
+		err      string
+	}{
+		{"Default", DefaultSettings(), ""},
+		{"FeedRule", Settings{IntervalInSeconds: 60, Feeds: []FeedRule{{URL: storetest.FeedURL, KeepDays: intPtr(0)}}}, ""},
+		{"Negative", Settings{IntervalInSeconds: 0, KeepDays: -1, KeepPosts: -1},
+			"interval_in_seconds should be positive\nkeep_days should not be negative\nkeep_posts should not be negative"},
+		{"DuplicateFeed", Settings{IntervalInSeconds: 60, Feeds: []FeedRule{{URL: storetest.FeedURL}, {URL: storetest.FeedURL, KeepPosts: intPtr(-1)}}},
+			fmt.Sprintf("feed %q has more than one rule\nfeed %q: keep_posts should not be negative", storetest.FeedURL, storetest.FeedURL)},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	t.Run("KeepPosts", func(t *testing.T) {
+		// Setup
+		janitor := &Janitor{Blogger: newTestBlogger(t), Settings: Settings{IntervalInSeconds: 60, KeepPosts: 2}, MinPostsPerFeed: 3}
+
+		// Execute
+		report, err := janitor.DryRun(context.Background())
+
+		// Verify
+		assert.NoError(t, err)
+		assert.True(t, report.DryRun)
+		// the newest three posts of the feed are protected, saved links are not
+		assert.Equal(t, map[string]string{"feed-3": ReasonCount, "feed-4": ReasonCount, "link-0": ReasonCount}, candidateIDs(report))
+		for _, post := range report.Posts {
+			if post.PartitionKey == "feed" {
+				assert.Equal(t, storetest.FeedURL, post.Feed)
+			}
+		}
+	})
+
+	t.Run("KeepDays", func(t *testing.T) {
+		// Setup
+		janitor := &Janitor{Blogger: newTestBlogger(t), Settings: Settings{IntervalInSeconds: 60, KeepDays: 4}, MinPostsPerFeed: 1}
+
+		// Execute
+		report, err := janitor.DryRun(context.Background())
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"feed-3": ReasonAge, "feed-4": ReasonAge,
+			"link-0": ReasonAge, "link-1": ReasonAge, "link-2": ReasonAge,
+			"gone-0": ReasonAge,
+		}, candidateIDs(report))
+	})
+
+	t.Run("FeedRule", func(t *testing.T) {
+		// Setup
+		settings := Settings{IntervalInSeconds: 60, KeepDays: 4, Feeds: []FeedRule{{URL: storetest.FeedURL, KeepDays: intPtr(0), KeepPosts: intPtr(4)}}}
+		janitor := &Janitor{Blogger: newTestBlogger(t), Settings: settings, MinPostsPerFeed: 1}
+
+		// Execute
+		report, err := janitor.DryRun(context.Background())
+
+		// Verify
+		assert.NoError(t, err)
+		ids := candidateIDs(report)
+		assert.Equal(t, ReasonCount, ids["feed-4"])
+		assert.NotContains(t, ids, "feed-3")
+		assert.Equal(t, ReasonAge, ids["link-0"])
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		// Setup
+		janitor := &Janitor{Blogger: newTestBlogger(t), Settings: Settings{IntervalInSeconds: 60}, MinPostsPerFeed: 1}
+		janitor.Reload(Settings{IntervalInSeconds: 60, KeepPosts: 1}, 4)
+
+		// Execute
+		report, err := janitor.DryRun(context.Background())
+
+		// Verify
+		assert.NoError(t, err)
+		// the removed feed keeps its two posts, all of them are protected now
+		assert.Equal(t, map[string]string{"feed-4": ReasonCount, "link-0": ReasonCount, "link-1": ReasonCount}, candidateIDs(report))
+	})
+}
+
+func TestPrune(t *testing.T) {
+	// Setup
+	proc := newTestBlogger(t)
+	janitor := &Janitor{Blogger: proc, Settings: Settings{IntervalInSeconds: 60, KeepDays: 4, Optimize: true}, MinPostsPerFeed: 1}
+
+	// Execute
+	report, err := janitor.Prune(context.Background())
+
+	// Verify
+	assert.NoError(t, err)
+	assert.False(t, report.DryRun)
+	assert.Equal(t, int64(6), report.Deleted)
+
+	remaining, err := proc.GetPosts(context.Background(), 1, 100, "")
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(remaining.Posts))
+	for _, post := range remaining.Posts {
+		ids = append(ids, post.ID)
+	}
+	assert.ElementsMatch(t, []string{"feed-0", "feed-1", "feed-2", "gone-1"}, ids)
+
+	// a second run has nothing left to prune
+	report, err = janitor.Prune(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, report.Posts)
+	assert.Zero(t, report.Deleted)
+}
@@ -0,0 +1,207 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/rss"
+)
+
+const (
+	feedRequestTimeout = 60 * time.Second
+	feedUserAgent      = "rss-sum (+https://github.com/rjxby/rss-sum)"
+	// ttlCustomKey carries the RSS <ttl> through the universal feed, which has no field for it
+	ttlCustomKey = "ttl"
+)
+
+var reMaxAge = regexp.MustCompile(`(?i)(?:^|[,\s])max-age\s*=\s*(\d+)`)
+
+// scheduleHints are the polling hints published by the feed and its server
+type scheduleHints struct {
+	// MinInterval is the shortest interval the publisher asks for (ttl, sy:updatePeriod, Cache-Control)
+	MinInterval time.Duration
+	// RetryAfter postpones the next fetch after throttling responses
+	RetryAfter time.Duration
+}
+
+// fetchResult is returned even for failed fetches, so schedule hints and status are not lost
+type fetchResult struct {
+	Feed       *gofeed.Feed
+	StatusCode int
+	Hints      scheduleHints
+}
+
+type fetchError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e fetchError) Error() string {
+	return fmt.Sprintf("feed returned non-2xx status code: %s", e.Status)
+}
+
+type feedFetcher struct {
+	http   *http.Client
+	parser *gofeed.Parser
+}
+
+func newFeedFetcher() *feedFetcher {
+	parser := gofeed.NewParser()
+	parser.RSSTranslator = &ttlRSSTranslator{}
+
+	return &feedFetcher{
+		http: &http.Client{
+			Timeout: feedRequestTimeout,
+		},
+		parser: parser,
+	}
+}
+
+// Fetch downloads and parses the feed, collecting schedule hints even for failed requests
+func (f *feedFetcher) Fetch(ctx context.Context, feedURL string) (*fetchResult, error) {
+	result := &fetchResult{}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", feedUserAgent)
+
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("failed to perform request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "feed", feedURL, "error", err)
+		}
+	}()
+
+	result.StatusCode = resp.StatusCode
+	result.Hints.MinInterval = parseMaxAge(resp.Header.Get("Cache-Control"))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			result.Hints.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return result, fetchError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	feed, err := f.parser.Parse(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse feed: %v", err)
+	}
+
+	result.Feed = feed
+	result.Hints.MinInterval = max(result.Hints.MinInterval, parseTTL(feed), parseUpdatePeriod(feed))
+
+	return result, nil
+}
+
+// ttlRSSTranslator keeps the RSS <ttl> which the default translator drops
+type ttlRSSTranslator struct {
+	gofeed.DefaultRSSTranslator
+}
+
+func (t *ttlRSSTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	result, err := t.DefaultRSSTranslator.Translate(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	if rssFeed, ok := feed.(*rss.Feed); ok && rssFeed.TTL != "" {
+		if result.Custom == nil {
+			result.Custom = map[string]string{}
+		}
+		result.Custom[ttlCustomKey] = rssFeed.TTL
+	}
+
+	return result, nil
+}
+
+// parseTTL reads the RSS <ttl>, given in minutes
+func parseTTL(feed *gofeed.Feed) time.Duration {
+	minutes, err := strconv.Atoi(strings.TrimSpace(feed.Custom[ttlCustomKey]))
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+
+	return time.Duration(minutes) * time.Minute
+}
+
+// parseUpdatePeriod reads sy:updatePeriod and sy:updateFrequency of the syndication module
+func parseUpdatePeriod(feed *gofeed.Feed) time.Duration {
+	sy, ok := feed.Extensions["sy"]
+	if !ok {
+		return 0
+	}
+
+	extensionValue := func(name string) string {
+		if values := sy[name]; len(values) > 0 {
+			return strings.TrimSpace(values[0].Value)
+		}
+		return ""
+	}
+
+	var period time.Duration
+	switch strings.ToLower(extensionValue("updatePeriod")) {
+	case "hourly":
+		period = time.Hour
+	case "daily":
+		period = 24 * time.Hour
+	case "weekly":
+		period = 7 * 24 * time.Hour
+	case "monthly":
+		period = 30 * 24 * time.Hour
+	case "yearly":
+		period = 365 * 24 * time.Hour
+	default:
+		return 0
+	}
+
+	frequency, err := strconv.Atoi(extensionValue("updateFrequency"))
+	if err != nil || frequency <= 0 {
+		frequency = 1
+	}
+
+	return period / time.Duration(frequency)
+}
+
+func parseMaxAge(cacheControl string) time.Duration {
+	match := reMaxAge.FindStringSubmatch(cacheControl)
+	if match == nil {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// parseRetryAfter supports both delay-seconds and HTTP-date forms
+func parseRetryAfter(retryAfter string, now time.Time) time.Duration {
+	retryAfter = strings.TrimSpace(retryAfter)
+	if retryAfter == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return max(date.Sub(now), 0)
+	}
+
+	return 0
+}
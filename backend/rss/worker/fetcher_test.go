@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetch(t *testing.T) {
+	t.Run("Hints", func(t *testing.T) {
+		// Create a mock server that returns a feed with schedule hints
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "public, max-age=600")
+			_, _ = w.Write([]byte(`<?xml version="1.0"?>
+<rss version="2.0" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+<channel><title>Feed</title><ttl>30</ttl>
+<sy:updatePeriod>hourly</sy:updatePeriod><sy:updateFrequency>1</sy:updateFrequency>
+<item><guid>1</guid><title>Post 1</title></item>
+</channel></rss>`))
+		}))
+		defer ts.Close()
+
+		// Execute
+		result, err := newFeedFetcher().Fetch(context.Background(), ts.URL)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(result.Feed.Items))
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, time.Hour, result.Hints.MinInterval)
+		assert.Equal(t, time.Duration(0), result.Hints.RetryAfter)
+	})
+
+	t.Run("TooManyRequests", func(t *testing.T) {
+		// Create a mock server that throttles the worker
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7200")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer ts.Close()
+
+		// Execute
+		result, err := newFeedFetcher().Fetch(context.Background(), ts.URL)
+
+		// Verify
+		assert.Error(t, err)
+		assert.Nil(t, result.Feed)
+		assert.ErrorAs(t, err, &fetchError{})
+		assert.Equal(t, http.StatusTooManyRequests, result.StatusCode)
+		assert.Equal(t, 2*time.Hour, result.Hints.RetryAfter)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tbl := []struct {
+		name     string
+		input    string
+		expected time.Duration
+	}{
+		{"Seconds", "120", 2 * time.Minute},
+		{"HTTPDate", "Wed, 01 Jan 2025 13:00:00 GMT", time.Hour},
+		{"PastDate", "Wed, 01 Jan 2025 11:00:00 GMT", 0},
+		{"Empty", "", 0},
+		{"Garbage", "soon", 0},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseRetryAfter(tt.input, now))
+		})
+	}
+}
+
+func TestParseMaxAge(t *testing.T) {
+	assert.Equal(t, 5*time.Minute, parseMaxAge("public, max-age=300"))
+	assert.Equal(t, 5*time.Minute, parseMaxAge("max-age=300, must-revalidate"))
+	assert.Equal(t, time.Duration(0), parseMaxAge("s-maxage=300"))
+	assert.Equal(t, time.Duration(0), parseMaxAge("no-cache"))
+}
+
+func TestParseUpdatePeriod(t *testing.T) {
+	syFeed := func(period, frequency string) *gofeed.Feed {
+		return &gofeed.Feed{Extensions: ext.Extensions{"sy": {
+			"updatePeriod":    {{Value: period}},
+			"updateFrequency": {{Value: frequency}},
+		}}}
+	}
+
+	assert.Equal(t, 12*time.Hour, parseUpdatePeriod(syFeed("daily", "2")))
+	assert.Equal(t, time.Hour, parseUpdatePeriod(syFeed("hourly", "")))
+	assert.Equal(t, time.Duration(0), parseUpdatePeriod(syFeed("sometimes", "1")))
+	assert.Equal(t, time.Duration(0), parseUpdatePeriod(&gofeed.Feed{}))
+}
@@ -0,0 +1,361 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mmcdole/gofeed"
+
+	"github.com/rjxby/rss-sum/backend/extractor"
+)
+
+// Actions of a filter rule
+const (
+	// ActionSkip leaves the item out, it is neither summarized nor stored
+	ActionSkip = "skip"
+	// ActionTag adds the tags of the rule to the post
+	ActionTag = "tag"
+	// ActionPrioritize summarizes the item before the items of the other feeds
+	ActionPrioritize = "prioritize"
+)
+
+// Fields of an item a rule matches its keywords and pattern against
+const (
+	FieldTitle    = "title"
+	FieldContent  = "content"
+	FieldAuthor   = "author"
+	FieldCategory = "category"
+)
+
+const (
+	// jobPriorityHigh is the priority of the summarization jobs of prioritized items
+	jobPriorityHigh = 1
+	// maxTagsLength fits the tags of a post into their column
+	maxTagsLength = 500
+)
+
+// ErrInvalidFilter is returned when previewing rules which are not valid
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// FeedFilter holds the rules of the feed with the given URL, evaluated in order for every new item
+type FeedFilter struct {
+	URL   string       `yaml:"url"`
+	Rules []FilterRule `yaml:"rules"`
+}
+
+// FilterRule matches an item when all of its conditions which are set hold, or when none does for an inverted rule
+type FilterRule struct {
+	Name string `yaml:"name" json:"name,omitempty"`
+	// Field restricts keywords and pattern to the title, content, author or category, empty matches any of them
+	Field string `yaml:"field" json:"field,omitempty"`
+	// Keywords match when any of them is part of the field, regardless of the case
+	Keywords []string `yaml:"keywords" json:"keywords,omitempty"`
+	Pattern  string   `yaml:"pattern" json:"pattern,omitempty"`
+	// MinLength matches content with at least that many characters
+	MinLength int `yaml:"min_length" json:"minLength,omitempty"`
+	// Language matches items in the language, like en or en-US, items without a declared language match any
+	Language string `yaml:"language" json:"language,omitempty"`
+	// Invert applies the action to the items the conditions do not match, e.g. to skip all but some
+	Invert bool     `yaml:"invert" json:"invert,omitempty"`
+	Action string   `yaml:"action" json:"action"`
+	Tags   []string `yaml:"tags" json:"tags,omitempty"`
+}
+
+// FilterPreview tells what the rules do with an item of the feed
+type FilterPreview struct {
+	GUID        string
+	Title       string
+	Link        string
+	Matched     []string // names of the matching rules
+	Skipped     bool
+	Prioritized bool
+	Tags        []string
+}
+
+// filterDecision is the outcome of all rules for an item
+type filterDecision struct {
+	matched    []string
+	skip       bool
+	prioritize bool
+	tags       []string
+}
+
+// compiledRule is a validated rule with its pattern compiled
+type compiledRule struct {
+	FilterRule
+	name    string
+	pattern *regexp.Regexp
+}
+
+// itemFilter evaluates the rules of a single feed
+type itemFilter struct {
+	rules        []compiledRule
+	feedLanguage string
+}
+
+// validateFilters reports every invalid feed filter
+func validateFilters(filters []FeedFilter) []error {
+	var errs []error
+
+	seen := make(map[string]bool)
+	for _, filter := range filters {
+		if _, err := extractor.ParseURL(filter.URL); err != nil {
+			errs = append(errs, fmt.Errorf("filter of feed %q is invalid: %v", filter.URL, err))
+		}
+		if seen[filter.URL] {
+			errs = append(errs, fmt.Errorf("feed %q has more than one filter", filter.URL))
+		}
+		seen[filter.URL] = true
+
+		if _, err := compileRules(filter.Rules); err != nil {
+			errs = append(errs, fmt.Errorf("filter of feed %q: %v", filter.URL, err))
+		}
+	}
+
+	return errs
+}
+
+// compileRules validates the rules and compiles their patterns, all invalid rules are reported at once
+func compileRules(rules []FilterRule) ([]compiledRule, error) {
+	var errs []error
+
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		result := compiledRule{FilterRule: rule, name: rule.Name}
+		if result.name == "" {
+			result.name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		if !slices.Contains([]string{"", FieldTitle, FieldContent, FieldAuthor, FieldCategory}, rule.Field) {
+			errs = append(errs, fmt.Errorf("%s: unknown field %q", result.name, rule.Field))
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: pattern is invalid: %v", result.name, err))
+			}
+			result.pattern = pattern
+		}
+		if rule.MinLength < 0 {
+			errs = append(errs, fmt.Errorf("%s: min_length should not be negative", result.name))
+		}
+		if len(rule.Keywords) == 0 && rule.Pattern == "" && rule.MinLength == 0 && rule.Language == "" {
+			errs = append(errs, fmt.Errorf("%s: has no condition", result.name))
+		}
+
+		switch rule.Action {
+		case ActionSkip, ActionPrioritize:
+		case ActionTag:
+			if len(rule.Tags) == 0 {
+				errs = append(errs, fmt.Errorf("%s: tags are empty", result.name))
+			}
+			for _, tag := range rule.Tags {
+				if strings.TrimSpace(tag) == "" || strings.Contains(tag, ",") {
+					errs = append(errs, fmt.Errorf("%s: tag %q should not be blank or contain commas", result.name, tag))
+				}
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown action %q", result.name, rule.Action))
+		}
+
+		compiled = append(compiled, result)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+// filterFor returns the rules of the feed with the given URL, nil when it has none
+func (s Settings) filterFor(feedURL string) []FilterRule {
+	for _, filter := range s.Filters {
+		if filter.URL == feedURL {
+			return filter.Rules
+		}
+	}
+	return nil
+}
+
+// newItemFilter evaluates the rules for the items of the feed, its language applies to items without their own
+func newItemFilter(rules []compiledRule, feed *gofeed.Feed) *itemFilter {
+	return &itemFilter{rules: rules, feedLanguage: feed.Language}
+}
+
+// evaluate applies all rules to the item, a skipping rule does not stop the others from being reported
+func (f *itemFilter) evaluate(item *gofeed.Item) filterDecision {
+	decision := filterDecision{}
+
+	for _, rule := range f.rules {
+		if f.matches(rule, item) == rule.Invert {
+			continue
+		}
+
+		decision.matched = append(decision.matched, rule.name)
+		switch rule.Action {
+		case ActionSkip:
+			decision.skip = true
+		case ActionPrioritize:
+			decision.prioritize = true
+		case ActionTag:
+			for _, tag := range rule.Tags {
+				if tag = strings.TrimSpace(tag); !slices.Contains(decision.tags, tag) {
+					decision.tags = append(decision.tags, tag)
+				}
+			}
+		}
+	}
+
+	return decision
+}
+
+// matches reports whether all conditions of the rule which are set hold for the item
+func (f *itemFilter) matches(rule compiledRule, item *gofeed.Item) bool {
+	values := itemFields(item, rule.Field)
+
+	if len(rule.Keywords) > 0 && !containsKeyword(values, rule.Keywords) {
+		return false
+	}
+	if rule.pattern != nil && !slices.ContainsFunc(values, rule.pattern.MatchString) {
+		return false
+	}
+	if rule.MinLength > 0 && utf8.RuneCountInString(itemContent(item)) < rule.MinLength {
+		return false
+	}
+	if rule.Language != "" && !sameLanguage(f.itemLanguage(item), rule.Language) {
+		return false
+	}
+
+	return true
+}
+
+// itemLanguage returns the declared language of the item, or of its feed
+func (f *itemFilter) itemLanguage(item *gofeed.Item) string {
+	if item.DublinCoreExt != nil && len(item.DublinCoreExt.Language) > 0 {
+		return item.DublinCoreExt.Language[0]
+	}
+	return f.feedLanguage
+}
+
+// itemFields returns the values of the field of the item, of all fields when it is empty
+func itemFields(item *gofeed.Item, field string) []string {
+	var values []string
+
+	if field == "" || field == FieldTitle {
+		values = append(values, item.Title)
+	}
+	if field == "" || field == FieldContent {
+		values = append(values, itemContent(item))
+	}
+	if field == "" || field == FieldAuthor {
+		if item.Author != nil {
+			values = append(values, item.Author.Name, item.Author.Email)
+		}
+		for _, author := range item.Authors {
+			if author != nil {
+				values = append(values, author.Name, author.Email)
+			}
+		}
+	}
+	if field == "" || field == FieldCategory {
+		values = append(values, item.Categories...)
+	}
+
+	return values
+}
+
+// itemContent returns the content of the item, its description when the feed has no content
+func itemContent(item *gofeed.Item) string {
+	if item.Content != "" {
+		return item.Content
+	}
+	return item.Description
+}
+
+// joinTags joins the tags for the post, leaving out the ones which do not fit
+func joinTags(tags []string) string {
+	var joined string
+	for _, tag := range tags {
+		next := tag
+		if joined != "" {
+			next = joined + "," + tag
+		}
+		if len(next) > maxTagsLength {
+			continue
+		}
+		joined = next
+	}
+	return joined
+}
+
+func containsKeyword(values []string, keywords []string) bool {
+	for _, value := range values {
+		value = strings.ToLower(value)
+		for _, keyword := range keywords {
+			if keyword != "" && strings.Contains(value, strings.ToLower(keyword)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameLanguage compares language tags by their primary language unless the wanted one has a region,
+// an undeclared language matches any
+func sameLanguage(language string, wanted string) bool {
+	language = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(language), "_", "-"))
+	wanted = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(wanted), "_", "-"))
+	if language == "" {
+		return true
+	}
+	if strings.Contains(wanted, "-") {
+		return language == wanted
+	}
+
+	primary, _, _ := strings.Cut(language, "-")
+	return primary == wanted
+}
+
+// PreviewFilters fetches the feed given by its ID or URL and tells what the rules would do with its current items,
+// the configured rules of the feed are previewed when rules is nil
+func (w *Worker) PreviewFilters(ctx context.Context, feedID string, rules []FilterRule) ([]*FilterPreview, error) {
+	feedURL, err := w.feedURL(feedID)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = w.settings().filterFor(feedURL)
+	}
+	compiled, err := compileRules(rules)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+
+	result, err := newFeedFetcher().Fetch(ctx, feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %v", err)
+	}
+
+	filter := newItemFilter(compiled, result.Feed)
+
+	previews := make([]*FilterPreview, 0, len(result.Feed.Items))
+	for _, item := range result.Feed.Items {
+		decision := filter.evaluate(item)
+		previews = append(previews, &FilterPreview{
+			GUID:        item.GUID,
+			Title:       item.Title,
+			Link:        item.Link,
+			Matched:     decision.matched,
+			Skipped:     decision.skip,
+			Prioritized: decision.prioritize,
+			Tags:        decision.tags,
+		})
+	}
+
+	return previews, nil
+}
@@ -0,0 +1,245 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCompileRules(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		rules, err := compileRules([]FilterRule{
+			{Name: "ads", Keywords: []string{"sponsored"}, Action: ActionSkip},
+			{Field: FieldTitle, Pattern: `(?i)\bgo\b`, Action: ActionTag, Tags: []string{"go"}},
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, rules, 2)
+		assert.Equal(t, "ads", rules[0].name)
+		assert.Equal(t, "rule 2", rules[1].name)
+		assert.NotNil(t, rules[1].pattern)
+	})
+
+	t.Run("AllErrors", func(t *testing.T) {
+		_, err := compileRules([]FilterRule{
+			{Field: "body", Pattern: "(", Action: "drop"},
+			{Name: "empty", Action: ActionSkip},
+			{Name: "tags", MinLength: -1, Action: ActionTag, Tags: []string{"a,b"}},
+		})
+
+		assert.ErrorContains(t, err, `rule 1: unknown field "body"`)
+		assert.ErrorContains(t, err, "rule 1: pattern is invalid")
+		assert.ErrorContains(t, err, `rule 1: unknown action "drop"`)
+		assert.ErrorContains(t, err, "empty: has no condition")
+		assert.ErrorContains(t, err, "tags: min_length should not be negative")
+		assert.ErrorContains(t, err, `tags: tag "a,b" should not be blank or contain commas`)
+	})
+}
+
+func TestValidateFilters(t *testing.T) {
+	settings := DefaultSettings()
+	settings.RSSFeedsURLs = []string{"http://example.com/feed"}
+	settings.Filters = []FeedFilter{
+		{URL: "http://example.com/feed", Rules: []FilterRule{{Keywords: []string{"ads"}, Action: ActionSkip}}},
+		{URL: "http://example.com/feed", Rules: []FilterRule{{Action: ActionSkip}}},
+		{URL: "example.com/other"},
+	}
+
+	err := settings.Validate()
+
+	assert.ErrorContains(t, err, `feed "http://example.com/feed" has more than one filter`)
+	assert.ErrorContains(t, err, `filter of feed "http://example.com/feed": rule 1: has no condition`)
+	assert.ErrorContains(t, err, `filter of feed "example.com/other" is invalid`)
+}
+
+func TestEvaluate(t *testing.T) {
+	feed := &gofeed.Feed{Language: "en-us"}
+	item := &gofeed.Item{
+		GUID:        "1",
+		Title:       "Go 1.24 is released",
+		Description: "The Go team announces a sponsored release",
+		Author:      &gofeed.Person{Name: "Gopher"},
+		Categories:  []string{"Programming"},
+	}
+
+	tbl := []struct {
+		name     string
+		rule     FilterRule
+		expected bool
+	}{
+		{"Keyword", FilterRule{Keywords: []string{"SPONSORED"}}, true},
+		{"KeywordOfOtherField", FilterRule{Field: FieldTitle, Keywords: []string{"sponsored"}}, false},
+		{"Pattern", FilterRule{Field: FieldTitle, Pattern: `^Go \d+\.\d+`}, true},
+		{"Author", FilterRule{Field: FieldAuthor, Keywords: []string{"gopher"}}, true},
+		{"Category", FilterRule{Field: FieldCategory, Pattern: "^Programming$"}, true},
+		{"MinLength", FilterRule{MinLength: 100}, false},
+		{"FeedLanguage", FilterRule{Language: "en"}, true},
+		{"OtherLanguage", FilterRule{Language: "de"}, false},
+		{"Region", FilterRule{Language: "en-GB"}, false},
+		{"AllConditions", FilterRule{Keywords: []string{"release"}, Language: "de"}, false},
+		{"Inverted", FilterRule{MinLength: 100, Invert: true}, true},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			tt.rule.Action = ActionSkip
+			rules, err := compileRules([]FilterRule{tt.rule})
+			assert.NoError(t, err)
+
+			// Execute
+			decision := newItemFilter(rules, feed).evaluate(item)
+
+			// Verify
+			assert.Equal(t, tt.expected, decision.skip)
+		})
+	}
+
+	t.Run("Actions", func(t *testing.T) {
+		// Setup
+		rules, err := compileRules([]FilterRule{
+			{Name: "go", Field: FieldTitle, Keywords: []string{"go"}, Action: ActionTag, Tags: []string{"go", "release"}},
+			{Name: "lang", Field: FieldCategory, Keywords: []string{"programming"}, Action: ActionTag, Tags: []string{"go", "programming"}},
+			{Name: "urgent", Keywords: []string{"released"}, Action: ActionPrioritize},
+			{Name: "rust", Keywords: []string{"rust"}, Action: ActionSkip},
+		})
+		assert.NoError(t, err)
+
+		// Execute
+		decision := newItemFilter(rules, feed).evaluate(item)
+
+		// Verify
+		assert.Equal(t, []string{"go", "lang", "urgent"}, decision.matched)
+		assert.Equal(t, []string{"go", "release", "programming"}, decision.tags)
+		assert.True(t, decision.prioritize)
+		assert.False(t, decision.skip)
+	})
+
+	t.Run("ItemLanguage", func(t *testing.T) {
+		// Setup
+		rules, err := compileRules([]FilterRule{{Language: "de", Action: ActionSkip}})
+		assert.NoError(t, err)
+		german := &gofeed.Item{DublinCoreExt: &ext.DublinCoreExtension{Language: []string{"de_DE"}}}
+		undeclared := &gofeed.Item{}
+
+		// Execute
+		filter := newItemFilter(rules, &gofeed.Feed{})
+
+		// Verify
+		assert.True(t, filter.evaluate(german).skip)
+		assert.True(t, filter.evaluate(undeclared).skip)
+	})
+}
+
+func TestJoinTags(t *testing.T) {
+	long := strings.Repeat("a", maxTagsLength)
+
+	assert.Equal(t, "go,release", joinTags([]string{"go", "release"}))
+	assert.Equal(t, long, joinTags([]string{long, "go"}))
+	assert.Equal(t, "", joinTags(nil))
+}
+
+func TestStoreFeedItemsFilters(t *testing.T) {
+	// Setup
+	mockBlogger := new(MockBlogger)
+	feed := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed"}
+	fresh := &gofeed.Feed{Items: []*gofeed.Item{
+		{GUID: "1", Title: "Sponsored: buy now", Link: "http://example.com/1", Content: "Text 1"},
+		{GUID: "2", Title: "Go 1.24", Link: "http://example.com/2", Content: "Text 2"},
+		{GUID: "3", Title: "Weekly notes", Link: "http://example.com/3", Content: "Text 3"},
+	}}
+	mockBlogger.On("GetPosts", 1, 3, "hash-1").Return(&store.PaginationPostsResult{Posts: []*store.PostV1{}}, nil)
+	mockBlogger.On("EnqueueJobs", mock.MatchedBy(func(jobs []*store.JobV1) bool {
+		return len(jobs) == 2 &&
+			jobs[0].Post.ID == "2" && jobs[0].Post.Tags == "go" && jobs[0].Priority == jobPriorityHigh &&
+			jobs[1].Post.ID == "3" && jobs[1].Post.Tags == "" && jobs[1].Priority == 0
+	})).Return(2, nil)
+
+	w := Worker{Blogger: mockBlogger, Settings: Settings{
+		RSSFeedLimit: 3,
+		Filters: []FeedFilter{{URL: "http://example.com/feed", Rules: []FilterRule{
+			{Field: FieldTitle, Keywords: []string{"sponsored"}, Action: ActionSkip},
+			{Field: FieldTitle, Pattern: `^Go\b`, Action: ActionTag, Tags: []string{"go"}},
+			{Field: FieldTitle, Pattern: `^Go\b`, Action: ActionPrioritize},
+		}}},
+	}}
+
+	// Execute
+	run := feedRun{}
+	err := w.storeFeedItems(context.Background(), feed, fresh, &run)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, 3, run.ItemsSeen)
+	assert.Equal(t, 2, run.ItemsNew)
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestPreviewFilters(t *testing.T) {
+	// Create a mock server that returns a feed
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Feed</title>
+<item><guid>1</guid><title>Sponsored: buy now</title><link>http://example.com/1</link></item>
+<item><guid>2</guid><title>Go 1.24</title><link>http://example.com/2</link></item>
+</channel></rss>`))
+	}))
+	defer ts.Close()
+
+	mockHasher := new(MockHasher)
+	mockHasher.On("HashString", ts.URL).Return("hash-1")
+	w := Worker{Hasher: mockHasher, Settings: Settings{
+		RSSFeedsURLs: []string{ts.URL},
+		Filters:      []FeedFilter{{URL: ts.URL, Rules: []FilterRule{{Name: "ads", Keywords: []string{"sponsored"}, Action: ActionSkip}}}},
+	}}
+
+	t.Run("Configured", func(t *testing.T) {
+		// Execute
+		previews, err := w.PreviewFilters(context.Background(), "hash-1", nil)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Len(t, previews, 2)
+		assert.Equal(t, "1", previews[0].GUID)
+		assert.Equal(t, []string{"ads"}, previews[0].Matched)
+		assert.True(t, previews[0].Skipped)
+		assert.Empty(t, previews[1].Matched)
+		assert.False(t, previews[1].Skipped)
+	})
+
+	t.Run("Given", func(t *testing.T) {
+		// Execute
+		previews, err := w.PreviewFilters(context.Background(), ts.URL, []FilterRule{
+			{Name: "go", Field: FieldTitle, Keywords: []string{"go"}, Action: ActionTag, Tags: []string{"go"}},
+		})
+
+		// Verify
+		assert.NoError(t, err)
+		assert.False(t, previews[0].Skipped)
+		assert.Equal(t, []string{"go"}, previews[1].Tags)
+	})
+
+	t.Run("InvalidRule", func(t *testing.T) {
+		// Execute
+		_, err := w.PreviewFilters(context.Background(), "hash-1", []FilterRule{{Action: ActionSkip}})
+
+		// Verify
+		assert.ErrorIs(t, err, ErrInvalidFilter)
+	})
+
+	t.Run("UnknownFeed", func(t *testing.T) {
+		// Execute
+		_, err := w.PreviewFilters(context.Background(), "hash-2", nil)
+
+		// Verify
+		assert.ErrorIs(t, err, ErrFeedNotFound)
+	})
+}
@@ -0,0 +1,249 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rjxby/rss-sum/backend/extractor"
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/tracing"
+)
+
+const (
+	// jobPollInterval is how often idle job workers look for due retries
+	jobPollInterval = 5 * time.Second
+	// jobRetryBaseDelay is doubled with every failed attempt up to jobRetryMaxDelay
+	jobRetryBaseDelay = 30 * time.Second
+	jobRetryMaxDelay  = 6 * time.Hour
+	// maxJobErrorLength fits the last error into its column
+	maxJobErrorLength = 1000
+	// jobPriorityLow is the priority of the scoring jobs, new posts are summarized first
+	jobPriorityLow = -1
+)
+
+// DrainJobs processes queued jobs until none is due, used to finish a one-off fetch
+func (w *Worker) DrainJobs(ctx context.Context) error {
+	for ctx.Err() == nil {
+		processed, err := w.processNextJob(ctx)
+		if err != nil {
+			return err
+		}
+		if !processed {
+			return nil
+		}
+	}
+
+	return ctx.Err()
+}
+
+// runJobs processes queued jobs until the context is cancelled
+func (w *Worker) runJobs(ctx context.Context) {
+	for ctx.Err() == nil {
+		processed, err := w.processNextJob(ctx)
+		if err != nil {
+			slog.Error("failed to process job", "error", err)
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-w.jobsWake():
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+func (w *Worker) jobsWake() chan struct{} {
+	w.wakeOnce.Do(func() {
+		w.wake = make(chan struct{}, 1)
+	})
+	return w.wake
+}
+
+// wakeJobs tells an idle job worker that new jobs were queued
+func (w *Worker) wakeJobs() {
+	select {
+	case w.jobsWake() <- struct{}{}:
+	default:
+	}
+}
+
+// processNextJob leases a single job and runs it, reports false when no job is due
+func (w *Worker) processNextJob(ctx context.Context) (bool, error) {
+	lease := time.Duration(w.settings().JobLeaseInSeconds) * time.Second
+
+	jobs, err := w.Blogger.LeaseJobs(ctx, lease, 1)
+	if err != nil {
+		return false, fmt.Errorf("failed to lease job: %v", err)
+	}
+	if len(jobs) == 0 {
+		return false, nil
+	}
+	job := jobs[0]
+
+	ctx, span := tracer.Start(ctx, "worker.job", trace.WithAttributes(
+		attribute.Int64("job.id", int64(job.ID)),
+		attribute.String("job.kind", job.Kind),
+		attribute.Int("job.attempt", job.Attempts),
+	))
+	defer span.End()
+
+	// finish before the lease expires, otherwise another worker picks the job up
+	jobCtx, cancel := context.WithTimeout(ctx, lease)
+	defer cancel()
+
+	jobErr := tracing.Error(span, w.runJob(jobCtx, job))
+
+	return true, w.finishJob(ctx, job, jobErr)
+}
+
+func (w *Worker) runJob(ctx context.Context, job *store.JobV1) error {
+	jobLogger(job).InfoContext(ctx, "run job", "attempt", job.Attempts)
+
+	switch job.Kind {
+	case store.JobKindSummarizePost:
+		post := job.Post
+		return w.summarizePost(ctx, &post)
+	case store.JobKindSaveLink:
+		return w.saveLink(ctx, job.Post.SourceURL)
+	case store.JobKindScorePost:
+		return w.scorePost(ctx, job.Post.ID)
+	default:
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+}
+
+// finishJob completes a successful job, a failed one is retried with exponential backoff
+// until it runs out of attempts and becomes dead
+func (w *Worker) finishJob(ctx context.Context, job *store.JobV1, jobErr error) error {
+	if jobErr == nil {
+		if err := w.Blogger.CompleteJob(ctx, job.ID); err != nil {
+			return fmt.Errorf("failed to complete job %d: %v", job.ID, err)
+		}
+		return nil
+	}
+
+	job.LastError = extractor.Truncate(jobErr.Error(), maxJobErrorLength)
+	job.LeasedUntil = nil
+
+	if job.Attempts >= w.settings().JobMaxAttempts {
+		jobLogger(job).ErrorContext(ctx, "job is dead, attempts exhausted", "attempts", job.Attempts, "error", jobErr)
+		job.Status = store.JobStatusDead
+	} else {
+		delay := jobRetryDelay(job.Attempts)
+		jobLogger(job).WarnContext(ctx, "job failed, retry later", "attempt", job.Attempts, "retry_in", delay, "error", jobErr)
+		job.Status = store.JobStatusPending
+		job.RunAt = time.Now().UTC().Add(delay)
+	}
+
+	if err := w.Blogger.SaveJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to save job %d: %v", job.ID, err)
+	}
+
+	return nil
+}
+
+func jobLogger(job *store.JobV1) *slog.Logger {
+	return slog.With("job", job.ID, "kind", job.Kind, "url", job.Post.SourceURL)
+}
+
+func jobRetryDelay(attempts int) time.Duration {
+	delay := jobRetryBaseDelay
+	for i := 1; i < attempts && delay < jobRetryMaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, jobRetryMaxDelay)
+}
+
+// saveLink extracts a submitted web page and summarizes it into the saved links partition
+func (w *Worker) saveLink(ctx context.Context, link string) error {
+	article, err := w.Extractor.Extract(ctx, link)
+	if err != nil {
+		return fmt.Errorf("failed to extract content: %v", err)
+	}
+
+	return w.summarizePost(ctx, &store.PostV1{
+		ID:           article.URL,
+		PartitionKey: store.SavedLinksPartitionKey,
+		SourceURL:    article.URL,
+		Title:        article.Title,
+		Text:         article.Text,
+		CreatedAt:    time.Now().UTC(),
+	})
+}
+
+// summarizePost summarizes, stores and embeds the post and queues its scoring unless it is stored already,
+// e.g. by an attempt which could not complete its job
+func (w *Worker) summarizePost(ctx context.Context, post *store.PostV1) error {
+	storedPosts, err := w.Blogger.GetPostsByIDs(ctx, []string{post.ID})
+	if err != nil {
+		return fmt.Errorf("failed to load existing posts: %v", err)
+	}
+	if len(w.distinctNewPosts([]*store.PostV1{post}, storedPosts)) == 0 {
+		slog.InfoContext(ctx, "post is already saved", "post", post.SourceURL)
+		return nil
+	}
+
+	summirizedText, err := w.Assistent.SummarizeText(ctx, post.Text)
+	if err != nil {
+		return fmt.Errorf("failed to summarize post: %v", err)
+	}
+	post.Text = summirizedText
+	post.Model = w.Assistent.Model()
+
+	if _, err := w.Blogger.SavePostsBulk(ctx, []*store.PostV1{post}); err != nil {
+		return fmt.Errorf("failed to save post: %v", err)
+	}
+
+	slog.InfoContext(ctx, "processed post", "post", post.SourceURL, "partition", post.PartitionKey)
+
+	w.embedPosts(ctx, []*store.PostV1{post})
+	if err := w.queueScoring(ctx, []*store.PostV1{post}); err != nil {
+		slog.WarnContext(ctx, "failed to queue post scoring", "post", post.SourceURL, "error", err)
+	}
+
+	if post.PartitionKey != store.SavedLinksPartitionKey {
+		if err := w.Blogger.IncrementFeedItemsSummarized(ctx, post.PartitionKey); err != nil {
+			slog.WarnContext(ctx, "failed to count summarized post", "post", post.SourceURL, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// scorePost stores the relevance of the stored post to the interest profiles,
+// a post deleted in the meantime or removed profiles leave nothing to score
+func (w *Worker) scorePost(ctx context.Context, postID string) error {
+	if len(w.Assistent.ProfileNames()) == 0 {
+		return nil
+	}
+
+	posts, err := w.Blogger.GetPostsByIDs(ctx, []string{postID})
+	if err != nil {
+		return fmt.Errorf("failed to load post: %v", err)
+	}
+	if len(posts) == 0 {
+		slog.InfoContext(ctx, "scored post is deleted", "post", postID)
+		return nil
+	}
+	post := posts[0]
+
+	scores, err := w.Assistent.ScoreRelevance(ctx, embeddingText(post))
+	if err != nil {
+		return fmt.Errorf("failed to score post: %v", err)
+	}
+
+	if err := w.Blogger.SavePostScores(ctx, post.ID, w.Assistent.Model(), scores); err != nil {
+		return fmt.Errorf("failed to save post scores: %v", err)
+	}
+
+	return nil
+}
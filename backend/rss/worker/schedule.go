@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// scheduleTick is how often the worker looks for feeds that are due
+	scheduleTick = time.Minute
+	// jitterRatio spreads fetches of feeds that share the same interval
+	jitterRatio = 0.1
+)
+
+// nextInterval polls busy feeds more often and quiet feeds less often, honouring publisher hints
+func (w *Worker) nextInterval(current time.Duration, newPosts int, fetchErr error, hints scheduleHints) time.Duration {
+	interval := current
+	switch {
+	case fetchErr != nil:
+		// keep the pace, failures are not a signal of feed activity
+	case newPosts > 0:
+		interval = current / 2
+	default:
+		interval = current * 3 / 2
+	}
+
+	interval = max(interval, hints.MinInterval)
+
+	settings := w.settings()
+	minInterval := time.Duration(settings.WorkerMinIntervalInSeconds) * time.Second
+	maxInterval := time.Duration(settings.WorkerMaxIntervalInSeconds) * time.Second
+
+	return min(max(interval, minInterval), maxInterval)
+}
+
+// nextFetchAt applies jitter to the interval and postpones the fetch when the server asked to retry later
+func nextFetchAt(now time.Time, interval time.Duration, hints scheduleHints) time.Time {
+	jitter := time.Duration((rand.Float64()*2 - 1) * jitterRatio * float64(interval))
+	next := now.Add(interval + jitter)
+
+	if retryAt := now.Add(hints.RetryAfter); retryAt.After(next) {
+		next = retryAt
+	}
+
+	return next
+}
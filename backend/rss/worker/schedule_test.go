@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextInterval(t *testing.T) {
+	tbl := []struct {
+		name     string
+		current  time.Duration
+		newPosts int
+		fetchErr error
+		hints    scheduleHints
+		expected time.Duration
+	}{
+		{"BusyFeed", time.Hour, 2, nil, scheduleHints{}, 30 * time.Minute},
+		{"QuietFeed", time.Hour, 0, nil, scheduleHints{}, 90 * time.Minute},
+		{"FailedFetch", time.Hour, 0, errors.New("timeout"), scheduleHints{}, time.Hour},
+		{"PublisherHint", time.Hour, 2, nil, scheduleHints{MinInterval: 2 * time.Hour}, 2 * time.Hour},
+		{"MinBound", 6 * time.Minute, 1, nil, scheduleHints{}, 5 * time.Minute},
+		{"MaxBound", 20 * time.Hour, 0, nil, scheduleHints{}, 24 * time.Hour},
+		{"HintAboveMax", time.Hour, 0, nil, scheduleHints{MinInterval: 7 * 24 * time.Hour}, 24 * time.Hour},
+	}
+
+	w := Worker{Settings: Settings{
+		WorkerMinIntervalInSeconds: 300,
+		WorkerMaxIntervalInSeconds: 86400,
+	}}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			result := w.nextInterval(tt.current, tt.newPosts, tt.fetchErr, tt.hints)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestNextFetchAt(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Jitter", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			next := nextFetchAt(now, time.Hour, scheduleHints{})
+			assert.WithinDuration(t, now.Add(time.Hour), next, 6*time.Minute)
+		}
+	})
+
+	t.Run("RetryAfter", func(t *testing.T) {
+		next := nextFetchAt(now, time.Hour, scheduleHints{RetryAfter: 3 * time.Hour})
+		assert.Equal(t, now.Add(3*time.Hour), next)
+	})
+}
@@ -2,34 +2,71 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"strconv"
-	"strings"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mmcdole/gofeed"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/rjxby/rss-sum/backend/extractor"
+	"github.com/rjxby/rss-sum/backend/metrics"
 	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/tracing"
 )
 
+var tracer = otel.Tracer("github.com/rjxby/rss-sum/backend/rss/worker")
+
 type Settings struct {
-	RSSFeedsURLs            []string
-	RSSFeedLimit            int
-	WorkerIntervalInSeconds int
-	WorkerTimeoutInSeconds  int
+	RSSFeedsURLs               []string `yaml:"feeds"`
+	RSSFeedLimit               int      `yaml:"feed_items_limit"`
+	WorkerIntervalInSeconds    int      `yaml:"interval_in_seconds"`
+	WorkerMinIntervalInSeconds int      `yaml:"min_interval_in_seconds"`
+	WorkerMaxIntervalInSeconds int      `yaml:"max_interval_in_seconds"`
+	WorkerTimeoutInSeconds     int      `yaml:"timeout_in_seconds"`
+	// FeedMaxConsecutiveFailures disables a feed after that many failed fetches in a row, 0 never disables
+	FeedMaxConsecutiveFailures int `yaml:"feed_max_consecutive_failures"`
+	JobWorkers                 int `yaml:"job_workers"`
+	JobMaxAttempts             int `yaml:"job_max_attempts"`
+	JobLeaseInSeconds          int `yaml:"job_lease_in_seconds"`
+	// Filters skip, tag or prioritize the new items of single feeds before they are summarized
+	Filters []FeedFilter `yaml:"filters"`
 }
 
 // Blogger defines an interface to save and load data
 type Blogger interface {
-	GetPosts(page int, pageSize int, partitionKey string) (*store.PaginationPostsResult, error)
-	SavePostsBulk(postsToSave []*store.PostV1) ([]*store.PostV1, error)
+	GetPosts(ctx context.Context, page int, pageSize int, partitionKey string) (*store.PaginationPostsResult, error)
+	SavePostsBulk(ctx context.Context, postsToSave []*store.PostV1) ([]*store.PostV1, error)
+	GetPostsByIDs(ctx context.Context, ids []string) ([]*store.PostV1, error)
+	GetPostsWithoutEmbedding(ctx context.Context, model string, limit int) ([]*store.PostV1, error)
+	SavePostEmbedding(ctx context.Context, postID string, model string, vector []float64) error
+	GetPostsWithoutScores(ctx context.Context, profiles []string, limit int) ([]*store.PostV1, error)
+	SavePostScores(ctx context.Context, postID string, model string, scores map[string]int) error
+	GetFeeds(ctx context.Context) ([]*store.FeedV1, error)
+	SaveFeed(ctx context.Context, feed *store.FeedV1) error
+	GetFeedHealth(ctx context.Context, feedID string) (*store.FeedHealthV1, error)
+	ResetFeedItemsSummarized(ctx context.Context, feedID string) error
+	SaveFeedRun(ctx context.Context, health *store.FeedHealthV1) error
+	IncrementFeedItemsSummarized(ctx context.Context, feedID string) error
+	EnqueueJobs(ctx context.Context, jobs []*store.JobV1) (int, error)
+	LeaseJobs(ctx context.Context, lease time.Duration, limit int) ([]*store.JobV1, error)
+	SaveJob(ctx context.Context, job *store.JobV1) error
+	CompleteJob(ctx context.Context, id uint64) error
 }
 
 // Assistent defines an interface to work with text
 type Assistent interface {
-	SummarizeText(text string) (string, error)
+	SummarizeText(ctx context.Context, text string) (string, error)
+	EmbedText(ctx context.Context, text string) ([]float64, error)
+	ScoreRelevance(ctx context.Context, text string) (map[string]int, error)
+	ProfileNames() []string
+	Model() string
+	EmbeddingModel() string
 }
 
 // Hasher defines an interface to hash data
@@ -37,191 +74,560 @@ type Hasher interface {
 	HashString(text string) string
 }
 
+// embeddingsBackfillLimit caps how many older posts get embedded per run
+const embeddingsBackfillLimit = 50
+
+// scoresBackfillLimit caps how many older posts get queued for scoring per run
+const scoresBackfillLimit = 50
+
+// Extractor defines an interface to read web pages
+type Extractor interface {
+	Extract(ctx context.Context, rawURL string) (*extractor.Article, error)
+}
+
 type Worker struct {
 	Assistent Assistent
 	Blogger   Blogger
 	Hasher    Hasher
+	Extractor Extractor
 	Settings  Settings
 	Version   string
+
+	wakeOnce sync.Once
+	wake     chan struct{}
+	running  atomic.Bool
+	reloaded atomic.Pointer[Settings]
+	// lastRunAt is the unix time in nanoseconds of the last finished fetch, or of the start of Run
+	lastRunAt atomic.Int64
 }
 
-func ParseSettings() (*Settings, error) {
-	settings := Settings{}
+var (
+	// ErrFetchInProgress is returned when a fetch is requested while another one is running
+	ErrFetchInProgress = errors.New("fetch is already in progress")
+	// ErrFeedNotFound is returned when the requested feed is not subscribed
+	ErrFeedNotFound = errors.New("feed is not subscribed")
+	// ErrWorkerNotRunning is returned by CheckLastRun before the worker has been started
+	ErrWorkerNotRunning = errors.New("worker is not running")
+)
 
-	workerFeedsStr := os.Getenv("FEEDS")
-	if workerFeedsStr == "" {
-		return nil, fmt.Errorf("FEEDS environment variable is empty; nothing to subscribe to")
-	}
-	settings.RSSFeedsURLs = strings.Split(workerFeedsStr, ",")
+// feedSelector picks the feeds to fetch in a run
+type feedSelector func(feed *store.FeedV1, now time.Time) bool
+
+// feedRun counts the items of a single feed fetch
+type feedRun struct {
+	StatusCode int
+	ItemsSeen  int
+	ItemsNew   int
+}
 
-	if len(settings.RSSFeedsURLs) == 0 {
-		return nil, fmt.Errorf("failed to parse FEEDS environment variable")
+// maxHealthErrorLength fits the last error into its column
+const maxHealthErrorLength = 1000
+
+// DefaultSettings returns the settings used for the values which are not configured
+func DefaultSettings() Settings {
+	return Settings{
+		RSSFeedLimit:               3,
+		WorkerIntervalInSeconds:    3600,  // 1 hour
+		WorkerMinIntervalInSeconds: 300,   // 5 min
+		WorkerMaxIntervalInSeconds: 86400, // 1 day
+		WorkerTimeoutInSeconds:     1800,  // 30 min
+		FeedMaxConsecutiveFailures: 10,
+		JobWorkers:                 1,
+		JobMaxAttempts:             5,
+		JobLeaseInSeconds:          600, // 10 min
 	}
+}
+
+// Validate reports every invalid setting
+func (s Settings) Validate() error {
+	var errs []error
 
-	workerTimeoutInSecondsStr := os.Getenv("WORKER_TIMEOUT_IN_SECONDS")
-	if workerTimeoutInSecondsStr == "" {
-		workerTimeoutInSecondsStr = "1800" // 1800s = 30 min
+	if len(s.RSSFeedsURLs) == 0 {
+		errs = append(errs, fmt.Errorf("feeds is empty; nothing to subscribe to"))
 	}
-	workerTimeoutInSeconds, err := strconv.Atoi(workerTimeoutInSecondsStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse WORKER_TIMEOUT_IN_SECONDS environment variable: %v", err)
+	for _, feedURL := range s.RSSFeedsURLs {
+		if _, err := extractor.ParseURL(feedURL); err != nil {
+			errs = append(errs, fmt.Errorf("feed %q is invalid: %v", feedURL, err))
+		}
 	}
-	settings.WorkerTimeoutInSeconds = workerTimeoutInSeconds
 
-	workerIntervalInSecondsStr := os.Getenv("WORKER_INTERVAL_IN_SECONDS")
-	if workerIntervalInSecondsStr == "" {
-		workerIntervalInSecondsStr = "3600" // 3600s = 1 hour
+	positive := []struct {
+		name  string
+		value int
+	}{
+		{"feed_items_limit", s.RSSFeedLimit},
+		{"interval_in_seconds", s.WorkerIntervalInSeconds},
+		{"min_interval_in_seconds", s.WorkerMinIntervalInSeconds},
+		{"max_interval_in_seconds", s.WorkerMaxIntervalInSeconds},
+		{"timeout_in_seconds", s.WorkerTimeoutInSeconds},
+		{"job_workers", s.JobWorkers},
+		{"job_max_attempts", s.JobMaxAttempts},
+		{"job_lease_in_seconds", s.JobLeaseInSeconds},
 	}
-	workerIntervalInSeconds, err := strconv.Atoi(workerIntervalInSecondsStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse WORKER_INTERVAL_IN_SECONDS environment variable: %v", err)
+	for _, setting := range positive {
+		if setting.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s should be positive", setting.name))
+		}
 	}
-	settings.WorkerIntervalInSeconds = workerIntervalInSeconds
 
-	rssFeedLimitStr := os.Getenv("FEED_ITEMS_LIMIT")
-	if rssFeedLimitStr == "" {
-		rssFeedLimitStr = "3"
+	if s.WorkerMinIntervalInSeconds > s.WorkerMaxIntervalInSeconds {
+		errs = append(errs, fmt.Errorf("min_interval_in_seconds should not be greater than max_interval_in_seconds"))
 	}
-	rssFeedLimit, err := strconv.Atoi(rssFeedLimitStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse FEED_ITEMS_LIMIT environment variable: %v", err)
+	if s.FeedMaxConsecutiveFailures < 0 {
+		errs = append(errs, fmt.Errorf("feed_max_consecutive_failures should not be negative"))
 	}
-	settings.RSSFeedLimit = rssFeedLimit
+	errs = append(errs, validateFilters(s.Filters)...)
 
-	return &settings, nil
+	return errors.Join(errs...)
+}
+
+// Reload swaps the settings of the following fetches and jobs.
+// The number of job workers is fixed once the worker runs, it changes with a restart
+func (w *Worker) Reload(settings Settings) {
+	w.reloaded.Store(&settings)
+}
+
+// settings returns the reloaded settings, or the initial ones until the first reload
+func (w *Worker) settings() *Settings {
+	if settings := w.reloaded.Load(); settings != nil {
+		return settings
+	}
+	return &w.Settings
 }
 
 // Run the RSS worker
-func (w Worker) Run(ctx context.Context) error {
-	log.Printf("[INFO] activate RSS worker")
+func (w *Worker) Run(ctx context.Context) error {
+	slog.Info("activate RSS worker", "workers", w.Settings.JobWorkers)
+	w.lastRunAt.Store(time.Now().UnixNano())
+
+	jobsWG := sync.WaitGroup{}
+	for i := 0; i < w.Settings.JobWorkers; i++ {
+		jobsWG.Add(1)
+		go func() {
+			defer jobsWG.Done()
+			w.runJobs(ctx)
+		}()
+	}
+	defer jobsWG.Wait()
 
-	ticker := time.NewTicker(time.Duration(w.Settings.WorkerIntervalInSeconds) * time.Second)
+	ticker := time.NewTicker(scheduleTick)
 	defer ticker.Stop()
 
+	// fetch due feeds right away instead of waiting for the first tick
+	w.runScheduledFetch()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
-			if err := w.runFetchPosts(); err != nil {
-				log.Printf("[ERROR] failed to fetch posts: %v", err)
-			}
+			w.runScheduledFetch()
 		}
 	}
 }
 
-func (w Worker) runFetchPosts() error {
-	log.Printf("[INFO] runFetchPosts triggered at {%v}", time.Now())
+// SubmitLink queues a single web page to be summarized into the saved links partition
+func (w *Worker) SubmitLink(ctx context.Context, link string) error {
+	job := &store.JobV1{
+		Kind:     store.JobKindSaveLink,
+		DedupKey: store.JobKindSaveLink + ":" + link,
+		Post:     store.PostV1{SourceURL: link},
+	}
 
-	fp := gofeed.NewParser()
+	if _, err := w.Blogger.EnqueueJobs(ctx, []*store.JobV1{job}); err != nil {
+		return fmt.Errorf("failed to queue link: %v", err)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(),
-		time.Duration(w.Settings.WorkerTimeoutInSeconds)*time.Second)
-	defer cancel()
+	w.wakeJobs()
+	return nil
+}
 
-	var finalErr error
+// Fetch fetches all feeds, or the single feed given by its ID or URL, regardless of their schedules
+func (w *Worker) Fetch(feedID string) error {
+	selectFeed, err := w.selectFeeds(feedID)
+	if err != nil {
+		return err
+	}
 
-	// Fetch fresh posts from all feeds
-	for _, feedURL := range w.Settings.RSSFeedsURLs {
-		var feed *gofeed.Feed
-		var err error
+	if !w.running.CompareAndSwap(false, true) {
+		return ErrFetchInProgress
+	}
+	defer w.running.Store(false)
 
-		for attempt := 0; attempt < 3; attempt++ {
-			if attempt > 0 {
-				log.Printf("[INFO] retry %d fetching feed %s", attempt, feedURL)
-				time.Sleep(time.Duration(attempt*2) * time.Second)
-			}
+	return w.runFetchPosts(selectFeed)
+}
 
-			feed, err = fp.ParseURLWithContext(feedURL, ctx)
-			if err == nil {
-				break
-			}
-			log.Printf("[WARN] attempt %d to fetch feed %s failed: %v", attempt+1, feedURL, err)
+// TriggerFetch runs Fetch in the background
+func (w *Worker) TriggerFetch(feedID string) error {
+	selectFeed, err := w.selectFeeds(feedID)
+	if err != nil {
+		return err
+	}
+
+	if !w.running.CompareAndSwap(false, true) {
+		return ErrFetchInProgress
+	}
+
+	go func() {
+		defer w.running.Store(false)
+
+		if err := w.runFetchPosts(selectFeed); err != nil {
+			slog.Error("failed to fetch posts", "error", err)
 		}
+	}()
 
-		if err != nil {
-			log.Printf("[ERROR] failed to parse feed (%v) after retries: %v", feedURL, err)
-			finalErr = fmt.Errorf("failed to parse feed: %v", err)
-			continue
+	return nil
+}
+
+// CheckLastRun reports an error when no fetch has finished for longer than a scheduled run may take,
+// e.g. because the scheduler is stuck
+func (w *Worker) CheckLastRun() error {
+	lastRunAt := w.lastRunAt.Load()
+	if lastRunAt == 0 {
+		return ErrWorkerNotRunning
+	}
+
+	staleAfter := scheduleTick + time.Duration(w.settings().WorkerTimeoutInSeconds)*time.Second
+	if since := time.Since(time.Unix(0, lastRunAt)); since > staleAfter {
+		return fmt.Errorf("last run finished %s ago", since.Round(time.Second))
+	}
+
+	return nil
+}
+
+func (w *Worker) runScheduledFetch() {
+	if !w.running.CompareAndSwap(false, true) {
+		slog.Info("skip scheduled fetch, another fetch is in progress")
+		return
+	}
+	defer w.running.Store(false)
+
+	if err := w.runFetchPosts(isDue); err != nil {
+		slog.Error("failed to fetch posts", "error", err)
+	}
+}
+
+func isDue(feed *store.FeedV1, now time.Time) bool {
+	return !feed.Disabled && !feed.NextFetchAt.After(now)
+}
+
+// selectFeeds matches a subscribed feed by its ID or URL, empty feedID selects all enabled feeds.
+// A single feed is fetched even when disabled, so it can be checked before enabling it again
+func (w *Worker) selectFeeds(feedID string) (feedSelector, error) {
+	if feedID == "" {
+		return func(feed *store.FeedV1, _ time.Time) bool { return !feed.Disabled }, nil
+	}
+
+	feedURL, err := w.feedURL(feedID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(feed *store.FeedV1, _ time.Time) bool { return feed.URL == feedURL }, nil
+}
+
+// feedURL returns the URL of the subscribed feed matched by its ID or URL
+func (w *Worker) feedURL(feedID string) (string, error) {
+	for _, feedURL := range w.settings().RSSFeedsURLs {
+		if feedURL == feedID || w.Hasher.HashString(feedURL) == feedID {
+			return feedURL, nil
 		}
+	}
 
-		if len(feed.Items) == 0 {
-			// feed is empty
+	return "", ErrFeedNotFound
+}
+
+func (w *Worker) runFetchPosts(selectFeed feedSelector) error {
+	started := time.Now()
+	slog.Info("fetch posts triggered")
+
+	fetcher := newFeedFetcher()
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Duration(w.settings().WorkerTimeoutInSeconds)*time.Second)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "worker.fetch_posts")
+	defer span.End()
+
+	var errs []error
+
+	feeds, err := w.syncFeeds(ctx)
+	if err != nil {
+		return tracing.Error(span, fmt.Errorf("failed to sync feeds: %v", err))
+	}
+
+	// Fetch fresh posts from the selected feeds
+	now := time.Now().UTC()
+	for _, feed := range feeds {
+		if !selectFeed(feed, now) {
 			continue
 		}
 
-		partitionKey := w.Hasher.HashString(feedURL)
-		freshPosts := []*store.PostV1{}
-		for _, item := range feed.Items[:min(len(feed.Items), w.Settings.RSSFeedLimit)] {
-			freshPosts = append(freshPosts, &store.PostV1{
-				ID:           item.GUID,
-				PartitionKey: partitionKey,
-				SourceURL:    item.Link,
-				Title:        item.Title,
-				Text:         item.Content,
-				CreatedAt:    time.Now().UTC(),
-			})
+		if err := w.fetchFeed(ctx, fetcher, feed); err != nil {
+			errs = append(errs, fmt.Errorf("feed %s: %v", feed.URL, err))
 		}
+	}
 
-		// Load stored posts from the database
-		storedPostsResult, err := w.Blogger.GetPosts(1, w.Settings.RSSFeedLimit, partitionKey)
-		if err != nil {
-			log.Printf("[ERROR] failed to load existing posts for feed %s: %v", feedURL, err)
-			finalErr = fmt.Errorf("failed to load existing posts: %v", err)
+	if err := w.backfillEmbeddings(ctx); err != nil {
+		slog.Error("failed to backfill embeddings", "error", err)
+		errs = append(errs, fmt.Errorf("failed to backfill embeddings: %v", err))
+	}
+	if err := w.backfillScores(ctx); err != nil {
+		slog.Error("failed to backfill scores", "error", err)
+		errs = append(errs, fmt.Errorf("failed to backfill scores: %v", err))
+	}
+
+	w.lastRunAt.Store(time.Now().UnixNano())
+	slog.Info("fetch posts finished", "duration", time.Since(started))
+	return tracing.Error(span, errors.Join(errs...))
+}
+
+// syncFeeds returns the schedules of the configured feeds, creating the missing ones as due
+func (w *Worker) syncFeeds(ctx context.Context) ([]*store.FeedV1, error) {
+	storedFeeds, err := w.Blogger.GetFeeds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feeds: %v", err)
+	}
+
+	storedMap := make(map[string]*store.FeedV1, len(storedFeeds))
+	for _, feed := range storedFeeds {
+		storedMap[feed.URL] = feed
+	}
+
+	settings := w.settings()
+	feeds := make([]*store.FeedV1, 0, len(settings.RSSFeedsURLs))
+	for _, feedURL := range settings.RSSFeedsURLs {
+		if feed, exists := storedMap[feedURL]; exists {
+			feeds = append(feeds, feed)
 			continue
 		}
-		storedPosts := storedPostsResult.Posts
 
-		postsToCreate := w.distinctNewPosts(freshPosts, storedPosts)
+		feed := &store.FeedV1{
+			ID:                w.Hasher.HashString(feedURL),
+			URL:               feedURL,
+			IntervalInSeconds: settings.WorkerIntervalInSeconds,
+			NextFetchAt:       time.Now().UTC(),
+		}
+		if err := w.Blogger.SaveFeed(ctx, feed); err != nil {
+			return nil, fmt.Errorf("failed to create feed %s: %v", feedURL, err)
+		}
 
-		var successfulPosts []*store.PostV1
+		feeds = append(feeds, feed)
+	}
 
-		for _, postToCreate := range postsToCreate {
-			var summirizedText string
-			var summarizeErr error
+	return feeds, nil
+}
+
+// fetchFeed stores fresh posts of the feed, records its health and schedules its next fetch
+func (w *Worker) fetchFeed(ctx context.Context, fetcher *feedFetcher, feed *store.FeedV1) error {
+	logger := slog.With("feed", feed.URL)
+
+	ctx, span := tracer.Start(ctx, "worker.fetch_feed", trace.WithAttributes(attribute.String("feed.url", feed.URL)))
+	defer span.End()
+
+	var result *fetchResult
+	var fetchErr error
+
+	started := time.Now()
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			logger.InfoContext(ctx, "retry fetching feed", "attempt", attempt)
+			time.Sleep(time.Duration(attempt*2) * time.Second)
+		}
 
-			for attempt := 0; attempt < 3; attempt++ {
-				if attempt > 0 {
-					log.Printf("[INFO] retry %d summarizing post %s", attempt, postToCreate.SourceURL)
-					time.Sleep(time.Duration(attempt) * time.Second)
-				}
+		result, fetchErr = fetcher.Fetch(ctx, feed.URL)
+		if fetchErr == nil {
+			break
+		}
+		logger.WarnContext(ctx, "failed to fetch feed", "attempt", attempt+1, "error", fetchErr)
 
-				summirizedText, summarizeErr = w.Assistent.SummarizeText(postToCreate.Text)
-				if summarizeErr == nil {
-					break
-				}
-				log.Printf("[WARN] attempt %d to summarize post %s failed: %v",
-					attempt+1, postToCreate.SourceURL, err)
-			}
+		// the server has answered, retrying right away would not change its mind
+		var statusErr fetchError
+		if errors.As(fetchErr, &statusErr) {
+			break
+		}
+	}
 
-			if summarizeErr != nil {
-				log.Printf("[ERROR] failed to summarize post (%v) after retries: %v",
-					postToCreate.SourceURL, err)
-				continue
-			}
+	metrics.FeedFetchDuration.WithLabelValues(feed.URL).Observe(time.Since(started).Seconds())
+	if fetchErr != nil {
+		metrics.FeedFetchesTotal.WithLabelValues(feed.URL, metrics.OutcomeError).Inc()
+	} else {
+		metrics.FeedFetchesTotal.WithLabelValues(feed.URL, metrics.OutcomeSuccess).Inc()
+	}
 
-			log.Printf("[INFO] runFetchPosts processed post: {%s}", postToCreate.SourceURL)
-			postToCreate.Text = summirizedText
-			successfulPosts = append(successfulPosts, postToCreate)
+	run := feedRun{StatusCode: result.StatusCode}
+	var storeErr error
+
+	if fetchErr != nil {
+		logger.ErrorContext(ctx, "failed to parse feed after retries", "error", fetchErr)
+		fetchErr = fmt.Errorf("failed to parse feed: %v", fetchErr)
+	} else {
+		// the jobs of the new items count up from zero, so the counter is reset before they are queued,
+		// a failed fetch queues nothing and keeps the count of the last run
+		if err := w.Blogger.ResetFeedItemsSummarized(ctx, feed.ID); err != nil {
+			logger.ErrorContext(ctx, "failed to reset summarized items", "error", err)
 		}
+		storeErr = w.storeFeedItems(ctx, feed, result.Feed, &run)
+	}
+
+	now := time.Now().UTC()
+	interval := w.nextInterval(time.Duration(feed.IntervalInSeconds)*time.Second, run.ItemsNew, fetchErr, result.Hints)
+	feed.IntervalInSeconds = int(interval.Seconds())
+	feed.NextFetchAt = nextFetchAt(now, interval, result.Hints)
+	feed.LastFetchedAt = &now
 
-		if len(successfulPosts) > 0 {
-			_, err := w.Blogger.SavePostsBulk(successfulPosts)
-			if err != nil {
-				log.Printf("[ERROR] failed to save posts for feed %s: %v", feedURL, err)
-				finalErr = fmt.Errorf("failed to save posts: %v", err)
-				continue
-			}
+	span.SetAttributes(
+		attribute.Int("http.status_code", run.StatusCode),
+		attribute.Int("feed.items_seen", run.ItemsSeen),
+		attribute.Int("feed.items_new", run.ItemsNew),
+	)
+
+	if err := w.recordHealth(ctx, feed, run, fetchErr, storeErr, now); err != nil {
+		logger.ErrorContext(ctx, "failed to record feed health", "error", err)
+	}
+
+	if err := w.Blogger.SaveFeed(ctx, feed); err != nil {
+		logger.ErrorContext(ctx, "failed to save feed schedule", "error", err)
+		return tracing.Error(span, fmt.Errorf("failed to save feed schedule: %v", err))
+	}
+
+	logger.InfoContext(ctx, "feed scheduled", "next_fetch_at", feed.NextFetchAt, "interval", interval,
+		"items_seen", run.ItemsSeen, "items_new", run.ItemsNew)
+	return tracing.Error(span, errors.Join(fetchErr, storeErr))
+}
+
+// recordHealth persists the outcome of the fetch and disables feeds which keep failing.
+// Only fetch failures count towards disabling, a failure to store the items is not the feed's fault
+func (w *Worker) recordHealth(ctx context.Context, feed *store.FeedV1, run feedRun, fetchErr error, storeErr error, now time.Time) error {
+	health, err := w.Blogger.GetFeedHealth(ctx, feed.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		health = &store.FeedHealthV1{FeedID: feed.ID}
+	} else if err != nil {
+		return fmt.Errorf("failed to load feed health: %v", err)
+	}
+
+	health.LastStatusCode = run.StatusCode
+	health.ItemsSeen = run.ItemsSeen
+	health.ItemsNew = run.ItemsNew
+
+	if fetchErr != nil {
+		health.ConsecutiveFailures++
+	} else {
+		health.ConsecutiveFailures = 0
+		health.LastSuccessAt = &now
+	}
 
-			log.Printf("[INFO] posts were updated for feed %s", feedURL)
+	if runErr := errors.Join(fetchErr, storeErr); runErr != nil {
+		health.LastErrorAt = &now
+		health.LastError = extractor.Truncate(runErr.Error(), maxHealthErrorLength)
+	}
+
+	maxFailures := w.settings().FeedMaxConsecutiveFailures
+	if maxFailures > 0 && health.ConsecutiveFailures >= maxFailures && !feed.Disabled {
+		slog.WarnContext(ctx, "disable feed after consecutive failures", "feed", feed.URL, "failures", health.ConsecutiveFailures)
+		feed.Disabled = true
+		feed.DisabledAt = &now
+	}
+
+	// the summarized items are counted up by the jobs which may run already, they are not written
+	if err := w.Blogger.SaveFeedRun(ctx, health); err != nil {
+		return fmt.Errorf("failed to save feed health: %v", err)
+	}
+
+	return nil
+}
+
+// storeFeedItems queues the posts which are not stored yet for summarization, counting them in the run.
+// The filter of the feed decides which new posts are skipped, tagged or prioritized
+func (w *Worker) storeFeedItems(ctx context.Context, feed *store.FeedV1, fresh *gofeed.Feed, run *feedRun) error {
+	run.ItemsSeen = len(fresh.Items)
+	if len(fresh.Items) == 0 {
+		// feed is empty
+		return nil
+	}
+
+	settings := w.settings()
+	rules, err := compileRules(settings.filterFor(feed.URL))
+	if err != nil {
+		return fmt.Errorf("failed to compile filter: %v", err)
+	}
+	filter := newItemFilter(rules, fresh)
+
+	freshPosts := []*store.PostV1{}
+	decisions := make(map[string]filterDecision)
+	limit := settings.RSSFeedLimit
+	for _, item := range fresh.Items[:min(len(fresh.Items), limit)] {
+		freshPosts = append(freshPosts, &store.PostV1{
+			ID:           item.GUID,
+			PartitionKey: feed.ID,
+			SourceURL:    item.Link,
+			Title:        item.Title,
+			Text:         item.Content,
+			CreatedAt:    time.Now().UTC(),
+		})
+		decisions[item.GUID] = filter.evaluate(item)
+	}
+
+	// Load stored posts from the database
+	storedPostsResult, err := w.Blogger.GetPosts(ctx, 1, limit, feed.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load existing posts", "feed", feed.URL, "error", err)
+		return fmt.Errorf("failed to load existing posts: %v", err)
+	}
+	storedPosts := storedPostsResult.Posts
+
+	postsToCreate := w.distinctNewPosts(freshPosts, storedPosts)
+
+	jobs := make([]*store.JobV1, 0, len(postsToCreate))
+	for _, post := range postsToCreate {
+		decision := decisions[post.ID]
+		if decision.skip {
+			// skipped items are never stored, they are evaluated again by the following fetches
+			metrics.FeedItemsFilteredTotal.WithLabelValues(feed.URL, ActionSkip).Inc()
+			slog.DebugContext(ctx, "skip filtered post", "feed", feed.URL, "post", post.SourceURL, "rules", decision.matched)
+			continue
+		}
+
+		job := &store.JobV1{
+			Kind:     store.JobKindSummarizePost,
+			DedupKey: store.JobKindSummarizePost + ":" + post.ID,
+			Post:     *post,
+		}
+		if len(decision.tags) > 0 {
+			job.Post.Tags = joinTags(decision.tags)
+			metrics.FeedItemsFilteredTotal.WithLabelValues(feed.URL, ActionTag).Inc()
 		}
+		if decision.prioritize {
+			job.Priority = jobPriorityHigh
+			metrics.FeedItemsFilteredTotal.WithLabelValues(feed.URL, ActionPrioritize).Inc()
+		}
+		jobs = append(jobs, job)
+	}
+
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	queued, err := w.Blogger.EnqueueJobs(ctx, jobs)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to queue posts", "feed", feed.URL, "error", err)
+		return fmt.Errorf("failed to queue posts: %v", err)
 	}
+	// skipped items and items which have a pending or dead job already do not count,
+	// they would keep the feed from being polled less often
+	run.ItemsNew = queued
+	if queued == 0 {
+		return nil
+	}
+
+	slog.InfoContext(ctx, "posts queued for summarization", "feed", feed.URL, "queued", queued)
+	w.wakeJobs()
 
-	log.Printf("[INFO] runFetchPosts finished at {%v}", time.Now())
-	return finalErr
+	return nil
 }
 
-func (w Worker) distinctNewPosts(freshPosts []*store.PostV1, storedPosts []*store.PostV1) []*store.PostV1 {
+func (w *Worker) distinctNewPosts(freshPosts []*store.PostV1, storedPosts []*store.PostV1) []*store.PostV1 {
 	storedMap := make(map[string]bool)
 	for _, post := range storedPosts {
 		storedMap[post.ID] = true
@@ -236,3 +642,88 @@ func (w Worker) distinctNewPosts(freshPosts []*store.PostV1, storedPosts []*stor
 
 	return postsToCreate
 }
+
+// embedPosts stores vectors for the posts; failures are logged and retried by the backfill
+func (w *Worker) embedPosts(ctx context.Context, posts []*store.PostV1) {
+	model := w.Assistent.EmbeddingModel()
+
+	for _, post := range posts {
+		vector, err := w.Assistent.EmbedText(ctx, embeddingText(post))
+		if err != nil {
+			slog.WarnContext(ctx, "failed to embed post", "post", post.SourceURL, "error", err)
+			continue
+		}
+
+		if err := w.Blogger.SavePostEmbedding(ctx, post.ID, model, vector); err != nil {
+			slog.WarnContext(ctx, "failed to save post embedding", "post", post.SourceURL, "error", err)
+		}
+	}
+}
+
+func (w *Worker) backfillEmbeddings(ctx context.Context) error {
+	posts, err := w.Blogger.GetPostsWithoutEmbedding(ctx, w.Assistent.EmbeddingModel(), embeddingsBackfillLimit)
+	if err != nil {
+		return fmt.Errorf("failed to load posts without embedding: %v", err)
+	}
+
+	if len(posts) > 0 {
+		slog.InfoContext(ctx, "backfilling embeddings", "posts", len(posts))
+		w.embedPosts(ctx, posts)
+	}
+
+	return nil
+}
+
+// queueScoring queues jobs scoring the relevance of the posts to the interest profiles,
+// posts which could not be queued are picked up by the backfill
+func (w *Worker) queueScoring(ctx context.Context, posts []*store.PostV1) error {
+	if len(posts) == 0 || len(w.Assistent.ProfileNames()) == 0 {
+		return nil
+	}
+
+	jobs := make([]*store.JobV1, 0, len(posts))
+	for _, post := range posts {
+		jobs = append(jobs, &store.JobV1{
+			Kind:     store.JobKindScorePost,
+			DedupKey: store.ScoreJobDedupKey(post.ID),
+			// the post is loaded by the job, it only needs to be identified
+			Post:     store.PostV1{ID: post.ID, PartitionKey: post.PartitionKey, SourceURL: post.SourceURL},
+			Priority: jobPriorityLow,
+		})
+	}
+
+	queued, err := w.Blogger.EnqueueJobs(ctx, jobs)
+	if err != nil {
+		return fmt.Errorf("failed to queue scoring: %v", err)
+	}
+	if queued > 0 {
+		w.wakeJobs()
+	}
+
+	return nil
+}
+
+// backfillScores queues the scoring of the posts stored before a profile was added, or whose scoring
+// could not be queued. Posts with a pending or dead scoring job are left to it
+func (w *Worker) backfillScores(ctx context.Context) error {
+	profiles := w.Assistent.ProfileNames()
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	posts, err := w.Blogger.GetPostsWithoutScores(ctx, profiles, scoresBackfillLimit)
+	if err != nil {
+		return fmt.Errorf("failed to load posts without scores: %v", err)
+	}
+
+	if len(posts) > 0 {
+		slog.InfoContext(ctx, "backfilling scores", "posts", len(posts))
+		return w.queueScoring(ctx, posts)
+	}
+
+	return nil
+}
+
+func embeddingText(post *store.PostV1) string {
+	return post.Title + "\n\n" + post.Text
+}
@@ -1,9 +1,18 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/mmcdole/gofeed"
+	"github.com/rjxby/rss-sum/backend/extractor"
 	"github.com/rjxby/rss-sum/backend/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -14,25 +23,128 @@ type MockBlogger struct {
 	mock.Mock
 }
 
-func (m *MockBlogger) GetPosts(page int, pageSize int, partitionKey string) (*store.PaginationPostsResult, error) {
+func (m *MockBlogger) GetPosts(ctx context.Context, page int, pageSize int, partitionKey string) (*store.PaginationPostsResult, error) {
 	args := m.Called(page, pageSize, partitionKey)
 	return args.Get(0).(*store.PaginationPostsResult), args.Error(1)
 }
 
-func (m *MockBlogger) SavePostsBulk(postsToSave []*store.PostV1) ([]*store.PostV1, error) {
+func (m *MockBlogger) SavePostsBulk(ctx context.Context, postsToSave []*store.PostV1) ([]*store.PostV1, error) {
 	args := m.Called(postsToSave)
 	return args.Get(0).([]*store.PostV1), args.Error(1)
 }
 
+func (m *MockBlogger) GetPostsByIDs(ctx context.Context, ids []string) ([]*store.PostV1, error) {
+	args := m.Called(ids)
+	return args.Get(0).([]*store.PostV1), args.Error(1)
+}
+
+func (m *MockBlogger) GetPostsWithoutEmbedding(ctx context.Context, model string, limit int) ([]*store.PostV1, error) {
+	args := m.Called(model, limit)
+	return args.Get(0).([]*store.PostV1), args.Error(1)
+}
+
+func (m *MockBlogger) SavePostEmbedding(ctx context.Context, postID string, model string, vector []float64) error {
+	args := m.Called(postID, model, vector)
+	return args.Error(0)
+}
+
+func (m *MockBlogger) GetPostsWithoutScores(ctx context.Context, profiles []string, limit int) ([]*store.PostV1, error) {
+	args := m.Called(profiles, limit)
+	return args.Get(0).([]*store.PostV1), args.Error(1)
+}
+
+func (m *MockBlogger) SavePostScores(ctx context.Context, postID string, model string, scores map[string]int) error {
+	args := m.Called(postID, model, scores)
+	return args.Error(0)
+}
+
+func (m *MockBlogger) GetFeeds(ctx context.Context) ([]*store.FeedV1, error) {
+	args := m.Called()
+	return args.Get(0).([]*store.FeedV1), args.Error(1)
+}
+
+func (m *MockBlogger) SaveFeed(ctx context.Context, feed *store.FeedV1) error {
+	args := m.Called(feed)
+	return args.Error(0)
+}
+
+func (m *MockBlogger) GetFeedHealth(ctx context.Context, feedID string) (*store.FeedHealthV1, error) {
+	args := m.Called(feedID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.FeedHealthV1), args.Error(1)
+}
+
+func (m *MockBlogger) ResetFeedItemsSummarized(ctx context.Context, feedID string) error {
+	args := m.Called(feedID)
+	return args.Error(0)
+}
+
+func (m *MockBlogger) SaveFeedRun(ctx context.Context, health *store.FeedHealthV1) error {
+	args := m.Called(health)
+	return args.Error(0)
+}
+
+func (m *MockBlogger) IncrementFeedItemsSummarized(ctx context.Context, feedID string) error {
+	args := m.Called(feedID)
+	return args.Error(0)
+}
+
+func (m *MockBlogger) EnqueueJobs(ctx context.Context, jobs []*store.JobV1) (int, error) {
+	args := m.Called(jobs)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockBlogger) LeaseJobs(ctx context.Context, lease time.Duration, limit int) ([]*store.JobV1, error) {
+	args := m.Called(lease, limit)
+	return args.Get(0).([]*store.JobV1), args.Error(1)
+}
+
+func (m *MockBlogger) SaveJob(ctx context.Context, job *store.JobV1) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockBlogger) CompleteJob(ctx context.Context, id uint64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
 type MockAssistant struct {
 	mock.Mock
 }
 
-func (m *MockAssistant) SummarizeText(text string) (string, error) {
+func (m *MockAssistant) SummarizeText(ctx context.Context, text string) (string, error) {
 	args := m.Called(text)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockAssistant) EmbedText(ctx context.Context, text string) ([]float64, error) {
+	args := m.Called(text)
+	return args.Get(0).([]float64), args.Error(1)
+}
+
+func (m *MockAssistant) ScoreRelevance(ctx context.Context, text string) (map[string]int, error) {
+	args := m.Called(text)
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *MockAssistant) ProfileNames() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *MockAssistant) Model() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockAssistant) EmbeddingModel() string {
+	args := m.Called()
+	return args.String(0)
+}
+
 type MockHasher struct {
 	mock.Mock
 }
@@ -42,6 +154,15 @@ func (m *MockHasher) HashString(text string) string {
 	return args.String(0)
 }
 
+type MockExtractor struct {
+	mock.Mock
+}
+
+func (m *MockExtractor) Extract(ctx context.Context, rawURL string) (*extractor.Article, error) {
+	args := m.Called(rawURL)
+	return args.Get(0).(*extractor.Article), args.Error(1)
+}
+
 func TestDistinctNewPosts(t *testing.T) {
 	tbl := []struct {
 		fresh    []*store.PostV1
@@ -110,45 +231,848 @@ func TestDistinctNewPosts(t *testing.T) {
 	}
 }
 
-func TestParseSettings(t *testing.T) {
-	// Test valid settings
-	t.Run("ValidSettings", func(t *testing.T) {
-		t.Setenv("FEEDS", "http://example.com/feed1,http://example.com/feed2")
-		t.Setenv("WORKER_TIMEOUT_IN_SECONDS", "120")
-		t.Setenv("WORKER_INTERVAL_IN_SECONDS", "60")
-		t.Setenv("FEED_ITEMS_LIMIT", "5")
+func TestEmbedPosts(t *testing.T) {
+	// Setup
+	mockAssistant := new(MockAssistant)
+	mockBlogger := new(MockBlogger)
+	posts := []*store.PostV1{
+		{ID: "1", Title: "Title 1", Text: "Summary 1"},
+		{ID: "2", Title: "Title 2", Text: "Summary 2"},
+	}
+	mockAssistant.On("EmbeddingModel").Return("embed-model")
+	mockAssistant.On("EmbedText", "Title 1\n\nSummary 1").Return([]float64{1, 0}, nil)
+	mockAssistant.On("EmbedText", "Title 2\n\nSummary 2").Return([]float64(nil), errors.New("ollama error"))
+	mockBlogger.On("SavePostEmbedding", "1", "embed-model", []float64{1, 0}).Return(nil)
+
+	w := Worker{Assistent: mockAssistant, Blogger: mockBlogger}
 
-		settings, err := ParseSettings()
+	// Execute
+	w.embedPosts(context.Background(), posts)
+
+	// Verify
+	mockAssistant.AssertExpectations(t)
+	mockBlogger.AssertExpectations(t)
+	mockBlogger.AssertNumberOfCalls(t, "SavePostEmbedding", 1)
+}
 
+func TestQueueScoring(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockBlogger := new(MockBlogger)
+		posts := []*store.PostV1{
+			{ID: "1", PartitionKey: "hash-1", SourceURL: "http://example.com/1", Title: "Title 1", Text: "Summary 1"},
+			{ID: "2", PartitionKey: "hash-1", SourceURL: "http://example.com/2", Title: "Title 2", Text: "Summary 2"},
+		}
+		mockAssistant.On("ProfileNames").Return([]string{"go"})
+		mockBlogger.On("EnqueueJobs", mock.MatchedBy(func(jobs []*store.JobV1) bool {
+			return len(jobs) == 2 &&
+				jobs[0].Kind == store.JobKindScorePost &&
+				jobs[0].DedupKey == "score_post:1" &&
+				jobs[0].Post.ID == "1" &&
+				jobs[0].Post.Text == "" &&
+				jobs[0].Priority == jobPriorityLow &&
+				jobs[1].DedupKey == "score_post:2"
+		})).Return(2, nil)
+
+		w := Worker{Assistent: mockAssistant, Blogger: mockBlogger}
+
+		// Execute
+		err := w.queueScoring(context.Background(), posts)
+
+		// Verify
 		assert.NoError(t, err)
-		assert.Equal(t, 2, len(settings.RSSFeedsURLs))
-		assert.Equal(t, 120, settings.WorkerTimeoutInSeconds)
-		assert.Equal(t, 60, settings.WorkerIntervalInSeconds)
-		assert.Equal(t, 5, settings.RSSFeedLimit)
+		mockBlogger.AssertExpectations(t)
+		mockAssistant.AssertNotCalled(t, "ScoreRelevance", mock.Anything)
 	})
 
-	// Test default values
-	t.Run("DefaultValues", func(t *testing.T) {
-		t.Setenv("FEEDS", "http://example.com/feed")
-		t.Setenv("WORKER_TIMEOUT_IN_SECONDS", "")
-		t.Setenv("WORKER_INTERVAL_IN_SECONDS", "")
-		t.Setenv("FEED_ITEMS_LIMIT", "")
+	t.Run("WithoutProfiles", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockBlogger := new(MockBlogger)
+		mockAssistant.On("ProfileNames").Return([]string{})
+
+		w := Worker{Assistent: mockAssistant, Blogger: mockBlogger}
+
+		// Execute
+		err := w.queueScoring(context.Background(), []*store.PostV1{{ID: "1"}})
+		assert.NoError(t, err)
+		err = w.backfillScores(context.Background())
+
+		// Verify
+		assert.NoError(t, err)
+		mockBlogger.AssertNotCalled(t, "EnqueueJobs", mock.Anything)
+		mockBlogger.AssertNotCalled(t, "GetPostsWithoutScores", mock.Anything, mock.Anything)
+	})
+}
+
+func TestBackfillScores(t *testing.T) {
+	// Setup
+	mockAssistant := new(MockAssistant)
+	mockBlogger := new(MockBlogger)
+	mockAssistant.On("ProfileNames").Return([]string{"go", "rust"})
+	mockBlogger.On("GetPostsWithoutScores", []string{"go", "rust"}, scoresBackfillLimit).
+		Return([]*store.PostV1{{ID: "1", Title: "Title 1", Text: "Summary 1"}}, nil)
+	mockBlogger.On("EnqueueJobs", mock.MatchedBy(func(jobs []*store.JobV1) bool {
+		return len(jobs) == 1 && jobs[0].DedupKey == "score_post:1"
+	})).Return(1, nil)
+
+	w := Worker{Assistent: mockAssistant, Blogger: mockBlogger}
+
+	// Execute
+	err := w.backfillScores(context.Background())
+
+	// Verify
+	assert.NoError(t, err)
+	mockBlogger.AssertExpectations(t)
+	// scoring generates text, it is left to the job runners
+	mockAssistant.AssertNotCalled(t, "ScoreRelevance", mock.Anything)
+}
+
+func TestSyncFeeds(t *testing.T) {
+	// Setup
+	mockBlogger := new(MockBlogger)
+	mockHasher := new(MockHasher)
+	storedFeed := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1", IntervalInSeconds: 600}
+	mockBlogger.On("GetFeeds").Return([]*store.FeedV1{
+		storedFeed,
+		{ID: "hash-old", URL: "http://example.com/unsubscribed"},
+	}, nil)
+	mockHasher.On("HashString", "http://example.com/feed2").Return("hash-2")
+	mockBlogger.On("SaveFeed", mock.MatchedBy(func(feed *store.FeedV1) bool {
+		return feed.ID == "hash-2" && feed.URL == "http://example.com/feed2" && feed.IntervalInSeconds == 3600
+	})).Return(nil)
+
+	w := Worker{
+		Blogger: mockBlogger,
+		Hasher:  mockHasher,
+		Settings: Settings{
+			RSSFeedsURLs:            []string{"http://example.com/feed1", "http://example.com/feed2"},
+			WorkerIntervalInSeconds: 3600,
+		},
+	}
+
+	// Execute
+	feeds, err := w.syncFeeds(context.Background())
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(feeds))
+	assert.Equal(t, storedFeed, feeds[0])
+	assert.Equal(t, "hash-2", feeds[1].ID)
+	assert.False(t, feeds[1].NextFetchAt.After(time.Now()))
+	mockBlogger.AssertExpectations(t)
+	mockHasher.AssertExpectations(t)
+}
+
+func TestFetchFeed(t *testing.T) {
+	// Create a mock server that returns a feed with a publisher ttl
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Feed</title><ttl>60</ttl>
+<item><guid>1</guid><title>Post 1</title><link>http://example.com/1</link><description>Text 1</description></item>
+</channel></rss>`))
+	}))
+	defer ts.Close()
+
+	// Setup
+	mockBlogger := new(MockBlogger)
+	mockAssistant := new(MockAssistant)
+	feed := &store.FeedV1{ID: "hash-1", URL: ts.URL, IntervalInSeconds: 3600}
+	mockBlogger.On("GetPosts", 1, 3, "hash-1").Return(&store.PaginationPostsResult{Posts: []*store.PostV1{{ID: "1"}}}, nil)
+	mockBlogger.On("SaveFeed", feed).Return(nil)
+	mockBlogger.On("ResetFeedItemsSummarized", "hash-1").Return(nil)
+	mockBlogger.On("GetFeedHealth", "hash-1").Return(nil, store.ErrNotFound)
+	mockBlogger.On("SaveFeedRun", mock.MatchedBy(func(h *store.FeedHealthV1) bool {
+		return h.FeedID == "hash-1" && h.LastStatusCode == http.StatusOK && h.ItemsSeen == 1 &&
+			h.ItemsNew == 0 && h.ConsecutiveFailures == 0 && h.LastSuccessAt != nil
+	})).Return(nil)
+
+	w := Worker{
+		Assistent: mockAssistant,
+		Blogger:   mockBlogger,
+		Settings: Settings{
+			RSSFeedLimit:               3,
+			WorkerMinIntervalInSeconds: 300,
+			WorkerMaxIntervalInSeconds: 86400,
+		},
+	}
+
+	// Execute
+	before := time.Now()
+	err := w.fetchFeed(context.Background(), newFeedFetcher(), feed)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, 5400, feed.IntervalInSeconds) // quiet feed, polled less often
+	assert.NotNil(t, feed.LastFetchedAt)
+	assert.WithinDuration(t, before.Add(5400*time.Second), feed.NextFetchAt, 541*time.Second)
+	mockBlogger.AssertExpectations(t)
+	mockAssistant.AssertNotCalled(t, "SummarizeText", mock.Anything)
+}
+
+func TestFetchFeedDeadJob(t *testing.T) {
+	// Create a mock server that returns an item whose summarization job is dead
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Feed</title>
+<item><guid>1</guid><title>Post 1</title><link>http://example.com/1</link><description>Text 1</description></item>
+</channel></rss>`))
+	}))
+	defer ts.Close()
+
+	// Setup
+	mockBlogger := new(MockBlogger)
+	feed := &store.FeedV1{ID: "hash-1", URL: ts.URL, IntervalInSeconds: 3600}
+	mockBlogger.On("GetPosts", 1, 3, "hash-1").Return(&store.PaginationPostsResult{Posts: []*store.PostV1{}}, nil)
+	// the dead job keeps its dedup key, so the item is not queued again
+	mockBlogger.On("EnqueueJobs", mock.Anything).Return(0, nil)
+	mockBlogger.On("SaveFeed", feed).Return(nil)
+	mockBlogger.On("ResetFeedItemsSummarized", "hash-1").Return(nil)
+	mockBlogger.On("GetFeedHealth", "hash-1").Return(nil, store.ErrNotFound)
+	mockBlogger.On("SaveFeedRun", mock.MatchedBy(func(h *store.FeedHealthV1) bool {
+		return h.ItemsSeen == 1 && h.ItemsNew == 0
+	})).Return(nil)
+
+	w := Worker{
+		Blogger: mockBlogger,
+		Settings: Settings{
+			RSSFeedLimit:               3,
+			WorkerMinIntervalInSeconds: 300,
+			WorkerMaxIntervalInSeconds: 86400,
+		},
+	}
+
+	// Execute
+	intervals := []int{}
+	for range 2 {
+		assert.NoError(t, w.fetchFeed(context.Background(), newFeedFetcher(), feed))
+		intervals = append(intervals, feed.IntervalInSeconds)
+	}
+
+	// Verify
+	assert.Equal(t, []int{5400, 8100}, intervals) // no new items, polled less often
+	mockBlogger.AssertNumberOfCalls(t, "EnqueueJobs", 2)
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestFetchFeedFailure(t *testing.T) {
+	// Create a mock server that fails
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	// Setup
+	mockBlogger := new(MockBlogger)
+	feed := &store.FeedV1{ID: "hash-1", URL: ts.URL, IntervalInSeconds: 3600}
+	mockBlogger.On("SaveFeed", feed).Return(nil)
+	mockBlogger.On("GetFeedHealth", "hash-1").Return(nil, store.ErrNotFound)
+	mockBlogger.On("SaveFeedRun", mock.MatchedBy(func(h *store.FeedHealthV1) bool {
+		return h.LastStatusCode == http.StatusInternalServerError && h.ConsecutiveFailures == 1 && h.ItemsNew == 0
+	})).Return(nil)
+
+	w := Worker{
+		Blogger: mockBlogger,
+		Settings: Settings{
+			RSSFeedLimit:               3,
+			WorkerMinIntervalInSeconds: 300,
+			WorkerMaxIntervalInSeconds: 86400,
+		},
+	}
+
+	// Execute
+	err := w.fetchFeed(context.Background(), newFeedFetcher(), feed)
+
+	// Verify
+	assert.Error(t, err)
+	mockBlogger.AssertExpectations(t)
+	// nothing was queued, the summarized items of the last run are kept
+	mockBlogger.AssertNotCalled(t, "ResetFeedItemsSummarized", mock.Anything)
+}
+
+func TestSelectFeeds(t *testing.T) {
+	// Setup
+	mockHasher := new(MockHasher)
+	mockHasher.On("HashString", "http://example.com/feed1").Return("hash-1")
+	mockHasher.On("HashString", "http://example.com/feed2").Return("hash-2")
+
+	w := Worker{
+		Hasher:   mockHasher,
+		Settings: Settings{RSSFeedsURLs: []string{"http://example.com/feed1", "http://example.com/feed2"}},
+	}
+	feed1 := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1", NextFetchAt: time.Now().Add(time.Hour)}
+	feed2 := &store.FeedV1{ID: "hash-2", URL: "http://example.com/feed2", NextFetchAt: time.Now().Add(time.Hour)}
+
+	t.Run("All", func(t *testing.T) {
+		selectFeed, err := w.selectFeeds("")
+		assert.NoError(t, err)
+		assert.True(t, selectFeed(feed1, time.Now()))
+		assert.True(t, selectFeed(feed2, time.Now()))
+	})
+
+	t.Run("ByID", func(t *testing.T) {
+		selectFeed, err := w.selectFeeds("hash-2")
+		assert.NoError(t, err)
+		assert.False(t, selectFeed(feed1, time.Now()))
+		assert.True(t, selectFeed(feed2, time.Now()))
+	})
+
+	t.Run("ByURL", func(t *testing.T) {
+		selectFeed, err := w.selectFeeds("http://example.com/feed1")
+		assert.NoError(t, err)
+		assert.True(t, selectFeed(feed1, time.Now()))
+		assert.False(t, selectFeed(feed2, time.Now()))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		disabled := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1", Disabled: true}
+
+		selectAll, err := w.selectFeeds("")
+		assert.NoError(t, err)
+		assert.False(t, selectAll(disabled, time.Now()))
+
+		selectOne, err := w.selectFeeds("hash-1")
+		assert.NoError(t, err)
+		assert.True(t, selectOne(disabled, time.Now()))
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		selectFeed, err := w.selectFeeds("http://example.com/other")
+		assert.ErrorIs(t, err, ErrFeedNotFound)
+		assert.Nil(t, selectFeed)
+	})
+}
+
+func TestFetchInProgress(t *testing.T) {
+	// Setup
+	w := Worker{}
+	w.running.Store(true)
+
+	// Execute
+	fetchErr := w.Fetch("")
+	triggerErr := w.TriggerFetch("")
+
+	// Verify
+	assert.ErrorIs(t, fetchErr, ErrFetchInProgress)
+	assert.ErrorIs(t, triggerErr, ErrFetchInProgress)
+}
+
+func TestCheckLastRun(t *testing.T) {
+	w := &Worker{Settings: Settings{WorkerTimeoutInSeconds: 60}}
+
+	t.Run("NotRunning", func(t *testing.T) {
+		assert.ErrorIs(t, w.CheckLastRun(), ErrWorkerNotRunning)
+	})
+
+	t.Run("Fresh", func(t *testing.T) {
+		w.lastRunAt.Store(time.Now().Add(-time.Minute).UnixNano())
+		assert.NoError(t, w.CheckLastRun())
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		w.lastRunAt.Store(time.Now().Add(-time.Hour).UnixNano())
+		assert.ErrorContains(t, w.CheckLastRun(), "last run finished")
+	})
+}
+
+func TestIsDue(t *testing.T) {
+	now := time.Now()
+
+	assert.True(t, isDue(&store.FeedV1{NextFetchAt: now.Add(-time.Minute)}, now))
+	assert.True(t, isDue(&store.FeedV1{NextFetchAt: now}, now))
+	assert.False(t, isDue(&store.FeedV1{NextFetchAt: now.Add(time.Minute)}, now))
+	assert.False(t, isDue(&store.FeedV1{NextFetchAt: now.Add(-time.Minute), Disabled: true}, now))
+}
+
+func TestRecordHealth(t *testing.T) {
+	now := time.Now().UTC()
+
+	t.Run("DisableAfterMaxFailures", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		feed := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1"}
+		mockBlogger.On("GetFeedHealth", "hash-1").Return(&store.FeedHealthV1{FeedID: "hash-1", ConsecutiveFailures: 2}, nil)
+		mockBlogger.On("SaveFeedRun", mock.Anything).Return(nil)
+
+		w := Worker{Blogger: mockBlogger, Settings: Settings{FeedMaxConsecutiveFailures: 3}}
+
+		// Execute
+		err := w.recordHealth(context.Background(), feed, feedRun{StatusCode: http.StatusNotFound}, errors.New("404 Not Found"), nil, now)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.True(t, feed.Disabled)
+		assert.Equal(t, &now, feed.DisabledAt)
+		health := mockBlogger.Calls[1].Arguments.Get(0).(*store.FeedHealthV1)
+		assert.Equal(t, 3, health.ConsecutiveFailures)
+		assert.Equal(t, http.StatusNotFound, health.LastStatusCode)
+		assert.Equal(t, "404 Not Found", health.LastError)
+		assert.Equal(t, &now, health.LastErrorAt)
+		assert.Nil(t, health.LastSuccessAt)
+	})
+
+	t.Run("StoreErrorDoesNotCountAsFailure", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		feed := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1"}
+		mockBlogger.On("GetFeedHealth", "hash-1").Return(&store.FeedHealthV1{FeedID: "hash-1", ConsecutiveFailures: 2}, nil)
+		mockBlogger.On("SaveFeedRun", mock.Anything).Return(nil)
+
+		w := Worker{Blogger: mockBlogger, Settings: Settings{FeedMaxConsecutiveFailures: 3}}
+
+		// Execute
+		run := feedRun{StatusCode: http.StatusOK, ItemsSeen: 2, ItemsNew: 2}
+		err := w.recordHealth(context.Background(), feed, run, nil, errors.New("failed to queue posts"), now)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.False(t, feed.Disabled)
+		health := mockBlogger.Calls[1].Arguments.Get(0).(*store.FeedHealthV1)
+		assert.Equal(t, 0, health.ConsecutiveFailures)
+		assert.Equal(t, &now, health.LastSuccessAt)
+		assert.Equal(t, "failed to queue posts", health.LastError)
+		assert.Equal(t, 2, health.ItemsNew)
+	})
+
+	t.Run("TruncateMultiByteError", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		feed := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1"}
+		mockBlogger.On("GetFeedHealth", "hash-1").Return(nil, store.ErrNotFound)
+		mockBlogger.On("SaveFeedRun", mock.Anything).Return(nil)
+
+		w := Worker{Blogger: mockBlogger}
+
+		// Execute
+		err := w.recordHealth(context.Background(), feed, feedRun{}, errors.New("x"+strings.Repeat("ошибка ", 200)), nil, now)
+
+		// Verify
+		assert.NoError(t, err)
+		health := mockBlogger.Calls[1].Arguments.Get(0).(*store.FeedHealthV1)
+		assert.True(t, utf8.ValidString(health.LastError))
+		assert.Equal(t, maxHealthErrorLength, utf8.RuneCountInString(health.LastError))
+	})
+
+	t.Run("NeverDisableWhenUnlimited", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		feed := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1"}
+		mockBlogger.On("GetFeedHealth", "hash-1").Return(&store.FeedHealthV1{FeedID: "hash-1", ConsecutiveFailures: 99}, nil)
+		mockBlogger.On("SaveFeedRun", mock.Anything).Return(nil)
+
+		w := Worker{Blogger: mockBlogger}
+
+		// Execute
+		err := w.recordHealth(context.Background(), feed, feedRun{}, errors.New("connection refused"), nil, now)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.False(t, feed.Disabled)
+	})
+}
+
+func TestStoreFeedItems(t *testing.T) {
+	// Setup
+	mockBlogger := new(MockBlogger)
+	feed := &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed"}
+	fresh := &gofeed.Feed{Items: []*gofeed.Item{
+		{GUID: "1", Title: "Post 1", Link: "http://example.com/1", Content: "Text 1"},
+		{GUID: "2", Title: "Post 2", Link: "http://example.com/2", Content: "Text 2"},
+	}}
+	mockBlogger.On("GetPosts", 1, 3, "hash-1").Return(&store.PaginationPostsResult{Posts: []*store.PostV1{{ID: "1"}}}, nil)
+	mockBlogger.On("EnqueueJobs", mock.MatchedBy(func(jobs []*store.JobV1) bool {
+		return len(jobs) == 1 &&
+			jobs[0].Kind == store.JobKindSummarizePost &&
+			jobs[0].DedupKey == "summarize_post:2" &&
+			jobs[0].Post.ID == "2" &&
+			jobs[0].Post.PartitionKey == "hash-1" &&
+			jobs[0].Post.Text == "Text 2"
+	})).Return(1, nil)
+
+	w := Worker{Blogger: mockBlogger, Settings: Settings{RSSFeedLimit: 3}}
+
+	// Execute
+	run := feedRun{}
+	err := w.storeFeedItems(context.Background(), feed, fresh, &run)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, 2, run.ItemsSeen)
+	assert.Equal(t, 1, run.ItemsNew)
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestRunJob(t *testing.T) {
+	article := &extractor.Article{
+		URL:   "http://example.com/post",
+		Title: "Post",
+		Text:  "Long article text",
+	}
+
+	t.Run("SummarizePost", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockBlogger := new(MockBlogger)
+		job := &store.JobV1{
+			ID:   1,
+			Kind: store.JobKindSummarizePost,
+			Post: store.PostV1{ID: "1", PartitionKey: "hash-1", SourceURL: "http://example.com/1", Title: "Post 1", Text: "Text 1"},
+		}
+		mockBlogger.On("GetPostsByIDs", []string{"1"}).Return([]*store.PostV1{}, nil)
+		mockAssistant.On("SummarizeText", "Text 1").Return("Summary", nil)
+		mockAssistant.On("Model").Return("llama3:8b")
+		mockBlogger.On("SavePostsBulk", mock.MatchedBy(func(posts []*store.PostV1) bool {
+			return len(posts) == 1 && posts[0].ID == "1" && posts[0].Text == "Summary" && posts[0].Model == "llama3:8b"
+		})).Return([]*store.PostV1{}, nil)
+		mockAssistant.On("EmbeddingModel").Return("embed-model")
+		mockAssistant.On("EmbedText", "Post 1\n\nSummary").Return([]float64{1}, nil)
+		mockBlogger.On("SavePostEmbedding", "1", "embed-model", []float64{1}).Return(nil)
+		mockAssistant.On("ProfileNames").Return([]string{"go"})
+		mockBlogger.On("EnqueueJobs", mock.MatchedBy(func(jobs []*store.JobV1) bool {
+			return len(jobs) == 1 && jobs[0].Kind == store.JobKindScorePost && jobs[0].Post.ID == "1"
+		})).Return(1, nil)
+		mockBlogger.On("IncrementFeedItemsSummarized", "hash-1").Return(nil)
+
+		w := Worker{Assistent: mockAssistant, Blogger: mockBlogger}
+
+		// Execute
+		err := w.runJob(context.Background(), job)
+
+		// Verify
+		assert.NoError(t, err)
+		mockAssistant.AssertExpectations(t)
+		mockBlogger.AssertExpectations(t)
+	})
+
+	t.Run("SaveLink", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockBlogger := new(MockBlogger)
+		mockExtractor := new(MockExtractor)
+		job := &store.JobV1{ID: 1, Kind: store.JobKindSaveLink, Post: store.PostV1{SourceURL: "http://example.com/post"}}
+		mockExtractor.On("Extract", "http://example.com/post").Return(article, nil)
+		mockBlogger.On("GetPostsByIDs", []string{"http://example.com/post"}).Return([]*store.PostV1{}, nil)
+		mockAssistant.On("SummarizeText", "Long article text").Return("Summary", nil)
+		mockAssistant.On("Model").Return("llama3:8b")
+		mockBlogger.On("SavePostsBulk", mock.MatchedBy(func(posts []*store.PostV1) bool {
+			return len(posts) == 1 &&
+				posts[0].ID == "http://example.com/post" &&
+				posts[0].PartitionKey == store.SavedLinksPartitionKey &&
+				posts[0].Title == "Post" &&
+				posts[0].Text == "Summary"
+		})).Return([]*store.PostV1{}, nil)
+		mockAssistant.On("EmbeddingModel").Return("embed-model")
+		mockAssistant.On("EmbedText", "Post\n\nSummary").Return([]float64{1}, nil)
+		mockBlogger.On("SavePostEmbedding", "http://example.com/post", "embed-model", []float64{1}).Return(nil)
+		mockAssistant.On("ProfileNames").Return([]string{})
+
+		w := Worker{Assistent: mockAssistant, Blogger: mockBlogger, Extractor: mockExtractor}
+
+		// Execute
+		err := w.runJob(context.Background(), job)
+
+		// Verify
+		assert.NoError(t, err)
+		mockAssistant.AssertExpectations(t)
+		mockBlogger.AssertExpectations(t)
+		mockExtractor.AssertExpectations(t)
+		mockBlogger.AssertNotCalled(t, "IncrementFeedItemsSummarized", mock.Anything)
+	})
+
+	t.Run("AlreadySaved", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockBlogger := new(MockBlogger)
+		mockExtractor := new(MockExtractor)
+		job := &store.JobV1{ID: 1, Kind: store.JobKindSaveLink, Post: store.PostV1{SourceURL: "http://example.com/post"}}
+		mockExtractor.On("Extract", "http://example.com/post").Return(article, nil)
+		mockBlogger.On("GetPostsByIDs", []string{"http://example.com/post"}).Return([]*store.PostV1{{ID: "http://example.com/post"}}, nil)
+
+		w := Worker{Assistent: mockAssistant, Blogger: mockBlogger, Extractor: mockExtractor}
+
+		// Execute
+		err := w.runJob(context.Background(), job)
+
+		// Verify
+		assert.NoError(t, err)
+		mockAssistant.AssertNotCalled(t, "SummarizeText", mock.Anything)
+		mockBlogger.AssertNotCalled(t, "SavePostsBulk", mock.Anything)
+	})
+
+	t.Run("ScorePost", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockBlogger := new(MockBlogger)
+		job := &store.JobV1{ID: 1, Kind: store.JobKindScorePost, Post: store.PostV1{ID: "1"}}
+		mockAssistant.On("ProfileNames").Return([]string{"go"})
+		mockBlogger.On("GetPostsByIDs", []string{"1"}).Return([]*store.PostV1{{ID: "1", Title: "Post 1", Text: "Summary"}}, nil)
+		mockAssistant.On("ScoreRelevance", "Post 1\n\nSummary").Return(map[string]int{"go": 80}, nil)
+		mockAssistant.On("Model").Return("llama3:8b")
+		mockBlogger.On("SavePostScores", "1", "llama3:8b", map[string]int{"go": 80}).Return(nil)
+
+		w := Worker{Assistent: mockAssistant, Blogger: mockBlogger}
+
+		// Execute
+		err := w.runJob(context.Background(), job)
+
+		// Verify
+		assert.NoError(t, err)
+		mockAssistant.AssertExpectations(t)
+		mockBlogger.AssertExpectations(t)
+	})
+
+	t.Run("ScorePostError", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockBlogger := new(MockBlogger)
+		job := &store.JobV1{ID: 1, Kind: store.JobKindScorePost, Post: store.PostV1{ID: "1"}}
+		mockAssistant.On("ProfileNames").Return([]string{"go"})
+		mockBlogger.On("GetPostsByIDs", []string{"1"}).Return([]*store.PostV1{{ID: "1", Title: "Post 1", Text: "Summary"}}, nil)
+		mockAssistant.On("ScoreRelevance", "Post 1\n\nSummary").Return(map[string]int(nil), errors.New("response is not a JSON object"))
+
+		w := Worker{Assistent: mockAssistant, Blogger: mockBlogger}
+
+		// Execute
+		err := w.runJob(context.Background(), job)
+
+		// Verify
+		assert.ErrorContains(t, err, "failed to score post")
+		mockBlogger.AssertNotCalled(t, "SavePostScores", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ScoreDeletedPost", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockBlogger := new(MockBlogger)
+		job := &store.JobV1{ID: 1, Kind: store.JobKindScorePost, Post: store.PostV1{ID: "1"}}
+		mockAssistant.On("ProfileNames").Return([]string{"go"})
+		mockBlogger.On("GetPostsByIDs", []string{"1"}).Return([]*store.PostV1{}, nil)
+
+		w := Worker{Assistent: mockAssistant, Blogger: mockBlogger}
 
-		settings, err := ParseSettings()
+		// Execute
+		err := w.runJob(context.Background(), job)
 
+		// Verify
 		assert.NoError(t, err)
+		mockAssistant.AssertNotCalled(t, "ScoreRelevance", mock.Anything)
+	})
+
+	t.Run("ExtractError", func(t *testing.T) {
+		// Setup
+		mockExtractor := new(MockExtractor)
+		job := &store.JobV1{ID: 1, Kind: store.JobKindSaveLink, Post: store.PostV1{SourceURL: "http://example.com/post"}}
+		mockExtractor.On("Extract", "http://example.com/post").Return((*extractor.Article)(nil), errors.New("timeout"))
+
+		w := Worker{Extractor: mockExtractor}
+
+		// Execute
+		err := w.runJob(context.Background(), job)
+
+		// Verify
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to extract content")
+	})
+
+	t.Run("UnknownKind", func(t *testing.T) {
+		w := Worker{}
+
+		err := w.runJob(context.Background(), &store.JobV1{ID: 1, Kind: "other"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestFinishJob(t *testing.T) {
+	t.Run("Complete", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("CompleteJob", uint64(1)).Return(nil)
+
+		w := Worker{Blogger: mockBlogger, Settings: Settings{JobMaxAttempts: 3}}
+
+		// Execute
+		err := w.finishJob(context.Background(), &store.JobV1{ID: 1, Attempts: 1}, nil)
+
+		// Verify
+		assert.NoError(t, err)
+		mockBlogger.AssertExpectations(t)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("SaveJob", mock.Anything).Return(nil)
+		leasedUntil := time.Now().Add(time.Minute)
+		job := &store.JobV1{ID: 1, Status: store.JobStatusLeased, Attempts: 2, LeasedUntil: &leasedUntil}
+
+		w := Worker{Blogger: mockBlogger, Settings: Settings{JobMaxAttempts: 3}}
+
+		// Execute
+		before := time.Now()
+		err := w.finishJob(context.Background(), job, errors.New("assistant is down"))
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, store.JobStatusPending, job.Status)
+		assert.Equal(t, "assistant is down", job.LastError)
+		assert.Nil(t, job.LeasedUntil)
+		assert.WithinDuration(t, before.Add(time.Minute), job.RunAt, time.Second)
+		mockBlogger.AssertExpectations(t)
+	})
+
+	t.Run("Dead", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("SaveJob", mock.Anything).Return(nil)
+		job := &store.JobV1{ID: 1, Status: store.JobStatusLeased, Attempts: 3}
+
+		w := Worker{Blogger: mockBlogger, Settings: Settings{JobMaxAttempts: 3}}
+
+		// Execute
+		err := w.finishJob(context.Background(), job, errors.New("assistant is down"))
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, store.JobStatusDead, job.Status)
+		assert.Equal(t, "assistant is down", job.LastError)
+		mockBlogger.AssertExpectations(t)
+	})
+
+	t.Run("TruncateMultiByteError", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("SaveJob", mock.Anything).Return(nil)
+		job := &store.JobV1{ID: 1, Status: store.JobStatusLeased, Attempts: 1}
+
+		w := Worker{Blogger: mockBlogger, Settings: Settings{JobMaxAttempts: 3}}
+
+		// Execute
+		err := w.finishJob(context.Background(), job, errors.New("x"+strings.Repeat("ошибка ", 200)))
+
+		// Verify
+		assert.NoError(t, err)
+		assert.True(t, utf8.ValidString(job.LastError))
+		assert.Equal(t, maxJobErrorLength, utf8.RuneCountInString(job.LastError))
+	})
+}
+
+func TestJobRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, jobRetryDelay(1))
+	assert.Equal(t, time.Minute, jobRetryDelay(2))
+	assert.Equal(t, 4*time.Minute, jobRetryDelay(4))
+	assert.Equal(t, jobRetryMaxDelay, jobRetryDelay(100))
+}
+
+func TestDrainJobs(t *testing.T) {
+	// Setup
+	mockBlogger := new(MockBlogger)
+	mockExtractor := new(MockExtractor)
+	job := &store.JobV1{ID: 1, Kind: store.JobKindSaveLink, Attempts: 1, Post: store.PostV1{SourceURL: "http://example.com/post"}}
+	mockBlogger.On("LeaseJobs", 60*time.Second, 1).Return([]*store.JobV1{job}, nil).Once()
+	mockBlogger.On("LeaseJobs", 60*time.Second, 1).Return([]*store.JobV1{}, nil).Once()
+	mockExtractor.On("Extract", "http://example.com/post").Return((*extractor.Article)(nil), errors.New("timeout"))
+	mockBlogger.On("SaveJob", job).Return(nil)
+
+	w := Worker{Blogger: mockBlogger, Extractor: mockExtractor, Settings: Settings{JobMaxAttempts: 3, JobLeaseInSeconds: 60}}
+
+	// Execute
+	err := w.DrainJobs(context.Background())
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, store.JobStatusPending, job.Status)
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestSubmitLink(t *testing.T) {
+	// Setup
+	mockBlogger := new(MockBlogger)
+	mockBlogger.On("EnqueueJobs", mock.MatchedBy(func(jobs []*store.JobV1) bool {
+		return len(jobs) == 1 &&
+			jobs[0].Kind == store.JobKindSaveLink &&
+			jobs[0].DedupKey == "save_link:http://example.com/post" &&
+			jobs[0].Post.SourceURL == "http://example.com/post"
+	})).Return(1, nil)
+
+	w := Worker{Blogger: mockBlogger}
+
+	// Execute
+	err := w.SubmitLink(context.Background(), "http://example.com/post")
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(w.jobsWake()))
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestValidate(t *testing.T) {
+	// Test default values
+	t.Run("DefaultValues", func(t *testing.T) {
+		settings := DefaultSettings()
+		settings.RSSFeedsURLs = []string{"http://example.com/feed"}
+
+		assert.NoError(t, settings.Validate())
 		assert.Equal(t, 1800, settings.WorkerTimeoutInSeconds)
 		assert.Equal(t, 3600, settings.WorkerIntervalInSeconds)
+		assert.Equal(t, 300, settings.WorkerMinIntervalInSeconds)
+		assert.Equal(t, 86400, settings.WorkerMaxIntervalInSeconds)
 		assert.Equal(t, 3, settings.RSSFeedLimit)
+		assert.Equal(t, 10, settings.FeedMaxConsecutiveFailures)
+		assert.Equal(t, 1, settings.JobWorkers)
+		assert.Equal(t, 5, settings.JobMaxAttempts)
+		assert.Equal(t, 600, settings.JobLeaseInSeconds)
+	})
+
+	// Test inconsistent interval bounds
+	t.Run("MinIntervalAboveMax", func(t *testing.T) {
+		settings := DefaultSettings()
+		settings.RSSFeedsURLs = []string{"http://example.com/feed"}
+		settings.WorkerMinIntervalInSeconds = 7200
+		settings.WorkerMaxIntervalInSeconds = 3600
+
+		assert.ErrorContains(t, settings.Validate(), "min_interval_in_seconds should not be greater than max_interval_in_seconds")
 	})
 
 	// Test missing required settings
 	t.Run("MissingFeeds", func(t *testing.T) {
-		t.Setenv("FEEDS", "")
+		settings := DefaultSettings()
+
+		assert.ErrorContains(t, settings.Validate(), "feeds is empty")
+	})
 
-		settings, err := ParseSettings()
+	t.Run("InvalidFeed", func(t *testing.T) {
+		settings := DefaultSettings()
+		settings.RSSFeedsURLs = []string{"example.com/feed"}
 
-		assert.Error(t, err)
-		assert.Nil(t, settings)
+		assert.ErrorContains(t, settings.Validate(), `feed "example.com/feed" is invalid`)
 	})
+
+	// Test that every problem is reported at once
+	t.Run("AllErrors", func(t *testing.T) {
+		settings := DefaultSettings()
+		settings.RSSFeedsURLs = []string{"http://example.com/feed"}
+		settings.JobWorkers = 0
+		settings.FeedMaxConsecutiveFailures = -1
+
+		err := settings.Validate()
+
+		assert.ErrorContains(t, err, "job_workers should be positive")
+		assert.ErrorContains(t, err, "feed_max_consecutive_failures should not be negative")
+	})
+}
+
+func TestReload(t *testing.T) {
+	// Setup
+	w := &Worker{Settings: Settings{RSSFeedsURLs: []string{"http://example.com/feed1"}, JobWorkers: 1}}
+
+	// Execute
+	before := w.settings().RSSFeedsURLs
+	w.Reload(Settings{RSSFeedsURLs: []string{"http://example.com/feed2"}, JobWorkers: 4})
+
+	// Verify
+	assert.Equal(t, []string{"http://example.com/feed1"}, before)
+	assert.Equal(t, []string{"http://example.com/feed2"}, w.settings().RSSFeedsURLs)
+	assert.Equal(t, 1, w.Settings.JobWorkers)
 }
@@ -2,17 +2,32 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/auth"
+	"github.com/rjxby/rss-sum/backend/metrics"
+	"github.com/rjxby/rss-sum/backend/store"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/rjxby/rss-sum/backend/server")
+
 // JSON is a map alias, just for convenience
 type JSON map[string]interface{}
 
@@ -28,8 +43,8 @@ const maxBody = 1024
 
 var reMultWhtsp = regexp.MustCompile(`[\s\p{Zs}]{2,}`)
 
-// Logger middleware prints http log. Customized by set of LoggerFlag
-func Logger(l *log.Logger, flags ...LoggerFlag) func(http.Handler) http.Handler {
+// Logger middleware prints http log and records request metrics. Customized by set of LoggerFlag
+func Logger(l *slog.Logger, flags ...LoggerFlag) func(http.Handler) http.Handler {
 
 	inFlags := func(f LoggerFlag) bool {
 		for _, flg := range flags {
@@ -72,9 +87,22 @@ func Logger(l *log.Logger, flags ...LoggerFlag) func(http.Handler) http.Handler
 				if qun, err := url.QueryUnescape(q); err == nil {
 					q = qun
 				}
-				l.Printf("[INFO] REST %s - %s - %s - %d (%d) - %v %s",
-					r.Method, q, strings.Split(r.RemoteAddr, ":")[0],
-					ww.Status(), ww.BytesWritten(), t2.Sub(t1), body)
+				attrs := []any{
+					"method", r.Method,
+					"url", q,
+					"remote", strings.Split(r.RemoteAddr, ":")[0],
+					"status", ww.Status(),
+					"bytes", ww.BytesWritten(),
+					"duration", t2.Sub(t1),
+				}
+				if body != "" {
+					attrs = append(attrs, "body", body)
+				}
+				l.InfoContext(r.Context(), "REST", attrs...)
+
+				route := routePattern(r)
+				metrics.HTTPRequestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(ww.Status())).Inc()
+				metrics.HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(t2.Sub(t1).Seconds())
 			}()
 
 			h.ServeHTTP(ww, r)
@@ -84,3 +112,126 @@ func Logger(l *log.Logger, flags ...LoggerFlag) func(http.Handler) http.Handler
 
 	return f
 }
+
+// Tracer middleware starts a span for every request, continuing the trace of the caller if it sent one.
+// The span is named after the route once it has been matched
+func Tracer(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("http.request_id", middleware.GetReqID(ctx)),
+		))
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := routePattern(r)
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", ww.Status()),
+		)
+		if ww.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(ww.Status()))
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// routePattern keeps the metrics cardinality bounded by labeling requests with their route, not the path
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return "unmatched"
+}
+
+// sessionCookie holds the session token of a signed in browser
+const sessionCookie = "rss_sum_session"
+
+type contextKey int
+
+const userKey contextKey = iota
+
+// UserFrom returns the user authenticated by the Authenticate middleware, nil for anonymous requests
+// and when authentication is disabled
+func UserFrom(ctx context.Context) *store.UserV1 {
+	user, _ := ctx.Value(userKey).(*store.UserV1)
+	return user
+}
+
+// Authenticate middleware puts the user of the bearer token or the session cookie into the request context.
+// Requests without credentials pass anonymously, RequireRole decides about them. An invalid bearer token is
+// rejected, an invalid session cookie is dropped
+func Authenticate(authenticator Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var user *store.UserV1
+			var err error
+
+			if header := r.Header.Get("Authorization"); header != "" {
+				token, ok := strings.CutPrefix(header, "Bearer ")
+				if !ok {
+					renderUnauthorized(w, r, "invalid authorization header", errors.New("expected a bearer token"))
+					return
+				}
+				if user, err = authenticator.TokenUser(r.Context(), token); err != nil {
+					renderUnauthorized(w, r, "invalid api token", err)
+					return
+				}
+			} else if cookie, cookieErr := r.Cookie(sessionCookie); cookieErr == nil {
+				if user, err = authenticator.SessionUser(r.Context(), cookie.Value); err != nil {
+					if !errors.Is(err, auth.ErrUnauthenticated) {
+						renderInternalServerError(w, r, "failed to authenticate", err)
+						return
+					}
+					slog.DebugContext(r.Context(), "dropping invalid session", "error", err)
+					http.SetCookie(w, expiredSessionCookie(r))
+					user = nil
+				}
+			}
+
+			if user != nil {
+				trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("enduser.id", user.Name))
+				r = r.WithContext(context.WithValue(r.Context(), userKey, user))
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireRole middleware rejects anonymous requests and the users whose role lacks the privileges of the given one.
+// Anonymous browsers are sent to the login page
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			user := UserFrom(r.Context())
+			switch {
+			case user == nil && isPageRequest(r):
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			case user == nil:
+				// HTMX follows the redirect with a full page load
+				w.Header().Set("HX-Redirect", "/login")
+				renderUnauthorized(w, r, "authentication required", errors.New("not signed in"))
+			case !auth.HasRole(user.Role, role):
+				slog.WarnContext(r.Context(), "forbidden", "user", user.Name, "role", user.Role, "required", role)
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, JSON{"error": "forbidden", "message": role + " role required"})
+			default:
+				next.ServeHTTP(w, r)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// isPageRequest tells full page loads of a browser apart from API and HTMX requests
+func isPageRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") && r.Header.Get("HX-Request") != "true"
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/rss/worker"
+)
+
+type FetchJSON struct {
+	Feed   string `json:"feed,omitempty"`
+	Status string `json:"status"`
+}
+
+// FilterPreviewRequestJSON holds the rules to preview, the configured rules of the feed when they are null
+type FilterPreviewRequestJSON struct {
+	Rules []worker.FilterRule `json:"rules"`
+}
+
+type FilterPreviewJSON struct {
+	GUID        string   `json:"guid"`
+	Title       string   `json:"title"`
+	Link        string   `json:"link"`
+	Matched     []string `json:"matched"`
+	Skipped     bool     `json:"skipped"`
+	Prioritized bool     `json:"prioritized"`
+	Tags        []string `json:"tags"`
+}
+
+type FilterPreviewsJSON struct {
+	Items []FilterPreviewJSON `json:"items"`
+}
+
+// POST /v1/admin/fetch
+func (s Server) triggerFetchCtrl(w http.ResponseWriter, r *http.Request) {
+	feedID := strings.TrimSpace(r.URL.Query().Get("feed"))
+
+	if err := s.Fetcher.TriggerFetch(feedID); err != nil {
+		switch {
+		case errors.Is(err, worker.ErrFeedNotFound):
+			renderNotFound(w, r, "failed to trigger fetch", err)
+		case errors.Is(err, worker.ErrFetchInProgress):
+			renderConflict(w, r, "failed to trigger fetch", err)
+		default:
+			renderInternalServerError(w, r, "failed to trigger fetch", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, FetchJSON{
+		Feed:   feedID,
+		Status: "triggered",
+	})
+}
+
+// POST /v1/admin/feeds/{id}/filters/preview
+func (s Server) previewFiltersCtrl(w http.ResponseWriter, r *http.Request) {
+	var previewRequest FilterPreviewRequestJSON
+	if err := decodeJSON(w, r, &previewRequest); err != nil && !errors.Is(err, io.EOF) {
+		renderDecodeError(w, r, err)
+		return
+	}
+
+	previews, err := s.Fetcher.PreviewFilters(r.Context(), chi.URLParam(r, "id"), previewRequest.Rules)
+	if err != nil {
+		switch {
+		case errors.Is(err, worker.ErrFeedNotFound):
+			renderNotFound(w, r, "feed not found", err)
+		case errors.Is(err, worker.ErrInvalidFilter):
+			renderBadRequest(w, r, "invalid filter", err)
+		default:
+			renderInternalServerError(w, r, "failed to preview filters", err)
+		}
+		return
+	}
+
+	items := make([]FilterPreviewJSON, 0, len(previews))
+	for _, preview := range previews {
+		items = append(items, FilterPreviewJSON{
+			GUID:        preview.GUID,
+			Title:       preview.Title,
+			Link:        preview.Link,
+			Matched:     append([]string{}, preview.Matched...),
+			Skipped:     preview.Skipped,
+			Prioritized: preview.Prioritized,
+			Tags:        append([]string{}, preview.Tags...),
+		})
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, FilterPreviewsJSON{Items: items})
+}
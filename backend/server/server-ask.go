@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+const (
+	defaultAskLimit = 5
+	maxAskLimit     = 10
+)
+
+type AskRequestJSON struct {
+	Question string     `json:"question"`
+	Limit    int        `json:"limit,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+type CitationJSON struct {
+	Index     int     `json:"index"`
+	ID        string  `json:"id"`
+	Title     string  `json:"title,omitempty"`
+	SourceURL string  `json:"sourceUrl,omitempty"`
+	Score     float64 `json:"score"`
+}
+
+// POST /v1/ask
+func (s Server) askCtrl(w http.ResponseWriter, r *http.Request) {
+	var askRequest AskRequestJSON
+	if err := decodeJSON(w, r, &askRequest); err != nil {
+		renderDecodeError(w, r, err)
+		return
+	}
+
+	askRequest.Question = strings.TrimSpace(askRequest.Question)
+	if askRequest.Question == "" {
+		renderBadRequest(w, r, "invalid question", fmt.Errorf("question is required"))
+		return
+	}
+
+	if askRequest.Limit == 0 {
+		askRequest.Limit = defaultAskLimit
+	}
+	if askRequest.Limit < 1 || askRequest.Limit > maxAskLimit {
+		renderBadRequest(w, r, "invalid limit", fmt.Errorf("limit should be between 1 and %d", maxAskLimit))
+		return
+	}
+
+	vector, err := s.Assistant.EmbedText(r.Context(), askRequest.Question)
+	if err != nil {
+		renderInternalServerError(w, r, "failed to embed question", err)
+		return
+	}
+
+	// load extra candidates so the time filter still leaves enough sources
+	candidates, err := s.Blogger.SearchPosts(r.Context(), s.Assistant.EmbeddingModel(), vector, maxSearchLimit)
+	if err != nil {
+		renderInternalServerError(w, r, "failed to search posts", err)
+		return
+	}
+
+	sources := make([]*store.PostV1, 0, askRequest.Limit)
+	citations := make([]CitationJSON, 0, askRequest.Limit)
+	for _, candidate := range candidates {
+		if len(sources) == askRequest.Limit {
+			break
+		}
+		if askRequest.Since != nil && candidate.Post.CreatedAt.Before(*askRequest.Since) {
+			continue
+		}
+
+		sources = append(sources, candidate.Post)
+		citations = append(citations, CitationJSON{
+			Index:     len(sources),
+			ID:        candidate.Post.ID,
+			Title:     candidate.Post.Title,
+			SourceURL: candidate.Post.SourceURL,
+			Score:     candidate.Score,
+		})
+	}
+
+	if len(sources) == 0 {
+		renderNotFound(w, r, "no relevant posts found", fmt.Errorf("nothing to answer from"))
+		return
+	}
+
+	citationsJSON, err := json.Marshal(citations)
+	if err != nil {
+		renderInternalServerError(w, r, "failed to encode citations", err)
+		return
+	}
+
+	stream := newSSEWriter(w)
+	if err := stream.Event("citations", string(citationsJSON)); err != nil {
+		slog.WarnContext(r.Context(), "failed to stream citations", "error", err)
+		return
+	}
+
+	tokenFunc := func(token string) error {
+		return stream.Event("token", token)
+	}
+
+	if err := s.Assistant.AnswerQuestion(r.Context(), askRequest.Question, sources, tokenFunc); err != nil {
+		slog.ErrorContext(r.Context(), "failed to answer question", "error", err)
+		if err := stream.Event("error", err.Error()); err != nil {
+			slog.WarnContext(r.Context(), "failed to stream error", "error", err)
+		}
+		return
+	}
+
+	if err := stream.Event("done", ""); err != nil {
+		slog.WarnContext(r.Context(), "failed to stream completion", "error", err)
+	}
+}
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/auth"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+type UserJSON struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type UsersResultsJSON struct {
+	Users []UserJSON `json:"users"`
+}
+
+type AddUserRequestJSON struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type TokenJSON struct {
+	ID         uint64     `json:"id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"` // only returned when the token is created
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type TokensResultsJSON struct {
+	Tokens []TokenJSON `json:"tokens"`
+}
+
+type CreateTokenRequestJSON struct {
+	Name string `json:"name"`
+}
+
+// requireRole is RequireRole when authentication is enabled, everyone has every role otherwise
+func (s Server) requireRole(role string) func(http.Handler) http.Handler {
+	if s.Authenticator == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return RequireRole(role)
+}
+
+// GET /login
+func (s *Server) loginPageCtrl(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirect(r.URL.Query().Get("next"))
+	if UserFrom(r.Context()) != nil {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	s.render(w, r, http.StatusOK, loginTmplName, loginTmplName, templateData{
+		Version: s.Version,
+		View:    loginView{Next: next},
+	})
+}
+
+// POST /login
+func (s *Server) loginCtrl(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	next := safeRedirect(r.FormValue("next"))
+
+	session, err := s.Authenticator.Login(r.Context(), name, r.FormValue("password"))
+	if err != nil {
+		status, message := http.StatusUnauthorized, "Invalid name or password"
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			slog.WarnContext(r.Context(), "failed to sign in", "user", name, "error", err)
+		} else {
+			slog.ErrorContext(r.Context(), "failed to sign in", "user", name, "error", err)
+			status, message = http.StatusInternalServerError, "Signing in failed, please try again"
+		}
+		s.render(w, r, status, loginTmplName, loginTmplName, templateData{
+			Version: s.Version,
+			View:    loginView{Name: name, Next: next, Error: message},
+		})
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   isSecure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// POST /logout
+func (s *Server) logoutCtrl(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := s.Authenticator.Logout(r.Context(), cookie.Value); err != nil {
+			renderInternalServerError(w, r, "failed to sign out", err)
+			return
+		}
+	}
+
+	http.SetCookie(w, expiredSessionCookie(r))
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// GET /v1/tokens
+func (s Server) getTokensCtrl(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.Authenticator.Tokens(r.Context(), UserFrom(r.Context()).ID)
+	if err != nil {
+		renderInternalServerError(w, r, "failed to load tokens", err)
+		return
+	}
+
+	results := make([]TokenJSON, 0, len(tokens))
+	for _, token := range tokens {
+		results = append(results, mapTokenToJSON(token, ""))
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, TokensResultsJSON{Tokens: results})
+}
+
+// POST /v1/tokens
+func (s Server) createTokenCtrl(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequestJSON
+	if err := decodeJSON(w, r, &req); err != nil {
+		renderDecodeError(w, r, err)
+		return
+	}
+
+	secret, token, err := s.Authenticator.CreateToken(r.Context(), UserFrom(r.Context()).ID, req.Name)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidUser) {
+			renderBadRequest(w, r, "invalid token", err)
+		} else {
+			renderInternalServerError(w, r, "failed to create token", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, mapTokenToJSON(token, secret))
+}
+
+// DELETE /v1/tokens/{id}
+func (s Server) revokeTokenCtrl(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderBadRequest(w, r, "invalid token id", err)
+		return
+	}
+
+	if err := s.Authenticator.RevokeToken(r.Context(), UserFrom(r.Context()).ID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			renderNotFound(w, r, "token not found", err)
+		} else {
+			renderInternalServerError(w, r, "failed to revoke token", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /v1/admin/users
+func (s Server) getUsersCtrl(w http.ResponseWriter, r *http.Request) {
+	users, err := s.Authenticator.Users(r.Context())
+	if err != nil {
+		renderInternalServerError(w, r, "failed to load users", err)
+		return
+	}
+
+	results := make([]UserJSON, 0, len(users))
+	for _, user := range users {
+		results = append(results, mapUserToJSON(user))
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, UsersResultsJSON{Users: results})
+}
+
+// POST /v1/admin/users
+func (s Server) addUserCtrl(w http.ResponseWriter, r *http.Request) {
+	var req AddUserRequestJSON
+	if err := decodeJSON(w, r, &req); err != nil {
+		renderDecodeError(w, r, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = store.RoleReader
+	}
+
+	user, err := s.Authenticator.AddUser(r.Context(), req.Name, req.Password, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidUser):
+			renderBadRequest(w, r, "invalid user", err)
+		case errors.Is(err, store.ErrAlreadyExists):
+			renderConflict(w, r, "user already exists", err)
+		default:
+			renderInternalServerError(w, r, "failed to add user", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, mapUserToJSON(user))
+}
+
+// DELETE /v1/admin/users/{id}
+func (s Server) removeUserCtrl(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderBadRequest(w, r, "invalid user id", err)
+		return
+	}
+
+	if err := s.Authenticator.RemoveUser(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			renderNotFound(w, r, "user not found", err)
+		case errors.Is(err, auth.ErrLastAdmin):
+			renderConflict(w, r, "failed to remove user", err)
+		default:
+			renderInternalServerError(w, r, "failed to remove user", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func mapUserToJSON(user *store.UserV1) UserJSON {
+	return UserJSON{
+		ID:        user.ID,
+		Name:      user.Name,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func mapTokenToJSON(token *store.APITokenV1, secret string) TokenJSON {
+	return TokenJSON{
+		ID:         token.ID,
+		Name:       token.Name,
+		Token:      secret,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+// safeRedirect keeps redirects after signing in on this site
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// isSecure tells whether the browser reached the server over HTTPS, directly or through a proxy
+func isSecure(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func expiredSessionCookie(r *http.Request) *http.Cookie {
+	return &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecure(r),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
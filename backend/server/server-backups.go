@@ -0,0 +1,62 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/backup"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+type BackupJSON struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type BackupsResultsJSON struct {
+	Backups []BackupJSON `json:"backups"`
+}
+
+// GET /v1/admin/backups
+func (s Server) getBackupsCtrl(w http.ResponseWriter, r *http.Request) {
+	archives, err := s.Backuper.Archives()
+	if err != nil {
+		renderInternalServerError(w, r, "failed to list backups", err)
+		return
+	}
+
+	results := make([]BackupJSON, 0, len(archives))
+	for _, archive := range archives {
+		results = append(results, mapBackupToJSON(&archive))
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, BackupsResultsJSON{Backups: results})
+}
+
+// POST /v1/admin/backups
+func (s Server) createBackupCtrl(w http.ResponseWriter, r *http.Request) {
+	archive, err := s.Backuper.Create(r.Context())
+	if err != nil {
+		if errors.Is(err, store.ErrSnapshotUnsupported) {
+			renderNotImplemented(w, r, "failed to back up", err)
+		} else {
+			renderInternalServerError(w, r, "failed to back up", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, mapBackupToJSON(archive))
+}
+
+func mapBackupToJSON(archive *backup.Archive) BackupJSON {
+	return BackupJSON{
+		Name:      archive.Name,
+		Size:      archive.Size,
+		CreatedAt: archive.CreatedAt,
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/rjxby/rss-sum/backend/export"
+)
+
+// GET /v1/export?format=ndjson&partitionKey=...&since=2024-01-02T03:04:05Z
+func (s Server) exportPostsCtrl(w http.ResponseWriter, r *http.Request) {
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		renderBadRequest(w, r, "invalid format", err)
+		return
+	}
+
+	filter := export.Filter{PartitionKey: r.URL.Query().Get("partitionKey")}
+	if since := r.URL.Query().Get("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			renderBadRequest(w, r, "invalid since parameter", err)
+			return
+		}
+	}
+
+	// exports of large databases outlast the write timeout of the server, the route is mounted without the request timeout for the same reason
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=rss-sum-posts.%s", format))
+	w.WriteHeader(http.StatusOK)
+
+	written, err := s.Exporter.Export(r.Context(), w, format, filter)
+	if err != nil {
+		// the status is sent already, the client gets a truncated file
+		slog.ErrorContext(r.Context(), "failed to export posts", "error", err, "written", written)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "posts exported", "format", format, "count", written)
+}
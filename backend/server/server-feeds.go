@@ -0,0 +1,108 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+type FeedHealthJSON struct {
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastStatusCode      int        `json:"lastStatusCode,omitempty"`
+	ItemsSeen           int        `json:"itemsSeen"`
+	ItemsNew            int        `json:"itemsNew"`
+	ItemsSummarized     int        `json:"itemsSummarized"`
+}
+
+type FeedJSON struct {
+	ID                string         `json:"id"`
+	URL               string         `json:"url"`
+	IntervalInSeconds int            `json:"intervalInSeconds"`
+	NextFetchAt       time.Time      `json:"nextFetchAt"`
+	LastFetchedAt     *time.Time     `json:"lastFetchedAt,omitempty"`
+	Disabled          bool           `json:"disabled"`
+	DisabledAt        *time.Time     `json:"disabledAt,omitempty"`
+	Health            FeedHealthJSON `json:"health"`
+}
+
+type FeedsResultsJSON struct {
+	Feeds []FeedJSON `json:"feeds"`
+}
+
+// GET /v1/feeds
+func (s Server) getFeedsCtrl(w http.ResponseWriter, r *http.Request) {
+	statuses, err := s.Blogger.GetFeedStatuses(r.Context())
+	if err != nil {
+		renderInternalServerError(w, r, "failed to load feeds", err)
+		return
+	}
+
+	feeds := make([]FeedJSON, 0, len(statuses))
+	for _, status := range statuses {
+		feeds = append(feeds, mapFeedStatusToJSON(status))
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, FeedsResultsJSON{Feeds: feeds})
+}
+
+// GET /v1/feeds/{id}/health
+func (s Server) getFeedHealthCtrl(w http.ResponseWriter, r *http.Request) {
+	status, err := s.Blogger.GetFeedStatus(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			renderNotFound(w, r, "feed not found", err)
+			return
+		}
+		renderInternalServerError(w, r, "failed to load feed health", err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, mapFeedStatusToJSON(status))
+}
+
+// POST /v1/admin/feeds/{id}/enable
+func (s Server) enableFeedCtrl(w http.ResponseWriter, r *http.Request) {
+	status, err := s.Blogger.EnableFeed(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			renderNotFound(w, r, "feed not found", err)
+			return
+		}
+		renderInternalServerError(w, r, "failed to enable feed", err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, mapFeedStatusToJSON(status))
+}
+
+func mapFeedStatusToJSON(status *store.FeedStatus) FeedJSON {
+	return FeedJSON{
+		ID:                status.Feed.ID,
+		URL:               status.Feed.URL,
+		IntervalInSeconds: status.Feed.IntervalInSeconds,
+		NextFetchAt:       status.Feed.NextFetchAt,
+		LastFetchedAt:     status.Feed.LastFetchedAt,
+		Disabled:          status.Feed.Disabled,
+		DisabledAt:        status.Feed.DisabledAt,
+		Health: FeedHealthJSON{
+			LastSuccessAt:       status.Health.LastSuccessAt,
+			LastErrorAt:         status.Health.LastErrorAt,
+			LastError:           status.Health.LastError,
+			ConsecutiveFailures: status.Health.ConsecutiveFailures,
+			LastStatusCode:      status.Health.LastStatusCode,
+			ItemsSeen:           status.Health.ItemsSeen,
+			ItemsNew:            status.Health.ItemsNew,
+			ItemsSummarized:     status.Health.ItemsSummarized,
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// readinessTimeout bounds all readiness checks, so a hanging dependency fails the probe instead of blocking it
+const readinessTimeout = 5 * time.Second
+
+type HealthJSON struct {
+	Status string `json:"status"`
+}
+
+type ReadinessJSON struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type VersionJSON struct {
+	Revision       string `json:"revision"`
+	GoVersion      string `json:"goVersion"`
+	Model          string `json:"model"`
+	EmbeddingModel string `json:"embeddingModel"`
+}
+
+// GET /healthz
+func (s Server) healthzCtrl(w http.ResponseWriter, r *http.Request) {
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, HealthJSON{Status: "ok"})
+}
+
+type readinessCheck struct {
+	name  string
+	check func() error
+}
+
+// GET /readyz
+func (s Server) readyzCtrl(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := []readinessCheck{
+		{"database", func() error { return s.Blogger.Ping(ctx) }},
+		{"ollama", func() error { return s.Assistant.Ping(ctx) }},
+		{"templates", s.checkTemplates},
+	}
+	// a web tier scaled separately is ready without the worker
+	if s.Scheduler != nil {
+		checks = append(checks, readinessCheck{"worker", s.Scheduler.CheckLastRun})
+	}
+
+	result := ReadinessJSON{Status: "ready", Checks: make(map[string]string, len(checks))}
+	status := http.StatusOK
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			slog.WarnContext(r.Context(), "readiness check failed", "check", c.name, "error", err)
+			result.Checks[c.name] = err.Error()
+			result.Status = "not ready"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		result.Checks[c.name] = "ok"
+	}
+
+	render.Status(r, status)
+	render.JSON(w, r, result)
+}
+
+// GET /api/v1/version
+func (s Server) versionCtrl(w http.ResponseWriter, r *http.Request) {
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, VersionJSON{
+		Revision:       s.Version,
+		GoVersion:      runtime.Version(),
+		Model:          s.Assistant.Model(),
+		EmbeddingModel: s.Assistant.EmbeddingModel(),
+	})
+}
+
+func (s Server) checkTemplates() error {
+	if len(s.templateCache) == 0 {
+		return errors.New("templates are not loaded")
+	}
+
+	return nil
+}
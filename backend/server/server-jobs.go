@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/blogger"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+type JobJSON struct {
+	ID        uint64    `json:"id"`
+	Kind      string    `json:"kind"`
+	Status    string    `json:"status"`
+	URL       string    `json:"url"`
+	Title     string    `json:"title,omitempty"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError,omitempty"`
+	RunAt     time.Time `json:"runAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type JobsResultsJSON struct {
+	Jobs []JobJSON `json:"jobs"`
+}
+
+// GET /v1/admin/jobs/dead
+func (s Server) getDeadJobsCtrl(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.Blogger.GetDeadJobs(r.Context())
+	if err != nil {
+		renderInternalServerError(w, r, "failed to load dead jobs", err)
+		return
+	}
+
+	results := make([]JobJSON, 0, len(jobs))
+	for _, job := range jobs {
+		results = append(results, mapJobToJSON(job))
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, JobsResultsJSON{Jobs: results})
+}
+
+// POST /v1/admin/jobs/{id}/requeue
+func (s Server) requeueJobCtrl(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		renderBadRequest(w, r, "invalid job id", err)
+		return
+	}
+
+	job, err := s.Blogger.RequeueJob(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			renderNotFound(w, r, "job not found", err)
+		case errors.Is(err, blogger.ErrJobNotDead):
+			renderConflict(w, r, "failed to requeue job", err)
+		default:
+			renderInternalServerError(w, r, "failed to requeue job", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, mapJobToJSON(job))
+}
+
+func mapJobToJSON(job *store.JobV1) JobJSON {
+	return JobJSON{
+		ID:        job.ID,
+		Kind:      job.Kind,
+		Status:    job.Status,
+		URL:       job.Post.SourceURL,
+		Title:     job.Post.Title,
+		Attempts:  job.Attempts,
+		LastError: job.LastError,
+		RunAt:     job.RunAt,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+}
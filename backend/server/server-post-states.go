@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+type PostStateJSON struct {
+	PostID    string `json:"postId"`
+	Read      bool   `json:"read"`
+	Starred   bool   `json:"starred"`
+	ReadLater bool   `json:"readLater"`
+}
+
+type MarkPostsReadJSON struct {
+	Marked int64 `json:"marked"`
+}
+
+// PUT and DELETE /v1/posts/{id}/{flag} set and clear a flag of the post for the user
+func (s Server) setPostStateCtrl(flag string, on bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := s.Blogger.SetPostState(r.Context(), userID(UserFrom(r.Context())), chi.URLParam(r, "id"), flag, on)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				renderNotFound(w, r, "post not found", err)
+			} else {
+				renderInternalServerError(w, r, "failed to save post state", err)
+			}
+			return
+		}
+
+		view := newPostStateView(state.PostID, state)
+
+		if r.Header.Get("HX-Request") == "true" {
+			data := templateData{
+				Version: s.Version,
+				User:    UserFrom(r.Context()),
+				View:    view,
+			}
+			s.render(w, r, http.StatusOK, postStateTmplName, postStateTmplName, data)
+			return
+		}
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, PostStateJSON(view))
+	}
+}
+
+// POST /v1/posts/read marks the posts of the timeline created until then as read, until defaults to now
+func (s Server) markPostsReadCtrl(w http.ResponseWriter, r *http.Request) {
+	until := time.Now().UTC()
+	if param := r.URL.Query().Get("until"); param != "" {
+		parsed, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			renderBadRequest(w, r, "invalid until parameter", err)
+			return
+		}
+		until = parsed
+	}
+
+	partitionKey := strings.TrimSpace(r.URL.Query().Get("partitionKey"))
+
+	query := store.PostsQuery{UserID: userID(UserFrom(r.Context()))}
+	marked, err := s.Blogger.MarkPostsRead(r.Context(), query, partitionKey, until)
+	if err != nil {
+		renderInternalServerError(w, r, "failed to mark posts read", err)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		// the posts container reloads on this event
+		w.Header().Set("HX-Trigger", "posts-read")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, MarkPostsReadJSON{Marked: marked})
+}
@@ -1,7 +1,11 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/render"
@@ -16,10 +20,24 @@ type PostsResultsJSON struct {
 }
 
 type PostJSON struct {
-	ID        string `json:"id,omitempty"`
-	Title     string `json:"title,omitempty"`
-	Text      string `json:"text,omitempty"`
-	SourceURL string `json:"sourceUrl,omitempty"`
+	ID        string   `json:"id,omitempty"`
+	Title     string   `json:"title,omitempty"`
+	Text      string   `json:"text,omitempty"`
+	SourceURL string   `json:"sourceUrl,omitempty"`
+	Score     float64  `json:"score,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Read      bool     `json:"read,omitempty"`
+	Starred   bool     `json:"starred,omitempty"`
+	ReadLater bool     `json:"readLater,omitempty"`
+	// Relevance holds the scores of the post by interest profile
+	Relevance map[string]int `json:"relevance,omitempty"`
+}
+
+// timelinePage is a page of posts with their states and relevance scores by post ID
+type timelinePage struct {
+	*store.PaginationPostsResult
+	States map[string]*store.PostStateV1
+	Scores map[string][]*store.PostScoreV1
 }
 
 // GET /v1/posts
@@ -38,20 +56,174 @@ func (s Server) getPostsCtrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query := store.PostsQuery{Page: page, PageSize: pageSize}
+	if err := parsePostsFilters(r.URL.Query(), &query); err != nil {
+		renderBadRequest(w, r, "invalid filter parameter", err)
+		return
+	}
+	if err := parseRelevance(r.URL.Query(), s.Assistant.ProfileNames(), &query); err != nil {
+		renderBadRequest(w, r, "invalid relevance parameter", err)
+		return
+	}
+
 	partitionKey := strings.TrimSpace(r.URL.Query().Get("partitionKey"))
 
-	posts, err := s.Blogger.GetPosts(page, pageSize, partitionKey)
+	posts, err := s.loadPosts(r, query, partitionKey)
 	if err != nil {
 		renderInternalServerError(w, r, "failed to load posts", err)
 		return
 	}
 
-	postsResults := mapToJSON(posts)
+	postsResults := mapToJSON(posts.PaginationPostsResult)
+	for i, post := range postsResults.Posts {
+		view := newPostStateView(post.ID, posts.States[post.ID])
+		postsResults.Posts[i].Read = view.Read
+		postsResults.Posts[i].Starred = view.Starred
+		postsResults.Posts[i].ReadLater = view.ReadLater
+
+		for _, score := range posts.Scores[post.ID] {
+			if postsResults.Posts[i].Relevance == nil {
+				postsResults.Posts[i].Relevance = make(map[string]int)
+			}
+			postsResults.Posts[i].Relevance[score.Profile] = score.Score
+		}
+	}
 
 	render.Status(r, http.StatusOK)
 	render.JSON(w, r, postsResults)
 }
 
+// loadPosts returns the timeline of the signed in user, or all posts when authentication is disabled,
+// along with the states and the scores of the profiles of the query of the returned posts
+func (s Server) loadPosts(r *http.Request, query store.PostsQuery, partitionKey string) (*timelinePage, error) {
+	user := UserFrom(r.Context())
+	query.UserID = userID(user)
+
+	var posts *store.PaginationPostsResult
+	var err error
+	if user == nil && !query.Unread && !query.Starred && !query.ReadLater &&
+		query.Sort != store.SortRelevance && query.MinScore == 0 {
+		posts, err = s.Blogger.GetPosts(r.Context(), query.Page, query.PageSize, partitionKey)
+	} else {
+		posts, err = s.Blogger.GetTimeline(r.Context(), query, partitionKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	page := &timelinePage{PaginationPostsResult: posts}
+	if len(posts.Posts) == 0 {
+		return page, nil
+	}
+
+	ids := make([]string, 0, len(posts.Posts))
+	for _, post := range posts.Posts {
+		ids = append(ids, post.ID)
+	}
+	page.States, err = s.Blogger.GetPostStates(r.Context(), query.UserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	// scores of removed profiles are left out
+	if len(query.Profiles) > 0 {
+		page.Scores, err = s.Blogger.GetPostScores(r.Context(), ids, query.Profiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// parsePostsFilters reads the unread, starred and readLater filters into the query, a missing one is off
+func parsePostsFilters(values url.Values, query *store.PostsQuery) error {
+	filters := []struct {
+		name  string
+		value *bool
+	}{
+		{"unread", &query.Unread},
+		{"starred", &query.Starred},
+		{"readLater", &query.ReadLater},
+	}
+
+	for _, filter := range filters {
+		param := values.Get(filter.name)
+		if param == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(param)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filter.name, err)
+		}
+		*filter.value = value
+	}
+
+	return nil
+}
+
+// parseRelevance reads the sort, profile and minScore parameters into the query. Posts are scored by the given profile,
+// or by all configured profiles when it is missing, relevance needs profiles to be configured
+func parseRelevance(values url.Values, profiles []string, query *store.PostsQuery) error {
+	if len(profiles) > 0 {
+		query.Profiles = profiles
+	}
+
+	switch sort := values.Get("sort"); sort {
+	case "", store.SortNewest, store.SortRelevance:
+		query.Sort = sort
+	default:
+		return fmt.Errorf("sort should be %q or %q", store.SortNewest, store.SortRelevance)
+	}
+
+	if param := values.Get("minScore"); param != "" {
+		minScore, err := strconv.Atoi(param)
+		if err != nil || minScore < 0 || minScore > store.MaxScore {
+			return fmt.Errorf("minScore should be a number from 0 to %d", store.MaxScore)
+		}
+		query.MinScore = minScore
+	}
+
+	if profile := values.Get("profile"); profile != "" {
+		if !slices.Contains(profiles, profile) {
+			return fmt.Errorf("unknown profile %q", profile)
+		}
+		query.Profiles = []string{profile}
+	}
+
+	if len(profiles) == 0 && (query.Sort == store.SortRelevance || query.MinScore > 0) {
+		return fmt.Errorf("no interest profiles are configured")
+	}
+
+	return nil
+}
+
+// relevanceParams encodes the relevance parameters of the request for the links to further pages
+func relevanceParams(query store.PostsQuery, profile string) string {
+	values := url.Values{}
+	if query.Sort != "" {
+		values.Set("sort", query.Sort)
+	}
+	if profile != "" {
+		values.Set("profile", profile)
+	}
+	if query.MinScore > 0 {
+		values.Set("minScore", strconv.Itoa(query.MinScore))
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+	return "&" + values.Encode()
+}
+
+// userID returns the ID of the user, the anonymous user when authentication is disabled
+func userID(user *store.UserV1) uint64 {
+	if user == nil {
+		return store.AnonymousUserID
+	}
+	return user.ID
+}
+
 func mapToJSON(posts *store.PaginationPostsResult) *PostsResultsJSON {
 	var mappedPosts []PostJSON
 	for _, post := range posts.Posts {
@@ -60,6 +232,7 @@ func mapToJSON(posts *store.PaginationPostsResult) *PostsResultsJSON {
 			Title:     post.Title,
 			Text:      post.Text,
 			SourceURL: post.SourceURL,
+			Tags:      post.TagList(),
 		})
 	}
 
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/retention"
+)
+
+type RetentionPostJSON struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	URL          string    `json:"url"`
+	PartitionKey string    `json:"partitionKey"`
+	Feed         string    `json:"feed,omitempty"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type RetentionReportJSON struct {
+	DryRun    bool                `json:"dryRun"`
+	Total     int                 `json:"total"`
+	Posts     []RetentionPostJSON `json:"posts"`
+	CreatedAt time.Time           `json:"createdAt"`
+}
+
+// GET /v1/admin/retention
+func (s Server) getRetentionReportCtrl(w http.ResponseWriter, r *http.Request) {
+	report, err := s.Pruner.DryRun(r.Context())
+	if err != nil {
+		renderInternalServerError(w, r, "failed to report expired posts", err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, mapRetentionReportToJSON(report))
+}
+
+func mapRetentionReportToJSON(report *retention.Report) RetentionReportJSON {
+	posts := make([]RetentionPostJSON, 0, len(report.Posts))
+	for _, post := range report.Posts {
+		posts = append(posts, RetentionPostJSON{
+			ID:           post.PostID,
+			Title:        post.Title,
+			URL:          post.SourceURL,
+			PartitionKey: post.PartitionKey,
+			Feed:         post.Feed,
+			Reason:       post.Reason,
+			CreatedAt:    post.CreatedAt,
+		})
+	}
+
+	return RetentionReportJSON{
+		DryRun:    report.DryRun,
+		Total:     len(posts),
+		Posts:     posts,
+		CreatedAt: report.CreatedAt,
+	}
+}
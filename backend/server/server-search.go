@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+type ScoredPostsResultsJSON struct {
+	Posts []PostJSON `json:"posts"`
+}
+
+// GET /v1/posts/similar
+func (s Server) getSimilarPostsCtrl(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		renderBadRequest(w, r, "invalid id parameter", fmt.Errorf("id is required"))
+		return
+	}
+
+	limit, err := parseLimitParam(r.URL.Query().Get("limit"))
+	if err != nil {
+		renderBadRequest(w, r, "invalid limit parameter", err)
+		return
+	}
+
+	posts, err := s.Blogger.GetSimilarPosts(r.Context(), id, limit)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			renderNotFound(w, r, "post has no embedding", err)
+			return
+		}
+		renderInternalServerError(w, r, "failed to load similar posts", err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, mapScoredToJSON(posts))
+}
+
+// GET /v1/search
+func (s Server) searchPostsCtrl(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("semantic"))
+	if query == "" {
+		renderBadRequest(w, r, "invalid semantic parameter", fmt.Errorf("semantic query is required"))
+		return
+	}
+
+	limit, err := parseLimitParam(r.URL.Query().Get("limit"))
+	if err != nil {
+		renderBadRequest(w, r, "invalid limit parameter", err)
+		return
+	}
+
+	vector, err := s.Assistant.EmbedText(r.Context(), query)
+	if err != nil {
+		renderInternalServerError(w, r, "failed to embed query", err)
+		return
+	}
+
+	posts, err := s.Blogger.SearchPosts(r.Context(), s.Assistant.EmbeddingModel(), vector, limit)
+	if err != nil {
+		renderInternalServerError(w, r, "failed to search posts", err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, mapScoredToJSON(posts))
+}
+
+func parseLimitParam(param string) (int, error) {
+	if param == "" {
+		return defaultSearchLimit, nil
+	}
+
+	limit, err := parseQueryParam(param)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 1 || limit > maxSearchLimit {
+		return 0, fmt.Errorf("limit should be between 1 and %d", maxSearchLimit)
+	}
+
+	return limit, nil
+}
+
+func mapScoredToJSON(posts []*store.ScoredPost) *ScoredPostsResultsJSON {
+	mappedPosts := make([]PostJSON, 0, len(posts))
+	for _, scored := range posts {
+		mappedPosts = append(mappedPosts, PostJSON{
+			ID:        scored.Post.ID,
+			Title:     scored.Post.Title,
+			Text:      scored.Post.Text,
+			SourceURL: scored.Post.SourceURL,
+			Score:     scored.Score,
+		})
+	}
+
+	return &ScoredPostsResultsJSON{
+		Posts: mappedPosts,
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/extractor"
+)
+
+type SubmissionRequestJSON struct {
+	URL string `json:"url"`
+}
+
+type SubmissionJSON struct {
+	URL    string `json:"url"`
+	Status string `json:"status"`
+}
+
+// POST /v1/submissions
+func (s Server) submitLinkCtrl(w http.ResponseWriter, r *http.Request) {
+	var submissionRequest SubmissionRequestJSON
+	if err := decodeJSON(w, r, &submissionRequest); err != nil {
+		renderDecodeError(w, r, err)
+		return
+	}
+
+	link, err := extractor.ParseURL(submissionRequest.URL)
+	if err != nil {
+		renderBadRequest(w, r, "invalid url", err)
+		return
+	}
+
+	if err := s.Submitter.SubmitLink(r.Context(), link.String()); err != nil {
+		renderServiceUnavailable(w, r, "failed to queue link", err)
+		return
+	}
+
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, SubmissionJSON{
+		URL:    link.String(),
+		Status: "queued",
+	})
+}
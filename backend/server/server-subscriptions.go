@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+type SubscriptionJSON struct {
+	FeedID string `json:"feedId"`
+	URL    string `json:"url"`
+}
+
+type SubscriptionsResultsJSON struct {
+	Subscriptions []SubscriptionJSON `json:"subscriptions"`
+}
+
+// GET /v1/subscriptions
+func (s Server) getSubscriptionsCtrl(w http.ResponseWriter, r *http.Request) {
+	feeds, err := s.Blogger.GetSubscribedFeeds(r.Context(), UserFrom(r.Context()).ID)
+	if err != nil {
+		renderInternalServerError(w, r, "failed to load subscriptions", err)
+		return
+	}
+
+	subscriptions := make([]SubscriptionJSON, 0, len(feeds))
+	for _, feed := range feeds {
+		subscriptions = append(subscriptions, SubscriptionJSON{FeedID: feed.ID, URL: feed.URL})
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, SubscriptionsResultsJSON{Subscriptions: subscriptions})
+}
+
+// PUT /v1/subscriptions/{id}
+func (s Server) subscribeCtrl(w http.ResponseWriter, r *http.Request) {
+	feed, err := s.Blogger.Subscribe(r.Context(), UserFrom(r.Context()).ID, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			renderNotFound(w, r, "feed not found", err)
+		} else {
+			renderInternalServerError(w, r, "failed to subscribe", err)
+		}
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		s.renderSubscription(w, r, feed.ID, true)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, SubscriptionJSON{FeedID: feed.ID, URL: feed.URL})
+}
+
+// DELETE /v1/subscriptions/{id}
+func (s Server) unsubscribeCtrl(w http.ResponseWriter, r *http.Request) {
+	feedID := chi.URLParam(r, "id")
+
+	if err := s.Blogger.Unsubscribe(r.Context(), UserFrom(r.Context()).ID, feedID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			renderNotFound(w, r, "subscription not found", err)
+		} else {
+			renderInternalServerError(w, r, "failed to unsubscribe", err)
+		}
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		s.renderSubscription(w, r, feedID, false)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// renderSubscription swaps the subscribe button of a feed on the status page
+func (s Server) renderSubscription(w http.ResponseWriter, r *http.Request, feedID string, subscribed bool) {
+	data := templateData{
+		Version: s.Version,
+		View:    subscriptionView{FeedID: feedID, Subscribed: subscribed},
+	}
+
+	s.render(w, r, http.StatusOK, subscriptionTmplName, subscriptionTmplName, data)
+}
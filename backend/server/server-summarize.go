@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type SummarizeRequestJSON struct {
+	URL  string `json:"url,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+type ArticleJSON struct {
+	Title     string `json:"title,omitempty"`
+	SourceURL string `json:"sourceUrl"`
+}
+
+// POST /v1/summarize
+func (s Server) summarizeCtrl(w http.ResponseWriter, r *http.Request) {
+	var summarizeRequest SummarizeRequestJSON
+	if err := decodeJSON(w, r, &summarizeRequest); err != nil {
+		renderDecodeError(w, r, err)
+		return
+	}
+
+	summarizeRequest.URL = strings.TrimSpace(summarizeRequest.URL)
+	summarizeRequest.Text = strings.TrimSpace(summarizeRequest.Text)
+	if (summarizeRequest.URL == "") == (summarizeRequest.Text == "") {
+		renderBadRequest(w, r, "invalid request body", fmt.Errorf("either url or text is required"))
+		return
+	}
+
+	text := summarizeRequest.Text
+	var article *ArticleJSON
+	if summarizeRequest.URL != "" {
+		extracted, err := s.Extractor.Extract(r.Context(), summarizeRequest.URL)
+		if err != nil {
+			renderBadRequest(w, r, "failed to extract content", err)
+			return
+		}
+
+		text = extracted.Text
+		article = &ArticleJSON{
+			Title:     extracted.Title,
+			SourceURL: extracted.URL,
+		}
+	}
+
+	stream := newSSEWriter(w)
+	if article != nil {
+		articleJSON, err := json.Marshal(article)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to encode article", "error", err)
+			return
+		}
+
+		if err := stream.Event("article", string(articleJSON)); err != nil {
+			slog.WarnContext(r.Context(), "failed to stream article", "error", err)
+			return
+		}
+	}
+
+	tokenFunc := func(token string) error {
+		return stream.Event("token", token)
+	}
+
+	if err := s.Assistant.SummarizeTextStream(r.Context(), text, tokenFunc); err != nil {
+		slog.ErrorContext(r.Context(), "failed to summarize", "error", err)
+		if err := stream.Event("error", err.Error()); err != nil {
+			slog.WarnContext(r.Context(), "failed to stream error", "error", err)
+		}
+		return
+	}
+
+	if err := stream.Event("done", ""); err != nil {
+		slog.WarnContext(r.Context(), "failed to stream completion", "error", err)
+	}
+}
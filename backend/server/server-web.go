@@ -4,32 +4,99 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
 	"path/filepath"
+	"slices"
+	"time"
 
+	"github.com/rjxby/rss-sum/backend/extractor"
 	"github.com/rjxby/rss-sum/backend/store"
 	"github.com/rjxby/rss-sum/frontend"
 )
 
 const (
-	baseTmpl       = "base"
-	clientTmplName = "index.tmpl.html"
-	postsTmplName  = "posts.tmpl.html"
+	baseTmpl             = "base"
+	clientTmplName       = "index.tmpl.html"
+	postsTmplName        = "posts.tmpl.html"
+	submissionsTmplName  = "submission.tmpl.html"
+	statusTmplName       = "status.tmpl.html"
+	loginTmplName        = "login.tmpl.html"
+	subscriptionTmplName = "subscription.tmpl.html"
+	postStateTmplName    = "post-state.tmpl.html"
 )
 
+// postFilters are the flag filters of the posts API selectable on the index page
+var postFilters = []string{"unread", "starred", "readLater"}
+
 type postsView struct {
-	Posts    []*store.PostV1
-	HasMore  bool
-	NextPage int
-	PageSize int
+	Posts                  []*store.PostV1
+	HasMore                bool
+	NextPage               int
+	PageSize               int
+	SavedLinksPartitionKey string
+	Query                  store.PostsQuery         // carries the filters across pages
+	Relevance              string                   // encoded relevance parameters, carried across pages like the filters
+	States                 map[string]postStateView // by post ID
+	Scores                 map[string][]*store.PostScoreV1
+}
+
+type indexView struct {
+	Filter string // one of postFilters, empty for all posts
+	Until  string // posts created until the page was rendered are marked read
+	// interest profiles offered for sorting and filtering by relevance, none hides the controls
+	Profiles  []string
+	Sort      string
+	Profile   string
+	MinScore  int
+	Relevance string // encoded relevance parameters for the posts container
+}
+
+type postStateView struct {
+	PostID    string
+	Read      bool
+	Starred   bool
+	ReadLater bool
+}
+
+type submissionView struct {
+	URL   string
+	Error string
+}
+
+type statusView struct {
+	Feeds      []*store.FeedStatus
+	Subscribed map[string]bool // feed IDs the user is subscribed to
+}
+
+type subscriptionView struct {
+	FeedID     string
+	Subscribed bool
+}
+
+type loginView struct {
+	Name  string
+	Next  string
+	Error string
 }
 
 type templateData struct {
 	Version string
+	User    *store.UserV1 // nil when authentication is disabled
 	View    any
 }
 
+// newPostStateView returns the flags of the post, none are set without a state
+func newPostStateView(postID string, state *store.PostStateV1) postStateView {
+	view := postStateView{PostID: postID}
+	if state != nil {
+		view.Read = state.ReadAt != nil
+		view.Starred = state.StarredAt != nil
+		view.ReadLater = state.ReadLaterAt != nil
+	}
+	return view
+}
+
 // NewTemplateCache initializes and returns a map of parsed templates
 func NewTemplateCache() (map[string]*template.Template, error) {
 	cache := map[string]*template.Template{}
@@ -62,11 +129,11 @@ func NewTemplateCache() (map[string]*template.Template, error) {
 }
 
 // render renders a template
-func (s *Server) render(w http.ResponseWriter, status int, page, tmplName string, data templateData) {
+func (s *Server) render(w http.ResponseWriter, r *http.Request, status int, page, tmplName string, data templateData) {
 	ts, ok := s.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
-		log.Printf("[ERROR] %v", err)
+		slog.ErrorContext(r.Context(), "failed to find template", "error", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -75,7 +142,7 @@ func (s *Server) render(w http.ResponseWriter, status int, page, tmplName string
 
 	err := ts.Execute(buf, data)
 	if err != nil {
-		log.Printf("[ERROR] %v", err)
+		slog.ErrorContext(r.Context(), "failed to execute template", "template", page, "error", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -83,7 +150,7 @@ func (s *Server) render(w http.ResponseWriter, status int, page, tmplName string
 	w.WriteHeader(status)
 	_, err = buf.WriteTo(w)
 	if err != nil {
-		log.Printf("[ERROR] %v", err)
+		slog.ErrorContext(r.Context(), "failed to write response", "template", page, "error", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -91,11 +158,61 @@ func (s *Server) render(w http.ResponseWriter, status int, page, tmplName string
 
 // clientCtrl serves the main HTML page
 func (s *Server) indexCtrl(w http.ResponseWriter, r *http.Request) {
+	view := indexView{
+		Until:    time.Now().UTC().Format(time.RFC3339),
+		Profiles: s.Assistant.ProfileNames(),
+	}
+	if filter := r.URL.Query().Get("filter"); slices.Contains(postFilters, filter) {
+		view.Filter = filter
+	}
+	// invalid relevance parameters show the newest posts
+	query := store.PostsQuery{}
+	if err := parseRelevance(r.URL.Query(), view.Profiles, &query); err == nil {
+		view.Sort = query.Sort
+		view.Profile = r.URL.Query().Get("profile")
+		view.MinScore = query.MinScore
+		view.Relevance = relevanceParams(query, view.Profile)
+	}
+
+	data := templateData{
+		Version: s.Version,
+		User:    UserFrom(r.Context()),
+		View:    view,
+	}
+
+	s.render(w, r, http.StatusOK, clientTmplName, clientTmplName, data)
+}
+
+// statusCtrl serves the feeds health page
+func (s *Server) statusCtrl(w http.ResponseWriter, r *http.Request) {
+	statuses, err := s.Blogger.GetFeedStatuses(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to load feed statuses", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	view := statusView{Feeds: statuses}
+	if user := UserFrom(r.Context()); user != nil {
+		feeds, err := s.Blogger.GetSubscribedFeeds(r.Context(), user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to load subscriptions", "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		view.Subscribed = make(map[string]bool, len(feeds))
+		for _, feed := range feeds {
+			view.Subscribed[feed.ID] = true
+		}
+	}
+
 	data := templateData{
 		Version: s.Version,
+		User:    UserFrom(r.Context()),
+		View:    view,
 	}
 
-	s.render(w, http.StatusOK, clientTmplName, clientTmplName, data)
+	s.render(w, r, http.StatusOK, statusTmplName, statusTmplName, data)
 }
 
 // getPostsHtmxCtrl handles HTMX requests for posts with pagination
@@ -113,29 +230,75 @@ func (s *Server) getPostsHtmxCtrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query := store.PostsQuery{Page: page, PageSize: pageSize}
+	if err := parsePostsFilters(r.URL.Query(), &query); err != nil {
+		renderBadRequest(w, r, "invalid filter parameter", err)
+		return
+	}
+	if err := parseRelevance(r.URL.Query(), s.Assistant.ProfileNames(), &query); err != nil {
+		renderBadRequest(w, r, "invalid relevance parameter", err)
+		return
+	}
+
 	partitionKey := r.URL.Query().Get("partitionKey")
 
 	// Reuse the same logic from getPostsCtrl to fetch posts
-	posts, err := s.Blogger.GetPosts(page, pageSize, partitionKey)
+	posts, err := s.loadPosts(r, query, partitionKey)
 	if err != nil {
 		renderInternalServerError(w, r, "failed to load posts", err)
 		return
 	}
 
+	views := make(map[string]postStateView, len(posts.Posts))
+	for _, post := range posts.Posts {
+		views[post.ID] = newPostStateView(post.ID, posts.States[post.ID])
+	}
+
 	// Check if there are more posts for pagination
 	hasMore := int64((page)*pageSize) < posts.Size
 
 	// Prepare data for the template
 	data := templateData{
 		Version: s.Version,
+		User:    UserFrom(r.Context()),
 		View: postsView{
 			Posts:    posts.Posts,
 			HasMore:  hasMore,
 			NextPage: page + 1,
 			PageSize: pageSize,
+
+			SavedLinksPartitionKey: store.SavedLinksPartitionKey,
+			Query:                  query,
+			Relevance:              relevanceParams(query, r.URL.Query().Get("profile")),
+			States:                 views,
+			Scores:                 posts.Scores,
 		},
 	}
 
 	// Render the template
-	s.render(w, http.StatusOK, postsTmplName, postsTmplName, data)
+	s.render(w, r, http.StatusOK, postsTmplName, postsTmplName, data)
+}
+
+// submitLinkHtmxCtrl handles HTMX form submissions of links to summarize
+func (s *Server) submitLinkHtmxCtrl(w http.ResponseWriter, r *http.Request) {
+	view := submissionView{}
+
+	link, err := extractor.ParseURL(r.FormValue("url"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "invalid submitted url", "error", err)
+		view.Error = err.Error()
+	} else if err := s.Submitter.SubmitLink(r.Context(), link.String()); err != nil {
+		slog.ErrorContext(r.Context(), "failed to queue link", "error", err)
+		view.Error = err.Error()
+	} else {
+		view.URL = link.String()
+	}
+
+	data := templateData{
+		Version: s.Version,
+		View:    view,
+	}
+
+	// HTMX swaps only successful responses, so errors are rendered in the fragment
+	s.render(w, r, http.StatusOK, submissionsTmplName, submissionsTmplName, data)
 }
@@ -2,9 +2,12 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,26 +17,111 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
+	"github.com/rjxby/rss-sum/backend/auth"
+	"github.com/rjxby/rss-sum/backend/backup"
+	"github.com/rjxby/rss-sum/backend/export"
+	"github.com/rjxby/rss-sum/backend/extractor"
+	"github.com/rjxby/rss-sum/backend/metrics"
+	"github.com/rjxby/rss-sum/backend/retention"
+	"github.com/rjxby/rss-sum/backend/rss/worker"
 	"github.com/rjxby/rss-sum/backend/store"
 )
 
 type Server struct {
 	Blogger       Blogger
+	Assistant     Assistant
+	Extractor     Extractor
+	Submitter     Submitter
+	Fetcher       Fetcher
+	Scheduler     Scheduler // nil when the worker runs in another process
+	Pruner        Pruner
+	Backuper      Backuper
+	Exporter      Exporter
+	Authenticator Authenticator // nil when authentication is disabled
 	Version       string
 	templateCache map[string]*template.Template
 }
 
 type Blogger interface {
-	GetPosts(page int, pageSize int, partitionKey string) (result *store.PaginationPostsResult, err error)
+	GetPosts(ctx context.Context, page int, pageSize int, partitionKey string) (result *store.PaginationPostsResult, err error)
+	GetTimeline(ctx context.Context, query store.PostsQuery, partitionKey string) (*store.PaginationPostsResult, error)
+	MarkPostsRead(ctx context.Context, query store.PostsQuery, partitionKey string, until time.Time) (int64, error)
+	SetPostState(ctx context.Context, userID uint64, postID string, flag string, on bool) (*store.PostStateV1, error)
+	GetPostStates(ctx context.Context, userID uint64, postIDs []string) (map[string]*store.PostStateV1, error)
+	GetPostScores(ctx context.Context, postIDs []string, profiles []string) (map[string][]*store.PostScoreV1, error)
+	GetSubscribedFeeds(ctx context.Context, userID uint64) ([]*store.FeedV1, error)
+	Subscribe(ctx context.Context, userID uint64, feedID string) (*store.FeedV1, error)
+	Unsubscribe(ctx context.Context, userID uint64, feedID string) error
+	GetSimilarPosts(ctx context.Context, id string, limit int) ([]*store.ScoredPost, error)
+	SearchPosts(ctx context.Context, model string, vector []float64, limit int) ([]*store.ScoredPost, error)
+	GetFeedStatuses(ctx context.Context) ([]*store.FeedStatus, error)
+	GetFeedStatus(ctx context.Context, id string) (*store.FeedStatus, error)
+	EnableFeed(ctx context.Context, id string) (*store.FeedStatus, error)
+	GetDeadJobs(ctx context.Context) ([]*store.JobV1, error)
+	RequeueJob(ctx context.Context, id uint64) (*store.JobV1, error)
+	Ping(ctx context.Context) error
+}
+
+type Assistant interface {
+	EmbedText(ctx context.Context, text string) ([]float64, error)
+	EmbeddingModel() string
+	Model() string
+	ProfileNames() []string
+	Ping(ctx context.Context) error
+	AnswerQuestion(ctx context.Context, question string, sources []*store.PostV1, fn func(token string) error) error
+	SummarizeTextStream(ctx context.Context, text string, fn func(token string) error) error
+}
+
+type Submitter interface {
+	SubmitLink(ctx context.Context, link string) error
+}
+
+type Fetcher interface {
+	TriggerFetch(feedID string) error
+	PreviewFilters(ctx context.Context, feedID string, rules []worker.FilterRule) ([]*worker.FilterPreview, error)
+}
+
+type Scheduler interface {
+	CheckLastRun() error
+}
+
+type Pruner interface {
+	DryRun(ctx context.Context) (*retention.Report, error)
+}
+
+type Backuper interface {
+	Create(ctx context.Context) (*backup.Archive, error)
+	Archives() ([]backup.Archive, error)
+}
+
+type Exporter interface {
+	Export(ctx context.Context, w io.Writer, format string, filter export.Filter) (int, error)
+}
+
+type Authenticator interface {
+	Login(ctx context.Context, name string, password string) (*auth.Session, error)
+	Logout(ctx context.Context, token string) error
+	SessionUser(ctx context.Context, token string) (*store.UserV1, error)
+	TokenUser(ctx context.Context, token string) (*store.UserV1, error)
+	Users(ctx context.Context) ([]*store.UserV1, error)
+	AddUser(ctx context.Context, name string, password string, role string) (*store.UserV1, error)
+	RemoveUser(ctx context.Context, id uint64) error
+	Tokens(ctx context.Context, userID uint64) ([]*store.APITokenV1, error)
+	CreateToken(ctx context.Context, userID uint64, name string) (string, *store.APITokenV1, error)
+	RevokeToken(ctx context.Context, userID uint64, id uint64) error
+}
+
+type Extractor interface {
+	Extract(ctx context.Context, rawURL string) (*extractor.Article, error)
 }
 
 // Run the lisener and request's router, activate rest server
 func (s Server) Run(ctx context.Context) error {
-	log.Printf("[INFO] activate rest server")
+	slog.Info("activate rest server")
 
 	templateCache, err := NewTemplateCache()
 	if err != nil {
-		return fmt.Errorf("[ERROR] failed to load templates: %v", err)
+		return fmt.Errorf("failed to load templates: %v", err)
 	}
 	s.templateCache = templateCache
 
@@ -47,7 +135,7 @@ func (s Server) Run(ctx context.Context) error {
 
 	go func() {
 		if err := httpServer.ListenAndServe(); err != nil {
-			log.Printf("[WARN] http server terminated, %s", err)
+			slog.Warn("http server terminated", "error", err)
 		}
 	}()
 
@@ -57,7 +145,7 @@ func (s Server) Run(ctx context.Context) error {
 	defer cancel()
 
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		log.Printf("[WARN] server shutdown error: %v", err)
+		slog.Warn("server shutdown error", "error", err)
 	}
 
 	return nil
@@ -66,21 +154,24 @@ func (s Server) Run(ctx context.Context) error {
 func (s Server) routes() chi.Router {
 	router := chi.NewRouter()
 
-	router.Use(middleware.Throttle(1000), middleware.Timeout(60*time.Second))
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Throttle(1000))
 	router.Use(tollbooth_chi.LimitHandler(tollbooth.NewLimiter(10, nil)))
 
-	router.Get("/", s.indexCtrl)
+	// scrapes and probes are neither logged nor counted
+	router.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(requestTimeout))
+		r.Handle("/metrics", metrics.Handler())
+		r.Get("/healthz", s.healthzCtrl)
+		r.Get("/readyz", s.readyzCtrl)
+	})
 
-	router.Route("/api/v1", func(r chi.Router) {
-		r.Use(Logger(log.Default()))
-		r.Get("/posts", func(w http.ResponseWriter, r *http.Request) {
-			// check if this is an HTMX request
-			if r.Header.Get("HX-Request") == "true" {
-				s.getPostsHtmxCtrl(w, r)
-			} else {
-				s.getPostsCtrl(w, r)
-			}
-		})
+	router.Group(func(r chi.Router) {
+		r.Use(Tracer, Logger(slog.Default()))
+		if s.Authenticator != nil {
+			r.Use(Authenticate(s.Authenticator))
+		}
+		s.loggedRoutes(r)
 	})
 
 	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
@@ -91,6 +182,96 @@ func (s Server) routes() chi.Router {
 	return router
 }
 
+func (s Server) loggedRoutes(router chi.Router) {
+	if s.Authenticator != nil {
+		router.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(requestTimeout))
+			r.Get("/login", s.loginPageCtrl)
+			r.Post("/login", s.loginCtrl)
+			r.Post("/logout", s.logoutCtrl)
+		})
+	}
+
+	router.Group(func(router chi.Router) {
+		router.Use(s.requireRole(store.RoleReader))
+		s.readerRoutes(router)
+	})
+}
+
+func (s Server) readerRoutes(router chi.Router) {
+	router.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(requestTimeout))
+		r.Get("/", s.indexCtrl)
+		r.Get("/status", s.statusCtrl)
+	})
+
+	router.Route("/api/v1", func(r chi.Router) {
+		// streamed answers and summaries last as long as the generation, exports as long as writing every post,
+		// they end with the client instead of the request timeout
+		r.Post("/ask", s.askCtrl)
+		r.Post("/summarize", s.summarizeCtrl)
+		r.Get("/export", s.exportPostsCtrl)
+
+		r.Group(s.apiRoutes)
+	})
+}
+
+func (s Server) apiRoutes(r chi.Router) {
+	r.Use(middleware.Timeout(requestTimeout))
+	r.Get("/posts", func(w http.ResponseWriter, r *http.Request) {
+		// check if this is an HTMX request
+		if r.Header.Get("HX-Request") == "true" {
+			s.getPostsHtmxCtrl(w, r)
+		} else {
+			s.getPostsCtrl(w, r)
+		}
+	})
+	r.Get("/posts/similar", s.getSimilarPostsCtrl)
+	r.Post("/posts/read", s.markPostsReadCtrl)
+	for _, flag := range []string{store.PostFlagRead, store.PostFlagStarred, store.PostFlagReadLater} {
+		r.Put("/posts/{id}/"+flag, s.setPostStateCtrl(flag, true))
+		r.Delete("/posts/{id}/"+flag, s.setPostStateCtrl(flag, false))
+	}
+	r.Get("/search", s.searchPostsCtrl)
+	r.Get("/version", s.versionCtrl)
+	r.Get("/feeds", s.getFeedsCtrl)
+	r.Get("/feeds/{id}/health", s.getFeedHealthCtrl)
+	r.Post("/submissions", func(w http.ResponseWriter, r *http.Request) {
+		// check if this is an HTMX request
+		if r.Header.Get("HX-Request") == "true" {
+			s.submitLinkHtmxCtrl(w, r)
+		} else {
+			s.submitLinkCtrl(w, r)
+		}
+	})
+
+	if s.Authenticator != nil {
+		r.Get("/tokens", s.getTokensCtrl)
+		r.Post("/tokens", s.createTokenCtrl)
+		r.Delete("/tokens/{id}", s.revokeTokenCtrl)
+		r.Get("/subscriptions", s.getSubscriptionsCtrl)
+		r.Put("/subscriptions/{id}", s.subscribeCtrl)
+		r.Delete("/subscriptions/{id}", s.unsubscribeCtrl)
+	}
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(s.requireRole(store.RoleAdmin))
+		r.Post("/fetch", s.triggerFetchCtrl)
+		r.Post("/feeds/{id}/enable", s.enableFeedCtrl)
+		r.Post("/feeds/{id}/filters/preview", s.previewFiltersCtrl)
+		r.Get("/jobs/dead", s.getDeadJobsCtrl)
+		r.Post("/jobs/{id}/requeue", s.requeueJobCtrl)
+		r.Get("/retention", s.getRetentionReportCtrl)
+		r.Get("/backups", s.getBackupsCtrl)
+		r.Post("/backups", s.createBackupCtrl)
+		if s.Authenticator != nil {
+			r.Get("/users", s.getUsersCtrl)
+			r.Post("/users", s.addUserCtrl)
+			r.Delete("/users/{id}", s.removeUserCtrl)
+		}
+	})
+}
+
 func parseQueryParam(param string) (int, error) {
 	value, err := strconv.Atoi(param)
 	if err != nil {
@@ -99,14 +280,70 @@ func parseQueryParam(param string) (int, error) {
 	return value, nil
 }
 
+// requestTimeout cancels requests which take too long, streams and exports are exempt
+const requestTimeout = 60 * time.Second
+
+// maxRequestBodySize caps the JSON bodies of requests, texts to summarize are the largest ones
+const maxRequestBodySize = 1 << 20
+
+// decodeJSON reads the JSON body of the request into v, bodies larger than maxRequestBodySize are cut off
+// and reported by a *http.MaxBytesError
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(v)
+}
+
+// renderDecodeError renders 413 for a request body which is too large, 400 for any other invalid body
+func renderDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		slog.WarnContext(r.Context(), "request body is too large", "error", err)
+		render.Status(r, http.StatusRequestEntityTooLarge)
+		render.JSON(w, r, JSON{"error": err.Error(), "message": "request body is too large"})
+		return
+	}
+
+	renderBadRequest(w, r, "invalid request body", err)
+}
+
 func renderBadRequest(w http.ResponseWriter, r *http.Request, message string, err error) {
-	log.Printf("[ERROR] %s: %v", message, err)
+	slog.ErrorContext(r.Context(), message, "error", err)
 	render.Status(r, http.StatusBadRequest)
 	render.JSON(w, r, JSON{"error": err.Error(), "message": message})
 }
 
+func renderUnauthorized(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.WarnContext(r.Context(), message, "error", err)
+	w.Header().Set("WWW-Authenticate", `Bearer realm="rss-sum"`)
+	render.Status(r, http.StatusUnauthorized)
+	render.JSON(w, r, JSON{"error": err.Error(), "message": message})
+}
+
+func renderNotFound(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.WarnContext(r.Context(), message, "error", err)
+	render.Status(r, http.StatusNotFound)
+	render.JSON(w, r, JSON{"error": err.Error(), "message": message})
+}
+
+func renderConflict(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.WarnContext(r.Context(), message, "error", err)
+	render.Status(r, http.StatusConflict)
+	render.JSON(w, r, JSON{"error": err.Error(), "message": message})
+}
+
+func renderNotImplemented(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.WarnContext(r.Context(), message, "error", err)
+	render.Status(r, http.StatusNotImplemented)
+	render.JSON(w, r, JSON{"error": err.Error(), "message": message})
+}
+
+func renderServiceUnavailable(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.ErrorContext(r.Context(), message, "error", err)
+	render.Status(r, http.StatusServiceUnavailable)
+	render.JSON(w, r, JSON{"error": err.Error(), "message": message})
+}
+
 func renderInternalServerError(w http.ResponseWriter, r *http.Request, message string, err error) {
-	log.Printf("[ERROR] %s: %v", message, err)
+	slog.ErrorContext(r.Context(), message, "error", err)
 	render.Status(r, http.StatusInternalServerError)
 	render.JSON(w, r, JSON{"error": err.Error(), "message": message})
 }
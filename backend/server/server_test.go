@@ -1,17 +1,42 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rjxby/rss-sum/backend/auth"
+	"github.com/rjxby/rss-sum/backend/backup"
+	"github.com/rjxby/rss-sum/backend/blogger"
+	"github.com/rjxby/rss-sum/backend/export"
+	"github.com/rjxby/rss-sum/backend/extractor"
+	"github.com/rjxby/rss-sum/backend/logging"
+	"github.com/rjxby/rss-sum/backend/metrics"
+	"github.com/rjxby/rss-sum/backend/retention"
+	"github.com/rjxby/rss-sum/backend/rss/worker"
 	"github.com/rjxby/rss-sum/backend/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // Mock blogger for testing
@@ -19,11 +44,284 @@ type MockBlogger struct {
 	mock.Mock
 }
 
-func (m *MockBlogger) GetPosts(page int, pageSize int, partitionKey string) (*store.PaginationPostsResult, error) {
+func (m *MockBlogger) GetPosts(ctx context.Context, page int, pageSize int, partitionKey string) (*store.PaginationPostsResult, error) {
 	args := m.Called(page, pageSize, partitionKey)
 	return args.Get(0).(*store.PaginationPostsResult), args.Error(1)
 }
 
+func (m *MockBlogger) GetTimeline(ctx context.Context, query store.PostsQuery, partitionKey string) (*store.PaginationPostsResult, error) {
+	args := m.Called(query, partitionKey)
+	return args.Get(0).(*store.PaginationPostsResult), args.Error(1)
+}
+
+func (m *MockBlogger) MarkPostsRead(ctx context.Context, query store.PostsQuery, partitionKey string, until time.Time) (int64, error) {
+	args := m.Called(query, partitionKey, until)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockBlogger) SetPostState(ctx context.Context, userID uint64, postID string, flag string, on bool) (*store.PostStateV1, error) {
+	args := m.Called(userID, postID, flag, on)
+	return args.Get(0).(*store.PostStateV1), args.Error(1)
+}
+
+func (m *MockBlogger) GetPostStates(ctx context.Context, userID uint64, postIDs []string) (map[string]*store.PostStateV1, error) {
+	args := m.Called(userID, postIDs)
+	return args.Get(0).(map[string]*store.PostStateV1), args.Error(1)
+}
+
+func (m *MockBlogger) GetPostScores(ctx context.Context, postIDs []string, profiles []string) (map[string][]*store.PostScoreV1, error) {
+	args := m.Called(postIDs, profiles)
+	return args.Get(0).(map[string][]*store.PostScoreV1), args.Error(1)
+}
+
+func (m *MockBlogger) GetSubscribedFeeds(ctx context.Context, userID uint64) ([]*store.FeedV1, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]*store.FeedV1), args.Error(1)
+}
+
+func (m *MockBlogger) Subscribe(ctx context.Context, userID uint64, feedID string) (*store.FeedV1, error) {
+	args := m.Called(userID, feedID)
+	return args.Get(0).(*store.FeedV1), args.Error(1)
+}
+
+func (m *MockBlogger) Unsubscribe(ctx context.Context, userID uint64, feedID string) error {
+	args := m.Called(userID, feedID)
+	return args.Error(0)
+}
+
+func (m *MockBlogger) GetSimilarPosts(ctx context.Context, id string, limit int) ([]*store.ScoredPost, error) {
+	args := m.Called(id, limit)
+	return args.Get(0).([]*store.ScoredPost), args.Error(1)
+}
+
+func (m *MockBlogger) SearchPosts(ctx context.Context, model string, vector []float64, limit int) ([]*store.ScoredPost, error) {
+	args := m.Called(model, vector, limit)
+	return args.Get(0).([]*store.ScoredPost), args.Error(1)
+}
+
+func (m *MockBlogger) GetFeedStatuses(ctx context.Context) ([]*store.FeedStatus, error) {
+	args := m.Called()
+	return args.Get(0).([]*store.FeedStatus), args.Error(1)
+}
+
+func (m *MockBlogger) GetFeedStatus(ctx context.Context, id string) (*store.FeedStatus, error) {
+	args := m.Called(id)
+	return args.Get(0).(*store.FeedStatus), args.Error(1)
+}
+
+func (m *MockBlogger) EnableFeed(ctx context.Context, id string) (*store.FeedStatus, error) {
+	args := m.Called(id)
+	return args.Get(0).(*store.FeedStatus), args.Error(1)
+}
+
+func (m *MockBlogger) GetDeadJobs(ctx context.Context) ([]*store.JobV1, error) {
+	args := m.Called()
+	return args.Get(0).([]*store.JobV1), args.Error(1)
+}
+
+func (m *MockBlogger) RequeueJob(ctx context.Context, id uint64) (*store.JobV1, error) {
+	args := m.Called(id)
+	return args.Get(0).(*store.JobV1), args.Error(1)
+}
+
+func (m *MockBlogger) Ping(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+// Mock assistant for testing
+type MockAssistant struct {
+	mock.Mock
+}
+
+func (m *MockAssistant) EmbedText(ctx context.Context, text string) ([]float64, error) {
+	args := m.Called(text)
+	return args.Get(0).([]float64), args.Error(1)
+}
+
+func (m *MockAssistant) EmbeddingModel() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockAssistant) Model() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockAssistant) ProfileNames() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *MockAssistant) Ping(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockAssistant) AnswerQuestion(ctx context.Context, question string, sources []*store.PostV1, fn func(token string) error) error {
+	args := m.Called(question, sources)
+	for _, token := range args.Get(0).([]string) {
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockAssistant) SummarizeTextStream(ctx context.Context, text string, fn func(token string) error) error {
+	args := m.Called(text)
+	for _, token := range args.Get(0).([]string) {
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+// Mock extractor for testing
+type MockExtractor struct {
+	mock.Mock
+}
+
+func (m *MockExtractor) Extract(ctx context.Context, rawURL string) (*extractor.Article, error) {
+	args := m.Called(rawURL)
+	return args.Get(0).(*extractor.Article), args.Error(1)
+}
+
+// Mock submitter for testing
+type MockSubmitter struct {
+	mock.Mock
+}
+
+func (m *MockSubmitter) SubmitLink(ctx context.Context, link string) error {
+	args := m.Called(link)
+	return args.Error(0)
+}
+
+// Mock fetcher for testing, also used as the scheduler
+type MockFetcher struct {
+	mock.Mock
+}
+
+func (m *MockFetcher) TriggerFetch(feedID string) error {
+	args := m.Called(feedID)
+	return args.Error(0)
+}
+
+func (m *MockFetcher) CheckLastRun() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockFetcher) PreviewFilters(ctx context.Context, feedID string, rules []worker.FilterRule) ([]*worker.FilterPreview, error) {
+	args := m.Called(feedID, rules)
+	return args.Get(0).([]*worker.FilterPreview), args.Error(1)
+}
+
+// Mock pruner for testing
+type MockPruner struct {
+	mock.Mock
+}
+
+func (m *MockPruner) DryRun(ctx context.Context) (*retention.Report, error) {
+	args := m.Called()
+	return args.Get(0).(*retention.Report), args.Error(1)
+}
+
+// Mock backuper for testing
+type MockBackuper struct {
+	mock.Mock
+}
+
+func (m *MockBackuper) Create(ctx context.Context) (*backup.Archive, error) {
+	args := m.Called()
+	return args.Get(0).(*backup.Archive), args.Error(1)
+}
+
+func (m *MockBackuper) Archives() ([]backup.Archive, error) {
+	args := m.Called()
+	return args.Get(0).([]backup.Archive), args.Error(1)
+}
+
+// Mock exporter for testing
+type MockExporter struct {
+	mock.Mock
+}
+
+func (m *MockExporter) Export(ctx context.Context, w io.Writer, format string, filter export.Filter) (int, error) {
+	args := m.Called(format, filter)
+	if _, err := io.WriteString(w, args.String(0)); err != nil {
+		return 0, err
+	}
+	return args.Int(1), args.Error(2)
+}
+
+// Mock authenticator for testing
+type MockAuthenticator struct {
+	mock.Mock
+}
+
+func (m *MockAuthenticator) Login(ctx context.Context, name string, password string) (*auth.Session, error) {
+	args := m.Called(name, password)
+	return args.Get(0).(*auth.Session), args.Error(1)
+}
+
+func (m *MockAuthenticator) Logout(ctx context.Context, token string) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockAuthenticator) SessionUser(ctx context.Context, token string) (*store.UserV1, error) {
+	args := m.Called(token)
+	return args.Get(0).(*store.UserV1), args.Error(1)
+}
+
+func (m *MockAuthenticator) TokenUser(ctx context.Context, token string) (*store.UserV1, error) {
+	args := m.Called(token)
+	return args.Get(0).(*store.UserV1), args.Error(1)
+}
+
+func (m *MockAuthenticator) Users(ctx context.Context) ([]*store.UserV1, error) {
+	args := m.Called()
+	return args.Get(0).([]*store.UserV1), args.Error(1)
+}
+
+func (m *MockAuthenticator) AddUser(ctx context.Context, name string, password string, role string) (*store.UserV1, error) {
+	args := m.Called(name, password, role)
+	return args.Get(0).(*store.UserV1), args.Error(1)
+}
+
+func (m *MockAuthenticator) RemoveUser(ctx context.Context, id uint64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockAuthenticator) Tokens(ctx context.Context, userID uint64) ([]*store.APITokenV1, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]*store.APITokenV1), args.Error(1)
+}
+
+func (m *MockAuthenticator) CreateToken(ctx context.Context, userID uint64, name string) (string, *store.APITokenV1, error) {
+	args := m.Called(userID, name)
+	return args.String(0), args.Get(1).(*store.APITokenV1), args.Error(2)
+}
+
+func (m *MockAuthenticator) RevokeToken(ctx context.Context, userID uint64, id uint64) error {
+	args := m.Called(userID, id)
+	return args.Error(0)
+}
+
+var (
+	testReader = &store.UserV1{ID: 1, Name: "bob", Role: store.RoleReader}
+	testAdmin  = &store.UserV1{ID: 2, Name: "alice", Role: store.RoleAdmin}
+)
+
+// withUser authenticates the request as the user, like the Authenticate middleware
+func withUser(req *http.Request, user *store.UserV1) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), userKey, user))
+}
+
 func TestParseQueryParam(t *testing.T) {
 	tbl := []struct {
 		input       string
@@ -69,10 +367,15 @@ func TestGetPostsCtrl(t *testing.T) {
 			Size:         2,
 		}
 		mockBlogger.On("GetPosts", 1, 10, "test-key").Return(expectedResult, nil)
+		mockBlogger.On("GetPostStates", store.AnonymousUserID, []string{"1", "2"}).Return(map[string]*store.PostStateV1{}, nil)
+
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("ProfileNames").Return([]string{})
 
 		server := Server{
-			Blogger: mockBlogger,
-			Version: "test",
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
 		}
 
 		// Create request
@@ -100,12 +403,57 @@ func TestGetPostsCtrl(t *testing.T) {
 		mockBlogger.AssertExpectations(t)
 	})
 
+	t.Run("Timeline", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("GetTimeline", store.PostsQuery{UserID: testReader.ID, Page: 1, PageSize: 10}, "").Return(&store.PaginationPostsResult{
+			Posts:    []*store.PostV1{{ID: "1", Text: "Content 1", SourceURL: "http://example.com/1"}},
+			Page:     1,
+			PageSize: 10,
+			Size:     1,
+		}, nil)
+		mockBlogger.On("GetPostStates", testReader.ID, []string{"1"}).Return(map[string]*store.PostStateV1{}, nil)
+
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("ProfileNames").Return([]string{})
+
+		server := Server{
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/posts", server.getPostsCtrl)
+		req := withUser(httptest.NewRequest("GET", "/api/v1/posts?page=1&pageSize=10", nil), testReader)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response PostsResultsJSON
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(response.Posts))
+
+		mockBlogger.AssertExpectations(t)
+		mockBlogger.AssertNotCalled(t, "GetPosts", mock.Anything, mock.Anything, mock.Anything)
+	})
+
 	t.Run("InvalidPage", func(t *testing.T) {
 		// Setup
 		mockBlogger := new(MockBlogger)
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("ProfileNames").Return([]string{})
+
 		server := Server{
-			Blogger: mockBlogger,
-			Version: "test",
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
 		}
 
 		// Create request
@@ -131,9 +479,13 @@ func TestGetPostsCtrl(t *testing.T) {
 	t.Run("InvalidPageSize", func(t *testing.T) {
 		// Setup
 		mockBlogger := new(MockBlogger)
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("ProfileNames").Return([]string{})
+
 		server := Server{
-			Blogger: mockBlogger,
-			Version: "test",
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
 		}
 
 		// Create request
@@ -162,9 +514,13 @@ func TestGetPostsCtrl(t *testing.T) {
 		expectedError := errors.New("database error")
 		mockBlogger.On("GetPosts", 1, 10, "").Return((*store.PaginationPostsResult)(nil), expectedError)
 
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("ProfileNames").Return([]string{})
+
 		server := Server{
-			Blogger: mockBlogger,
-			Version: "test",
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
 		}
 
 		// Create request
@@ -190,53 +546,2260 @@ func TestGetPostsCtrl(t *testing.T) {
 	})
 }
 
-func TestMapToJSON(t *testing.T) {
-	// Setup
-	input := &store.PaginationPostsResult{
-		Posts: []*store.PostV1{
-			{ID: "1", Text: "Content 1", SourceURL: "http://example.com/1"},
-			{ID: "2", Text: "Content 2", SourceURL: "http://example.com/2"},
-		},
-		Page:         1,
-		PageSize:     10,
-		PartitionKey: "test-key",
-		Size:         2,
-	}
+func TestSearchPostsCtrl(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("EmbedText", "chip export controls").Return([]float64{0.1, 0.2}, nil)
+		mockAssistant.On("EmbeddingModel").Return("embed-model")
+		mockBlogger.On("SearchPosts", "embed-model", []float64{0.1, 0.2}, 5).Return([]*store.ScoredPost{
+			{Post: &store.PostV1{ID: "1", Title: "Chips"}, Score: 0.9},
+		}, nil)
 
-	// Execute
-	result := mapToJSON(input)
+		server := Server{
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
+		}
 
-	// Verify
-	assert.Equal(t, 1, result.Page)
-	assert.Equal(t, 10, result.PageSize)
-	assert.Equal(t, "test-key", result.PartitionKey)
-	assert.Equal(t, 2, len(result.Posts))
-	assert.Equal(t, "1", result.Posts[0].ID)
-	assert.Equal(t, "Content 1", result.Posts[0].Text)
-	assert.Equal(t, "http://example.com/1", result.Posts[0].SourceURL)
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/search", server.searchPostsCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/search?semantic=chip+export+controls&limit=5", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response ScoredPostsResultsJSON
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1, len(response.Posts))
+		assert.Equal(t, "1", response.Posts[0].ID)
+		assert.Equal(t, 0.9, response.Posts[0].Score)
+
+		mockAssistant.AssertExpectations(t)
+		mockBlogger.AssertExpectations(t)
+	})
+
+	t.Run("MissingQuery", func(t *testing.T) {
+		// Setup
+		server := Server{
+			Blogger:   new(MockBlogger),
+			Assistant: new(MockAssistant),
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/search", server.searchPostsCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/search", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
 }
 
-func TestNotFound(t *testing.T) {
-	// Setup
-	server := Server{
-		Version: "test",
-	}
+func TestGetSimilarPostsCtrl(t *testing.T) {
+	t.Run("NotFound", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("GetSimilarPosts", "missing", 10).Return([]*store.ScoredPost(nil), store.ErrNotFound)
 
-	// Create request
-	r := server.routes()
-	req := httptest.NewRequest("GET", "/non-existent", nil)
-	rec := httptest.NewRecorder()
+		server := Server{
+			Blogger: mockBlogger,
+			Version: "test",
+		}
 
-	// Execute
-	r.ServeHTTP(rec, req)
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/posts/similar", server.getSimilarPostsCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/posts/similar?id=missing", nil)
+		rec := httptest.NewRecorder()
 
-	// Verify
-	assert.Equal(t, http.StatusNotFound, rec.Code)
+		// Execute
+		r.ServeHTTP(rec, req)
 
-	var response map[string]string
-	err := json.Unmarshal(rec.Body.Bytes(), &response)
-	assert.NoError(t, err)
+		// Verify
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		mockBlogger.AssertExpectations(t)
+	})
 
-	assert.Contains(t, response, "error")
-	assert.Equal(t, "not found", response["error"])
+	t.Run("InvalidLimit", func(t *testing.T) {
+		// Setup
+		server := Server{
+			Blogger: new(MockBlogger),
+			Version: "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/posts/similar", server.getSimilarPostsCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/posts/similar?id=1&limit=500", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestAskCtrl(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockAssistant := new(MockAssistant)
+		oldPost := &store.PostV1{ID: "1", Title: "Go 1.24", CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+		newPost := &store.PostV1{ID: "2", Title: "Go 1.25", SourceURL: "http://example.com/2", CreatedAt: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)}
+		mockAssistant.On("EmbedText", "what about Go?").Return([]float64{0.1}, nil)
+		mockAssistant.On("EmbeddingModel").Return("embed-model")
+		mockBlogger.On("SearchPosts", "embed-model", []float64{0.1}, maxSearchLimit).Return([]*store.ScoredPost{
+			{Post: oldPost, Score: 0.9},
+			{Post: newPost, Score: 0.8},
+		}, nil)
+		mockAssistant.On("AnswerQuestion", "what about Go?", []*store.PostV1{newPost}).
+			Return([]string{"Go 1.25 ", "shipped\nin August [1]"}, nil)
+
+		server := Server{
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/ask", server.askCtrl)
+		body := `{"question": "what about Go?", "since": "2025-07-01T00:00:00Z"}`
+		req := httptest.NewRequest("POST", "/api/v1/ask", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+		expected := "event: citations\n" +
+			`data: [{"index":1,"id":"2","title":"Go 1.25","sourceUrl":"http://example.com/2","score":0.8}]` + "\n\n" +
+			"event: token\ndata: Go 1.25 \n\n" +
+			"event: token\ndata: shipped\ndata: in August [1]\n\n" +
+			"event: done\ndata: \n\n"
+		assert.Equal(t, expected, rec.Body.String())
+
+		mockAssistant.AssertExpectations(t)
+		mockBlogger.AssertExpectations(t)
+	})
+
+	t.Run("NoSources", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("EmbedText", "anything?").Return([]float64{0.1}, nil)
+		mockAssistant.On("EmbeddingModel").Return("embed-model")
+		mockBlogger.On("SearchPosts", "embed-model", []float64{0.1}, maxSearchLimit).Return([]*store.ScoredPost{}, nil)
+
+		server := Server{
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/ask", server.askCtrl)
+		req := httptest.NewRequest("POST", "/api/v1/ask", strings.NewReader(`{"question": "anything?"}`))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		mockAssistant.AssertNotCalled(t, "AnswerQuestion", mock.Anything, mock.Anything)
+	})
+
+	t.Run("MissingQuestion", func(t *testing.T) {
+		// Setup
+		server := Server{
+			Blogger:   new(MockBlogger),
+			Assistant: new(MockAssistant),
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/ask", server.askCtrl)
+		req := httptest.NewRequest("POST", "/api/v1/ask", strings.NewReader(`{"question": "  "}`))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("BodyTooLarge", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		server := Server{
+			Blogger:   new(MockBlogger),
+			Assistant: mockAssistant,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/ask", server.askCtrl)
+		body := `{"question": "` + strings.Repeat("a", maxRequestBodySize) + `"}`
+		req := httptest.NewRequest("POST", "/api/v1/ask", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Contains(t, rec.Body.String(), "request body is too large")
+		mockAssistant.AssertNotCalled(t, "EmbedText", mock.Anything)
+	})
+}
+
+func TestSummarizeCtrl(t *testing.T) {
+	t.Run("URL", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockExtractor := new(MockExtractor)
+		mockExtractor.On("Extract", "http://example.com/post").Return(&extractor.Article{
+			URL:   "http://example.com/post",
+			Title: "Post",
+			Text:  "Long article text",
+		}, nil)
+		mockAssistant.On("SummarizeTextStream", "Long article text").Return([]string{"Short ", "summary"}, nil)
+
+		server := Server{
+			Assistant: mockAssistant,
+			Extractor: mockExtractor,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/summarize", server.summarizeCtrl)
+		req := httptest.NewRequest("POST", "/api/v1/summarize", strings.NewReader(`{"url": "http://example.com/post"}`))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		expected := "event: article\n" +
+			`data: {"title":"Post","sourceUrl":"http://example.com/post"}` + "\n\n" +
+			"event: token\ndata: Short \n\n" +
+			"event: token\ndata: summary\n\n" +
+			"event: done\ndata: \n\n"
+		assert.Equal(t, expected, rec.Body.String())
+
+		mockAssistant.AssertExpectations(t)
+		mockExtractor.AssertExpectations(t)
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("SummarizeTextStream", "Raw text").Return([]string{"Summary"}, errors.New("ollama error"))
+
+		server := Server{
+			Assistant: mockAssistant,
+			Extractor: new(MockExtractor),
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/summarize", server.summarizeCtrl)
+		req := httptest.NewRequest("POST", "/api/v1/summarize", strings.NewReader(`{"text": "Raw text"}`))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "event: token\ndata: Summary\n\nevent: error\ndata: ollama error\n\n", rec.Body.String())
+		mockAssistant.AssertExpectations(t)
+	})
+
+	t.Run("BothURLAndText", func(t *testing.T) {
+		// Setup
+		server := Server{
+			Assistant: new(MockAssistant),
+			Extractor: new(MockExtractor),
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/summarize", server.summarizeCtrl)
+		req := httptest.NewRequest("POST", "/api/v1/summarize", strings.NewReader(`{"url": "http://example.com", "text": "Raw text"}`))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("BodyTooLarge", func(t *testing.T) {
+		// Setup
+		mockAssistant := new(MockAssistant)
+		server := Server{
+			Assistant: mockAssistant,
+			Extractor: new(MockExtractor),
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/summarize", server.summarizeCtrl)
+		body := `{"text": "` + strings.Repeat("a", maxRequestBodySize) + `"}`
+		req := httptest.NewRequest("POST", "/api/v1/summarize", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		mockAssistant.AssertNotCalled(t, "SummarizeTextStream", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSubmitLinkCtrl(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockSubmitter := new(MockSubmitter)
+		mockSubmitter.On("SubmitLink", "http://example.com/post").Return(nil)
+
+		server := Server{
+			Submitter: mockSubmitter,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/submissions", server.submitLinkCtrl)
+		req := httptest.NewRequest("POST", "/api/v1/submissions", strings.NewReader(`{"url": " http://example.com/post "}`))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+
+		var response SubmissionJSON
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://example.com/post", response.URL)
+		assert.Equal(t, "queued", response.Status)
+
+		mockSubmitter.AssertExpectations(t)
+	})
+
+	t.Run("InvalidURL", func(t *testing.T) {
+		// Setup
+		mockSubmitter := new(MockSubmitter)
+		server := Server{
+			Submitter: mockSubmitter,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/submissions", server.submitLinkCtrl)
+		req := httptest.NewRequest("POST", "/api/v1/submissions", strings.NewReader(`{"url": "ftp://example.com"}`))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		mockSubmitter.AssertNotCalled(t, "SubmitLink", mock.Anything)
+	})
+
+	t.Run("QueueFull", func(t *testing.T) {
+		// Setup
+		mockSubmitter := new(MockSubmitter)
+		mockSubmitter.On("SubmitLink", "http://example.com/post").Return(errors.New("submission queue is full"))
+
+		server := Server{
+			Submitter: mockSubmitter,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/submissions", server.submitLinkCtrl)
+		req := httptest.NewRequest("POST", "/api/v1/submissions", strings.NewReader(`{"url": "http://example.com/post"}`))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		mockSubmitter.AssertExpectations(t)
+	})
+
+	t.Run("BodyTooLarge", func(t *testing.T) {
+		// Setup
+		mockSubmitter := new(MockSubmitter)
+		server := Server{
+			Submitter: mockSubmitter,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/submissions", server.submitLinkCtrl)
+		body := `{"url": "http://example.com/` + strings.Repeat("a", maxRequestBodySize) + `"}`
+		req := httptest.NewRequest("POST", "/api/v1/submissions", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		mockSubmitter.AssertNotCalled(t, "SubmitLink", mock.Anything)
+	})
+
+	t.Run("Htmx", func(t *testing.T) {
+		// Setup
+		templateCache, err := NewTemplateCache()
+		assert.NoError(t, err)
+
+		mockSubmitter := new(MockSubmitter)
+		mockSubmitter.On("SubmitLink", "http://example.com/post").Return(nil)
+
+		server := Server{
+			Submitter:     mockSubmitter,
+			Version:       "test",
+			templateCache: templateCache,
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/submissions", server.submitLinkHtmxCtrl)
+		req := httptest.NewRequest("POST", "/api/v1/submissions", strings.NewReader("url=http%3A%2F%2Fexample.com%2Fpost"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "submission-success")
+		mockSubmitter.AssertExpectations(t)
+	})
+}
+
+func TestTriggerFetchCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		query        string
+		feedID       string
+		err          error
+		expectedCode int
+	}{
+		{"AllFeeds", "", "", nil, http.StatusAccepted},
+		{"SingleFeed", "?feed=http%3A%2F%2Fexample.com%2Ffeed", "http://example.com/feed", nil, http.StatusAccepted},
+		{"UnknownFeed", "?feed=unknown", "unknown", worker.ErrFeedNotFound, http.StatusNotFound},
+		{"InProgress", "", "", worker.ErrFetchInProgress, http.StatusConflict},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockFetcher := new(MockFetcher)
+			mockFetcher.On("TriggerFetch", tt.feedID).Return(tt.err)
+
+			server := Server{
+				Fetcher: mockFetcher,
+				Version: "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Post("/api/v1/admin/fetch", server.triggerFetchCtrl)
+			req := httptest.NewRequest("POST", "/api/v1/admin/fetch"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPreviewFiltersCtrl(t *testing.T) {
+	rules := []worker.FilterRule{{Keywords: []string{"go"}, Action: worker.ActionTag, Tags: []string{"golang"}}}
+	previews := []*worker.FilterPreview{
+		{GUID: "1", Title: "Go 1.24", Link: "http://example.com/1", Matched: []string{"rule 1"}, Tags: []string{"golang"}},
+		{GUID: "2", Title: "Rust", Link: "http://example.com/2"},
+	}
+
+	tbl := []struct {
+		name         string
+		body         string
+		rules        []worker.FilterRule
+		previews     []*worker.FilterPreview
+		err          error
+		expectedCode int
+	}{
+		{"ConfiguredRules", "", nil, previews, nil, http.StatusOK},
+		{"GivenRules", `{"rules":[{"keywords":["go"],"action":"tag","tags":["golang"]}]}`, rules, previews, nil, http.StatusOK},
+		{"InvalidBody", "{", nil, nil, nil, http.StatusBadRequest},
+		{"BodyTooLarge", `{"rules":[{"keywords":["` + strings.Repeat("a", maxRequestBodySize) + `"]}]}`, nil, nil, nil, http.StatusRequestEntityTooLarge},
+		{"InvalidFilter", `{"rules":[{"keywords":["go"],"action":"tag","tags":["golang"]}]}`, rules, nil, worker.ErrInvalidFilter, http.StatusBadRequest},
+		{"UnknownFeed", "", nil, nil, worker.ErrFeedNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockFetcher := new(MockFetcher)
+			if tt.previews != nil || tt.err != nil {
+				mockFetcher.On("PreviewFilters", "hash-1", tt.rules).Return(tt.previews, tt.err)
+			}
+
+			server := Server{
+				Fetcher: mockFetcher,
+				Version: "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Post("/api/v1/admin/feeds/{id}/filters/preview", server.previewFiltersCtrl)
+			req := httptest.NewRequest("POST", "/api/v1/admin/feeds/hash-1/filters/preview", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode == http.StatusOK {
+				var response FilterPreviewsJSON
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Len(t, response.Items, 2)
+				assert.Equal(t, []string{"rule 1"}, response.Items[0].Matched)
+				assert.Equal(t, []string{"golang"}, response.Items[0].Tags)
+				assert.Empty(t, response.Items[1].Matched)
+			}
+			mockFetcher.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetFeedHealthCtrl(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		lastErrorAt := time.Now().UTC()
+		mockBlogger.On("GetFeedStatus", "hash-1").Return(&store.FeedStatus{
+			Feed: &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed", Disabled: true},
+			Health: &store.FeedHealthV1{
+				FeedID:              "hash-1",
+				LastErrorAt:         &lastErrorAt,
+				LastError:           "404 Not Found",
+				ConsecutiveFailures: 10,
+				LastStatusCode:      http.StatusNotFound,
+			},
+		}, nil)
+
+		server := Server{
+			Blogger: mockBlogger,
+			Version: "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/feeds/{id}/health", server.getFeedHealthCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/feeds/hash-1/health", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response FeedJSON
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "hash-1", response.ID)
+		assert.True(t, response.Disabled)
+		assert.Equal(t, 10, response.Health.ConsecutiveFailures)
+		assert.Equal(t, http.StatusNotFound, response.Health.LastStatusCode)
+		assert.Equal(t, "404 Not Found", response.Health.LastError)
+		mockBlogger.AssertExpectations(t)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("GetFeedStatus", "unknown").Return((*store.FeedStatus)(nil), fmt.Errorf("failed to get feed: %w", store.ErrNotFound))
+
+		server := Server{
+			Blogger: mockBlogger,
+			Version: "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/feeds/{id}/health", server.getFeedHealthCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/feeds/unknown/health", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		mockBlogger.AssertExpectations(t)
+	})
+}
+
+func TestEnableFeedCtrl(t *testing.T) {
+	// Setup
+	mockBlogger := new(MockBlogger)
+	mockBlogger.On("EnableFeed", "hash-1").Return(&store.FeedStatus{
+		Feed:   &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed"},
+		Health: &store.FeedHealthV1{FeedID: "hash-1"},
+	}, nil)
+
+	server := Server{
+		Blogger: mockBlogger,
+		Version: "test",
+	}
+
+	// Create request
+	r := chi.NewRouter()
+	r.Post("/api/v1/admin/feeds/{id}/enable", server.enableFeedCtrl)
+	req := httptest.NewRequest("POST", "/api/v1/admin/feeds/hash-1/enable", nil)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response FeedJSON
+	err := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.False(t, response.Disabled)
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestGetDeadJobsCtrl(t *testing.T) {
+	// Setup
+	mockBlogger := new(MockBlogger)
+	mockBlogger.On("GetDeadJobs").Return([]*store.JobV1{{
+		ID:        7,
+		Kind:      store.JobKindSummarizePost,
+		Status:    store.JobStatusDead,
+		Post:      store.PostV1{ID: "1", SourceURL: "http://example.com/1", Title: "Post 1"},
+		Attempts:  5,
+		LastError: "assistant is down",
+	}}, nil)
+
+	server := Server{
+		Blogger: mockBlogger,
+		Version: "test",
+	}
+
+	// Create request
+	r := chi.NewRouter()
+	r.Get("/api/v1/admin/jobs/dead", server.getDeadJobsCtrl)
+	req := httptest.NewRequest("GET", "/api/v1/admin/jobs/dead", nil)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response JobsResultsJSON
+	err := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(response.Jobs))
+	assert.Equal(t, uint64(7), response.Jobs[0].ID)
+	assert.Equal(t, "http://example.com/1", response.Jobs[0].URL)
+	assert.Equal(t, 5, response.Jobs[0].Attempts)
+	assert.Equal(t, "assistant is down", response.Jobs[0].LastError)
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestGetRetentionReportCtrl(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockPruner := new(MockPruner)
+		createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		mockPruner.On("DryRun").Return(&retention.Report{
+			DryRun: true,
+			Posts: []*retention.Candidate{{
+				PostID:       "1",
+				Title:        "Post 1",
+				SourceURL:    "http://example.com/1",
+				PartitionKey: "feed",
+				Feed:         "http://example.com/feed",
+				Reason:       retention.ReasonAge,
+				CreatedAt:    createdAt,
+			}},
+		}, nil)
+
+		server := Server{
+			Pruner:  mockPruner,
+			Version: "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/admin/retention", server.getRetentionReportCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/admin/retention", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response RetentionReportJSON
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.True(t, response.DryRun)
+		assert.Equal(t, 1, response.Total)
+		assert.Equal(t, "http://example.com/1", response.Posts[0].URL)
+		assert.Equal(t, "http://example.com/feed", response.Posts[0].Feed)
+		assert.Equal(t, retention.ReasonAge, response.Posts[0].Reason)
+		assert.Equal(t, createdAt, response.Posts[0].CreatedAt)
+		mockPruner.AssertExpectations(t)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		// Setup
+		mockPruner := new(MockPruner)
+		mockPruner.On("DryRun").Return((*retention.Report)(nil), errors.New("database is locked"))
+
+		server := Server{
+			Pruner:  mockPruner,
+			Version: "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/admin/retention", server.getRetentionReportCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/admin/retention", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestGetBackupsCtrl(t *testing.T) {
+	// Setup
+	mockBackuper := new(MockBackuper)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockBackuper.On("Archives").Return([]backup.Archive{
+		{Name: "rss-sum-20240102T030405Z.tar.gz", Path: "data/backups/rss-sum-20240102T030405Z.tar.gz", Size: 1024, CreatedAt: createdAt},
+	}, nil)
+
+	server := Server{
+		Backuper: mockBackuper,
+		Version:  "test",
+	}
+
+	// Create request
+	r := chi.NewRouter()
+	r.Get("/api/v1/admin/backups", server.getBackupsCtrl)
+	req := httptest.NewRequest("GET", "/api/v1/admin/backups", nil)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response BackupsResultsJSON
+	err := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []BackupJSON{{Name: "rss-sum-20240102T030405Z.tar.gz", Size: 1024, CreatedAt: createdAt}}, response.Backups)
+	mockBackuper.AssertExpectations(t)
+}
+
+func TestCreateBackupCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		archive      *backup.Archive
+		err          error
+		expectedCode int
+	}{
+		{"Success", &backup.Archive{Name: "rss-sum-20240102T030405Z.tar.gz", Size: 1024}, nil, http.StatusCreated},
+		{"Unsupported", nil, fmt.Errorf("%w, use pg_dump for postgres", store.ErrSnapshotUnsupported), http.StatusNotImplemented},
+		{"Error", nil, errors.New("disk is full"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockBackuper := new(MockBackuper)
+			mockBackuper.On("Create").Return(tt.archive, tt.err)
+
+			server := Server{
+				Backuper: mockBackuper,
+				Version:  "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Post("/api/v1/admin/backups", server.createBackupCtrl)
+			req := httptest.NewRequest("POST", "/api/v1/admin/backups", nil)
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.archive != nil {
+				var response BackupJSON
+				err := json.Unmarshal(rec.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.archive.Name, response.Name)
+			}
+		})
+	}
+}
+
+func TestExportPostsCtrl(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockExporter := new(MockExporter)
+		since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		mockExporter.On("Export", export.FormatCSV, export.Filter{PartitionKey: "feed", Since: since}).
+			Return("id,partitionKey\n1,feed\n", 1, nil)
+
+		server := Server{
+			Exporter: mockExporter,
+			Version:  "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/export", server.exportPostsCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/export?format=csv&partitionKey=feed&since=2024-01-02T03:04:05Z", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=rss-sum-posts.csv", rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, "id,partitionKey\n1,feed\n", rec.Body.String())
+		mockExporter.AssertExpectations(t)
+	})
+
+	tbl := []struct {
+		name  string
+		query string
+	}{
+		{"InvalidFormat", "format=xml"},
+		{"InvalidSince", "since=yesterday"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockExporter := new(MockExporter)
+
+			server := Server{
+				Exporter: mockExporter,
+				Version:  "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Get("/api/v1/export", server.exportPostsCtrl)
+			req := httptest.NewRequest("GET", "/api/v1/export?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			mockExporter.AssertNotCalled(t, "Export", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRequeueJobCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		id           string
+		job          *store.JobV1
+		err          error
+		expectedCode int
+	}{
+		{"Success", "7", &store.JobV1{ID: 7, Status: store.JobStatusPending}, nil, http.StatusOK},
+		{"NotFound", "8", (*store.JobV1)(nil), fmt.Errorf("failed to get job: %w", store.ErrNotFound), http.StatusNotFound},
+		{"NotDead", "9", (*store.JobV1)(nil), blogger.ErrJobNotDead, http.StatusConflict},
+		{"InvalidID", "abc", nil, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockBlogger := new(MockBlogger)
+			if id, err := strconv.ParseUint(tt.id, 10, 64); err == nil {
+				mockBlogger.On("RequeueJob", id).Return(tt.job, tt.err)
+			}
+
+			server := Server{
+				Blogger: mockBlogger,
+				Version: "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Post("/api/v1/admin/jobs/{id}/requeue", server.requeueJobCtrl)
+			req := httptest.NewRequest("POST", "/api/v1/admin/jobs/"+tt.id+"/requeue", nil)
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			mockBlogger.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMapToJSON(t *testing.T) {
+	// Setup
+	input := &store.PaginationPostsResult{
+		Posts: []*store.PostV1{
+			{ID: "1", Text: "Content 1", SourceURL: "http://example.com/1"},
+			{ID: "2", Text: "Content 2", SourceURL: "http://example.com/2"},
+		},
+		Page:         1,
+		PageSize:     10,
+		PartitionKey: "test-key",
+		Size:         2,
+	}
+
+	// Execute
+	result := mapToJSON(input)
+
+	// Verify
+	assert.Equal(t, 1, result.Page)
+	assert.Equal(t, 10, result.PageSize)
+	assert.Equal(t, "test-key", result.PartitionKey)
+	assert.Equal(t, 2, len(result.Posts))
+	assert.Equal(t, "1", result.Posts[0].ID)
+	assert.Equal(t, "Content 1", result.Posts[0].Text)
+	assert.Equal(t, "http://example.com/1", result.Posts[0].SourceURL)
+}
+
+func TestNotFound(t *testing.T) {
+	// Setup
+	server := Server{
+		Version: "test",
+	}
+
+	// Create request
+	r := server.routes()
+	req := httptest.NewRequest("GET", "/non-existent", nil)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	var response map[string]string
+	err := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Contains(t, response, "error")
+	assert.Equal(t, "not found", response["error"])
+}
+
+// deadlineAssistant records whether the context of the last call had a deadline
+type deadlineAssistant struct {
+	*MockAssistant
+	deadline bool
+}
+
+func (a *deadlineAssistant) EmbedText(ctx context.Context, text string) ([]float64, error) {
+	_, a.deadline = ctx.Deadline()
+	return nil, errors.New("embedding failed")
+}
+
+func (a *deadlineAssistant) SummarizeTextStream(ctx context.Context, text string, fn func(token string) error) error {
+	_, a.deadline = ctx.Deadline()
+	return errors.New("summary failed")
+}
+
+// deadlineExporter records whether the context of the export had a deadline
+type deadlineExporter struct {
+	deadline bool
+}
+
+func (e *deadlineExporter) Export(ctx context.Context, w io.Writer, format string, filter export.Filter) (int, error) {
+	_, e.deadline = ctx.Deadline()
+	return 0, nil
+}
+
+func TestRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		code     int
+		deadline bool
+	}{
+		{"Search", "GET", "/api/v1/search?semantic=go", "", http.StatusInternalServerError, true},
+		{"Ask", "POST", "/api/v1/ask", `{"question": "go?"}`, http.StatusInternalServerError, false},
+		// the error of a stream is sent as an event, after the status
+		{"Summarize", "POST", "/api/v1/summarize", `{"text": "go"}`, http.StatusOK, false},
+		{"Export", "GET", "/api/v1/export", "", http.StatusOK, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			assistant := &deadlineAssistant{MockAssistant: new(MockAssistant)}
+			exporter := &deadlineExporter{}
+			server := Server{
+				Blogger:   new(MockBlogger),
+				Assistant: assistant,
+				Exporter:  exporter,
+				Version:   "test",
+			}
+
+			// Create request
+			r := server.routes()
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tc.code, rec.Code)
+			assert.Equal(t, tc.deadline, assistant.deadline || exporter.deadline)
+		})
+	}
+}
+
+func TestLoggerMetrics(t *testing.T) {
+	// Setup
+	router := chi.NewRouter()
+	router.Use(Logger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	router.Get("/api/v1/feeds/{id}/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	counter := metrics.HTTPRequestsTotal.WithLabelValues("GET", "/api/v1/feeds/{id}/health", "418")
+	before := testutil.ToFloat64(counter)
+
+	// Execute
+	for _, id := range []string{"a", "b"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/feeds/"+id+"/health", nil))
+	}
+
+	// Verify
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+}
+
+func TestLoggerRequestID(t *testing.T) {
+	// Setup
+	var buf bytes.Buffer
+	logger := logging.New(&buf, &logging.Settings{Level: slog.LevelInfo, Format: logging.FormatJSON})
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(Logger(logger))
+	router.Get("/api/v1/posts", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/posts", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+
+	// Execute
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Verify
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "GET", record["method"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+}
+
+func TestTracer(t *testing.T) {
+	// Setup
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	router := chi.NewRouter()
+	router.Use(Tracer)
+	router.Get("/api/v1/feeds/{id}/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/feeds/a/health", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// Execute
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Verify
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /api/v1/feeds/{id}/health", spans[0].Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("http.response.status_code", http.StatusInternalServerError))
+}
+
+func TestHealthzCtrl(t *testing.T) {
+	// Setup
+	server := Server{Version: "test"}
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	server.healthzCtrl(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestReadyzCtrl(t *testing.T) {
+	templateCache, err := NewTemplateCache()
+	assert.NoError(t, err)
+
+	t.Run("Ready", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("Ping").Return(nil)
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("Ping").Return(nil)
+		mockFetcher := new(MockFetcher)
+		mockFetcher.On("CheckLastRun").Return(nil)
+
+		server := Server{
+			Blogger:       mockBlogger,
+			Assistant:     mockAssistant,
+			Scheduler:     mockFetcher,
+			templateCache: templateCache,
+		}
+		req := httptest.NewRequest("GET", "/readyz", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		server.readyzCtrl(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var result ReadinessJSON
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, "ready", result.Status)
+		assert.Equal(t, map[string]string{"database": "ok", "ollama": "ok", "templates": "ok", "worker": "ok"}, result.Checks)
+	})
+
+	t.Run("NotReady", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("Ping").Return(nil)
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("Ping").Return(errors.New("connection refused"))
+		mockFetcher := new(MockFetcher)
+		mockFetcher.On("CheckLastRun").Return(errors.New("last run finished 2h0m0s ago"))
+
+		server := Server{
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Scheduler: mockFetcher,
+		}
+		req := httptest.NewRequest("GET", "/readyz", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		server.readyzCtrl(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		var result ReadinessJSON
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, "not ready", result.Status)
+		assert.Equal(t, "ok", result.Checks["database"])
+		assert.Equal(t, "connection refused", result.Checks["ollama"])
+		assert.Equal(t, "templates are not loaded", result.Checks["templates"])
+		assert.Equal(t, "last run finished 2h0m0s ago", result.Checks["worker"])
+	})
+
+	t.Run("WorkerInAnotherProcess", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("Ping").Return(nil)
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("Ping").Return(nil)
+
+		server := Server{
+			Blogger:       mockBlogger,
+			Assistant:     mockAssistant,
+			templateCache: templateCache,
+		}
+		req := httptest.NewRequest("GET", "/readyz", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		server.readyzCtrl(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var result ReadinessJSON
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, map[string]string{"database": "ok", "ollama": "ok", "templates": "ok"}, result.Checks)
+	})
+}
+
+func TestVersionCtrl(t *testing.T) {
+	// Setup
+	mockAssistant := new(MockAssistant)
+	mockAssistant.On("Model").Return("llama3:8b")
+	mockAssistant.On("EmbeddingModel").Return("nomic-embed-text")
+
+	server := Server{
+		Assistant: mockAssistant,
+		Version:   "abc123",
+	}
+	req := httptest.NewRequest("GET", "/api/v1/version", nil)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	server.versionCtrl(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var result VersionJSON
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, VersionJSON{
+		Revision:       "abc123",
+		GoVersion:      runtime.Version(),
+		Model:          "llama3:8b",
+		EmbeddingModel: "nomic-embed-text",
+	}, result)
+}
+
+func TestAuthentication(t *testing.T) {
+	tbl := []struct {
+		name         string
+		path         string
+		header       map[string]string
+		cookie       string
+		expectedCode int
+		location     string
+	}{
+		{"AnonymousPage", "/status?feed=1", nil, "", http.StatusSeeOther, "/login?next=%2Fstatus%3Ffeed%3D1"},
+		{"AnonymousAPI", "/api/v1/version", nil, "", http.StatusUnauthorized, ""},
+		{"AnonymousHtmx", "/api/v1/version", map[string]string{"HX-Request": "true"}, "", http.StatusUnauthorized, ""},
+		{"MalformedHeader", "/api/v1/version", map[string]string{"Authorization": "Basic Ym9iOnNlY3JldA=="}, "", http.StatusUnauthorized, ""},
+		{"UnknownToken", "/api/v1/version", map[string]string{"Authorization": "Bearer rss_unknown"}, "", http.StatusUnauthorized, ""},
+		{"ReaderToken", "/api/v1/version", map[string]string{"Authorization": "Bearer rss_reader"}, "", http.StatusOK, ""},
+		{"ReaderForbidden", "/api/v1/admin/jobs/dead", map[string]string{"Authorization": "Bearer rss_reader"}, "", http.StatusForbidden, ""},
+		{"AdminSession", "/api/v1/admin/jobs/dead", nil, "admin", http.StatusOK, ""},
+		{"ExpiredSession", "/", nil, "expired", http.StatusSeeOther, "/login?next=%2F"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockAuthenticator := new(MockAuthenticator)
+			mockAuthenticator.On("TokenUser", "rss_reader").Return(testReader, nil)
+			mockAuthenticator.On("TokenUser", "rss_unknown").Return((*store.UserV1)(nil), fmt.Errorf("%w: unknown token", auth.ErrUnauthenticated))
+			mockAuthenticator.On("SessionUser", "admin").Return(testAdmin, nil)
+			mockAuthenticator.On("SessionUser", "expired").Return((*store.UserV1)(nil), fmt.Errorf("%w: session expired", auth.ErrUnauthenticated))
+
+			mockAssistant := new(MockAssistant)
+			mockAssistant.On("Model").Return("llama3:8b")
+			mockAssistant.On("EmbeddingModel").Return("nomic-embed-text")
+			mockBlogger := new(MockBlogger)
+			mockBlogger.On("GetDeadJobs").Return([]*store.JobV1{}, nil)
+
+			server := Server{
+				Blogger:       mockBlogger,
+				Assistant:     mockAssistant,
+				Authenticator: mockAuthenticator,
+				Version:       "test",
+			}
+
+			// Create request
+			r := server.routes()
+			req := httptest.NewRequest("GET", tt.path, nil)
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.location, rec.Header().Get("Location"))
+			if tt.expectedCode == http.StatusUnauthorized {
+				assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+			}
+			if tt.header["HX-Request"] == "true" {
+				assert.Equal(t, "/login", rec.Header().Get("HX-Redirect"))
+			}
+			if tt.cookie == "expired" {
+				assert.Contains(t, rec.Header().Get("Set-Cookie"), sessionCookie+"=;")
+			}
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("GetDeadJobs").Return([]*store.JobV1{}, nil)
+
+		server := Server{
+			Blogger: mockBlogger,
+			Version: "test",
+		}
+
+		// Create request
+		r := server.routes()
+		req := httptest.NewRequest("GET", "/api/v1/admin/jobs/dead", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+		req = httptest.NewRequest("GET", "/login", nil)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestLoginCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		password     string
+		next         string
+		err          error
+		expectedCode int
+		location     string
+	}{
+		{"Success", "secret password", "/status", nil, http.StatusSeeOther, "/status"},
+		{"OpenRedirect", "secret password", "//example.com", nil, http.StatusSeeOther, "/"},
+		{"InvalidCredentials", "wrong password", "/", auth.ErrInvalidCredentials, http.StatusUnauthorized, ""},
+		{"Error", "secret password", "/", errors.New("database is locked"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			templateCache, err := NewTemplateCache()
+			assert.NoError(t, err)
+
+			session := &auth.Session{Token: "token", User: testReader, ExpiresAt: time.Now().Add(time.Hour)}
+			if tt.err != nil {
+				session = nil
+			}
+			mockAuthenticator := new(MockAuthenticator)
+			mockAuthenticator.On("Login", "bob", tt.password).Return(session, tt.err)
+
+			server := Server{
+				Authenticator: mockAuthenticator,
+				Version:       "test",
+				templateCache: templateCache,
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Post("/login", server.loginCtrl)
+			form := "name=bob&password=" + strings.ReplaceAll(tt.password, " ", "+") + "&next=" + tt.next
+			req := httptest.NewRequest("POST", "/login", strings.NewReader(form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.location, rec.Header().Get("Location"))
+			cookies := rec.Result().Cookies()
+			if tt.err == nil {
+				assert.Len(t, cookies, 1)
+				assert.Equal(t, "token", cookies[0].Value)
+				assert.True(t, cookies[0].HttpOnly)
+				assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+			} else {
+				assert.Empty(t, cookies)
+				assert.Contains(t, rec.Body.String(), "login-error")
+			}
+			mockAuthenticator.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLogoutCtrl(t *testing.T) {
+	// Setup
+	mockAuthenticator := new(MockAuthenticator)
+	mockAuthenticator.On("Logout", "token").Return(nil)
+
+	server := Server{
+		Authenticator: mockAuthenticator,
+		Version:       "test",
+	}
+
+	// Create request
+	r := chi.NewRouter()
+	r.Post("/logout", server.logoutCtrl)
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "token"})
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/login", rec.Header().Get("Location"))
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Negative(t, cookies[0].MaxAge)
+	mockAuthenticator.AssertExpectations(t)
+}
+
+func TestCreateTokenCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		body         string
+		err          error
+		expectedCode int
+	}{
+		{"Success", `{"name":"cli"}`, nil, http.StatusCreated},
+		{"InvalidName", `{"name":""}`, fmt.Errorf("%w: token name should have 1 to 100 characters", auth.ErrInvalidUser), http.StatusBadRequest},
+		{"InvalidBody", `{`, nil, http.StatusBadRequest},
+		{"BodyTooLarge", `{"name":"` + strings.Repeat("a", maxRequestBodySize) + `"}`, nil, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			var req CreateTokenRequestJSON
+			_ = json.Unmarshal([]byte(tt.body), &req)
+			token := &store.APITokenV1{ID: 3, UserID: testReader.ID, Name: req.Name}
+			mockAuthenticator := new(MockAuthenticator)
+			mockAuthenticator.On("CreateToken", testReader.ID, req.Name).Return("rss_secret", token, tt.err)
+
+			server := Server{
+				Authenticator: mockAuthenticator,
+				Version:       "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Post("/api/v1/tokens", server.createTokenCtrl)
+			httpReq := withUser(httptest.NewRequest("POST", "/api/v1/tokens", strings.NewReader(tt.body)), testReader)
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, httpReq)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode == http.StatusCreated {
+				var response TokenJSON
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, TokenJSON{ID: 3, Name: "cli", Token: "rss_secret"}, response)
+			}
+		})
+	}
+}
+
+func TestRevokeTokenCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		id           string
+		err          error
+		expectedCode int
+	}{
+		{"Success", "3", nil, http.StatusNoContent},
+		{"OtherUser", "4", fmt.Errorf("token 4: %w", store.ErrNotFound), http.StatusNotFound},
+		{"InvalidID", "abc", nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockAuthenticator := new(MockAuthenticator)
+			if id, err := strconv.ParseUint(tt.id, 10, 64); err == nil {
+				mockAuthenticator.On("RevokeToken", testReader.ID, id).Return(tt.err)
+			}
+
+			server := Server{
+				Authenticator: mockAuthenticator,
+				Version:       "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Delete("/api/v1/tokens/{id}", server.revokeTokenCtrl)
+			req := withUser(httptest.NewRequest("DELETE", "/api/v1/tokens/"+tt.id, nil), testReader)
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			mockAuthenticator.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAddUserCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		body         string
+		role         string
+		err          error
+		expectedCode int
+	}{
+		{"DefaultRole", `{"name":"carol","password":"secret password"}`, store.RoleReader, nil, http.StatusCreated},
+		{"Admin", `{"name":"carol","password":"secret password","role":"admin"}`, store.RoleAdmin, nil, http.StatusCreated},
+		{"Invalid", `{"name":"carol","password":"secret password","role":"owner"}`, "owner", auth.ErrInvalidUser, http.StatusBadRequest},
+		{"Taken", `{"name":"carol","password":"secret password"}`, store.RoleReader, store.ErrAlreadyExists, http.StatusConflict},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			var user *store.UserV1
+			if tt.err == nil {
+				user = &store.UserV1{ID: 3, Name: "carol", Role: tt.role}
+			}
+			mockAuthenticator := new(MockAuthenticator)
+			mockAuthenticator.On("AddUser", "carol", "secret password", tt.role).Return(user, tt.err)
+
+			server := Server{
+				Authenticator: mockAuthenticator,
+				Version:       "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Post("/api/v1/admin/users", server.addUserCtrl)
+			req := httptest.NewRequest("POST", "/api/v1/admin/users", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if user != nil {
+				var response UserJSON
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, tt.role, response.Role)
+				assert.NotContains(t, rec.Body.String(), "password")
+			}
+			mockAuthenticator.AssertExpectations(t)
+		})
+	}
+
+	t.Run("BodyTooLarge", func(t *testing.T) {
+		// Setup
+		mockAuthenticator := new(MockAuthenticator)
+		server := Server{
+			Authenticator: mockAuthenticator,
+			Version:       "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Post("/api/v1/admin/users", server.addUserCtrl)
+		body := `{"name":"carol","password":"` + strings.Repeat("a", maxRequestBodySize) + `"}`
+		req := httptest.NewRequest("POST", "/api/v1/admin/users", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		mockAuthenticator.AssertNotCalled(t, "AddUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRemoveUserCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{"Success", nil, http.StatusNoContent},
+		{"NotFound", fmt.Errorf("failed to get user: %w", store.ErrNotFound), http.StatusNotFound},
+		{"LastAdmin", auth.ErrLastAdmin, http.StatusConflict},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockAuthenticator := new(MockAuthenticator)
+			mockAuthenticator.On("RemoveUser", uint64(2)).Return(tt.err)
+
+			server := Server{
+				Authenticator: mockAuthenticator,
+				Version:       "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Delete("/api/v1/admin/users/{id}", server.removeUserCtrl)
+			req := httptest.NewRequest("DELETE", "/api/v1/admin/users/2", nil)
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			mockAuthenticator.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	assert.Equal(t, "/status?feed=1", safeRedirect("/status?feed=1"))
+	assert.Equal(t, "/", safeRedirect(""))
+	assert.Equal(t, "/", safeRedirect("https://example.com"))
+	assert.Equal(t, "/", safeRedirect("//example.com"))
+	assert.Equal(t, "/", safeRedirect("/\\example.com"))
+}
+
+func TestGetPostsHtmxCtrl(t *testing.T) {
+	// Setup
+	templateCache, err := NewTemplateCache()
+	assert.NoError(t, err)
+
+	mockBlogger := new(MockBlogger)
+	mockBlogger.On("GetTimeline", store.PostsQuery{UserID: testReader.ID, Page: 1, PageSize: 10}, "").Return(&store.PaginationPostsResult{
+		Posts:    []*store.PostV1{},
+		Page:     1,
+		PageSize: 10,
+	}, nil)
+
+	mockAssistant := new(MockAssistant)
+	mockAssistant.On("ProfileNames").Return([]string{})
+
+	server := Server{
+		Blogger:       mockBlogger,
+		Assistant:     mockAssistant,
+		Version:       "test",
+		templateCache: templateCache,
+	}
+
+	// Create request
+	r := chi.NewRouter()
+	r.Get("/api/v1/posts", server.getPostsHtmxCtrl)
+	req := withUser(httptest.NewRequest("GET", "/api/v1/posts?page=1&pageSize=10", nil), testReader)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Your timeline is empty")
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestGetSubscriptionsCtrl(t *testing.T) {
+	// Setup
+	mockBlogger := new(MockBlogger)
+	mockBlogger.On("GetSubscribedFeeds", testReader.ID).Return([]*store.FeedV1{
+		{ID: "hash-1", URL: "http://example.com/feed1"},
+	}, nil)
+
+	server := Server{
+		Blogger: mockBlogger,
+		Version: "test",
+	}
+
+	// Create request
+	r := chi.NewRouter()
+	r.Get("/api/v1/subscriptions", server.getSubscriptionsCtrl)
+	req := withUser(httptest.NewRequest("GET", "/api/v1/subscriptions", nil), testReader)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response SubscriptionsResultsJSON
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, []SubscriptionJSON{{FeedID: "hash-1", URL: "http://example.com/feed1"}}, response.Subscriptions)
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestSubscribeCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		feedID       string
+		htmx         bool
+		err          error
+		expectedCode int
+		expectedBody string
+	}{
+		{"Success", "hash-1", false, nil, http.StatusOK, `"feedId":"hash-1"`},
+		{"Htmx", "hash-1", true, nil, http.StatusOK, "Unsubscribe"},
+		{"UnknownFeed", "hash-3", false, fmt.Errorf("failed to get feed: %w", store.ErrNotFound), http.StatusNotFound, "feed not found"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			templateCache, err := NewTemplateCache()
+			assert.NoError(t, err)
+
+			var feed *store.FeedV1
+			if tt.err == nil {
+				feed = &store.FeedV1{ID: tt.feedID, URL: "http://example.com/feed1"}
+			}
+			mockBlogger := new(MockBlogger)
+			mockBlogger.On("Subscribe", testReader.ID, tt.feedID).Return(feed, tt.err)
+
+			server := Server{
+				Blogger:       mockBlogger,
+				Version:       "test",
+				templateCache: templateCache,
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Put("/api/v1/subscriptions/{id}", server.subscribeCtrl)
+			req := withUser(httptest.NewRequest("PUT", "/api/v1/subscriptions/"+tt.feedID, nil), testReader)
+			if tt.htmx {
+				req.Header.Set("HX-Request", "true")
+			}
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+			mockBlogger.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUnsubscribeCtrl(t *testing.T) {
+	tbl := []struct {
+		name         string
+		htmx         bool
+		err          error
+		expectedCode int
+		expectedBody string
+	}{
+		{"Success", false, nil, http.StatusNoContent, ""},
+		{"Htmx", true, nil, http.StatusOK, "Subscribe"},
+		{"NotSubscribed", false, fmt.Errorf("failed to unsubscribe: %w", store.ErrNotFound), http.StatusNotFound, "subscription not found"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			templateCache, err := NewTemplateCache()
+			assert.NoError(t, err)
+
+			mockBlogger := new(MockBlogger)
+			mockBlogger.On("Unsubscribe", testReader.ID, "hash-1").Return(tt.err)
+
+			server := Server{
+				Blogger:       mockBlogger,
+				Version:       "test",
+				templateCache: templateCache,
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Delete("/api/v1/subscriptions/{id}", server.unsubscribeCtrl)
+			req := withUser(httptest.NewRequest("DELETE", "/api/v1/subscriptions/hash-1", nil), testReader)
+			if tt.htmx {
+				req.Header.Set("HX-Request", "true")
+			}
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+			mockBlogger.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStatusCtrlSubscriptions(t *testing.T) {
+	// Setup
+	templateCache, err := NewTemplateCache()
+	assert.NoError(t, err)
+
+	mockBlogger := new(MockBlogger)
+	mockBlogger.On("GetFeedStatuses").Return([]*store.FeedStatus{
+		{Feed: &store.FeedV1{ID: "hash-1", URL: "http://example.com/feed1"}, Health: &store.FeedHealthV1{FeedID: "hash-1"}},
+		{Feed: &store.FeedV1{ID: "hash-2", URL: "http://example.com/feed2"}, Health: &store.FeedHealthV1{FeedID: "hash-2"}},
+	}, nil)
+	mockBlogger.On("GetSubscribedFeeds", testReader.ID).Return([]*store.FeedV1{{ID: "hash-1"}}, nil)
+
+	server := Server{
+		Blogger:       mockBlogger,
+		Version:       "test",
+		templateCache: templateCache,
+	}
+
+	// Create request
+	r := chi.NewRouter()
+	r.Get("/status", server.statusCtrl)
+	req := withUser(httptest.NewRequest("GET", "/status", nil), testReader)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `hx-delete="/api/v1/subscriptions/hash-1"`)
+	assert.Contains(t, rec.Body.String(), `hx-put="/api/v1/subscriptions/hash-2"`)
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestGetPostsCtrlFilters(t *testing.T) {
+	t.Run("Starred", func(t *testing.T) {
+		// Setup
+		starredAt := time.Now()
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("GetTimeline", store.PostsQuery{UserID: store.AnonymousUserID, Starred: true, Page: 1, PageSize: 10}, "").Return(&store.PaginationPostsResult{
+			Posts:    []*store.PostV1{{ID: "1"}, {ID: "2"}},
+			Page:     1,
+			PageSize: 10,
+			Size:     2,
+		}, nil)
+		mockBlogger.On("GetPostStates", store.AnonymousUserID, []string{"1", "2"}).Return(map[string]*store.PostStateV1{
+			"1": {PostID: "1", StarredAt: &starredAt, ReadAt: &starredAt},
+		}, nil)
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("ProfileNames").Return([]string{})
+
+		server := Server{
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/posts", server.getPostsCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/posts?page=1&pageSize=10&starred=true", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var response PostsResultsJSON
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []PostJSON{{ID: "1", Read: true, Starred: true}, {ID: "2"}}, response.Posts)
+		mockBlogger.AssertExpectations(t)
+	})
+
+	t.Run("InvalidFilter", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		server := Server{
+			Blogger: mockBlogger,
+			Version: "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/posts", server.getPostsCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/posts?page=1&pageSize=10&unread=maybe", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid filter parameter")
+		mockBlogger.AssertNotCalled(t, "GetTimeline", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetPostsCtrlRelevance(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Setup
+		mockBlogger := new(MockBlogger)
+		mockBlogger.On("GetTimeline", store.PostsQuery{
+			UserID:   store.AnonymousUserID,
+			Page:     1,
+			PageSize: 10,
+			Profiles: []string{"go"},
+			Sort:     store.SortRelevance,
+			MinScore: 50,
+		}, "").Return(&store.PaginationPostsResult{
+			Posts:    []*store.PostV1{{ID: "1"}, {ID: "2"}},
+			Page:     1,
+			PageSize: 10,
+			Size:     2,
+		}, nil)
+		mockBlogger.On("GetPostStates", store.AnonymousUserID, []string{"1", "2"}).Return(map[string]*store.PostStateV1{}, nil)
+		mockBlogger.On("GetPostScores", []string{"1", "2"}, []string{"go"}).Return(map[string][]*store.PostScoreV1{
+			"1": {{PostID: "1", Profile: "go", Score: 90}},
+		}, nil)
+		mockAssistant := new(MockAssistant)
+		mockAssistant.On("ProfileNames").Return([]string{"go", "security"})
+
+		server := Server{
+			Blogger:   mockBlogger,
+			Assistant: mockAssistant,
+			Version:   "test",
+		}
+
+		// Create request
+		r := chi.NewRouter()
+		r.Get("/api/v1/posts", server.getPostsCtrl)
+		req := httptest.NewRequest("GET", "/api/v1/posts?page=1&pageSize=10&sort=relevance&profile=go&minScore=50", nil)
+		rec := httptest.NewRecorder()
+
+		// Execute
+		r.ServeHTTP(rec, req)
+
+		// Verify
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var response PostsResultsJSON
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []PostJSON{{ID: "1", Relevance: map[string]int{"go": 90}}, {ID: "2"}}, response.Posts)
+		mockBlogger.AssertExpectations(t)
+		mockBlogger.AssertNotCalled(t, "GetPosts", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	tbl := []struct {
+		name     string
+		params   string
+		profiles []string
+	}{
+		{"UnknownSort", "sort=oldest", []string{"go"}},
+		{"UnknownProfile", "profile=rust", []string{"go"}},
+		{"NegativeMinScore", "minScore=-1", []string{"go"}},
+		{"MinScoreAboveMax", "minScore=101", []string{"go"}},
+		{"NoProfiles", "sort=relevance", []string{}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockBlogger := new(MockBlogger)
+			mockAssistant := new(MockAssistant)
+			mockAssistant.On("ProfileNames").Return(tt.profiles)
+
+			server := Server{
+				Blogger:   mockBlogger,
+				Assistant: mockAssistant,
+				Version:   "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Get("/api/v1/posts", server.getPostsCtrl)
+			req := httptest.NewRequest("GET", "/api/v1/posts?page=1&pageSize=10&"+tt.params, nil)
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "invalid relevance parameter")
+			mockBlogger.AssertNotCalled(t, "GetTimeline", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetPostsHtmxCtrlRelevance(t *testing.T) {
+	// Setup
+	templateCache, err := NewTemplateCache()
+	assert.NoError(t, err)
+
+	mockBlogger := new(MockBlogger)
+	mockBlogger.On("GetTimeline", store.PostsQuery{
+		UserID:   testReader.ID,
+		Page:     1,
+		PageSize: 1,
+		Profiles: []string{"go", "security"},
+		Sort:     store.SortRelevance,
+	}, "").Return(&store.PaginationPostsResult{
+		Posts:    []*store.PostV1{{ID: "1", Title: "Post 1"}},
+		Page:     1,
+		PageSize: 1,
+		Size:     2,
+	}, nil)
+	mockBlogger.On("GetPostStates", testReader.ID, []string{"1"}).Return(map[string]*store.PostStateV1{}, nil)
+	mockBlogger.On("GetPostScores", []string{"1"}, []string{"go", "security"}).Return(map[string][]*store.PostScoreV1{
+		"1": {{PostID: "1", Profile: "go", Score: 85}, {PostID: "1", Profile: "security", Score: 10}},
+	}, nil)
+	mockAssistant := new(MockAssistant)
+	mockAssistant.On("ProfileNames").Return([]string{"go", "security"})
+
+	server := Server{
+		Blogger:       mockBlogger,
+		Assistant:     mockAssistant,
+		Version:       "test",
+		templateCache: templateCache,
+	}
+
+	// Create request
+	r := chi.NewRouter()
+	r.Get("/api/v1/posts", server.getPostsHtmxCtrl)
+	req := withUser(httptest.NewRequest("GET", "/api/v1/posts?page=1&pageSize=1&sort=relevance", nil), testReader)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "go: 85")
+	assert.Contains(t, body, "security: 10")
+	assert.Contains(t, body, `&pageSize=1&amp;sort=relevance"`)
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestGetPostsHtmxCtrlStates(t *testing.T) {
+	// Setup
+	templateCache, err := NewTemplateCache()
+	assert.NoError(t, err)
+
+	readAt := time.Now()
+	mockBlogger := new(MockBlogger)
+	mockBlogger.On("GetTimeline", store.PostsQuery{UserID: testReader.ID, Unread: true, Page: 1, PageSize: 1}, "").Return(&store.PaginationPostsResult{
+		Posts:    []*store.PostV1{{ID: "1", Title: "Post 1"}},
+		Page:     1,
+		PageSize: 1,
+		Size:     2,
+	}, nil)
+	mockBlogger.On("GetPostStates", testReader.ID, []string{"1"}).Return(map[string]*store.PostStateV1{
+		"1": {PostID: "1", ReadAt: &readAt},
+	}, nil)
+
+	mockAssistant := new(MockAssistant)
+	mockAssistant.On("ProfileNames").Return([]string{})
+
+	server := Server{
+		Blogger:       mockBlogger,
+		Assistant:     mockAssistant,
+		Version:       "test",
+		templateCache: templateCache,
+	}
+
+	// Create request
+	r := chi.NewRouter()
+	r.Get("/api/v1/posts", server.getPostsHtmxCtrl)
+	req := withUser(httptest.NewRequest("GET", "/api/v1/posts?page=1&pageSize=1&unread=true", nil), testReader)
+	rec := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(rec, req)
+
+	// Verify
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `hx-delete="/api/v1/posts/1/read"`)
+	assert.Contains(t, body, `hx-put="/api/v1/posts/1/starred"`)
+	assert.Contains(t, body, "&pageSize=1&unread=true")
+	assert.NotContains(t, body, "Your timeline is empty")
+	mockBlogger.AssertExpectations(t)
+}
+
+func TestSetPostStateCtrl(t *testing.T) {
+	starredAt := time.Now()
+
+	tbl := []struct {
+		name         string
+		method       string
+		htmx         bool
+		err          error
+		expectedCode int
+		expectedBody string
+	}{
+		{"Star", "PUT", false, nil, http.StatusOK, `"starred":true`},
+		{"Htmx", "PUT", true, nil, http.StatusOK, `hx-delete="/api/v1/posts/1/starred"`},
+		{"Unstar", "DELETE", false, nil, http.StatusOK, `"starred":false`},
+		{"UnknownPost", "PUT", false, fmt.Errorf("failed to get post: %w", store.ErrNotFound), http.StatusNotFound, "post not found"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			templateCache, err := NewTemplateCache()
+			assert.NoError(t, err)
+
+			on := tt.method == "PUT"
+			var state *store.PostStateV1
+			if tt.err == nil {
+				state = &store.PostStateV1{UserID: testReader.ID, PostID: "1"}
+				if on {
+					state.StarredAt = &starredAt
+				}
+			}
+			mockBlogger := new(MockBlogger)
+			mockBlogger.On("SetPostState", testReader.ID, "1", store.PostFlagStarred, on).Return(state, tt.err)
+
+			server := Server{
+				Blogger:       mockBlogger,
+				Version:       "test",
+				templateCache: templateCache,
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Put("/api/v1/posts/{id}/starred", server.setPostStateCtrl(store.PostFlagStarred, true))
+			r.Delete("/api/v1/posts/{id}/starred", server.setPostStateCtrl(store.PostFlagStarred, false))
+			req := withUser(httptest.NewRequest(tt.method, "/api/v1/posts/1/starred", nil), testReader)
+			if tt.htmx {
+				req.Header.Set("HX-Request", "true")
+			}
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+			mockBlogger.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMarkPostsReadCtrl(t *testing.T) {
+	until := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tbl := []struct {
+		name         string
+		url          string
+		htmx         bool
+		expectedCode int
+		expectedBody string
+	}{
+		{"Success", "/api/v1/posts/read?until=2024-05-01T12:00:00Z&partitionKey=hash-1", false, http.StatusOK, `{"marked":3}`},
+		{"Htmx", "/api/v1/posts/read?until=2024-05-01T12:00:00Z&partitionKey=hash-1", true, http.StatusNoContent, ""},
+		{"InvalidUntil", "/api/v1/posts/read?until=yesterday", false, http.StatusBadRequest, "invalid until parameter"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockBlogger := new(MockBlogger)
+			mockBlogger.On("MarkPostsRead", store.PostsQuery{UserID: testReader.ID}, "hash-1", until).Return(int64(3), nil)
+
+			server := Server{
+				Blogger: mockBlogger,
+				Version: "test",
+			}
+
+			// Create request
+			r := chi.NewRouter()
+			r.Post("/api/v1/posts/read", server.markPostsReadCtrl)
+			req := withUser(httptest.NewRequest("POST", tt.url, nil), testReader)
+			if tt.htmx {
+				req.Header.Set("HX-Request", "true")
+			}
+			rec := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(rec, req)
+
+			// Verify
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+			if tt.htmx {
+				assert.Equal(t, "posts-read", rec.Header().Get("HX-Trigger"))
+			}
+			if tt.expectedCode != http.StatusBadRequest {
+				mockBlogger.AssertExpectations(t)
+			}
+		})
+	}
 }
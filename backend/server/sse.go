@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sseWriter streams server-sent events to the client, flushing after every event
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// newSSEWriter writes the event stream headers and lifts the server write deadline,
+// since a streamed answer can take longer than a regular response
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	rc := http.NewResponseController(w)
+	// not every writer supports deadlines (e.g. httptest recorder), the stream works without it
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	return &sseWriter{w: w, rc: rc}
+}
+
+// Event sends a named event, splitting multi-line data into several data fields
+func (s *sseWriter) Event(event string, data string) error {
+	var sb strings.Builder
+	if event != "" {
+		fmt.Fprintf(&sb, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&sb, "data: %s\n", line)
+	}
+	sb.WriteString("\n")
+
+	if _, err := s.w.Write([]byte(sb.String())); err != nil {
+		return fmt.Errorf("failed to write event: %v", err)
+	}
+
+	if err := s.rc.Flush(); err != nil {
+		return fmt.Errorf("failed to flush event: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,951 @@
+package store_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/store/storetest"
+)
+
+// engine mirrors blogger.Engine, which imports this package, every storage backend has to implement it
+type engine interface {
+	GetPosts(page int, pageSize int, partitionKey string) (*store.PaginationPostsResult, error)
+	QueryPosts(query store.PostsQuery) (*store.PaginationPostsResult, error)
+	MarkPostsRead(query store.PostsQuery, until time.Time, readAt time.Time) (int64, error)
+	SavePostsBulk(postsToSave []*store.PostV1) ([]*store.PostV1, error)
+	GetPostsByIDs(ids []string) ([]*store.PostV1, error)
+	GetPostsWithoutEmbedding(model string, limit int) ([]*store.PostV1, error)
+	GetPostsAfter(partitionKey string, afterID string, limit int) ([]*store.PostV1, error)
+	UpsertPosts(posts []*store.PostV1) (int, error)
+	GetPartitionKeys() ([]string, error)
+	GetExpiredPosts(partitionKey string, skip int, createdBefore time.Time) ([]*store.PostV1, error)
+	DeletePosts(ids []string) (int64, error)
+	Optimize() error
+	GetPostEmbedding(postID string) (*store.PostEmbeddingV1, error)
+	GetPostEmbeddings(model string) ([]*store.PostEmbeddingV1, error)
+	SavePostEmbedding(embedding *store.PostEmbeddingV1) error
+	GetFeeds() ([]*store.FeedV1, error)
+	GetFeed(id string) (*store.FeedV1, error)
+	SaveFeed(feed *store.FeedV1) error
+	GetFeedHealth(feedID string) (*store.FeedHealthV1, error)
+	GetFeedsHealth() ([]*store.FeedHealthV1, error)
+	SaveFeedHealth(health *store.FeedHealthV1) error
+	IncrementFeedItemsSummarized(feedID string) error
+	ResetFeedItemsSummarized(feedID string) error
+	SaveFeedRun(health *store.FeedHealthV1) error
+	EnqueueJobs(jobs []*store.JobV1) (int, error)
+	LeaseJobs(now time.Time, lease time.Duration, limit int) ([]*store.JobV1, error)
+	GetJob(id uint64) (*store.JobV1, error)
+	GetJobsByStatus(status string) ([]*store.JobV1, error)
+	SaveJob(job *store.JobV1) error
+	DeleteJob(id uint64) error
+	CountJobsByStatus() (map[string]int64, error)
+	CreateUser(user *store.UserV1) error
+	GetUser(id uint64) (*store.UserV1, error)
+	GetUserByName(name string) (*store.UserV1, error)
+	GetUsers() ([]*store.UserV1, error)
+	SaveUser(user *store.UserV1) error
+	DeleteUser(id uint64) error
+	SaveSession(session *store.SessionV1) error
+	GetSession(id string) (*store.SessionV1, error)
+	DeleteSession(id string) error
+	DeleteExpiredSessions(now time.Time) (int64, error)
+	SaveAPIToken(token *store.APITokenV1) error
+	GetAPITokenByHash(hash string) (*store.APITokenV1, error)
+	GetAPITokens(userID uint64) ([]*store.APITokenV1, error)
+	DeleteAPIToken(id uint64) error
+	SaveSubscription(subscription *store.SubscriptionV1) error
+	DeleteSubscription(userID uint64, feedID string) error
+	GetSubscriptions(userID uint64) ([]*store.SubscriptionV1, error)
+	SavePostState(state *store.PostStateV1) error
+	GetPostStates(userID uint64, postIDs []string) ([]*store.PostStateV1, error)
+	GetPostsWithoutScores(profiles []string, limit int) ([]*store.PostV1, error)
+	GetPostScores(postIDs []string, profiles []string) ([]*store.PostScoreV1, error)
+	SavePostScores(scores []*store.PostScoreV1) error
+	Ping() error
+}
+
+// forEachBackend runs the test as a subtest per storage backend, each starting empty
+func forEachBackend(t *testing.T, fn func(t *testing.T, e engine)) {
+	t.Run(store.DialectMemory, func(t *testing.T) {
+		fn(t, store.NewMemory())
+	})
+
+	store.ForEachDialect(t, func(t *testing.T, dialect string) {
+		database, _ := store.NewTestDatabase(t, dialect)
+		if err := database.Migrate(); err != nil {
+			t.Fatalf("Failed to migrate database: %v", err)
+		}
+		fn(t, database)
+	})
+}
+
+func postIDs(posts []*store.PostV1) []string {
+	ids := make([]string, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+func TestConformancePosts(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		_, err := e.SavePostsBulk([]*store.PostV1{
+			storetest.NewPost("1", "feed-a", now.Add(-3*time.Hour)),
+			storetest.NewPost("2", "feed-b", now.Add(-2*time.Hour)),
+			storetest.NewPost("3", "feed-a", now.Add(-1*time.Hour)),
+		})
+		assert.NoError(t, err)
+
+		t.Run("Pagination", func(t *testing.T) {
+			first, err := e.GetPosts(1, 2, "")
+			assert.NoError(t, err)
+			second, err := e.GetPosts(2, 2, "")
+			assert.NoError(t, err)
+			beyond, err := e.GetPosts(3, 2, "")
+			assert.NoError(t, err)
+
+			assert.Equal(t, []string{"3", "2"}, postIDs(first.Posts))
+			assert.Equal(t, int64(3), first.Size)
+			assert.Equal(t, []string{"1"}, postIDs(second.Posts))
+			assert.NotNil(t, beyond.Posts)
+			assert.Empty(t, beyond.Posts)
+			assert.Equal(t, int64(3), beyond.Size)
+		})
+
+		t.Run("PartitionFilter", func(t *testing.T) {
+			result, err := e.GetPosts(1, 10, "feed-a")
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"3", "1"}, postIDs(result.Posts))
+			assert.Equal(t, int64(2), result.Size)
+			assert.Equal(t, "feed-a", result.PartitionKey)
+			assert.Equal(t, "Title 3", result.Posts[0].Title)
+			assert.True(t, now.Add(-time.Hour).Equal(result.Posts[0].CreatedAt))
+		})
+
+		t.Run("DuplicateSavesNothing", func(t *testing.T) {
+			_, err := e.SavePostsBulk([]*store.PostV1{storetest.NewPost("4", "feed-a", now), storetest.NewPost("1", "feed-a", now)})
+			assert.Error(t, err)
+
+			posts, err := e.GetPostsByIDs([]string{"4"})
+			assert.NoError(t, err)
+			assert.Empty(t, posts)
+		})
+
+		t.Run("ByIDs", func(t *testing.T) {
+			posts, err := e.GetPostsByIDs([]string{"1", "unknown", "2"})
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{"1", "2"}, postIDs(posts))
+
+			posts, err = e.GetPostsByIDs(nil)
+			assert.NoError(t, err)
+			assert.NotNil(t, posts)
+			assert.Empty(t, posts)
+		})
+
+		t.Run("CopiesRecords", func(t *testing.T) {
+			posts, err := e.GetPostsByIDs([]string{"1"})
+			assert.NoError(t, err)
+			posts[0].Title = "Changed"
+
+			posts, err = e.GetPostsByIDs([]string{"1"})
+			assert.NoError(t, err)
+			assert.Equal(t, "Title 1", posts[0].Title)
+		})
+	})
+}
+
+func TestConformanceEmbeddings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		_, err := e.SavePostsBulk([]*store.PostV1{storetest.NewPost("1", "feed", time.Now()), storetest.NewPost("2", "feed", time.Now())})
+		assert.NoError(t, err)
+
+		// Execute
+		assert.NoError(t, e.SavePostEmbedding(&store.PostEmbeddingV1{PostID: "1", Model: "old", Vector: []float64{1, 0}}))
+		assert.NoError(t, e.SavePostEmbedding(&store.PostEmbeddingV1{PostID: "1", Model: "new", Vector: []float64{0, 1}}))
+
+		// Verify
+		embedding, err := e.GetPostEmbedding("1")
+		assert.NoError(t, err)
+		assert.Equal(t, "new", embedding.Model)
+		assert.Equal(t, []float64{0, 1}, embedding.Vector)
+
+		_, err = e.GetPostEmbedding("2")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+
+		embeddings, err := e.GetPostEmbeddings("new")
+		assert.NoError(t, err)
+		assert.Len(t, embeddings, 1)
+		embeddings, err = e.GetPostEmbeddings("old")
+		assert.NoError(t, err)
+		assert.Empty(t, embeddings)
+
+		missing, err := e.GetPostsWithoutEmbedding("new", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2"}, postIDs(missing))
+		missing, err = e.GetPostsWithoutEmbedding("old", 1)
+		assert.NoError(t, err)
+		assert.Len(t, missing, 1)
+	})
+}
+
+func TestConformanceFeeds(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		later := &store.FeedV1{ID: "later", URL: "http://example.com/later", IntervalInSeconds: 3600, NextFetchAt: now.Add(time.Hour)}
+		sooner := &store.FeedV1{ID: "sooner", URL: "http://example.com/sooner", IntervalInSeconds: 600, NextFetchAt: now}
+		assert.NoError(t, e.SaveFeed(later))
+		assert.NoError(t, e.SaveFeed(sooner))
+
+		// Execute
+		disabledAt := now
+		later.Disabled = true
+		later.DisabledAt = &disabledAt
+		assert.NoError(t, e.SaveFeed(later))
+
+		// Verify
+		feeds, err := e.GetFeeds()
+		assert.NoError(t, err)
+		assert.Len(t, feeds, 2)
+		assert.Equal(t, "sooner", feeds[0].ID)
+		assert.Equal(t, "later", feeds[1].ID)
+
+		feed, err := e.GetFeed("later")
+		assert.NoError(t, err)
+		assert.True(t, feed.Disabled)
+		assert.True(t, now.Equal(*feed.DisabledAt))
+		assert.Equal(t, 3600, feed.IntervalInSeconds)
+
+		_, err = e.GetFeed("unknown")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
+
+func TestConformanceFeedHealth(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		assert.NoError(t, e.SaveFeedHealth(&store.FeedHealthV1{FeedID: "b", ConsecutiveFailures: 2, LastError: "timeout"}))
+		assert.NoError(t, e.SaveFeedHealth(&store.FeedHealthV1{FeedID: "a", ItemsSummarized: 1}))
+
+		// Execute
+		assert.NoError(t, e.IncrementFeedItemsSummarized("a"))
+		assert.NoError(t, e.IncrementFeedItemsSummarized("unknown"))
+
+		// Verify
+		health, err := e.GetFeedHealth("a")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, health.ItemsSummarized)
+
+		health, err = e.GetFeedHealth("b")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, health.ConsecutiveFailures)
+		assert.Equal(t, "timeout", health.LastError)
+
+		healths, err := e.GetFeedsHealth()
+		assert.NoError(t, err)
+		assert.Len(t, healths, 2)
+
+		_, err = e.GetFeedHealth("unknown")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
+
+func TestConformanceFeedRun(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		assert.NoError(t, e.SaveFeedHealth(&store.FeedHealthV1{FeedID: "a", ItemsSummarized: 5, LastError: "timeout"}))
+
+		// Execute
+		assert.NoError(t, e.ResetFeedItemsSummarized("a"))
+		assert.NoError(t, e.ResetFeedItemsSummarized("b"))
+		assert.NoError(t, e.IncrementFeedItemsSummarized("a"))
+		assert.NoError(t, e.IncrementFeedItemsSummarized("b"))
+		// the outcome of the fetch is written after the jobs of its items started
+		assert.NoError(t, e.SaveFeedRun(&store.FeedHealthV1{FeedID: "a", ItemsSeen: 3, ItemsNew: 2, ConsecutiveFailures: 1, LastError: "refused"}))
+		assert.NoError(t, e.SaveFeedRun(&store.FeedHealthV1{FeedID: "b", ItemsSeen: 1, ItemsNew: 1}))
+		assert.NoError(t, e.SaveFeedRun(&store.FeedHealthV1{FeedID: "c", ItemsSeen: 1}))
+
+		// Verify
+		health, err := e.GetFeedHealth("a")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, health.ItemsSummarized)
+		assert.Equal(t, 3, health.ItemsSeen)
+		assert.Equal(t, 2, health.ItemsNew)
+		assert.Equal(t, 1, health.ConsecutiveFailures)
+		assert.Equal(t, "refused", health.LastError)
+
+		health, err = e.GetFeedHealth("b")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, health.ItemsSummarized)
+		assert.Equal(t, 1, health.ItemsNew)
+
+		health, err = e.GetFeedHealth("c")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, health.ItemsSeen)
+	})
+}
+
+func TestConformanceJobs(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		created, err := e.EnqueueJobs([]*store.JobV1{
+			{Kind: store.JobKindSummarizePost, DedupKey: "post-1", Post: *storetest.NewPost("1", "feed", now), Status: store.JobStatusPending, RunAt: now.Add(-time.Minute)},
+			{Kind: store.JobKindSaveLink, DedupKey: "link-1", Post: store.PostV1{SourceURL: "http://example.com/link"}, Status: store.JobStatusPending, RunAt: now},
+			{Kind: store.JobKindSummarizePost, DedupKey: "post-2", Status: store.JobStatusPending, RunAt: now.Add(time.Hour)},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, created)
+
+		t.Run("Dedup", func(t *testing.T) {
+			created, err := e.EnqueueJobs([]*store.JobV1{
+				{Kind: store.JobKindSummarizePost, DedupKey: "post-1", Status: store.JobStatusPending, RunAt: now},
+				{Kind: store.JobKindSummarizePost, DedupKey: "post-3", Status: store.JobStatusPending, RunAt: now.Add(time.Hour)},
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, 1, created)
+		})
+
+		t.Run("Lease", func(t *testing.T) {
+			first, err := e.LeaseJobs(now, time.Minute, 1)
+			assert.NoError(t, err)
+			second, err := e.LeaseJobs(now, time.Minute, 10)
+			assert.NoError(t, err)
+			expired, err := e.LeaseJobs(now.Add(time.Minute), time.Minute, 10)
+			assert.NoError(t, err)
+
+			assert.Len(t, first, 1)
+			assert.Equal(t, "post-1", first[0].DedupKey)
+			assert.Equal(t, store.JobStatusLeased, first[0].Status)
+			assert.Equal(t, 1, first[0].Attempts)
+			assert.True(t, now.Add(time.Minute).Equal(*first[0].LeasedUntil))
+			assert.Equal(t, "1", first[0].Post.ID)
+			assert.Len(t, second, 1)
+			assert.Equal(t, "link-1", second[0].DedupKey)
+			assert.Equal(t, "http://example.com/link", second[0].Post.SourceURL)
+			assert.Len(t, expired, 2)
+			assert.Equal(t, 2, expired[0].Attempts)
+		})
+
+		t.Run("DeadAndDelete", func(t *testing.T) {
+			leased, err := e.GetJobsByStatus(store.JobStatusLeased)
+			assert.NoError(t, err)
+			assert.Len(t, leased, 2)
+
+			dead := leased[0]
+			dead.Status = store.JobStatusDead
+			dead.LastError = "failed"
+			assert.NoError(t, e.SaveJob(dead))
+			assert.NoError(t, e.DeleteJob(leased[1].ID))
+
+			job, err := e.GetJob(dead.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, store.JobStatusDead, job.Status)
+			assert.Equal(t, "failed", job.LastError)
+
+			_, err = e.GetJob(leased[1].ID)
+			assert.ErrorIs(t, err, store.ErrNotFound)
+
+			counts, err := e.CountJobsByStatus()
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]int64{store.JobStatusPending: 2, store.JobStatusLeased: 0, store.JobStatusDead: 1}, counts)
+		})
+	})
+}
+
+func TestConformanceUpsert(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		_, err := e.SavePostsBulk([]*store.PostV1{storetest.NewPost("1", "feed-a", now), storetest.NewPost("2", "feed-a", now)})
+		assert.NoError(t, err)
+		for _, id := range []string{"1", "2"} {
+			assert.NoError(t, e.SavePostEmbedding(&store.PostEmbeddingV1{PostID: id, Model: "embed", Vector: []float64{1}}))
+		}
+
+		unchanged := storetest.NewPost("1", "feed-b", now.Add(-time.Hour))
+		changed := storetest.NewPost("2", "feed-a", now)
+		changed.Text = "Imported text"
+		changed.Model = "llama3:8b"
+
+		// Execute
+		created, err := e.UpsertPosts([]*store.PostV1{unchanged, changed, storetest.NewPost("3", "feed-a", now)})
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, 1, created)
+
+		posts, err := e.GetPostsByIDs([]string{"1", "2", "3"})
+		assert.NoError(t, err)
+		byID := make(map[string]*store.PostV1)
+		for _, post := range posts {
+			byID[post.ID] = post
+		}
+		assert.Len(t, byID, 3)
+		assert.Equal(t, "feed-b", byID["1"].PartitionKey)
+		assert.True(t, now.Add(-time.Hour).Equal(byID["1"].CreatedAt))
+		assert.Equal(t, "Imported text", byID["2"].Text)
+		assert.Equal(t, "llama3:8b", byID["2"].Model)
+
+		// only the embedding of the post with another text is outdated
+		_, err = e.GetPostEmbedding("1")
+		assert.NoError(t, err)
+		_, err = e.GetPostEmbedding("2")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
+
+func TestConformancePostsAfter(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		_, err := e.SavePostsBulk([]*store.PostV1{
+			storetest.NewPost("c", "feed-a", now),
+			storetest.NewPost("a", "feed-a", now),
+			storetest.NewPost("b", "feed-b", now),
+			storetest.NewPost("d", "feed-a", now),
+		})
+		assert.NoError(t, err)
+
+		t.Run("Pages", func(t *testing.T) {
+			first, err := e.GetPostsAfter("", "", 2)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a", "b"}, postIDs(first))
+
+			second, err := e.GetPostsAfter("", "b", 2)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"c", "d"}, postIDs(second))
+
+			last, err := e.GetPostsAfter("", "d", 2)
+			assert.NoError(t, err)
+			assert.Empty(t, last)
+		})
+
+		t.Run("Partition", func(t *testing.T) {
+			posts, err := e.GetPostsAfter("feed-a", "a", 10)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"c", "d"}, postIDs(posts))
+		})
+	})
+}
+
+func TestConformanceRetention(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		_, err := e.SavePostsBulk([]*store.PostV1{
+			storetest.NewPost("1", "feed-a", now.Add(-4*24*time.Hour)),
+			storetest.NewPost("2", "feed-a", now.Add(-3*24*time.Hour)),
+			storetest.NewPost("3", "feed-a", now.Add(-2*24*time.Hour)),
+			storetest.NewPost("4", "feed-a", now),
+			storetest.NewPost("5", "feed-b", now.Add(-4*24*time.Hour)),
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, e.SavePostEmbedding(&store.PostEmbeddingV1{PostID: "1", Model: "model", Vector: []float64{1}}))
+
+		t.Run("PartitionKeys", func(t *testing.T) {
+			keys, err := e.GetPartitionKeys()
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"feed-a", "feed-b"}, keys)
+		})
+
+		t.Run("ExpiredPosts", func(t *testing.T) {
+			beyondNewest, err := e.GetExpiredPosts("feed-a", 2, time.Time{})
+			assert.NoError(t, err)
+			olderThan, err := e.GetExpiredPosts("feed-a", 0, now.Add(-3*24*time.Hour+time.Minute))
+			assert.NoError(t, err)
+			protected, err := e.GetExpiredPosts("feed-a", 3, now.Add(-24*time.Hour))
+			assert.NoError(t, err)
+
+			assert.Equal(t, []string{"2", "1"}, postIDs(beyondNewest))
+			assert.Equal(t, []string{"2", "1"}, postIDs(olderThan))
+			assert.Equal(t, []string{"1"}, postIDs(protected))
+		})
+
+		t.Run("Delete", func(t *testing.T) {
+			deleted, err := e.DeletePosts([]string{"1", "2", "unknown"})
+			assert.NoError(t, err)
+			assert.NoError(t, e.Optimize())
+
+			assert.Equal(t, int64(2), deleted)
+			result, err := e.GetPosts(1, 10, "feed-a")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"4", "3"}, postIDs(result.Posts))
+			_, err = e.GetPostEmbedding("1")
+			assert.ErrorIs(t, err, store.ErrNotFound)
+		})
+	})
+}
+
+func TestConformanceUsers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		bob := &store.UserV1{Name: "bob", PasswordHash: "hash", Role: store.RoleReader}
+		alice := &store.UserV1{Name: "alice", PasswordHash: "hash", Role: store.RoleAdmin}
+		assert.NoError(t, e.CreateUser(bob))
+		assert.NoError(t, e.CreateUser(alice))
+
+		// Execute
+		err := e.CreateUser(&store.UserV1{Name: "bob", PasswordHash: "other", Role: store.RoleAdmin})
+		bob.Role = store.RoleAdmin
+		assert.NoError(t, e.SaveUser(bob))
+
+		// Verify
+		assert.ErrorIs(t, err, store.ErrAlreadyExists)
+		assert.NotZero(t, bob.ID)
+		assert.NotEqual(t, bob.ID, alice.ID)
+
+		users, err := e.GetUsers()
+		assert.NoError(t, err)
+		assert.Len(t, users, 2)
+		assert.Equal(t, "alice", users[0].Name)
+		assert.Equal(t, "bob", users[1].Name)
+
+		user, err := e.GetUserByName("bob")
+		assert.NoError(t, err)
+		assert.Equal(t, bob.ID, user.ID)
+		assert.Equal(t, store.RoleAdmin, user.Role)
+		assert.Equal(t, "hash", user.PasswordHash)
+
+		user, err = e.GetUser(alice.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", user.Name)
+
+		_, err = e.GetUser(100)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		_, err = e.GetUserByName("carol")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
+
+func TestConformanceSessions(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		assert.NoError(t, e.SaveSession(&store.SessionV1{ID: "expired", UserID: 1, ExpiresAt: now.Add(-time.Minute)}))
+		assert.NoError(t, e.SaveSession(&store.SessionV1{ID: "active", UserID: 1, ExpiresAt: now.Add(time.Hour)}))
+		assert.NoError(t, e.SaveSession(&store.SessionV1{ID: "logout", UserID: 2, ExpiresAt: now.Add(time.Hour)}))
+
+		// Execute
+		deleted, err := e.DeleteExpiredSessions(now)
+		assert.NoError(t, err)
+		assert.NoError(t, e.DeleteSession("logout"))
+
+		// Verify
+		assert.Equal(t, int64(1), deleted)
+		session, err := e.GetSession("active")
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), session.UserID)
+		assert.True(t, now.Add(time.Hour).Equal(session.ExpiresAt))
+
+		_, err = e.GetSession("expired")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		_, err = e.GetSession("logout")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
+
+func TestConformanceAPITokens(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		user := &store.UserV1{Name: "alice", PasswordHash: "hash", Role: store.RoleReader}
+		assert.NoError(t, e.CreateUser(user))
+		first := &store.APITokenV1{UserID: user.ID, Name: "cli", Hash: "first"}
+		second := &store.APITokenV1{UserID: user.ID, Name: "ci", Hash: "second"}
+		assert.NoError(t, e.SaveAPIToken(first))
+		assert.NoError(t, e.SaveAPIToken(second))
+		assert.NoError(t, e.SaveAPIToken(&store.APITokenV1{UserID: user.ID + 1, Name: "other", Hash: "third"}))
+		assert.NoError(t, e.SaveSession(&store.SessionV1{ID: "session", UserID: user.ID, ExpiresAt: now.Add(time.Hour)}))
+
+		// Execute
+		first.LastUsedAt = &now
+		assert.NoError(t, e.SaveAPIToken(first))
+		assert.NoError(t, e.DeleteAPIToken(second.ID))
+
+		// Verify
+		token, err := e.GetAPITokenByHash("first")
+		assert.NoError(t, err)
+		assert.Equal(t, first.ID, token.ID)
+		assert.True(t, now.Equal(*token.LastUsedAt))
+		_, err = e.GetAPITokenByHash("second")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+
+		tokens, err := e.GetAPITokens(user.ID)
+		assert.NoError(t, err)
+		assert.Len(t, tokens, 1)
+		assert.Equal(t, "cli", tokens[0].Name)
+
+		// deleting the user deletes its tokens and sessions
+		assert.NoError(t, e.DeleteUser(user.ID))
+		tokens, err = e.GetAPITokens(user.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, tokens)
+		_, err = e.GetSession("session")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		_, err = e.GetUser(user.ID)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		_, err = e.GetAPITokenByHash("third")
+		assert.NoError(t, err)
+	})
+}
+
+func TestConformancePing(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, e engine) {
+		assert.NoError(t, e.Ping())
+	})
+}
+
+func TestConformanceSubscriptions(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		user := &store.UserV1{Name: "alice", PasswordHash: "hash", Role: store.RoleReader}
+		assert.NoError(t, e.CreateUser(user))
+		_, err := e.SavePostsBulk([]*store.PostV1{
+			storetest.NewPost("1", "feed-a", now.Add(-3*time.Hour)),
+			storetest.NewPost("2", "feed-b", now.Add(-2*time.Hour)),
+			storetest.NewPost("3", "feed-c", now.Add(-1*time.Hour)),
+		})
+		assert.NoError(t, err)
+
+		// Execute
+		assert.NoError(t, e.SaveSubscription(&store.SubscriptionV1{UserID: user.ID, FeedID: "feed-c"}))
+		assert.NoError(t, e.SaveSubscription(&store.SubscriptionV1{UserID: user.ID, FeedID: "feed-a"}))
+		assert.NoError(t, e.SaveSubscription(&store.SubscriptionV1{UserID: user.ID, FeedID: "feed-a"}))
+		assert.NoError(t, e.SaveSubscription(&store.SubscriptionV1{UserID: user.ID + 1, FeedID: "feed-b"}))
+
+		// Verify
+		subscriptions, err := e.GetSubscriptions(user.ID)
+		assert.NoError(t, err)
+		assert.Len(t, subscriptions, 2)
+		assert.Equal(t, "feed-a", subscriptions[0].FeedID)
+		assert.Equal(t, "feed-c", subscriptions[1].FeedID)
+		assert.False(t, subscriptions[0].CreatedAt.IsZero())
+
+		result, err := e.QueryPosts(store.PostsQuery{PartitionKeys: []string{"feed-a", "feed-c"}, Page: 1, PageSize: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"3"}, postIDs(result.Posts))
+		assert.Equal(t, int64(2), result.Size)
+		result, err = e.QueryPosts(store.PostsQuery{PartitionKeys: []string{"feed-a", "feed-c"}, Page: 2, PageSize: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1"}, postIDs(result.Posts))
+		result, err = e.QueryPosts(store.PostsQuery{PartitionKeys: []string{"feed-d"}, Page: 1, PageSize: 10})
+		assert.NoError(t, err)
+		assert.NotNil(t, result.Posts)
+		assert.Empty(t, result.Posts)
+		assert.Zero(t, result.Size)
+
+		assert.NoError(t, e.DeleteSubscription(user.ID, "feed-c"))
+		assert.ErrorIs(t, e.DeleteSubscription(user.ID, "feed-c"), store.ErrNotFound)
+		subscriptions, err = e.GetSubscriptions(user.ID)
+		assert.NoError(t, err)
+		assert.Len(t, subscriptions, 1)
+
+		// deleting the user deletes its subscriptions
+		assert.NoError(t, e.DeleteUser(user.ID))
+		subscriptions, err = e.GetSubscriptions(user.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, subscriptions)
+		subscriptions, err = e.GetSubscriptions(user.ID + 1)
+		assert.NoError(t, err)
+		assert.Len(t, subscriptions, 1)
+	})
+}
+
+func TestConformancePostStates(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		user := &store.UserV1{Name: "alice", PasswordHash: "hash", Role: store.RoleReader}
+		assert.NoError(t, e.CreateUser(user))
+		_, err := e.SavePostsBulk([]*store.PostV1{
+			storetest.NewPost("1", "feed-a", now.Add(-4*time.Hour)),
+			storetest.NewPost("2", "feed-a", now.Add(-3*time.Hour)),
+			storetest.NewPost("3", "feed-b", now.Add(-2*time.Hour)),
+			storetest.NewPost("4", "feed-a", now.Add(-1*time.Hour)),
+		})
+		assert.NoError(t, err)
+
+		// Execute
+		starred := &store.PostStateV1{UserID: user.ID, PostID: "2"}
+		starred.Set(store.PostFlagStarred, true, now)
+		assert.NoError(t, e.SavePostState(starred))
+		later := &store.PostStateV1{UserID: user.ID, PostID: "4"}
+		later.Set(store.PostFlagReadLater, true, now)
+		assert.NoError(t, e.SavePostState(later))
+		// other users have their own states
+		other := &store.PostStateV1{UserID: store.AnonymousUserID, PostID: "3"}
+		other.Set(store.PostFlagRead, true, now)
+		assert.NoError(t, e.SavePostState(other))
+
+		marked, err := e.MarkPostsRead(store.PostsQuery{UserID: user.ID, PartitionKeys: []string{"feed-a"}}, now.Add(-2*time.Hour), now)
+		assert.NoError(t, err)
+
+		// Verify
+		assert.Equal(t, int64(2), marked)
+
+		query := func(query store.PostsQuery) []string {
+			query.UserID, query.Page, query.PageSize = user.ID, 1, 10
+			result, err := e.QueryPosts(query)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(result.Posts)), result.Size)
+			return postIDs(result.Posts)
+		}
+		assert.Equal(t, []string{"4", "3"}, query(store.PostsQuery{Unread: true}))
+		assert.Equal(t, []string{"4"}, query(store.PostsQuery{Unread: true, PartitionKeys: []string{"feed-a"}}))
+		assert.Equal(t, []string{"2"}, query(store.PostsQuery{Starred: true}))
+		assert.Equal(t, []string{"4"}, query(store.PostsQuery{ReadLater: true}))
+		assert.Equal(t, []string{"4", "3", "2", "1"}, query(store.PostsQuery{}))
+
+		states, err := e.GetPostStates(user.ID, []string{"1", "2", "3", "4"})
+		assert.NoError(t, err)
+		assert.Len(t, states, 3)
+		byID := make(map[string]*store.PostStateV1)
+		for _, state := range states {
+			byID[state.PostID] = state
+		}
+		assert.True(t, now.Equal(*byID["1"].ReadAt))
+		assert.NotNil(t, byID["2"].ReadAt)
+		assert.NotNil(t, byID["2"].StarredAt)
+		assert.Nil(t, byID["4"].ReadAt)
+
+		// clearing a flag keeps the others
+		starred.Set(store.PostFlagStarred, false, now)
+		starred.ReadAt = byID["2"].ReadAt
+		assert.NoError(t, e.SavePostState(starred))
+		assert.Empty(t, query(store.PostsQuery{Starred: true}))
+		assert.Equal(t, []string{"4", "3"}, query(store.PostsQuery{Unread: true}))
+
+		// deleting a post or the user deletes their states
+		_, err = e.DeletePosts([]string{"1"})
+		assert.NoError(t, err)
+		states, err = e.GetPostStates(user.ID, []string{"1"})
+		assert.NoError(t, err)
+		assert.Empty(t, states)
+		assert.NoError(t, e.DeleteUser(user.ID))
+		states, err = e.GetPostStates(user.ID, []string{"2", "4"})
+		assert.NoError(t, err)
+		assert.Empty(t, states)
+		states, err = e.GetPostStates(store.AnonymousUserID, []string{"3"})
+		assert.NoError(t, err)
+		assert.Len(t, states, 1)
+	})
+}
+
+func TestConformanceExpiredPostsKeepStarred(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		_, err := e.SavePostsBulk([]*store.PostV1{
+			storetest.NewPost("1", "feed-a", now.Add(-3*time.Hour)),
+			storetest.NewPost("2", "feed-a", now.Add(-2*time.Hour)),
+			storetest.NewPost("3", "feed-a", now.Add(-1*time.Hour)),
+		})
+		assert.NoError(t, err)
+		starred := &store.PostStateV1{UserID: store.AnonymousUserID, PostID: "1"}
+		starred.Set(store.PostFlagStarred, true, now)
+		assert.NoError(t, e.SavePostState(starred))
+		read := &store.PostStateV1{UserID: store.AnonymousUserID, PostID: "2"}
+		read.Set(store.PostFlagStarred, true, now)
+		assert.NoError(t, e.SavePostState(read))
+		// saving again replaces the state of the anonymous user
+		read = &store.PostStateV1{UserID: store.AnonymousUserID, PostID: "2"}
+		read.Set(store.PostFlagRead, true, now)
+		assert.NoError(t, e.SavePostState(read))
+
+		// Execute
+		posts, err := e.GetExpiredPosts("feed-a", 1, time.Time{})
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2"}, postIDs(posts))
+	})
+}
+
+func TestConformanceJobPriority(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		tagged := storetest.NewPost("2", "feed", now)
+		tagged.Tags = "go,release"
+		_, err := e.EnqueueJobs([]*store.JobV1{
+			{Kind: store.JobKindSummarizePost, DedupKey: "post-1", Post: *storetest.NewPost("1", "feed", now), Status: store.JobStatusPending, RunAt: now.Add(-time.Hour)},
+			{Kind: store.JobKindSummarizePost, DedupKey: "post-2", Post: *tagged, Status: store.JobStatusPending, RunAt: now, Priority: 1},
+		})
+		assert.NoError(t, err)
+
+		// Execute
+		jobs, err := e.LeaseJobs(now, time.Minute, 1)
+
+		// Verify
+		assert.NoError(t, err)
+		assert.Len(t, jobs, 1)
+		assert.Equal(t, "post-2", jobs[0].DedupKey)
+		assert.Equal(t, 1, jobs[0].Priority)
+		assert.Equal(t, "go,release", jobs[0].Post.Tags)
+
+		_, err = e.SavePostsBulk([]*store.PostV1{&jobs[0].Post})
+		assert.NoError(t, err)
+		posts, err := e.GetPostsByIDs([]string{"2"})
+		assert.NoError(t, err)
+		assert.Len(t, posts, 1)
+		assert.Equal(t, "go,release", posts[0].Tags)
+	})
+}
+
+func TestConformanceConcurrentLeases(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		var jobs []*store.JobV1
+		for i := range 20 {
+			jobs = append(jobs, &store.JobV1{Kind: store.JobKindSummarizePost, DedupKey: fmt.Sprintf("post-%d", i), Status: store.JobStatusPending, RunAt: now})
+		}
+		_, err := e.EnqueueJobs(jobs)
+		assert.NoError(t, err)
+
+		// Execute
+		mu := sync.Mutex{}
+		leases := map[uint64]int{}
+		wg := sync.WaitGroup{}
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 10 {
+					// SQLite may refuse a lease while another worker writes, the job stays for the next one
+					leased, err := e.LeaseJobs(now, time.Minute, 3)
+					if err != nil {
+						continue
+					}
+					mu.Lock()
+					for _, job := range leased {
+						leases[job.ID]++
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		// Verify
+		assert.NotEmpty(t, leases)
+		for id, count := range leases {
+			assert.Equal(t, 1, count, "job %d leased more than once", id)
+			job, err := e.GetJob(id)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, job.Attempts)
+			assert.Equal(t, store.JobStatusLeased, job.Status)
+		}
+	})
+}
+
+func TestConformancePostScores(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	forEachBackend(t, func(t *testing.T, e engine) {
+		// Setup
+		_, err := e.SavePostsBulk([]*store.PostV1{
+			storetest.NewPost("1", "feed-a", now.Add(-4*time.Hour)),
+			storetest.NewPost("2", "feed-a", now.Add(-3*time.Hour)),
+			storetest.NewPost("3", "feed-b", now.Add(-2*time.Hour)),
+			storetest.NewPost("4", "feed-a", now.Add(-1*time.Hour)),
+		})
+		assert.NoError(t, err)
+
+		// Execute
+		err = e.SavePostScores([]*store.PostScoreV1{
+			{PostID: "1", Profile: "go", Score: 90, Model: "llama"},
+			{PostID: "1", Profile: "rust", Score: 10, Model: "llama"},
+			{PostID: "2", Profile: "go", Score: 40, Model: "llama"},
+			{PostID: "2", Profile: "rust", Score: 70, Model: "llama"},
+			{PostID: "3", Profile: "go", Score: 70, Model: "llama"},
+		})
+		assert.NoError(t, err)
+		// scoring again replaces the score
+		assert.NoError(t, e.SavePostScores([]*store.PostScoreV1{{PostID: "3", Profile: "go", Score: 60, Model: "llama"}}))
+
+		// Verify
+		query := func(query store.PostsQuery) []string {
+			query.Page, query.PageSize = 1, 10
+			result, err := e.QueryPosts(query)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(result.Posts)), result.Size)
+			return postIDs(result.Posts)
+		}
+		assert.Equal(t, []string{"1", "2", "3", "4"}, query(store.PostsQuery{Sort: store.SortRelevance}))
+		assert.Equal(t, []string{"1", "3", "2", "4"}, query(store.PostsQuery{Sort: store.SortRelevance, Profiles: []string{"go"}}))
+		assert.Equal(t, []string{"2", "1"}, query(store.PostsQuery{Sort: store.SortRelevance, Profiles: []string{"rust"}, MinScore: 10}))
+		assert.Equal(t, []string{"3", "2", "1"}, query(store.PostsQuery{MinScore: 50}))
+		assert.Equal(t, []string{"1"}, query(store.PostsQuery{Sort: store.SortRelevance, PartitionKeys: []string{"feed-a"}, Profiles: []string{"go"}, MinScore: 50}))
+
+		scores, err := e.GetPostScores([]string{"1", "3", "4"}, []string{"go"})
+		assert.NoError(t, err)
+		assert.Len(t, scores, 2)
+		assert.Equal(t, "1", scores[0].PostID)
+		assert.Equal(t, 90, scores[0].Score)
+		assert.Equal(t, "llama", scores[0].Model)
+		assert.Equal(t, 60, scores[1].Score)
+		scores, err = e.GetPostScores([]string{"1"}, nil)
+		assert.NoError(t, err)
+		assert.Len(t, scores, 2)
+
+		unscored, err := e.GetPostsWithoutScores([]string{"go", "rust"}, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"4", "3"}, postIDs(unscored))
+		unscored, err = e.GetPostsWithoutScores([]string{"go"}, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"4"}, postIDs(unscored))
+
+		// posts with a scoring job are left to it
+		_, err = e.EnqueueJobs([]*store.JobV1{{
+			Kind:     store.JobKindScorePost,
+			DedupKey: store.ScoreJobDedupKey("4"),
+			Post:     store.PostV1{ID: "4"},
+			Status:   store.JobStatusDead,
+			RunAt:    now,
+		}})
+		assert.NoError(t, err)
+		unscored, err = e.GetPostsWithoutScores([]string{"go", "rust"}, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"3"}, postIDs(unscored))
+
+		// changed or deleted posts lose their scores
+		changed := storetest.NewPost("2", "feed-a", now.Add(-3*time.Hour))
+		changed.Text = "Changed"
+		_, err = e.UpsertPosts([]*store.PostV1{changed})
+		assert.NoError(t, err)
+		_, err = e.DeletePosts([]string{"1"})
+		assert.NoError(t, err)
+		scores, err = e.GetPostScores([]string{"1", "2", "3"}, nil)
+		assert.NoError(t, err)
+		assert.Len(t, scores, 1)
+		assert.Equal(t, "3", scores[0].PostID)
+	})
+}
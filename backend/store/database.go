@@ -0,0 +1,1033 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+	DialectMemory   = "memory"
+)
+
+type Settings struct {
+	// DSN selects the database, postgres:// and postgresql:// URLs open PostgreSQL, memory:// keeps everything
+	// in memory, anything else is the path of a SQLite file, optionally prefixed with sqlite://
+	DSN string `yaml:"dsn"`
+}
+
+// DefaultSettings returns the settings used for the values which are not configured
+func DefaultSettings() Settings {
+	return Settings{
+		DSN: "data/rss-sum.sqlite",
+	}
+}
+
+// Validate reports every invalid setting
+func (s Settings) Validate() error {
+	if strings.TrimPrefix(s.DSN, "sqlite://") == "" {
+		return fmt.Errorf("dsn is empty")
+	}
+
+	return nil
+}
+
+// Dialect returns the database selected by the DSN
+func (s Settings) Dialect() string {
+	switch {
+	case strings.HasPrefix(s.DSN, "postgres://"), strings.HasPrefix(s.DSN, "postgresql://"):
+		return DialectPostgres
+	case s.DSN == "memory://":
+		return DialectMemory
+	default:
+		return DialectSQLite
+	}
+}
+
+// Path returns the path of the SQLite file
+func (s Settings) Path() string {
+	return strings.TrimPrefix(s.DSN, "sqlite://")
+}
+
+// ErrSnapshotUnsupported is returned when the store cannot be copied into a snapshot file
+var ErrSnapshotUnsupported = errors.New("snapshots are only supported for SQLite")
+
+// deleteBatchSize limits the number of IDs deleted by a single statement
+const deleteBatchSize = 500
+
+type Database struct {
+	db         *gorm.DB
+	dialect    string
+	migrations []Migration
+}
+
+type PaginationPostsResult struct {
+	Posts        []*PostV1
+	PartitionKey string
+	Page         int
+	PageSize     int
+	Size         int64
+}
+
+// NewDatabase opens the SQLite or PostgreSQL database selected by the DSN, the connection pool is safe to share
+func NewDatabase(settings *Settings) (*Database, error) {
+	dialect := settings.Dialect()
+	slog.Info("persistent store", "dialect", dialect)
+
+	var dialector gorm.Dialector
+	switch dialect {
+	case DialectPostgres:
+		dialector = postgres.Open(settings.DSN)
+	default:
+		dialector = sqlite.Open(settings.Path())
+	}
+
+	return openDatabase(dialector, dialect)
+}
+
+func openDatabase(dialector gorm.Dialector, dialect string) (*Database, error) {
+	migrations, err := loadMigrations(migrationFiles, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: newGormLogger(slog.Default()),
+		// unique violations of both dialects are reported as gorm.ErrDuplicatedKey
+		TranslateError: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	result := Database{db: db, dialect: dialect, migrations: migrations}
+	if err := result.checkSchemaVersion(); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *Database) GetPosts(page int, pageSize int, partitionKey string) (result *PaginationPostsResult, err error) {
+	var posts []*PostV1
+	var size int64
+	offset := (page - 1) * pageSize
+
+	if partitionKey != "" {
+		s.db.Model(&PostV1{}).Where("partition_key = ?", partitionKey).Count(&size)
+		s.db.Where("partition_key = ?", partitionKey).Order("created_at desc").Offset(offset).Limit(pageSize).Find(&posts)
+	} else {
+		s.db.Model(&PostV1{}).Count(&size)
+		s.db.Order("created_at desc").Offset(offset).Limit(pageSize).Find(&posts)
+	}
+
+	if posts == nil {
+		posts = make([]*PostV1, 0)
+	}
+
+	return &PaginationPostsResult{
+		Posts:        posts,
+		PartitionKey: partitionKey,
+		Page:         page,
+		PageSize:     pageSize,
+		Size:         size}, nil
+}
+
+// QueryPosts pages through the posts selected by the query, newest or most relevant first
+func (s *Database) QueryPosts(query PostsQuery) (*PaginationPostsResult, error) {
+	posts := make([]*PostV1, 0)
+	var size int64
+
+	if err := s.selectPosts(query).Count(&size).Error; err != nil {
+		return nil, fmt.Errorf("failed to count posts: %v", err)
+	}
+
+	order := "post_v1.created_at desc"
+	if query.Sort == SortRelevance {
+		// NULLs are ordered differently by SQLite and PostgreSQL
+		order = "COALESCE(relevance.score, -1) desc, post_v1.created_at desc"
+	}
+
+	err := s.selectPosts(query).Select("post_v1.*").Order(order).
+		Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&posts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %v", err)
+	}
+
+	return &PaginationPostsResult{
+		Posts:    posts,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Size:     size}, nil
+}
+
+// MarkPostsRead marks the unread posts selected by the query which were created until then as read by its user,
+// and returns how many were marked
+func (s *Database) MarkPostsRead(query PostsQuery, until time.Time, readAt time.Time) (int64, error) {
+	query.Unread = true
+
+	var ids []string
+	if err := s.selectPosts(query).Where("post_v1.created_at <= ?", until).Pluck("post_v1.id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to get unread posts: %v", err)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for batch := range slices.Chunk(ids, deleteBatchSize) {
+			states := make([]*PostStateV1, 0, len(batch))
+			for _, id := range batch {
+				states = append(states, &PostStateV1{UserID: query.UserID, PostID: id, ReadAt: &readAt})
+			}
+
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"read_at", "updated_at"}),
+			}).Create(&states).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark posts read: %v", err)
+	}
+
+	return int64(len(ids)), nil
+}
+
+// selectPosts filters the posts by the query, joining the post states of its user for the flags
+// and the highest score of the profiles as relevance
+func (s *Database) selectPosts(query PostsQuery) *gorm.DB {
+	db := s.db.Model(&PostV1{})
+
+	if query.Unread || query.Starred || query.ReadLater {
+		db = db.Joins("LEFT JOIN post_state_v1 ON post_state_v1.post_id = post_v1.id AND post_state_v1.user_id = ?", query.UserID)
+	}
+	if query.Sort == SortRelevance || query.MinScore > 0 {
+		relevance := s.db.Model(&PostScoreV1{}).Select("post_id, MAX(score) AS score").Group("post_id")
+		if len(query.Profiles) > 0 {
+			relevance = relevance.Where("profile IN ?", query.Profiles)
+		}
+		db = db.Joins("LEFT JOIN (?) AS relevance ON relevance.post_id = post_v1.id", relevance)
+	}
+	if len(query.PartitionKeys) > 0 {
+		db = db.Where("post_v1.partition_key IN ?", query.PartitionKeys)
+	}
+	if query.Unread {
+		db = db.Where("post_state_v1.read_at IS NULL")
+	}
+	if query.Starred {
+		db = db.Where("post_state_v1.starred_at IS NOT NULL")
+	}
+	if query.ReadLater {
+		db = db.Where("post_state_v1.read_later_at IS NOT NULL")
+	}
+	if query.MinScore > 0 {
+		db = db.Where("relevance.score >= ?", query.MinScore)
+	}
+
+	return db
+}
+
+func (s *Database) SavePostsBulk(postsToSave []*PostV1) ([]*PostV1, error) {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, postToSave := range postsToSave {
+		if err := tx.Create(postToSave).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to create posts: %v", err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit posts creation transaction: %v", err)
+	}
+
+	return postsToSave, nil
+}
+
+func (s *Database) GetPostsByIDs(ids []string) ([]*PostV1, error) {
+	posts := make([]*PostV1, 0, len(ids))
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	if err := s.db.Where("id IN ?", ids).Find(&posts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load posts: %v", err)
+	}
+
+	return posts, nil
+}
+
+// GetPostsAfter returns up to limit posts ordered by ID, the ones following afterID, of the partition unless it is empty.
+// Paging by ID neither skips nor repeats posts when others are saved meanwhile
+func (s *Database) GetPostsAfter(partitionKey string, afterID string, limit int) ([]*PostV1, error) {
+	posts := make([]*PostV1, 0, limit)
+
+	query := s.db.Where("id > ?", afterID)
+	if partitionKey != "" {
+		query = query.Where("partition_key = ?", partitionKey)
+	}
+
+	if err := query.Order("id").Limit(limit).Find(&posts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load posts: %v", err)
+	}
+
+	return posts, nil
+}
+
+// UpsertPosts creates the posts and replaces the stored ones with the same IDs, returns how many posts were created.
+// Embeddings and scores of replaced posts with another title or text are deleted, so they are embedded and scored again
+func (s *Database) UpsertPosts(posts []*PostV1) (int, error) {
+	created := 0
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for batch := range slices.Chunk(posts, deleteBatchSize) {
+			ids := make([]string, 0, len(batch))
+			for _, post := range batch {
+				ids = append(ids, post.ID)
+			}
+
+			var existing []*PostV1
+			if err := tx.Where("id IN ?", ids).Find(&existing).Error; err != nil {
+				return err
+			}
+			stale := changedPostIDs(existing, batch)
+			created += len(batch) - len(existing)
+
+			if len(stale) > 0 {
+				if err := tx.Where("post_id IN ?", stale).Delete(&PostEmbeddingV1{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Where("post_id IN ?", stale).Delete(&PostScoreV1{}).Error; err != nil {
+					return err
+				}
+			}
+
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"partition_key", "title", "text", "source_url", "model", "tags", "created_at"}),
+			}).Create(&batch).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to upsert posts: %v", err)
+	}
+
+	return created, nil
+}
+
+// GetPartitionKeys returns the partition keys of the stored posts, including the ones of removed feeds
+func (s *Database) GetPartitionKeys() ([]string, error) {
+	keys := make([]string, 0)
+	if err := s.db.Model(&PostV1{}).Distinct("partition_key").Order("partition_key").Pluck("partition_key", &keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to load partition keys: %v", err)
+	}
+
+	return keys, nil
+}
+
+// GetExpiredPosts returns the posts of the partition except its newest skip ones, only the ones created before
+// createdBefore unless it is zero, newest first. Posts starred or kept to read later by any user never expire
+func (s *Database) GetExpiredPosts(partitionKey string, skip int, createdBefore time.Time) ([]*PostV1, error) {
+	posts := make([]*PostV1, 0)
+
+	query := s.db.Where("partition_key = ?", partitionKey)
+	if skip > 0 {
+		newest := s.db.Model(&PostV1{}).Select("id").Where("partition_key = ?", partitionKey).Order("created_at desc, id").Limit(skip)
+		query = query.Where("id NOT IN (?)", newest)
+	}
+	if !createdBefore.IsZero() {
+		query = query.Where("created_at < ?", createdBefore)
+	}
+	kept := s.db.Model(&PostStateV1{}).Select("post_id").Where("starred_at IS NOT NULL OR read_later_at IS NOT NULL")
+	query = query.Where("id NOT IN (?)", kept)
+
+	if err := query.Order("created_at desc, id").Find(&posts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load expired posts: %v", err)
+	}
+
+	return posts, nil
+}
+
+// DeletePosts deletes the posts with their embeddings, scores and states, and returns how many posts were deleted
+func (s *Database) DeletePosts(ids []string) (int64, error) {
+	var deleted int64
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// keep the number of bound variables within the limits of SQLite
+		for batch := range slices.Chunk(ids, deleteBatchSize) {
+			if err := tx.Where("post_id IN ?", batch).Delete(&PostEmbeddingV1{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id IN ?", batch).Delete(&PostScoreV1{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id IN ?", batch).Delete(&PostStateV1{}).Error; err != nil {
+				return err
+			}
+
+			result := tx.Where("id IN ?", batch).Delete(&PostV1{})
+			if result.Error != nil {
+				return result.Error
+			}
+			deleted += result.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete posts: %v", err)
+	}
+
+	return deleted, nil
+}
+
+// Optimize reclaims the space of deleted rows and refreshes the query planner statistics,
+// PostgreSQL reclaims space with autovacuum
+func (s *Database) Optimize() error {
+	statements := []string{"VACUUM", "ANALYZE"}
+	if s.dialect == DialectPostgres {
+		statements = []string{"ANALYZE"}
+	}
+
+	for _, statement := range statements {
+		if err := s.db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to run %s: %v", statement, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Database) GetPostsWithoutEmbedding(model string, limit int) ([]*PostV1, error) {
+	posts := make([]*PostV1, 0)
+
+	err := s.db.
+		Where("NOT EXISTS (?)", s.db.Model(&PostEmbeddingV1{}).
+			Select("1").
+			Where("post_embedding_v1.post_id = post_v1.id AND post_embedding_v1.model = ?", model)).
+		Limit(limit).
+		Find(&posts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load posts without embedding: %v", err)
+	}
+
+	return posts, nil
+}
+
+func (s *Database) GetPostEmbedding(postID string) (*PostEmbeddingV1, error) {
+	var embedding PostEmbeddingV1
+	if err := s.db.Where("post_id = ?", postID).First(&embedding).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to load post embedding: %v", err)
+	}
+
+	return &embedding, nil
+}
+
+func (s *Database) GetPostEmbeddings(model string) ([]*PostEmbeddingV1, error) {
+	embeddings := make([]*PostEmbeddingV1, 0)
+	if err := s.db.Where("model = ?", model).Find(&embeddings).Error; err != nil {
+		return nil, fmt.Errorf("failed to load post embeddings: %v", err)
+	}
+
+	return embeddings, nil
+}
+
+func (s *Database) SavePostEmbedding(embedding *PostEmbeddingV1) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "post_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"model", "vector", "created_at"}),
+	}).Create(embedding).Error
+	if err != nil {
+		return fmt.Errorf("failed to save post embedding: %v", err)
+	}
+
+	return nil
+}
+
+// GetPostsWithoutScores returns up to limit posts, newest first, which miss the score of any of the profiles
+// and have no scoring job, so posts whose jobs are pending or dead do not hold back older ones
+func (s *Database) GetPostsWithoutScores(profiles []string, limit int) ([]*PostV1, error) {
+	posts := make([]*PostV1, 0)
+	if len(profiles) == 0 {
+		return posts, nil
+	}
+
+	scored := s.db.Model(&PostScoreV1{}).
+		Select("COUNT(*)").
+		Where("post_score_v1.post_id = post_v1.id AND post_score_v1.profile IN ?", profiles)
+	queued := s.db.Model(&JobV1{}).
+		Select("1").
+		Where("job_v1.dedup_key = ? || post_v1.id", ScoreJobDedupKey(""))
+	err := s.db.
+		Where("(?) < ?", scored, len(profiles)).
+		Where("NOT EXISTS (?)", queued).
+		Order("created_at desc").
+		Limit(limit).
+		Find(&posts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load posts without scores: %v", err)
+	}
+
+	return posts, nil
+}
+
+// GetPostScores returns the scores of the posts for the profiles, for all profiles when there are none
+func (s *Database) GetPostScores(postIDs []string, profiles []string) ([]*PostScoreV1, error) {
+	scores := make([]*PostScoreV1, 0)
+	if len(postIDs) == 0 {
+		return scores, nil
+	}
+
+	query := s.db.Where("post_id IN ?", postIDs)
+	if len(profiles) > 0 {
+		query = query.Where("profile IN ?", profiles)
+	}
+	if err := query.Order("post_id, profile").Find(&scores).Error; err != nil {
+		return nil, fmt.Errorf("failed to get post scores: %v", err)
+	}
+
+	return scores, nil
+}
+
+// SavePostScores creates the scores and replaces the stored ones of the same posts and profiles
+func (s *Database) SavePostScores(scores []*PostScoreV1) error {
+	if len(scores) == 0 {
+		return nil
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "post_id"}, {Name: "profile"}},
+		DoUpdates: clause.AssignmentColumns([]string{"score", "model", "created_at"}),
+	}).Create(&scores).Error
+	if err != nil {
+		return fmt.Errorf("failed to save post scores: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Database) GetFeeds() ([]*FeedV1, error) {
+	feeds := make([]*FeedV1, 0)
+	if err := s.db.Order("next_fetch_at").Find(&feeds).Error; err != nil {
+		return nil, fmt.Errorf("failed to load feeds: %v", err)
+	}
+
+	return feeds, nil
+}
+
+func (s *Database) SaveFeed(feed *FeedV1) error {
+	if err := s.db.Save(feed).Error; err != nil {
+		return fmt.Errorf("failed to save feed: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Database) GetFeed(id string) (*FeedV1, error) {
+	var feed FeedV1
+	if err := s.db.Where("id = ?", id).First(&feed).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to load feed: %v", err)
+	}
+
+	return &feed, nil
+}
+
+func (s *Database) GetFeedHealth(feedID string) (*FeedHealthV1, error) {
+	var health FeedHealthV1
+	if err := s.db.Where("feed_id = ?", feedID).First(&health).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to load feed health: %v", err)
+	}
+
+	return &health, nil
+}
+
+func (s *Database) GetFeedsHealth() ([]*FeedHealthV1, error) {
+	healths := make([]*FeedHealthV1, 0)
+	if err := s.db.Find(&healths).Error; err != nil {
+		return nil, fmt.Errorf("failed to load feeds health: %v", err)
+	}
+
+	return healths, nil
+}
+
+func (s *Database) SaveFeedHealth(health *FeedHealthV1) error {
+	if err := s.db.Save(health).Error; err != nil {
+		return fmt.Errorf("failed to save feed health: %v", err)
+	}
+
+	return nil
+}
+
+// ResetFeedItemsSummarized creates the health of the feed when it has none and sets its summarized items to zero,
+// the other columns are left alone
+func (s *Database) ResetFeedItemsSummarized(feedID string) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "feed_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"items_summarized", "updated_at"}),
+	}).Create(&FeedHealthV1{FeedID: feedID}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reset summarized items: %v", err)
+	}
+
+	return nil
+}
+
+// SaveFeedRun writes the outcome of a fetch to the health of the feed, creating it when it has none.
+// The summarized items are counted up by the jobs concurrently and are never written
+func (s *Database) SaveFeedRun(health *FeedHealthV1) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "feed_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"last_success_at", "last_error_at", "last_error", "consecutive_failures",
+			"last_status_code", "items_seen", "items_new", "updated_at",
+		}),
+	}).Create(health).Error
+	if err != nil {
+		return fmt.Errorf("failed to save feed run: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Database) IncrementFeedItemsSummarized(feedID string) error {
+	err := s.db.Model(&FeedHealthV1{}).
+		Where("feed_id = ?", feedID).
+		UpdateColumn("items_summarized", gorm.Expr("items_summarized + ?", 1)).Error
+	if err != nil {
+		return fmt.Errorf("failed to increment summarized items: %v", err)
+	}
+
+	return nil
+}
+
+// EnqueueJobs creates the jobs, skipping the ones whose dedup key is already queued, and returns how many were created
+func (s *Database) EnqueueJobs(jobs []*JobV1) (int, error) {
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+
+	result := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "dedup_key"}},
+		DoNothing: true,
+	}).Create(&jobs)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to enqueue jobs: %v", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// LeaseJobs marks due pending jobs and jobs with expired leases as leased until now+lease, higher priorities first
+func (s *Database) LeaseJobs(now time.Time, lease time.Duration, limit int) ([]*JobV1, error) {
+	candidates := make([]*JobV1, 0)
+	jobs := make([]*JobV1, 0)
+
+	leaseJobs := func(tx *gorm.DB) error {
+		query := tx
+		if s.dialect == DialectPostgres {
+			// concurrent workers lease different jobs instead of waiting for each other
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		err := query.
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND leased_until <= ?)",
+				JobStatusPending, now, JobStatusLeased, now).
+			Order("priority desc, run_at").
+			Limit(limit).
+			Find(&candidates).Error
+		if err != nil {
+			return err
+		}
+
+		// a job is only leased while it is still due, one leased by another worker meanwhile is skipped
+		leasedUntil := now.Add(lease)
+		for _, job := range candidates {
+			result := tx.Model(&JobV1{}).
+				Where("id = ? AND ((status = ? AND run_at <= ?) OR (status = ? AND leased_until <= ?))",
+					job.ID, JobStatusPending, now, JobStatusLeased, now).
+				Updates(map[string]any{
+					"status":       JobStatusLeased,
+					"leased_until": leasedUntil,
+					"attempts":     gorm.Expr("attempts + 1"),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected != 1 {
+				continue
+			}
+
+			job.Status = JobStatusLeased
+			job.LeasedUntil = &leasedUntil
+			job.Attempts++
+			jobs = append(jobs, job)
+		}
+
+		return nil
+	}
+
+	var err error
+	if s.dialect == DialectPostgres {
+		err = s.db.Transaction(leaseJobs)
+	} else {
+		// SQLite locks the whole database, a transaction reading before it writes fails when another one writes meanwhile
+		err = leaseJobs(s.db)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease jobs: %v", err)
+	}
+
+	return jobs, nil
+}
+
+func (s *Database) GetJob(id uint64) (*JobV1, error) {
+	var job JobV1
+	if err := s.db.Where("id = ?", id).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to load job: %v", err)
+	}
+
+	return &job, nil
+}
+
+func (s *Database) GetJobsByStatus(status string) ([]*JobV1, error) {
+	jobs := make([]*JobV1, 0)
+	if err := s.db.Where("status = ?", status).Order("updated_at desc").Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %v", err)
+	}
+
+	return jobs, nil
+}
+
+func (s *Database) SaveJob(job *JobV1) error {
+	if err := s.db.Save(job).Error; err != nil {
+		return fmt.Errorf("failed to save job: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Database) DeleteJob(id uint64) error {
+	if err := s.db.Delete(&JobV1{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete job: %v", err)
+	}
+
+	return nil
+}
+
+// CreateUser creates the user, ErrAlreadyExists is returned when the name is taken
+func (s *Database) CreateUser(user *UserV1) error {
+	// the unique index on the name decides, a check before creating would race with concurrent requests
+	if err := s.db.Create(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("user %q %w", user.Name, ErrAlreadyExists)
+		}
+		return fmt.Errorf("failed to create user: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Database) GetUser(id uint64) (*UserV1, error) {
+	var user UserV1
+	if err := s.db.Where("id = ?", id).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to load user: %v", err)
+	}
+
+	return &user, nil
+}
+
+func (s *Database) GetUserByName(name string) (*UserV1, error) {
+	var user UserV1
+	if err := s.db.Where("name = ?", name).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to load user: %v", err)
+	}
+
+	return &user, nil
+}
+
+// GetUsers returns all users ordered by name
+func (s *Database) GetUsers() ([]*UserV1, error) {
+	users := make([]*UserV1, 0)
+	if err := s.db.Order("name").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to load users: %v", err)
+	}
+
+	return users, nil
+}
+
+func (s *Database) SaveUser(user *UserV1) error {
+	if err := s.db.Save(user).Error; err != nil {
+		return fmt.Errorf("failed to save user: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteUser deletes the user with its sessions, API tokens, subscriptions and post states
+func (s *Database) DeleteUser(id uint64) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&SessionV1{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&APITokenV1{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&SubscriptionV1{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&PostStateV1{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&UserV1{}, id).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Database) SaveSession(session *SessionV1) error {
+	if err := s.db.Save(session).Error; err != nil {
+		return fmt.Errorf("failed to save session: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Database) GetSession(id string) (*SessionV1, error) {
+	var session SessionV1
+	if err := s.db.Where("id = ?", id).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to load session: %v", err)
+	}
+
+	return &session, nil
+}
+
+func (s *Database) DeleteSession(id string) error {
+	if err := s.db.Where("id = ?", id).Delete(&SessionV1{}).Error; err != nil {
+		return fmt.Errorf("failed to delete session: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions which expired at or before now and returns how many were deleted
+func (s *Database) DeleteExpiredSessions(now time.Time) (int64, error) {
+	result := s.db.Where("expires_at <= ?", now).Delete(&SessionV1{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %v", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (s *Database) SaveAPIToken(token *APITokenV1) error {
+	if err := s.db.Save(token).Error; err != nil {
+		return fmt.Errorf("failed to save api token: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Database) GetAPITokenByHash(hash string) (*APITokenV1, error) {
+	var token APITokenV1
+	if err := s.db.Where("hash = ?", hash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to load api token: %v", err)
+	}
+
+	return &token, nil
+}
+
+// GetAPITokens returns the API tokens of the user, oldest first
+func (s *Database) GetAPITokens(userID uint64) ([]*APITokenV1, error) {
+	tokens := make([]*APITokenV1, 0)
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to load api tokens: %v", err)
+	}
+
+	return tokens, nil
+}
+
+func (s *Database) DeleteAPIToken(id uint64) error {
+	if err := s.db.Delete(&APITokenV1{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete api token: %v", err)
+	}
+
+	return nil
+}
+
+// SaveSubscription subscribes a user to a feed, subscribing twice keeps the first subscription
+func (s *Database) SaveSubscription(subscription *SubscriptionV1) error {
+	err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(subscription).Error
+	if err != nil {
+		return fmt.Errorf("failed to save subscription: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteSubscription unsubscribes a user from a feed, ErrNotFound when they were not subscribed
+func (s *Database) DeleteSubscription(userID uint64, feedID string) error {
+	result := s.db.Where("user_id = ? AND feed_id = ?", userID, feedID).Delete(&SubscriptionV1{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete subscription: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetSubscriptions returns the subscriptions of a user ordered by feed ID
+func (s *Database) GetSubscriptions(userID uint64) ([]*SubscriptionV1, error) {
+	var subscriptions []*SubscriptionV1
+	if err := s.db.Where("user_id = ?", userID).Order("feed_id").Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %v", err)
+	}
+
+	return subscriptions, nil
+}
+
+// SavePostState creates or replaces the state, the anonymous user has the zero ID so it is upserted rather than saved
+func (s *Database) SavePostState(state *PostStateV1) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"read_at", "starred_at", "read_later_at", "updated_at"}),
+	}).Create(state).Error
+	if err != nil {
+		return fmt.Errorf("failed to save post state: %v", err)
+	}
+
+	return nil
+}
+
+// GetPostStates returns the states of the posts the user did something with
+func (s *Database) GetPostStates(userID uint64, postIDs []string) ([]*PostStateV1, error) {
+	states := make([]*PostStateV1, 0)
+	if len(postIDs) == 0 {
+		return states, nil
+	}
+
+	if err := s.db.Where("user_id = ? AND post_id IN ?", userID, postIDs).Find(&states).Error; err != nil {
+		return nil, fmt.Errorf("failed to get post states: %v", err)
+	}
+
+	return states, nil
+}
+
+// CountJobsByStatus counts queued jobs, every known status is present even without jobs
+func (s *Database) CountJobsByStatus() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+
+	if err := s.db.Model(&JobV1{}).Select("status, count(*) as count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %v", err)
+	}
+
+	counts := map[string]int64{
+		JobStatusPending: 0,
+		JobStatusLeased:  0,
+		JobStatusDead:    0,
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+
+	return counts, nil
+}
+
+// DatabaseSize returns the size of the database in bytes
+func (s *Database) DatabaseSize() (int64, error) {
+	query := "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+	if s.dialect == DialectPostgres {
+		query = "SELECT pg_database_size(current_database())"
+	}
+
+	var size int64
+	if err := s.db.Raw(query).Scan(&size).Error; err != nil {
+		return 0, fmt.Errorf("failed to read database size: %v", err)
+	}
+
+	return size, nil
+}
+
+// Snapshot writes a consistent copy of the SQLite database to a new file while it is in use,
+// PostgreSQL is backed up with pg_dump
+func (s *Database) Snapshot(path string) error {
+	if s.dialect != DialectSQLite {
+		return fmt.Errorf("%w, use pg_dump for %s", ErrSnapshotUnsupported, s.dialect)
+	}
+
+	if err := s.db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// Close closes the connection pool
+func (s *Database) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get connection pool: %v", err)
+	}
+
+	return sqlDB.Close()
+}
+
+// Ping checks that the database can be queried
+func (s *Database) Ping() error {
+	if err := s.db.Exec("SELECT 1").Error; err != nil {
+		return fmt.Errorf("failed to query database: %v", err)
+	}
+
+	return nil
+}
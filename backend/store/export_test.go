@@ -0,0 +1,7 @@
+package store
+
+// ForEachDialect and NewTestDatabase let the tests in store_test, which share the storetest fixtures, open test databases
+var (
+	ForEachDialect  = forEachDialect
+	NewTestDatabase = newTestDatabase
+)
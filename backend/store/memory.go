@@ -0,0 +1,1128 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory keeps everything in memory, for tests and ephemeral runs. It follows the ordering, pagination
+// and conflict handling of Database, records are copied in and out so callers can't change them in place
+type Memory struct {
+	mu sync.RWMutex
+
+	posts      map[string]*PostV1
+	postOrder  []string
+	embeddings map[string]*PostEmbeddingV1
+	feeds      map[string]*FeedV1
+	healths    map[string]*FeedHealthV1
+	jobs       map[uint64]*JobV1
+	lastJobID  uint64
+
+	users       map[uint64]*UserV1
+	lastUserID  uint64
+	sessions    map[string]*SessionV1
+	tokens      map[uint64]*APITokenV1
+	lastTokenID uint64
+
+	// subscriptions by user and feed ID
+	subscriptions map[uint64]map[string]*SubscriptionV1
+	// states of posts by user and post ID
+	states map[uint64]map[string]*PostStateV1
+	// scores of posts by post ID and profile
+	scores map[string]map[string]*PostScoreV1
+}
+
+// NewMemory makes an empty in-memory store
+func NewMemory() *Memory {
+	slog.Info("in-memory (ephemeral) store")
+	return &Memory{
+		posts:      make(map[string]*PostV1),
+		embeddings: make(map[string]*PostEmbeddingV1),
+		scores:     make(map[string]map[string]*PostScoreV1),
+		feeds:      make(map[string]*FeedV1),
+		healths:    make(map[string]*FeedHealthV1),
+		jobs:       make(map[uint64]*JobV1),
+		users:      make(map[uint64]*UserV1),
+		sessions:   make(map[string]*SessionV1),
+		tokens:     make(map[uint64]*APITokenV1),
+
+		subscriptions: make(map[uint64]map[string]*SubscriptionV1),
+		states:        make(map[uint64]map[string]*PostStateV1),
+	}
+}
+
+// Migrate has nothing to do, the in-memory store has no schema
+func (m *Memory) Migrate() error {
+	return nil
+}
+
+func (m *Memory) GetPosts(page int, pageSize int, partitionKey string) (*PaginationPostsResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filtered := make([]*PostV1, 0)
+	for _, id := range m.postOrder {
+		if post := m.posts[id]; partitionKey == "" || post.PartitionKey == partitionKey {
+			filtered = append(filtered, post)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].CreatedAt.After(filtered[j].CreatedAt) })
+
+	posts := make([]*PostV1, 0)
+	for i := max((page-1)*pageSize, 0); i < len(filtered) && len(posts) < pageSize; i++ {
+		posts = append(posts, copyPost(filtered[i]))
+	}
+
+	return &PaginationPostsResult{
+		Posts:        posts,
+		PartitionKey: partitionKey,
+		Page:         page,
+		PageSize:     pageSize,
+		Size:         int64(len(filtered))}, nil
+}
+
+// QueryPosts pages through the posts selected by the query, newest or most relevant first
+func (m *Memory) QueryPosts(query PostsQuery) (*PaginationPostsResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filtered := m.selectPosts(query)
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].CreatedAt.After(filtered[j].CreatedAt) })
+	if query.Sort == SortRelevance {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return m.relevance(filtered[i].ID, query.Profiles) > m.relevance(filtered[j].ID, query.Profiles)
+		})
+	}
+
+	posts := make([]*PostV1, 0)
+	for i := max((query.Page-1)*query.PageSize, 0); i < len(filtered) && len(posts) < query.PageSize; i++ {
+		posts = append(posts, copyPost(filtered[i]))
+	}
+
+	return &PaginationPostsResult{
+		Posts:    posts,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Size:     int64(len(filtered))}, nil
+}
+
+// MarkPostsRead marks the unread posts selected by the query which were created until then as read by its user,
+// and returns how many were marked
+func (m *Memory) MarkPostsRead(query PostsQuery, until time.Time, readAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query.Unread = true
+
+	var marked int64
+	for _, post := range m.selectPosts(query) {
+		if post.CreatedAt.After(until) {
+			continue
+		}
+
+		state := m.postState(query.UserID, post.ID)
+		state.ReadAt = &readAt
+		state.UpdatedAt = time.Now()
+		marked++
+	}
+
+	return marked, nil
+}
+
+// selectPosts filters the posts by the query, m.mu has to be held
+func (m *Memory) selectPosts(query PostsQuery) []*PostV1 {
+	partitions := make(map[string]bool, len(query.PartitionKeys))
+	for _, partitionKey := range query.PartitionKeys {
+		partitions[partitionKey] = true
+	}
+
+	filtered := make([]*PostV1, 0)
+	for _, id := range m.postOrder {
+		post := m.posts[id]
+		if len(partitions) > 0 && !partitions[post.PartitionKey] {
+			continue
+		}
+
+		state := m.states[query.UserID][id]
+		if state == nil {
+			state = &PostStateV1{}
+		}
+		if (query.Unread && state.ReadAt != nil) || (query.Starred && state.StarredAt == nil) ||
+			(query.ReadLater && state.ReadLaterAt == nil) {
+			continue
+		}
+		if query.MinScore > 0 && m.relevance(id, query.Profiles) < query.MinScore {
+			continue
+		}
+
+		filtered = append(filtered, post)
+	}
+
+	return filtered
+}
+
+// relevance returns the highest score of the post for the profiles, -1 without any, m.mu has to be held
+func (m *Memory) relevance(postID string, profiles []string) int {
+	relevance := -1
+	for profile, score := range m.scores[postID] {
+		if len(profiles) == 0 || slices.Contains(profiles, profile) {
+			relevance = max(relevance, score.Score)
+		}
+	}
+	return relevance
+}
+
+// postState returns the stored state of a post, creating it when the user did nothing with the post yet,
+// m.mu has to be held
+func (m *Memory) postState(userID uint64, postID string) *PostStateV1 {
+	states, ok := m.states[userID]
+	if !ok {
+		states = make(map[string]*PostStateV1)
+		m.states[userID] = states
+	}
+
+	state, ok := states[postID]
+	if !ok {
+		state = &PostStateV1{UserID: userID, PostID: postID}
+		states[postID] = state
+	}
+
+	return state
+}
+
+// SavePostsBulk creates all posts or none of them, like the transaction of Database
+func (m *Memory) SavePostsBulk(postsToSave []*PostV1) ([]*PostV1, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make(map[string]bool, len(postsToSave))
+	for _, post := range postsToSave {
+		if _, ok := m.posts[post.ID]; ok || ids[post.ID] {
+			return nil, fmt.Errorf("failed to create posts: post %s already exists", post.ID)
+		}
+		ids[post.ID] = true
+	}
+
+	now := time.Now()
+	for _, post := range postsToSave {
+		if post.CreatedAt.IsZero() {
+			post.CreatedAt = now
+		}
+		m.posts[post.ID] = copyPost(post)
+		m.postOrder = append(m.postOrder, post.ID)
+	}
+
+	return postsToSave, nil
+}
+
+func (m *Memory) GetPostsByIDs(ids []string) ([]*PostV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := make([]*PostV1, 0, len(ids))
+	for _, id := range ids {
+		if post, ok := m.posts[id]; ok {
+			posts = append(posts, copyPost(post))
+		}
+	}
+
+	return posts, nil
+}
+
+// GetPostsAfter returns up to limit posts ordered by ID, the ones following afterID, of the partition unless it is empty
+func (m *Memory) GetPostsAfter(partitionKey string, afterID string, limit int) ([]*PostV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0)
+	for id, post := range m.posts {
+		if id > afterID && (partitionKey == "" || post.PartitionKey == partitionKey) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	posts := make([]*PostV1, 0, min(limit, len(ids)))
+	for _, id := range ids[:min(limit, len(ids))] {
+		posts = append(posts, copyPost(m.posts[id]))
+	}
+
+	return posts, nil
+}
+
+// UpsertPosts creates the posts and replaces the stored ones with the same IDs, returns how many posts were created.
+// Embeddings and scores of replaced posts with another title or text are deleted, so they are embedded and scored again
+func (m *Memory) UpsertPosts(posts []*PostV1) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stored []*PostV1
+	for _, post := range posts {
+		if existing, ok := m.posts[post.ID]; ok {
+			stored = append(stored, existing)
+		}
+	}
+	for _, id := range changedPostIDs(stored, posts) {
+		delete(m.embeddings, id)
+		delete(m.scores, id)
+	}
+
+	created := 0
+	now := time.Now()
+	for _, post := range posts {
+		if post.CreatedAt.IsZero() {
+			post.CreatedAt = now
+		}
+		if _, ok := m.posts[post.ID]; !ok {
+			m.postOrder = append(m.postOrder, post.ID)
+			created++
+		}
+		m.posts[post.ID] = copyPost(post)
+	}
+
+	return created, nil
+}
+
+// GetPartitionKeys returns the partition keys of the stored posts, including the ones of removed feeds
+func (m *Memory) GetPartitionKeys() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]string, 0)
+	for _, post := range m.posts {
+		if !slices.Contains(keys, post.PartitionKey) {
+			keys = append(keys, post.PartitionKey)
+		}
+	}
+	slices.Sort(keys)
+
+	return keys, nil
+}
+
+// GetExpiredPosts returns the posts of the partition except its newest skip ones, only the ones created before
+// createdBefore unless it is zero, newest first
+func (m *Memory) GetExpiredPosts(partitionKey string, skip int, createdBefore time.Time) ([]*PostV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	kept := make(map[string]bool)
+	for _, states := range m.states {
+		for _, state := range states {
+			if state.StarredAt != nil || state.ReadLaterAt != nil {
+				kept[state.PostID] = true
+			}
+		}
+	}
+
+	partition := make([]*PostV1, 0)
+	for _, post := range m.posts {
+		if post.PartitionKey == partitionKey {
+			partition = append(partition, post)
+		}
+	}
+	sort.Slice(partition, func(i, j int) bool {
+		if partition[i].CreatedAt.Equal(partition[j].CreatedAt) {
+			return partition[i].ID < partition[j].ID
+		}
+		return partition[i].CreatedAt.After(partition[j].CreatedAt)
+	})
+
+	posts := make([]*PostV1, 0)
+	for _, post := range partition[min(skip, len(partition)):] {
+		if (createdBefore.IsZero() || post.CreatedAt.Before(createdBefore)) && !kept[post.ID] {
+			posts = append(posts, copyPost(post))
+		}
+	}
+
+	return posts, nil
+}
+
+// DeletePosts deletes the posts with their embeddings, scores and states, and returns how many posts were deleted
+func (m *Memory) DeletePosts(ids []string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for _, id := range ids {
+		if _, ok := m.posts[id]; ok {
+			delete(m.posts, id)
+			deleted++
+		}
+		delete(m.embeddings, id)
+		delete(m.scores, id)
+		for _, states := range m.states {
+			delete(states, id)
+		}
+	}
+	m.postOrder = slices.DeleteFunc(m.postOrder, func(id string) bool {
+		_, ok := m.posts[id]
+		return !ok
+	})
+
+	return deleted, nil
+}
+
+// Optimize has nothing to do, deleted records are released right away
+func (m *Memory) Optimize() error {
+	return nil
+}
+
+func (m *Memory) GetPostsWithoutEmbedding(model string, limit int) ([]*PostV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := make([]*PostV1, 0)
+	for _, id := range m.postOrder {
+		if len(posts) >= limit {
+			break
+		}
+		if embedding, ok := m.embeddings[id]; ok && embedding.Model == model {
+			continue
+		}
+		posts = append(posts, copyPost(m.posts[id]))
+	}
+
+	return posts, nil
+}
+
+func (m *Memory) GetPostEmbedding(postID string) (*PostEmbeddingV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	embedding, ok := m.embeddings[postID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return copyEmbedding(embedding), nil
+}
+
+func (m *Memory) GetPostEmbeddings(model string) ([]*PostEmbeddingV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	embeddings := make([]*PostEmbeddingV1, 0)
+	for _, id := range m.postOrder {
+		if embedding, ok := m.embeddings[id]; ok && embedding.Model == model {
+			embeddings = append(embeddings, copyEmbedding(embedding))
+		}
+	}
+
+	return embeddings, nil
+}
+
+// SavePostEmbedding replaces the embedding of the post, if there is one
+func (m *Memory) SavePostEmbedding(embedding *PostEmbeddingV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if embedding.CreatedAt.IsZero() {
+		embedding.CreatedAt = time.Now()
+	}
+	m.embeddings[embedding.PostID] = copyEmbedding(embedding)
+
+	return nil
+}
+
+// GetPostsWithoutScores returns up to limit posts, newest first, which miss the score of any of the profiles
+// GetPostsWithoutScores returns up to limit posts, newest first, which miss the score of any of the profiles
+// and have no scoring job
+func (m *Memory) GetPostsWithoutScores(profiles []string, limit int) ([]*PostV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := make([]*PostV1, 0)
+	if len(profiles) == 0 {
+		return posts, nil
+	}
+
+	ordered := make([]*PostV1, 0, len(m.postOrder))
+	for _, id := range m.postOrder {
+		ordered = append(ordered, m.posts[id])
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].CreatedAt.After(ordered[j].CreatedAt) })
+
+	queued := make(map[string]bool, len(m.jobs))
+	for _, job := range m.jobs {
+		queued[job.DedupKey] = true
+	}
+
+	for _, post := range ordered {
+		if len(posts) >= limit {
+			break
+		}
+		if queued[ScoreJobDedupKey(post.ID)] {
+			continue
+		}
+		for _, profile := range profiles {
+			if _, ok := m.scores[post.ID][profile]; !ok {
+				posts = append(posts, copyPost(post))
+				break
+			}
+		}
+	}
+
+	return posts, nil
+}
+
+// GetPostScores returns the scores of the posts for the profiles, for all profiles when there are none
+func (m *Memory) GetPostScores(postIDs []string, profiles []string) ([]*PostScoreV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := make([]*PostScoreV1, 0)
+	for _, id := range postIDs {
+		for profile, score := range m.scores[id] {
+			if len(profiles) == 0 || slices.Contains(profiles, profile) {
+				copied := *score
+				scores = append(scores, &copied)
+			}
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].PostID != scores[j].PostID {
+			return scores[i].PostID < scores[j].PostID
+		}
+		return scores[i].Profile < scores[j].Profile
+	})
+
+	return scores, nil
+}
+
+// SavePostScores creates the scores and replaces the stored ones of the same posts and profiles
+func (m *Memory) SavePostScores(scores []*PostScoreV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, score := range scores {
+		if score.CreatedAt.IsZero() {
+			score.CreatedAt = now
+		}
+		if m.scores[score.PostID] == nil {
+			m.scores[score.PostID] = make(map[string]*PostScoreV1)
+		}
+		copied := *score
+		m.scores[score.PostID][score.Profile] = &copied
+	}
+
+	return nil
+}
+
+func (m *Memory) GetFeeds() ([]*FeedV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	feeds := make([]*FeedV1, 0, len(m.feeds))
+	for _, feed := range m.feeds {
+		feeds = append(feeds, copyFeed(feed))
+	}
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].NextFetchAt.Before(feeds[j].NextFetchAt) })
+
+	return feeds, nil
+}
+
+func (m *Memory) SaveFeed(feed *FeedV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if feed.CreatedAt.IsZero() {
+		feed.CreatedAt = now
+	}
+	feed.UpdatedAt = now
+	m.feeds[feed.ID] = copyFeed(feed)
+
+	return nil
+}
+
+func (m *Memory) GetFeed(id string) (*FeedV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	feed, ok := m.feeds[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return copyFeed(feed), nil
+}
+
+func (m *Memory) GetFeedHealth(feedID string) (*FeedHealthV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	health, ok := m.healths[feedID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return copyHealth(health), nil
+}
+
+func (m *Memory) GetFeedsHealth() ([]*FeedHealthV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	healths := make([]*FeedHealthV1, 0, len(m.healths))
+	for _, health := range m.healths {
+		healths = append(healths, copyHealth(health))
+	}
+	sort.Slice(healths, func(i, j int) bool { return healths[i].FeedID < healths[j].FeedID })
+
+	return healths, nil
+}
+
+func (m *Memory) SaveFeedHealth(health *FeedHealthV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health.UpdatedAt = time.Now()
+	m.healths[health.FeedID] = copyHealth(health)
+
+	return nil
+}
+
+// ResetFeedItemsSummarized creates the health of the feed when it has none and sets its summarized items to zero
+func (m *Memory) ResetFeedItemsSummarized(feedID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health, ok := m.healths[feedID]
+	if !ok {
+		health = &FeedHealthV1{FeedID: feedID}
+		m.healths[feedID] = health
+	}
+	health.ItemsSummarized = 0
+	health.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// SaveFeedRun writes the outcome of a fetch to the health of the feed, creating it when it has none.
+// The summarized items are left alone
+func (m *Memory) SaveFeedRun(health *FeedHealthV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health.UpdatedAt = time.Now()
+	saved := copyHealth(health)
+	if stored, ok := m.healths[health.FeedID]; ok {
+		saved.ItemsSummarized = stored.ItemsSummarized
+	}
+	m.healths[health.FeedID] = saved
+
+	return nil
+}
+
+func (m *Memory) IncrementFeedItemsSummarized(feedID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if health, ok := m.healths[feedID]; ok {
+		health.ItemsSummarized++
+	}
+
+	return nil
+}
+
+// EnqueueJobs creates the jobs, skipping the ones whose dedup key is already queued, and returns how many were created
+func (m *Memory) EnqueueJobs(jobs []*JobV1) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queued := make(map[string]bool, len(m.jobs))
+	for _, job := range m.jobs {
+		queued[job.DedupKey] = true
+	}
+
+	now := time.Now()
+	created := 0
+	for _, job := range jobs {
+		if queued[job.DedupKey] {
+			continue
+		}
+		queued[job.DedupKey] = true
+
+		m.lastJobID++
+		job.ID = m.lastJobID
+		if job.CreatedAt.IsZero() {
+			job.CreatedAt = now
+		}
+		if job.UpdatedAt.IsZero() {
+			job.UpdatedAt = now
+		}
+		m.jobs[job.ID] = copyJob(job)
+		created++
+	}
+
+	return created, nil
+}
+
+// LeaseJobs marks due pending jobs and jobs with expired leases as leased until now+lease, higher priorities first
+func (m *Memory) LeaseJobs(now time.Time, lease time.Duration, limit int) ([]*JobV1, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	due := make([]*JobV1, 0)
+	for _, job := range m.jobs {
+		pending := job.Status == JobStatusPending && !job.RunAt.After(now)
+		expired := job.Status == JobStatusLeased && job.LeasedUntil != nil && !job.LeasedUntil.After(now)
+		if pending || expired {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].Priority != due[j].Priority {
+			return due[i].Priority > due[j].Priority
+		}
+		if due[i].RunAt.Equal(due[j].RunAt) {
+			return due[i].ID < due[j].ID
+		}
+		return due[i].RunAt.Before(due[j].RunAt)
+	})
+
+	leasedUntil := now.Add(lease)
+	jobs := make([]*JobV1, 0, min(limit, len(due)))
+	for _, job := range due[:min(limit, len(due))] {
+		job.Status = JobStatusLeased
+		job.LeasedUntil = copyTime(&leasedUntil)
+		job.Attempts++
+		job.UpdatedAt = time.Now()
+		jobs = append(jobs, copyJob(job))
+	}
+
+	return jobs, nil
+}
+
+func (m *Memory) GetJob(id uint64) (*JobV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return copyJob(job), nil
+}
+
+func (m *Memory) GetJobsByStatus(status string) ([]*JobV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := make([]*JobV1, 0)
+	for _, job := range m.jobs {
+		if job.Status == status {
+			jobs = append(jobs, copyJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].UpdatedAt.After(jobs[j].UpdatedAt) })
+
+	return jobs, nil
+}
+
+func (m *Memory) SaveJob(job *JobV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if job.ID == 0 {
+		m.lastJobID++
+		job.ID = m.lastJobID
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+	m.jobs[job.ID] = copyJob(job)
+
+	return nil
+}
+
+func (m *Memory) DeleteJob(id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.jobs, id)
+
+	return nil
+}
+
+// CreateUser creates the user, ErrAlreadyExists is returned when the name is taken
+func (m *Memory) CreateUser(user *UserV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.users {
+		if existing.Name == user.Name {
+			return fmt.Errorf("user %q %w", user.Name, ErrAlreadyExists)
+		}
+	}
+
+	now := time.Now()
+	m.lastUserID++
+	user.ID = m.lastUserID
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	m.users[user.ID] = copyUser(user)
+
+	return nil
+}
+
+func (m *Memory) GetUser(id uint64) (*UserV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+func (m *Memory) GetUserByName(name string) (*UserV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Name == name {
+			return copyUser(user), nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// GetUsers returns all users ordered by name
+func (m *Memory) GetUsers() ([]*UserV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]*UserV1, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, copyUser(user))
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	return users, nil
+}
+
+func (m *Memory) SaveUser(user *UserV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if user.ID == 0 {
+		m.lastUserID++
+		user.ID = m.lastUserID
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	m.users[user.ID] = copyUser(user)
+
+	return nil
+}
+
+// DeleteUser deletes the user with its sessions, API tokens, subscriptions and post states
+func (m *Memory) DeleteUser(id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for sessionID, session := range m.sessions {
+		if session.UserID == id {
+			delete(m.sessions, sessionID)
+		}
+	}
+	for tokenID, token := range m.tokens {
+		if token.UserID == id {
+			delete(m.tokens, tokenID)
+		}
+	}
+	delete(m.subscriptions, id)
+	delete(m.states, id)
+	delete(m.users, id)
+
+	return nil
+}
+
+func (m *Memory) SaveSession(session *SessionV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	result := *session
+	m.sessions[session.ID] = &result
+
+	return nil
+}
+
+func (m *Memory) GetSession(id string) (*SessionV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	result := *session
+	return &result, nil
+}
+
+func (m *Memory) DeleteSession(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions which expired at or before now and returns how many were deleted
+func (m *Memory) DeleteExpiredSessions(now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for id, session := range m.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(m.sessions, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (m *Memory) SaveAPIToken(token *APITokenV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if token.ID == 0 {
+		m.lastTokenID++
+		token.ID = m.lastTokenID
+	}
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	m.tokens[token.ID] = copyToken(token)
+
+	return nil
+}
+
+func (m *Memory) GetAPITokenByHash(hash string) (*APITokenV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, token := range m.tokens {
+		if token.Hash == hash {
+			return copyToken(token), nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// GetAPITokens returns the API tokens of the user, oldest first
+func (m *Memory) GetAPITokens(userID uint64) ([]*APITokenV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tokens := make([]*APITokenV1, 0)
+	for _, token := range m.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, copyToken(token))
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+
+	return tokens, nil
+}
+
+func (m *Memory) DeleteAPIToken(id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tokens, id)
+
+	return nil
+}
+
+// CountJobsByStatus counts queued jobs, every known status is present even without jobs
+func (m *Memory) CountJobsByStatus() (map[string]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := map[string]int64{
+		JobStatusPending: 0,
+		JobStatusLeased:  0,
+		JobStatusDead:    0,
+	}
+	for _, job := range m.jobs {
+		counts[job.Status]++
+	}
+
+	return counts, nil
+}
+
+// Snapshot is not supported, the in-memory store is gone with the process
+func (m *Memory) Snapshot(path string) error {
+	return fmt.Errorf("%w, the in-memory store has no file", ErrSnapshotUnsupported)
+}
+
+// DatabaseSize is always 0, nothing is written to disk
+func (m *Memory) DatabaseSize() (int64, error) {
+	return 0, nil
+}
+
+// Ping always succeeds
+func (m *Memory) Ping() error {
+	return nil
+}
+
+// SaveSubscription subscribes a user to a feed, subscribing twice keeps the first subscription
+func (m *Memory) SaveSubscription(subscription *SubscriptionV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feeds, ok := m.subscriptions[subscription.UserID]
+	if !ok {
+		feeds = make(map[string]*SubscriptionV1)
+		m.subscriptions[subscription.UserID] = feeds
+	}
+	if _, exists := feeds[subscription.FeedID]; exists {
+		return nil
+	}
+
+	if subscription.CreatedAt.IsZero() {
+		subscription.CreatedAt = time.Now()
+	}
+	stored := *subscription
+	feeds[subscription.FeedID] = &stored
+
+	return nil
+}
+
+// DeleteSubscription unsubscribes a user from a feed, ErrNotFound when they were not subscribed
+func (m *Memory) DeleteSubscription(userID uint64, feedID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.subscriptions[userID][feedID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.subscriptions[userID], feedID)
+
+	return nil
+}
+
+// GetSubscriptions returns the subscriptions of a user ordered by feed ID
+func (m *Memory) GetSubscriptions(userID uint64) ([]*SubscriptionV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	subscriptions := make([]*SubscriptionV1, 0, len(m.subscriptions[userID]))
+	for _, subscription := range m.subscriptions[userID] {
+		stored := *subscription
+		subscriptions = append(subscriptions, &stored)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].FeedID < subscriptions[j].FeedID })
+
+	return subscriptions, nil
+}
+
+func (m *Memory) SavePostState(state *PostStateV1) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state.UpdatedAt = time.Now()
+	stored := m.postState(state.UserID, state.PostID)
+	*stored = *copyPostState(state)
+
+	return nil
+}
+
+// GetPostStates returns the states of the posts the user did something with
+func (m *Memory) GetPostStates(userID uint64, postIDs []string) ([]*PostStateV1, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	states := make([]*PostStateV1, 0)
+	for _, id := range postIDs {
+		if state, ok := m.states[userID][id]; ok {
+			states = append(states, copyPostState(state))
+		}
+	}
+
+	return states, nil
+}
+
+func copyPost(post *PostV1) *PostV1 {
+	result := *post
+	return &result
+}
+
+func copyEmbedding(embedding *PostEmbeddingV1) *PostEmbeddingV1 {
+	result := *embedding
+	result.Vector = append([]float64(nil), embedding.Vector...)
+	return &result
+}
+
+func copyFeed(feed *FeedV1) *FeedV1 {
+	result := *feed
+	result.LastFetchedAt = copyTime(feed.LastFetchedAt)
+	result.DisabledAt = copyTime(feed.DisabledAt)
+	return &result
+}
+
+func copyHealth(health *FeedHealthV1) *FeedHealthV1 {
+	result := *health
+	result.LastSuccessAt = copyTime(health.LastSuccessAt)
+	result.LastErrorAt = copyTime(health.LastErrorAt)
+	return &result
+}
+
+func copyJob(job *JobV1) *JobV1 {
+	result := *job
+	result.LeasedUntil = copyTime(job.LeasedUntil)
+	return &result
+}
+
+func copyUser(user *UserV1) *UserV1 {
+	result := *user
+	return &result
+}
+
+func copyToken(token *APITokenV1) *APITokenV1 {
+	result := *token
+	result.LastUsedAt = copyTime(token.LastUsedAt)
+	return &result
+}
+
+func copyPostState(state *PostStateV1) *PostStateV1 {
+	copied := *state
+	copied.ReadAt = copyTime(state.ReadAt)
+	copied.StarredAt = copyTime(state.StarredAt)
+	copied.ReadLaterAt = copyTime(state.ReadLaterAt)
+	return &copied
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	result := *t
+	return &result
+}
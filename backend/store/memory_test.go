@@ -0,0 +1,46 @@
+package store_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/store/storetest"
+)
+
+func TestMemoryConcurrency(t *testing.T) {
+	// Setup
+	memory := store.NewMemory()
+	now := time.Now()
+	wg := sync.WaitGroup{}
+
+	// Execute
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprint(i)
+			_, err := memory.SavePostsBulk([]*store.PostV1{storetest.NewPost(id, "feed", now)})
+			assert.NoError(t, err)
+			_, err = memory.EnqueueJobs([]*store.JobV1{{Kind: store.JobKindSummarizePost, DedupKey: id, Status: store.JobStatusPending, RunAt: now}})
+			assert.NoError(t, err)
+			_, err = memory.LeaseJobs(now, time.Minute, 1)
+			assert.NoError(t, err)
+			_, err = memory.GetPosts(1, 5, "")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// Verify
+	result, err := memory.GetPosts(1, 5, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), result.Size)
+	counts, err := memory.CountJobsByStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), counts[store.JobStatusLeased])
+}
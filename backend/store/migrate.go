@@ -0,0 +1,231 @@
+package store
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+const migrationsTable = "schema_migrations"
+
+var reMigrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer version of the service
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of the service")
+
+// Migration is a numbered schema change, Down reverts Up
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaStatus compares the version of the database with the migrations known by the service
+type SchemaStatus struct {
+	Version int
+	Latest  int
+	Pending []Migration
+}
+
+// migrationRecord is a row of the schema_migrations table
+type migrationRecord struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+func (migrationRecord) TableName() string {
+	return migrationsTable
+}
+
+// loadMigrations reads the migrations of the dialect named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// versions have to be numbered from 1 without gaps
+func loadMigrations(fsys fs.FS, dialect string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/"+dialect+"/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %v", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no migrations for %s", dialect)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		name := path.Base(file)
+		match := reMigrationFile.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.<up|down>.sql", name)
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", name, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+	}
+
+	return migrations, nil
+}
+
+// SchemaVersion returns the version of the latest applied migration, 0 for an empty database
+func (s *Database) SchemaVersion() (int, error) {
+	if !s.db.Migrator().HasTable(migrationsTable) {
+		return 0, nil
+	}
+
+	var version int
+	if err := s.db.Model(&migrationRecord{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	return version, nil
+}
+
+// SchemaStatus reports the version of the database and the migrations which are not applied yet
+func (s *Database) SchemaStatus() (*SchemaStatus, error) {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &SchemaStatus{Version: version, Latest: len(s.migrations)}
+	if version < len(s.migrations) {
+		status.Pending = s.migrations[version:]
+	}
+
+	return status, nil
+}
+
+// checkSchemaVersion refuses databases migrated further than the migrations known by the service,
+// their schema may not match the models anymore
+func (s *Database) checkSchemaVersion() error {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if version > len(s.migrations) {
+		return fmt.Errorf("%w: database is at version %d, latest known is %d", ErrSchemaTooNew, version, len(s.migrations))
+	}
+
+	return nil
+}
+
+// Migrate applies all pending migrations
+func (s *Database) Migrate() error {
+	slog.Info("migrating database")
+
+	if _, err := s.MigrateUp(false); err != nil {
+		return err
+	}
+
+	slog.Info("database migrated")
+	return nil
+}
+
+// MigrateUp applies the pending migrations in order and returns them, a dry run only returns them
+func (s *Database) MigrateUp(dryRun bool) ([]Migration, error) {
+	status, err := s.SchemaStatus()
+	if err != nil {
+		return nil, err
+	}
+	if dryRun || len(status.Pending) == 0 {
+		return status.Pending, nil
+	}
+
+	if err := s.db.AutoMigrate(&migrationRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to create %s table: %v", migrationsTable, err)
+	}
+
+	for _, migration := range status.Pending {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&migrationRecord{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply migration %d_%s: %v", migration.Version, migration.Name, err)
+		}
+
+		slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	return status.Pending, nil
+}
+
+// MigrateDown reverts the latest applied migrations, at most steps of them, and returns them in the order
+// they were reverted, a dry run only returns them
+func (s *Database) MigrateDown(steps int, dryRun bool) ([]Migration, error) {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := make([]Migration, 0, steps)
+	for v := version; v > 0 && len(reverted) < steps; v-- {
+		reverted = append(reverted, s.migrations[v-1])
+	}
+	if dryRun {
+		return reverted, nil
+	}
+
+	for _, migration := range reverted {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&migrationRecord{}, migration.Version).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to revert migration %d_%s: %v", migration.Version, migration.Name, err)
+		}
+
+		slog.Info("reverted migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	return reverted, nil
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// autoMigratedModels were created by AutoMigrate before versioned migrations
+var autoMigratedModels = []any{&PostV1{}, &PostEmbeddingV1{}, &FeedV1{}, &FeedHealthV1{}, &JobV1{}}
+
+var models = append(autoMigratedModels, &UserV1{}, &SessionV1{}, &APITokenV1{}, &SubscriptionV1{}, &PostStateV1{}, &PostScoreV1{})
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Embedded", func(t *testing.T) {
+		sqliteMigrations, err := loadMigrations(migrationFiles, DialectSQLite)
+		assert.NoError(t, err)
+		postgresMigrations, err := loadMigrations(migrationFiles, DialectPostgres)
+		assert.NoError(t, err)
+
+		// every schema change is written for both dialects
+		assert.NotEmpty(t, sqliteMigrations)
+		assert.Equal(t, "initial", sqliteMigrations[0].Name)
+		assert.Equal(t, len(sqliteMigrations), len(postgresMigrations))
+		for i := range sqliteMigrations {
+			assert.Equal(t, sqliteMigrations[i].Name, postgresMigrations[i].Name)
+		}
+	})
+
+	tbl := []struct {
+		name  string
+		files fstest.MapFS
+		err   string
+	}{
+		{"MissingDown", fstest.MapFS{
+			"migrations/sqlite/0001_initial.up.sql": {Data: []byte("SELECT 1")},
+		}, "migration 1_initial needs both up and down files"},
+		{"Gap", fstest.MapFS{
+			"migrations/sqlite/0001_initial.up.sql":   {Data: []byte("SELECT 1")},
+			"migrations/sqlite/0001_initial.down.sql": {Data: []byte("SELECT 1")},
+			"migrations/sqlite/0003_later.up.sql":     {Data: []byte("SELECT 1")},
+			"migrations/sqlite/0003_later.down.sql":   {Data: []byte("SELECT 1")},
+		}, "migration 2 is missing"},
+		{"BadName", fstest.MapFS{
+			"migrations/sqlite/initial.sql": {Data: []byte("SELECT 1")},
+		}, "migration initial.sql is not named <version>_<name>.<up|down>.sql"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.files, DialectSQLite)
+
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dialect string) {
+		t.Run("MatchesModels", func(t *testing.T) {
+			// Setup
+			database, _ := newTestDatabase(t, dialect)
+
+			// Execute
+			err := database.Migrate()
+
+			// Verify
+			assert.NoError(t, err)
+			version, err := database.SchemaVersion()
+			assert.NoError(t, err)
+			assert.Equal(t, len(database.migrations), version)
+
+			for _, model := range models {
+				stmt := &gorm.Statement{DB: database.db}
+				assert.NoError(t, stmt.Parse(model))
+				assert.True(t, database.db.Migrator().HasTable(model), stmt.Schema.Table)
+				for _, field := range stmt.Schema.Fields {
+					if field.DBName != "" {
+						assert.True(t, database.db.Migrator().HasColumn(model, field.DBName), "%s.%s", stmt.Schema.Table, field.DBName)
+					}
+				}
+			}
+		})
+
+		t.Run("AdoptsAutoMigratedDatabase", func(t *testing.T) {
+			// Setup
+			database, _ := newTestDatabase(t, dialect)
+			assert.NoError(t, database.db.AutoMigrate(autoMigratedModels...))
+			// the models of the service before versioned migrations had no model, tags or priority columns
+			assert.NoError(t, database.db.Migrator().DropColumn(&PostV1{}, "model"))
+			assert.NoError(t, database.db.Migrator().DropColumn(&PostV1{}, "tags"))
+			assert.NoError(t, database.db.Migrator().DropColumn(&JobV1{}, "priority"))
+			assert.NoError(t, database.db.Omit("Model", "Tags").Create(&PostV1{ID: "1", PartitionKey: "feed", Title: "Title", Text: "Text", SourceURL: "http://example.com"}).Error)
+
+			// Execute
+			err := database.Migrate()
+
+			// Verify
+			assert.NoError(t, err)
+			posts, err := database.GetPostsByIDs([]string{"1"})
+			assert.NoError(t, err)
+			assert.Len(t, posts, 1)
+		})
+
+		t.Run("DryRun", func(t *testing.T) {
+			// Setup
+			database, _ := newTestDatabase(t, dialect)
+
+			// Execute
+			pending, err := database.MigrateUp(true)
+
+			// Verify
+			assert.NoError(t, err)
+			assert.Equal(t, database.migrations, pending)
+			assert.False(t, database.db.Migrator().HasTable(migrationsTable))
+			assert.False(t, database.db.Migrator().HasTable(&PostV1{}))
+		})
+
+		t.Run("Down", func(t *testing.T) {
+			// Setup
+			database, _ := newTestDatabase(t, dialect)
+			assert.NoError(t, database.Migrate())
+			latest := len(database.migrations)
+
+			// Execute
+			planned, err := database.MigrateDown(latest, true)
+			assert.NoError(t, err)
+			reverted, err := database.MigrateDown(latest, false)
+
+			// Verify
+			assert.NoError(t, err)
+			assert.Equal(t, planned, reverted)
+			assert.Equal(t, latest, reverted[0].Version)
+			version, err := database.SchemaVersion()
+			assert.NoError(t, err)
+			assert.Equal(t, 0, version)
+			assert.False(t, database.db.Migrator().HasTable(&PostV1{}))
+		})
+
+		t.Run("SchemaTooNew", func(t *testing.T) {
+			// Setup
+			database, reopen := newTestDatabase(t, dialect)
+			assert.NoError(t, database.Migrate())
+			assert.NoError(t, database.db.Create(&migrationRecord{Version: 999, Name: "future"}).Error)
+
+			// Execute
+			_, err := reopen()
+
+			// Verify
+			assert.ErrorIs(t, err, ErrSchemaTooNew)
+		})
+	})
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS job_v1;
+DROP TABLE IF EXISTS feed_health_v1;
+DROP TABLE IF EXISTS feed_v1;
+DROP TABLE IF EXISTS post_embedding_v1;
+DROP TABLE IF EXISTS post_v1;
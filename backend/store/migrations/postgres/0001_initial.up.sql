@@ -0,0 +1,15 @@
+-- Schema created by GORM AutoMigrate before versioned migrations, IF NOT EXISTS adopts those databases as they are
+CREATE TABLE IF NOT EXISTS post_v1 (id text,partition_key text NOT NULL,title varchar(500) NOT NULL,text varchar(4000) NOT NULL,source_url text NOT NULL,created_at timestamptz,PRIMARY KEY (id));
+
+CREATE TABLE IF NOT EXISTS post_embedding_v1 (post_id text,model text NOT NULL,vector text NOT NULL,created_at timestamptz,PRIMARY KEY (post_id));
+CREATE INDEX IF NOT EXISTS idx_post_embedding_v1_model ON post_embedding_v1 (model);
+
+CREATE TABLE IF NOT EXISTS feed_v1 (id text,url text NOT NULL,interval_in_seconds bigint NOT NULL,next_fetch_at timestamptz NOT NULL,last_fetched_at timestamptz,disabled boolean NOT NULL DEFAULT false,disabled_at timestamptz,created_at timestamptz,updated_at timestamptz,PRIMARY KEY (id));
+CREATE INDEX IF NOT EXISTS idx_feed_v1_next_fetch_at ON feed_v1 (next_fetch_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feed_v1_url ON feed_v1 (url);
+
+CREATE TABLE IF NOT EXISTS feed_health_v1 (feed_id text,last_success_at timestamptz,last_error_at timestamptz,last_error varchar(1000),consecutive_failures bigint NOT NULL DEFAULT 0,last_status_code bigint,items_seen bigint,items_new bigint,items_summarized bigint,updated_at timestamptz,PRIMARY KEY (feed_id));
+
+CREATE TABLE IF NOT EXISTS job_v1 (id bigserial,kind varchar(50) NOT NULL,dedup_key text NOT NULL,post text NOT NULL,status varchar(20) NOT NULL,run_at timestamptz NOT NULL,leased_until timestamptz,attempts bigint NOT NULL DEFAULT 0,last_error varchar(1000),created_at timestamptz,updated_at timestamptz,PRIMARY KEY (id));
+CREATE INDEX IF NOT EXISTS idx_job_v1_status_run_at ON job_v1 (status,run_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_v1_dedup_key ON job_v1 (dedup_key);
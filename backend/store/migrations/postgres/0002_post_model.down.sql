@@ -0,0 +1 @@
+ALTER TABLE post_v1 DROP COLUMN model;
@@ -0,0 +1 @@
+ALTER TABLE post_v1 ADD COLUMN model varchar(200) NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+CREATE TABLE user_v1 (id bigserial,name varchar(100) NOT NULL,password_hash varchar(100) NOT NULL,role varchar(20) NOT NULL,created_at timestamptz,updated_at timestamptz,PRIMARY KEY (id));
+CREATE UNIQUE INDEX idx_user_v1_name ON user_v1 (name);
+
+CREATE TABLE session_v1 (id text,user_id bigint NOT NULL,expires_at timestamptz NOT NULL,created_at timestamptz,PRIMARY KEY (id));
+CREATE INDEX idx_session_v1_user_id ON session_v1 (user_id);
+CREATE INDEX idx_session_v1_expires_at ON session_v1 (expires_at);
+
+CREATE TABLE api_token_v1 (id bigserial,user_id bigint NOT NULL,name varchar(100) NOT NULL,hash text NOT NULL,last_used_at timestamptz,created_at timestamptz,PRIMARY KEY (id));
+CREATE INDEX idx_api_token_v1_user_id ON api_token_v1 (user_id);
+CREATE UNIQUE INDEX idx_api_token_v1_hash ON api_token_v1 (hash);
@@ -0,0 +1,2 @@
+CREATE TABLE post_state_v1 (user_id bigint,post_id text,read_at timestamptz,starred_at timestamptz,read_later_at timestamptz,updated_at timestamptz,PRIMARY KEY (user_id,post_id));
+CREATE INDEX idx_post_state_v1_post_id ON post_state_v1 (post_id);
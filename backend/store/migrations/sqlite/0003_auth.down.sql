@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `api_token_v1`;
+DROP TABLE IF EXISTS `session_v1`;
+DROP TABLE IF EXISTS `user_v1`;
@@ -0,0 +1,10 @@
+CREATE TABLE `user_v1` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` varchar(100) NOT NULL,`password_hash` varchar(100) NOT NULL,`role` varchar(20) NOT NULL,`created_at` datetime,`updated_at` datetime);
+CREATE UNIQUE INDEX `idx_user_v1_name` ON `user_v1`(`name`);
+
+CREATE TABLE `session_v1` (`id` text,`user_id` integer NOT NULL,`expires_at` datetime NOT NULL,`created_at` datetime,PRIMARY KEY (`id`));
+CREATE INDEX `idx_session_v1_user_id` ON `session_v1`(`user_id`);
+CREATE INDEX `idx_session_v1_expires_at` ON `session_v1`(`expires_at`);
+
+CREATE TABLE `api_token_v1` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` integer NOT NULL,`name` varchar(100) NOT NULL,`hash` text NOT NULL,`last_used_at` datetime,`created_at` datetime);
+CREATE INDEX `idx_api_token_v1_user_id` ON `api_token_v1`(`user_id`);
+CREATE UNIQUE INDEX `idx_api_token_v1_hash` ON `api_token_v1`(`hash`);
@@ -0,0 +1,2 @@
+CREATE TABLE `subscription_v1` (`user_id` integer,`feed_id` text,`created_at` datetime,PRIMARY KEY (`user_id`,`feed_id`));
+CREATE INDEX `idx_subscription_v1_feed_id` ON `subscription_v1`(`feed_id`);
@@ -0,0 +1,2 @@
+CREATE TABLE `post_state_v1` (`user_id` integer,`post_id` text,`read_at` datetime,`starred_at` datetime,`read_later_at` datetime,`updated_at` datetime,PRIMARY KEY (`user_id`,`post_id`));
+CREATE INDEX `idx_post_state_v1_post_id` ON `post_state_v1`(`post_id`);
@@ -0,0 +1,2 @@
+ALTER TABLE `job_v1` DROP COLUMN `priority`;
+ALTER TABLE `post_v1` DROP COLUMN `tags`;
@@ -0,0 +1,2 @@
+ALTER TABLE `post_v1` ADD COLUMN `tags` varchar(500) NOT NULL DEFAULT '';
+ALTER TABLE `job_v1` ADD COLUMN `priority` integer NOT NULL DEFAULT 0;
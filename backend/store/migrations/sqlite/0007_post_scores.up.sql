@@ -0,0 +1,2 @@
+CREATE TABLE `post_score_v1` (`post_id` text,`profile` varchar(100),`score` integer NOT NULL,`model` varchar(200) NOT NULL DEFAULT '',`created_at` datetime,PRIMARY KEY (`post_id`,`profile`));
+CREATE INDEX `idx_post_score_v1_profile` ON `post_score_v1`(`profile`);
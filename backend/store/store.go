@@ -1,9 +1,23 @@
 package store
 
 import (
+	"errors"
+	"strings"
 	"time"
 )
 
+// ErrNotFound is returned when the requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyExists is returned when creating a record whose unique key is taken
+var ErrAlreadyExists = errors.New("already exists")
+
+// SavedLinksPartitionKey groups posts submitted by hand rather than fetched from a feed
+const SavedLinksPartitionKey = "saved-links"
+
+// AnonymousUserID owns the post states when authentication is disabled
+const AnonymousUserID uint64 = 0
+
 type PostV1 struct {
 	ID           string `gorm:"primaryKey"`
 	PartitionKey string `gorm:"not null"`
@@ -11,6 +25,254 @@ type PostV1 struct {
 	Title     string `gorm:"type:varchar(500);not null"`
 	Text      string `gorm:"type:varchar(4000);not null"`
 	SourceURL string `gorm:"not null"`
+	// Model summarized the post, empty for posts summarized before it was recorded
+	Model string `gorm:"type:varchar(200);not null;default:''"`
+	// Tags are added by the feed filters which matched the item, comma separated
+	Tags string `gorm:"type:varchar(500);not null;default:''"`
+
+	CreatedAt time.Time
+}
+
+// TagList returns the tags of the post, empty when it has none
+func (p *PostV1) TagList() []string {
+	if p.Tags == "" {
+		return nil
+	}
+	return strings.Split(p.Tags, ",")
+}
+
+type PostEmbeddingV1 struct {
+	PostID string `gorm:"primaryKey"`
+	Model  string `gorm:"not null;index"`
+
+	Vector []float64 `gorm:"serializer:json;not null"`
+
+	CreatedAt time.Time
+}
+
+// FeedV1 keeps the polling schedule of a subscribed feed, ID matches the partition key of its posts
+type FeedV1 struct {
+	ID  string `gorm:"primaryKey"`
+	URL string `gorm:"not null;uniqueIndex"`
+
+	IntervalInSeconds int       `gorm:"not null"`
+	NextFetchAt       time.Time `gorm:"not null;index"`
+	LastFetchedAt     *time.Time
+
+	// Disabled feeds are skipped by scheduled fetches until enabled again
+	Disabled   bool `gorm:"not null;default:false"`
+	DisabledAt *time.Time
 
 	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// FeedHealthV1 records the outcome of the latest fetches of a feed
+type FeedHealthV1 struct {
+	FeedID string `gorm:"primaryKey"`
+
+	LastSuccessAt       *time.Time
+	LastErrorAt         *time.Time
+	LastError           string `gorm:"type:varchar(1000)"`
+	ConsecutiveFailures int    `gorm:"not null;default:0"`
+	LastStatusCode      int
+
+	// Items counters of the latest run
+	ItemsSeen       int
+	ItemsNew        int
+	ItemsSummarized int
+
+	UpdatedAt time.Time
+}
+
+const (
+	// JobKindSummarizePost summarizes an item fetched from a feed
+	JobKindSummarizePost = "summarize_post"
+	// JobKindSaveLink extracts and summarizes a submitted web page
+	JobKindSaveLink = "save_link"
+	// JobKindScorePost scores a stored post for the interest profiles
+	JobKindScorePost = "score_post"
+)
+
+// ScoreJobDedupKey deduplicates the scoring jobs of the post, a dead one keeps the post from being scored again
+// until it is requeued
+func ScoreJobDedupKey(postID string) string {
+	return JobKindScorePost + ":" + postID
+}
+
+const (
+	JobStatusPending = "pending"
+	JobStatusLeased  = "leased"
+	JobStatusDead    = "dead"
+)
+
+// JobV1 is a unit of summarization work, completed jobs are deleted.
+// DedupKey deduplicates jobs of the same item while they are pending, leased or dead
+type JobV1 struct {
+	ID       uint64 `gorm:"primaryKey;autoIncrement"`
+	Kind     string `gorm:"type:varchar(50);not null"`
+	DedupKey string `gorm:"not null;uniqueIndex"`
+
+	// Post holds the item to summarize, only SourceURL is set for submitted links
+	Post PostV1 `gorm:"serializer:json;not null"`
+
+	Status      string    `gorm:"type:varchar(20);not null;index:idx_job_v1_status_run_at"`
+	RunAt       time.Time `gorm:"not null;index:idx_job_v1_status_run_at"`
+	LeasedUntil *time.Time
+	Attempts    int    `gorm:"not null;default:0"`
+	LastError   string `gorm:"type:varchar(1000)"`
+	// Priority jobs are leased before the ones with a lower one, regardless of when they are due
+	Priority int `gorm:"not null;default:0"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+const (
+	// RoleReader reads posts, searches, asks and submits links
+	RoleReader = "reader"
+	// RoleAdmin additionally manages users, feeds, jobs and backups
+	RoleAdmin = "admin"
+)
+
+// UserV1 is a local account, PasswordHash is a bcrypt hash
+type UserV1 struct {
+	ID           uint64 `gorm:"primaryKey;autoIncrement"`
+	Name         string `gorm:"type:varchar(100);not null;uniqueIndex"`
+	PasswordHash string `gorm:"type:varchar(100);not null"`
+	Role         string `gorm:"type:varchar(20);not null"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SessionV1 is a signed in browser, ID is the SHA-256 hash of its cookie so a leaked database holds no usable sessions
+type SessionV1 struct {
+	ID        string    `gorm:"primaryKey"`
+	UserID    uint64    `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+
+	CreatedAt time.Time
+}
+
+// APITokenV1 authenticates API clients of a user, Hash is the SHA-256 hash of the token
+type APITokenV1 struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement"`
+	UserID     uint64 `gorm:"not null;index"`
+	Name       string `gorm:"type:varchar(100);not null"`
+	Hash       string `gorm:"not null;uniqueIndex"`
+	LastUsedAt *time.Time
+
+	CreatedAt time.Time
+}
+
+// SubscriptionV1 links a user to a feed, the timeline of a user holds the posts of their subscribed feeds
+type SubscriptionV1 struct {
+	UserID uint64 `gorm:"primaryKey;autoIncrement:false"`
+	FeedID string `gorm:"primaryKey;index"`
+
+	CreatedAt time.Time
+}
+
+// Flags of PostStateV1
+const (
+	PostFlagRead      = "read"
+	PostFlagStarred   = "starred"
+	PostFlagReadLater = "read-later"
+)
+
+// PostStateV1 records what a user did with a post, a flag is set when its time is
+type PostStateV1 struct {
+	UserID uint64 `gorm:"primaryKey;autoIncrement:false"`
+	PostID string `gorm:"primaryKey;index"`
+
+	ReadAt      *time.Time
+	StarredAt   *time.Time
+	ReadLaterAt *time.Time
+
+	UpdatedAt time.Time
+}
+
+// Set sets or clears a flag at now, false for an unknown flag
+func (s *PostStateV1) Set(flag string, on bool, now time.Time) bool {
+	var at **time.Time
+	switch flag {
+	case PostFlagRead:
+		at = &s.ReadAt
+	case PostFlagStarred:
+		at = &s.StarredAt
+	case PostFlagReadLater:
+		at = &s.ReadLaterAt
+	default:
+		return false
+	}
+
+	if !on {
+		*at = nil
+	} else if *at == nil {
+		*at = &now
+	}
+	return true
+}
+
+// MaxScore is the score of a post which matches an interest profile perfectly
+const MaxScore = 100
+
+// PostScoreV1 is the relevance of a post to an interest profile, from 0 to MaxScore
+type PostScoreV1 struct {
+	PostID  string `gorm:"primaryKey"`
+	Profile string `gorm:"primaryKey;type:varchar(100);index"`
+	Score   int    `gorm:"not null"`
+	// Model scored the post
+	Model string `gorm:"type:varchar(200);not null;default:''"`
+
+	CreatedAt time.Time
+}
+
+// Orders of PostsQuery
+const (
+	SortNewest    = "newest"
+	SortRelevance = "relevance"
+)
+
+// PostsQuery selects a page of posts newest first, the flags filter by the post states of the user.
+// The relevance of a post is its highest score of the profiles, posts without a score come last
+type PostsQuery struct {
+	UserID        uint64
+	PartitionKeys []string // empty selects all partitions
+	Unread        bool
+	Starred       bool
+	ReadLater     bool
+	Profiles      []string // empty scores the posts by all profiles
+	Sort          string   // SortNewest or SortRelevance, empty is SortNewest
+	MinScore      int      // posts less relevant are left out, 0 keeps the posts without a score
+	Page          int
+	PageSize      int
+}
+
+// changedPostIDs returns the IDs of the stored posts whose title or text differ from the ones to save,
+// their embeddings and scores are outdated
+func changedPostIDs(stored []*PostV1, posts []*PostV1) []string {
+	byID := make(map[string]*PostV1, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+
+	var ids []string
+	for _, post := range stored {
+		if update, ok := byID[post.ID]; ok && (update.Title != post.Title || update.Text != post.Text) {
+			ids = append(ids, post.ID)
+		}
+	}
+	return ids
+}
+
+type FeedStatus struct {
+	Feed   *FeedV1
+	Health *FeedHealthV1
+}
+
+type ScoredPost struct {
+	Post  *PostV1
+	Score float64
 }
@@ -0,0 +1,60 @@
+// Package storetest has the fixtures shared by the tests of the store package and the packages built on it
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rjxby/rss-sum/backend/blogger"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+const (
+	// FeedID is the partition key of the posts of the seeded feed
+	FeedID = "feed"
+	// FeedURL is the url of the seeded feed
+	FeedURL = "https://example.com/rss"
+)
+
+// Seeder is the part of blogger.BloggerProc used to seed a store
+type Seeder interface {
+	SaveFeed(ctx context.Context, feed *store.FeedV1) error
+	SavePostsBulk(ctx context.Context, postsToSave []*store.PostV1) ([]*store.PostV1, error)
+}
+
+// NewPost builds a post with a title, text and url derived from its id
+func NewPost(id, partitionKey string, createdAt time.Time) *store.PostV1 {
+	return &store.PostV1{
+		ID:           id,
+		PartitionKey: partitionKey,
+		Title:        "Title " + id,
+		Text:         "Text " + id,
+		SourceURL:    "https://example.com/" + id,
+		CreatedAt:    createdAt,
+	}
+}
+
+// NewBlogger returns a blogger over an empty in-memory store seeded with the feed and the posts
+func NewBlogger(t *testing.T, posts ...*store.PostV1) *blogger.BloggerProc {
+	t.Helper()
+	proc := blogger.New(store.NewMemory())
+	Seed(t, proc, posts...)
+	return proc
+}
+
+// Seed saves the feed and the posts, failing the test on error
+func Seed(t *testing.T, seeder Seeder, posts ...*store.PostV1) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := seeder.SaveFeed(ctx, &store.FeedV1{ID: FeedID, URL: FeedURL, NextFetchAt: time.Now()}); err != nil {
+		t.Fatalf("Failed to save feed: %v", err)
+	}
+	if len(posts) == 0 {
+		return
+	}
+	if _, err := seeder.SavePostsBulk(ctx, posts); err != nil {
+		t.Fatalf("Failed to save posts: %v", err)
+	}
+}
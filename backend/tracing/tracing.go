@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const serviceName = "rss-sum"
+
+type Settings struct {
+	Exporter    string  `yaml:"exporter"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// DefaultSettings returns the settings used for the values which are not configured
+func DefaultSettings() Settings {
+	return Settings{
+		Exporter:    ExporterNone,
+		SampleRatio: 1,
+	}
+}
+
+// Validate reports every invalid setting
+func (s Settings) Validate() error {
+	var errs []error
+
+	if s.Exporter != ExporterNone && s.Exporter != ExporterStdout && s.Exporter != ExporterOTLP {
+		errs = append(errs, fmt.Errorf("exporter should be %q, %q or %q", ExporterNone, ExporterStdout, ExporterOTLP))
+	}
+	if s.SampleRatio < 0 || s.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("sample_ratio should be between 0 and 1"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Setup installs the global tracer provider, the returned func flushes pending spans on shutdown.
+// OTLP exporter reads its endpoint and headers from the standard OTEL_EXPORTER_OTLP_* variables
+func Setup(ctx context.Context, settings *Settings, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch settings.Exporter {
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		// global provider stays a no-op
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %v", settings.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(settings.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Error records err on the span and returns it, so it can wrap return statements
+func Error(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("DefaultValues", func(t *testing.T) {
+		settings := DefaultSettings()
+
+		assert.NoError(t, settings.Validate())
+		assert.Equal(t, ExporterNone, settings.Exporter)
+		assert.Equal(t, 1.0, settings.SampleRatio)
+	})
+
+	t.Run("InvalidExporter", func(t *testing.T) {
+		settings := DefaultSettings()
+		settings.Exporter = "zipkin"
+
+		assert.ErrorContains(t, settings.Validate(), "exporter should be")
+	})
+
+	t.Run("InvalidSampleRatio", func(t *testing.T) {
+		settings := DefaultSettings()
+		settings.SampleRatio = 2
+
+		assert.ErrorContains(t, settings.Validate(), "sample_ratio should be between 0 and 1")
+	})
+}
+
+func TestError(t *testing.T) {
+	// Setup
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	// Execute
+	_, okSpan := tracer.Start(context.Background(), "ok")
+	okErr := Error(okSpan, nil)
+	okSpan.End()
+
+	_, failedSpan := tracer.Start(context.Background(), "failed")
+	failedErr := Error(failedSpan, errors.New("boom"))
+	failedSpan.End()
+
+	// Verify
+	assert.NoError(t, okErr)
+	assert.EqualError(t, failedErr, "boom")
+
+	spans := recorder.Ended()
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "boom", spans[1].Status().Description)
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rjxby/rss-sum/backend/assistant"
+	"github.com/rjxby/rss-sum/backend/auth"
+	"github.com/rjxby/rss-sum/backend/backup"
+	"github.com/rjxby/rss-sum/backend/blogger"
+	"github.com/rjxby/rss-sum/backend/config"
+	"github.com/rjxby/rss-sum/backend/export"
+	"github.com/rjxby/rss-sum/backend/extractor"
+	"github.com/rjxby/rss-sum/backend/store"
+)
+
+// runAllCommand runs the server and the worker in one process, e.g. `rss-sum`
+func runAllCommand(cfg *config.Config, dataStore storage, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+
+	// the worker is shared, so the server can hand submitted links over to it
+	c := newComponents(cfg, dataStore)
+
+	runUntilSignal(c,
+		func(ctx context.Context, wg *sync.WaitGroup) { runServer(ctx, wg, cfg, dataStore, c, c.rssWorker) },
+		func(ctx context.Context, wg *sync.WaitGroup) { runWorker(ctx, wg, c.rssWorker) },
+		func(ctx context.Context, wg *sync.WaitGroup) { runJanitor(ctx, wg, c.janitor) },
+		func(ctx context.Context, wg *sync.WaitGroup) { runArchiver(ctx, wg, c.archiver) },
+	)
+	return nil
+}
+
+// runServeCommand runs the server only, e.g. `rss-sum serve`. Submitted links are queued in the database
+// for the worker processes, a manual fetch runs in this process and queues its items the same way
+func runServeCommand(cfg *config.Config, dataStore storage, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+
+	// the worker is not run, it only queues jobs and serves manual fetches, the janitor only reports
+	// what the workers would prune and backups are only written on request
+	c := newComponents(cfg, dataStore)
+
+	runUntilSignal(c,
+		func(ctx context.Context, wg *sync.WaitGroup) { runServer(ctx, wg, cfg, dataStore, c, nil) },
+	)
+	return nil
+}
+
+// runWorkerCommand runs the feed scheduler and the job workers only, e.g. `rss-sum worker`
+func runWorkerCommand(cfg *config.Config, dataStore storage, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+
+	c := newComponents(cfg, dataStore)
+
+	runUntilSignal(c,
+		func(ctx context.Context, wg *sync.WaitGroup) { runWorker(ctx, wg, c.rssWorker) },
+		func(ctx context.Context, wg *sync.WaitGroup) { runJanitor(ctx, wg, c.janitor) },
+		func(ctx context.Context, wg *sync.WaitGroup) { runArchiver(ctx, wg, c.archiver) },
+	)
+	return nil
+}
+
+// runMigrateCommand applies or reverts the schema migrations and exits,
+// e.g. `rss-sum migrate up --dry-run`, `rss-sum migrate down --steps 2` or `rss-sum migrate status`
+func runMigrateCommand(_ *config.Config, dataStore storage, args []string) error {
+	direction := "up"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		direction, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the migrations instead of applying them")
+	steps := flags.Int("steps", 1, "number of migrations to revert with down")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	database, ok := dataStore.(*store.Database)
+	if !ok {
+		return fmt.Errorf("the in-memory store has no schema to migrate")
+	}
+
+	var migrations []store.Migration
+	var err error
+	switch direction {
+	case "status":
+		status, err := database.SchemaStatus()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("schema version %d, latest %d\n", status.Version, status.Latest)
+		for _, migration := range status.Pending {
+			fmt.Printf("pending %04d_%s\n", migration.Version, migration.Name)
+		}
+		return nil
+	case "up":
+		migrations, err = database.MigrateUp(*dryRun)
+	case "down":
+		if *steps < 1 {
+			return fmt.Errorf("steps should be positive")
+		}
+		migrations, err = database.MigrateDown(*steps, *dryRun)
+	default:
+		return fmt.Errorf("usage: rss-sum migrate [up|down|status] [--steps N] [--dry-run]")
+	}
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		for _, migration := range migrations {
+			sql := migration.Up
+			if direction == "down" {
+				sql = migration.Down
+			}
+			fmt.Printf("-- %s %04d_%s\n%s\n", direction, migration.Version, migration.Name, sql)
+		}
+		return nil
+	}
+
+	slog.Info("database migrated", "direction", direction, "migrations", len(migrations))
+	return nil
+}
+
+// runFetchCommand fetches feeds once, summarizes the queued items and exits, e.g. `rss-sum fetch --once --feed https://example.com/feed`
+func runFetchCommand(cfg *config.Config, dataStore storage, args []string) error {
+	flags := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	once := flags.Bool("once", false, "fetch once and exit, required since the worker command fetches on schedule")
+	feedID := flags.String("feed", "", "fetch a single feed, given by its URL or ID")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*once {
+		return fmt.Errorf("fetch requires --once, use the worker command to fetch on schedule")
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	rssWorker := newWorker(cfg, dataStore, assistant.New(&cfg.Assistant))
+
+	fetchErr := rssWorker.Fetch(*feedID)
+
+	// summarize the queued items before exiting, including the ones left by failed feeds
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	return errors.Join(fetchErr, rssWorker.DrainJobs(ctx))
+}
+
+// runSummarizeCommand prints the summary of a web page as it is generated, without storing it,
+// e.g. `rss-sum summarize https://example.com/article`
+func runSummarizeCommand(cfg *config.Config, _ storage, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: rss-sum summarize <url>")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	article, err := extractor.New().Extract(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %v", args[0], err)
+	}
+
+	fmt.Printf("%s\n%s\n\n", article.Title, article.URL)
+
+	err = assistant.New(&cfg.Assistant).SummarizeTextStream(ctx, article.Text, func(token string) error {
+		_, err := fmt.Print(token)
+		return err
+	})
+	fmt.Println()
+	if err != nil {
+		return fmt.Errorf("failed to summarize %s: %v", article.URL, err)
+	}
+
+	return nil
+}
+
+// runBackupCommand writes a backup archive and exits, e.g. `rss-sum backup --dir /mnt/backups`.
+// Archives written to the configured directory are rotated like scheduled ones
+func runBackupCommand(cfg *config.Config, dataStore storage, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dir := flags.String("dir", cfg.Backup.Dir, "directory of the archive")
+	compress := flags.Bool("compress", cfg.Backup.Compress, "gzip the archive")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	archive, err := newArchiver(cfg, dataStore).Backup(ctx, *dir, *compress)
+	if err != nil {
+		return err
+	}
+	if *dir == cfg.Backup.Dir {
+		if err := backup.Rotate(*dir, cfg.Backup.Keep); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(archive.Path)
+	return nil
+}
+
+// runRestoreCommand replaces the SQLite database by the one of a backup archive and exits, the service has to be
+// stopped meanwhile, e.g. `rss-sum restore data/backups/rss-sum-20240101T000000Z.tar.gz --force --config config.yaml`
+func runRestoreCommand(cfg *config.Config, _ storage, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: rss-sum restore <archive> [--force] [--config path]")
+	}
+	archivePath, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	force := flags.Bool("force", false, "replace the existing database and config file")
+	configPath := flags.String("config", "", "write the config file of the archive to this path")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	if dialect := cfg.Database.Dialect(); dialect != store.DialectSQLite {
+		return fmt.Errorf("%w, restore %s with its own tools", store.ErrSnapshotUnsupported, dialect)
+	}
+
+	manifest, err := backup.Restore(archivePath, cfg.Database.Path(), *configPath, *force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("restored backup of version %s created at %s\n", manifest.Version, manifest.CreatedAt.Format(time.RFC3339))
+	return nil
+}
+
+// runExportCommand writes the posts to stdout or a file and exits,
+// e.g. `rss-sum export --format csv --since 2024-01-01T00:00:00Z --out posts.csv`
+func runExportCommand(_ *config.Config, dataStore storage, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "", "ndjson, json or csv, by the extension of --out by default")
+	partitionKey := flags.String("partition", "", "export the posts of a single feed, given by its ID")
+	since := flags.String("since", "", "export the posts created at or after this RFC 3339 time")
+	out := flags.String("out", "-", "file to write, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	if *format == "" {
+		*format = export.FormatOf(*out)
+	}
+	filter := export.Filter{PartitionKey: *partitionKey}
+	if *since != "" {
+		var err error
+		if filter.Since, err = time.Parse(time.RFC3339, *since); err != nil {
+			return fmt.Errorf("invalid since: %v", err)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	w := os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", *out, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	written, err := (&export.Exporter{Blogger: blogger.New(dataStore)}).Export(ctx, w, *format, filter)
+	if err != nil {
+		return err
+	}
+	if w != os.Stdout {
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %v", *out, err)
+		}
+	}
+
+	slog.Info("posts exported", "format", *format, "count", written)
+	return nil
+}
+
+// runImportCommand upserts the posts of an export and exits, e.g. `rss-sum import posts.ndjson` or
+// `rss-sum import --format csv - < posts.csv`
+func runImportCommand(_ *config.Config, dataStore storage, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "ndjson, json or csv, by the extension of the file by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: rss-sum import [--format ndjson|json|csv] <file|->")
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		*format = export.FormatOf(path)
+	}
+
+	r := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", path, err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	result, err := (&export.Exporter{Blogger: blogger.New(dataStore)}).Import(ctx, r, *format)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("read %d posts, created %d, updated %d\n", result.Read, result.Created, result.Updated)
+	return nil
+}
+
+const userUsage = `usage: rss-sum user <command>
+  add <name> [--role reader|admin]  add a user with the password read from stdin
+  list                              list the users
+  remove <name>                     remove a user with their sessions and API tokens
+  passwd <name>                     set the password read from stdin
+  role <name> <reader|admin>        set the role of a user
+  token <name> [--name cli]         create an API token and print it`
+
+// runUserCommand manages the user accounts and exits, e.g. `echo "$PASSWORD" | rss-sum user add alice --role admin`
+func runUserCommand(cfg *config.Config, dataStore storage, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", userUsage)
+	}
+	action, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("user "+action, flag.ContinueOnError)
+	role := flags.String("role", store.RoleReader, "role of the added user, reader or admin")
+	tokenName := flags.String("name", "cli", "name of the API token")
+	// the name comes first, flags follow it
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional, args = append(positional, args[0]), args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	positional = append(positional, flags.Args()...)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	proc := blogger.New(dataStore)
+	authenticator := &auth.Authenticator{Blogger: proc, Settings: cfg.Auth}
+
+	switch {
+	case action == "list" && len(positional) == 0:
+		users, err := authenticator.Users(ctx)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			fmt.Printf("%d\t%s\t%s\t%s\n", user.ID, user.Name, user.Role, user.CreatedAt.Format(time.RFC3339))
+		}
+		return nil
+	case action == "add" && len(positional) == 1:
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		user, err := authenticator.AddUser(ctx, positional[0], password, *role)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("added %s %s\n", user.Role, user.Name)
+		return nil
+	case action == "passwd" && len(positional) == 1:
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		return authenticator.SetPassword(ctx, positional[0], password)
+	case action == "role" && len(positional) == 2:
+		return authenticator.SetRole(ctx, positional[0], positional[1])
+	case action == "remove" && len(positional) == 1:
+		user, err := proc.GetUserByName(ctx, positional[0])
+		if err != nil {
+			return err
+		}
+		return authenticator.RemoveUser(ctx, user.ID)
+	case action == "token" && len(positional) == 1:
+		user, err := proc.GetUserByName(ctx, positional[0])
+		if err != nil {
+			return err
+		}
+		token, _, err := authenticator.CreateToken(ctx, user.ID, *tokenName)
+		if err != nil {
+			return err
+		}
+		// the token is only shown once, its hash is stored
+		fmt.Println(token)
+		return nil
+	default:
+		return fmt.Errorf("%s", userUsage)
+	}
+}
+
+// readPassword reads the first line of stdin, so passwords stay out of the shell history and process list
+func readPassword() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
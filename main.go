@@ -2,140 +2,347 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
-	"strconv"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/rjxby/rss-sum/backend/assistant"
+	"github.com/rjxby/rss-sum/backend/auth"
+	"github.com/rjxby/rss-sum/backend/backup"
 	"github.com/rjxby/rss-sum/backend/blogger"
+	"github.com/rjxby/rss-sum/backend/config"
+	"github.com/rjxby/rss-sum/backend/export"
+	"github.com/rjxby/rss-sum/backend/extractor"
 	"github.com/rjxby/rss-sum/backend/hasher"
+	"github.com/rjxby/rss-sum/backend/logging"
+	"github.com/rjxby/rss-sum/backend/metrics"
+	"github.com/rjxby/rss-sum/backend/retention"
 	"github.com/rjxby/rss-sum/backend/rss/worker"
 	"github.com/rjxby/rss-sum/backend/server"
 	"github.com/rjxby/rss-sum/backend/store"
+	"github.com/rjxby/rss-sum/backend/tracing"
 )
 
 var revision = "latest"
 
-type settings struct {
-	RunMigration bool
+const usage = `Usage: rss-sum [command]
+
+Commands:
+  (none)                       run the HTTP server and the worker in one process
+  serve                        run the HTTP server only, submitted links are queued for the workers
+  worker                       run the feed scheduler, the summarization jobs, the retention janitor and scheduled backups only
+  migrate [up|down|status]     apply, revert (--steps N) or list the schema migrations and exit, --dry-run prints them
+  fetch --once [--feed URL]    fetch feeds once, summarize the queued items and exit
+  summarize <url>              summarize a web page to stdout
+  backup [--dir path]          write a backup archive of the database, feeds and config file and exit
+  restore <archive> [--force]  replace the SQLite database, and the config file with --config path, by a backup
+  export [--format ndjson]     write the posts as ndjson, json or csv to stdout, or to --out path, and exit
+  import <file|->              upsert the posts of an export, keeping their IDs, and exit
+  user add|list|remove|passwd|role|token
+                               manage the user accounts and API tokens, passwords are read from stdin
+  config check [--file path]   validate the configuration and exit
+`
+
+// storage is implemented by every storage backend
+type storage interface {
+	blogger.Engine
+	metrics.StoreStats
+	backup.Snapshotter
+	Migrate() error
+}
+
+// command runs with the loaded configuration and the arguments following its name,
+// dataStore is nil unless the command uses the store
+type command struct {
+	run       func(cfg *config.Config, dataStore storage, args []string) error
+	usesStore bool
+}
+
+var commands = map[string]command{
+	"":          {run: runAllCommand, usesStore: true},
+	"serve":     {run: runServeCommand, usesStore: true},
+	"worker":    {run: runWorkerCommand, usesStore: true},
+	"migrate":   {run: runMigrateCommand, usesStore: true},
+	"fetch":     {run: runFetchCommand, usesStore: true},
+	"summarize": {run: runSummarizeCommand},
+	"backup":    {run: runBackupCommand, usesStore: true},
+	"restore":   {run: runRestoreCommand},
+	"export":    {run: runExportCommand, usesStore: true},
+	"import":    {run: runImportCommand, usesStore: true},
+	"user":      {run: runUserCommand, usesStore: true},
 }
 
 func main() {
-	log.Printf("rss-sum %s\n", revision)
+	name, args := "", os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	switch name {
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	case "config":
+		// checking the configuration must not depend on it being valid
+		if err := runConfigCommand(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
+	}
 
-	settings, err := parseSettings()
+	configPath := config.Path()
+	cfg, err := config.Load(configPath)
 	if err != nil {
-		log.Fatalf("[ERROR] failed to parse settings: %v", err)
+		fatal("invalid configuration", err)
 	}
 
-	if settings.RunMigration {
-		if err := runDatabaseMigration(); err != nil {
-			log.Fatalf("[ERROR] failed to run database migration: %v", err)
+	// logging is configured first, so everything below is structured
+	logging.Setup(&cfg.Log)
+
+	slog.Info("rss-sum", "revision", revision, "command", name, "config", configPath)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), &cfg.Tracing, revision)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+	// flush the spans which are still batched, fatal exits skip deferred calls
+	stopTracing := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("failed to shut down tracing", "error", err)
 		}
 	}
+	defer stopTracing()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// a single connection pool is shared by the server and the worker
+	var dataStore storage
+	if cmd.usesStore {
+		if dataStore, err = newStore(&cfg.Database); err != nil {
+			stopTracing()
+			fatal("failed to create data store", err)
+		}
 
-	wg := sync.WaitGroup{}
+		// the migrate command applies the migrations itself
+		if cfg.RunMigration && name != "migrate" {
+			if err := dataStore.Migrate(); err != nil {
+				stopTracing()
+				fatal("failed to run database migration", err)
+			}
+		}
+	}
 
-	wg.Add(1)
-	go runServer(ctx, &wg)
+	if err := cmd.run(cfg, dataStore, args); err != nil {
+		stopTracing()
+		fatal("command failed", err)
+	}
+}
 
-	wg.Add(1)
-	go runWorker(ctx, &wg)
+// fatal logs the error and exits, slog has no fatal level
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
 
-	// listen for C-c
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
+// runConfigCommand validates the configuration without starting anything, e.g. `rss-sum config check --file config.yaml`
+func runConfigCommand(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("usage: rss-sum config check [--file path]")
+	}
 
-	// tell the goroutines to stop
-	cancel()
+	flags := flag.NewFlagSet("config check", flag.ContinueOnError)
+	path := flags.String("file", config.Path(), "configuration file, CONFIG_FILE by default")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
 
-	// and wait for them both to reply back
-	wg.Wait()
+	if _, err := config.Load(*path); err != nil {
+		return fmt.Errorf("configuration is invalid:\n%v", err)
+	}
+
+	fmt.Println("configuration is valid")
+	return nil
 }
 
-func parseSettings() (*settings, error) {
-	settings := settings{}
+// components are shared by the server and the background services of a process, SIGHUP reloads their settings
+type components struct {
+	assistantProc *assistant.AssistantProc
+	rssWorker     *worker.Worker
+	janitor       *retention.Janitor
+	archiver      *backup.Archiver
+}
+
+func newComponents(cfg *config.Config, dataStore storage) *components {
+	// the assistant is shared, so reloaded prompts and profiles apply to the server and the worker alike
+	assistantProc := assistant.New(&cfg.Assistant)
 
-	runMigrationStr := os.Getenv("RUN_MIGRATION")
-	if runMigrationStr == "" {
-		runMigrationStr = "false"
+	return &components{
+		assistantProc: assistantProc,
+		rssWorker:     newWorker(cfg, dataStore, assistantProc),
+		janitor:       newJanitor(cfg, dataStore),
+		archiver:      newArchiver(cfg, dataStore),
 	}
+}
 
-	runMigration, err := strconv.ParseBool(runMigrationStr)
+// reloadConfig applies the feeds, intervals, prompts, profiles, retention rules and backup settings of the changed
+// configuration, an invalid configuration is reported and the current one is kept
+func reloadConfig(path string, c *components) {
+	slog.Info("reloading configuration", "config", path)
+
+	cfg, err := config.Load(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse RUN_MIGRATION environment variable: %v", err)
+		slog.Error("failed to reload configuration, keeping the current one", "error", err)
+		return
 	}
-	settings.RunMigration = runMigration
 
-	return &settings, nil
+	c.rssWorker.Reload(cfg.Worker)
+	c.janitor.Reload(cfg.Retention, cfg.Worker.RSSFeedLimit)
+	c.archiver.Reload(cfg.Backup)
+	c.assistantProc.UpdatePrompts(cfg.Assistant.Prompts)
+	c.assistantProc.UpdateProfiles(cfg.Assistant.Profiles)
+
+	slog.Info("configuration reloaded", "feeds", len(cfg.Worker.RSSFeedsURLs))
 }
 
-func runDatabaseMigration() error {
-	database, err := store.NewDatabase()
-	if err != nil {
-		return fmt.Errorf("failed to open database: %v", err)
+func newStore(settings *store.Settings) (storage, error) {
+	if settings.Dialect() == store.DialectMemory {
+		return store.NewMemory(), nil
 	}
+	return store.NewDatabase(settings)
+}
+
+// runServer serves the web UI and the API, scheduler is nil when the worker runs in another process
+func runServer(ctx context.Context, wg *sync.WaitGroup, cfg *config.Config, dataStore storage, c *components, scheduler server.Scheduler) {
+	defer wg.Done()
+
+	metrics.RegisterStore(dataStore)
 
-	if err := database.Migrate(); err != nil {
-		return fmt.Errorf("failed to migrate database: %v", err)
+	srv := &server.Server{
+		Blogger:   blogger.New(dataStore),
+		Assistant: c.assistantProc,
+		Extractor: extractor.New(),
+		Submitter: c.rssWorker,
+		Fetcher:   c.rssWorker,
+		Scheduler: scheduler,
+		Pruner:    c.janitor,
+		Backuper:  c.archiver,
+		Exporter:  &export.Exporter{Blogger: blogger.New(dataStore)},
+		Version:   revision,
+	}
+	if authenticator := newAuthenticator(ctx, cfg, dataStore); authenticator != nil {
+		srv.Authenticator = authenticator
 	}
 
-	return nil
+	if err := srv.Run(ctx); err != nil {
+		fatal("failed to run server", err)
+	}
 }
 
-func runServer(ctx context.Context, wg *sync.WaitGroup) {
-	defer wg.Done()
+// newAuthenticator returns nil when authentication is disabled, the server is open to anyone then
+func newAuthenticator(ctx context.Context, cfg *config.Config, dataStore storage) *auth.Authenticator {
+	if !cfg.Auth.Enabled {
+		slog.Warn("authentication is disabled, anyone reaching the server can use it")
+		return nil
+	}
 
-	dataStore, err := store.NewDatabase()
-	if err != nil {
-		log.Fatalf("[ERROR] failed to create data store: %v", err)
+	authenticator := &auth.Authenticator{Blogger: blogger.New(dataStore), Settings: cfg.Auth}
+	if users, err := authenticator.Users(ctx); err == nil && len(users) == 0 {
+		slog.Warn("authentication is enabled without users, add one with `rss-sum user add <name> --role admin`")
+	}
+	return authenticator
+}
+
+func newWorker(cfg *config.Config, dataStore storage, assistantProc *assistant.AssistantProc) *worker.Worker {
+	return &worker.Worker{
+		Settings:  cfg.Worker,
+		Assistent: assistantProc,
+		Blogger:   blogger.New(dataStore),
+		Hasher:    hasher.New(),
+		Extractor: extractor.New(),
+		Version:   revision,
 	}
+}
 
-	srv := &server.Server{
-		Blogger: blogger.New(dataStore),
-		Version: revision,
+// newJanitor never prunes the posts the worker compares fetched items against
+func newJanitor(cfg *config.Config, dataStore storage) *retention.Janitor {
+	return &retention.Janitor{
+		Settings:        cfg.Retention,
+		Blogger:         blogger.New(dataStore),
+		MinPostsPerFeed: cfg.Worker.RSSFeedLimit,
 	}
+}
 
-	if err := srv.Run(ctx); err != nil {
-		log.Fatalf("[ERROR] failed to run server: %v", err)
+// newArchiver copies the configuration file into the archives, a configuration of environment variables only
+// is not backed up
+func newArchiver(cfg *config.Config, dataStore storage) *backup.Archiver {
+	return &backup.Archiver{
+		Store:      dataStore,
+		Blogger:    blogger.New(dataStore),
+		ConfigFile: config.Path(),
+		Settings:   cfg.Backup,
+		Version:    revision,
 	}
 }
 
-func runWorker(ctx context.Context, wg *sync.WaitGroup) {
+func runJanitor(ctx context.Context, wg *sync.WaitGroup, janitor *retention.Janitor) {
 	defer wg.Done()
 
-	workerSettings, err := worker.ParseSettings()
-	if err != nil {
-		log.Fatalf("[ERROR] failed to parse worker settings: %v", err)
+	if err := janitor.Run(ctx); err != nil {
+		fatal("failed to run retention janitor", err)
 	}
+}
 
-	assistantSettings, err := assistant.ParseSettings()
-	if err != nil {
-		log.Fatalf("[ERROR] failed to parse assistant settings: %v", err)
+func runArchiver(ctx context.Context, wg *sync.WaitGroup, archiver *backup.Archiver) {
+	defer wg.Done()
+
+	if err := archiver.Run(ctx); err != nil {
+		fatal("failed to run scheduled backups", err)
 	}
+}
 
-	dataStore, err := store.NewDatabase()
-	if err != nil {
-		log.Fatalf("[ERROR] failed to create data store: %v", err)
+func runWorker(ctx context.Context, wg *sync.WaitGroup, rssWorker *worker.Worker) {
+	defer wg.Done()
+
+	if err := rssWorker.Run(ctx); err != nil {
+		fatal("failed to run RSS worker", err)
 	}
+}
 
-	worker := worker.Worker{
-		Settings:  *workerSettings,
-		Assistent: assistant.New(assistantSettings),
-		Blogger:   blogger.New(dataStore),
-		Hasher:    hasher.New(),
-		Version:   revision,
+// runUntilSignal runs the services until C-c or SIGTERM, SIGHUP reloads the configuration
+func runUntilSignal(c *components, services ...func(ctx context.Context, wg *sync.WaitGroup)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	for _, service := range services {
+		wg.Add(1)
+		go service(ctx, &wg)
 	}
 
-	if err := worker.Run(ctx); err != nil {
-		log.Fatalf("[ERROR] failed to run RSS worker: %v", err)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		reloadConfig(config.Path(), c)
 	}
+
+	// tell the goroutines to stop
+	cancel()
+
+	// and wait for them all to reply back
+	wg.Wait()
 }